@@ -0,0 +1,185 @@
+// Command cocaine-call invokes a method of a cocaine service
+// and prints the streamed response, one chunk per line:
+//
+//	cocaine-call [flags] service method [args...]
+//
+// Arguments are JSON values packed to msgpack, e.g.
+//
+//	cocaine-call storage read '"collection"' '"key"'
+//	cocaine-call -locator host:10053 unicorn get '"/path"'
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+	"github.com/cocaine/cocaine-framework-go/version"
+)
+
+var (
+	locators = flag.String("locator", "", "comma separated endpoints of locators")
+	timeout  = flag.Duration("timeout", 10*time.Second, "timeout of the call, 0 means no timeout")
+	verbose  = flag.Bool("v", false, "prefix chunks with their names")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] service method [args...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Arguments are JSON values. Version %s\n", version.Version)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := call(flag.Arg(0), flag.Arg(1), flag.Args()[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func call(name, method string, rawArgs []string) error {
+	args := make([]interface{}, 0, len(rawArgs))
+	for i, raw := range rawArgs {
+		arg, err := parseArg(raw)
+		if err != nil {
+			return fmt.Errorf("argument %d is not JSON: %v", i+1, err)
+		}
+		args = append(args, arg)
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var endpoints []string
+	if *locators != "" {
+		endpoints = strings.Split(*locators, ",")
+	}
+
+	service, err := cocaine.NewService(ctx, name, endpoints)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+
+	methodNum, err := service.API.MethodByName(method)
+	if err != nil {
+		return fmt.Errorf("service %s has %v, its methods are %s",
+			name, err, strings.Join(service.API.Methods(), ", "))
+	}
+	upstream := service.API[methodNum].Upstream
+
+	channel, err := service.Call(ctx, method, args...)
+	if err != nil {
+		return err
+	}
+
+	for !channel.Closed() {
+		result, err := channel.Get(ctx)
+		if err != nil {
+			return err
+		}
+
+		chunkNum, payload, err := result.Result()
+		if err != nil {
+			return err
+		}
+
+		line, err := formatPayload(payload)
+		if err != nil {
+			return err
+		}
+
+		if *verbose {
+			chunkName := fmt.Sprintf("%d", chunkNum)
+			if upstream != nil {
+				if item, ok := (*upstream)[chunkNum]; ok {
+					chunkName = item.Name
+				}
+			}
+			fmt.Printf("%s\t%s\n", chunkName, line)
+		} else {
+			fmt.Printf("%s\n", line)
+		}
+	}
+
+	return nil
+}
+
+// parseArg decodes a JSON value keeping integers integers,
+// so services can unpack them to integer types
+func parseArg(raw string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return fromJSON(v), nil
+}
+
+func fromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = fromJSON(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = fromJSON(value)
+		}
+	}
+	return v
+}
+
+// formatPayload prints a single value of a chunk as is
+// and a tuple of several values as an array
+func formatPayload(payload []interface{}) ([]byte, error) {
+	var v interface{} = payload
+	if len(payload) == 1 {
+		v = payload[0]
+	}
+	return json.Marshal(toJSON(v))
+}
+
+// toJSON converts values unpacked from msgpack,
+// which JSON can't marshal
+func toJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(toJSON(k))] = toJSON(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = toJSON(value)
+		}
+		return v
+	case []byte:
+		return string(v)
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+// Command cocaine-new creates a skeleton of a cocaine application
+// written in Go:
+//
+//	cocaine-new [flags] appname
+//
+// The directory appname gets a worker with a typed handler, its tests
+// run against cocainetest, a manifest and a Dockerfile. The application
+// runs standalone if it's started without cocaine-runtime.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/template"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+type project struct {
+	// Name of the application
+	Name string
+	// Module is the path of the Go module
+	Module string
+}
+
+func main() {
+	module := flag.String("module", "", "path of the Go module, the name of the application by default")
+	dir := flag.String("dir", "", "directory to create, the name of the application by default")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] appname\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	p := project{Name: flag.Arg(0), Module: *module}
+	if p.Module == "" {
+		p.Module = p.Name
+	}
+	if *dir == "" {
+		*dir = p.Name
+	}
+
+	if err := generate(*dir, p); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s in %s. Next steps:\n\n", p.Name, *dir)
+	fmt.Printf("\tcd %s && go mod tidy && go test ./...\n", *dir)
+	fmt.Printf("\techo '{\"id\": 1, \"event\": \"ping\", \"chunks\": [{\"Name\": \"cocaine\"}], \"json\": true}' | go run .\n")
+}
+
+// generate writes files of the project to dir, which must not exist
+func generate(dir string, p project) error {
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid application name %q: it must start with a letter"+
+			" and contain only letters, digits, '-' and '_'", p.Name)
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range projectFileNames() {
+		if err := writeTemplate(filepath.Join(dir, name), projectFiles[name], p); err != nil {
+			return fmt.Errorf("unable to write %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func writeTemplate(path, text string, p project) error {
+	tmpl, err := template.New(filepath.Base(path)).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, p)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "cocaine-new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, "app")
+	if err := generate(dir, project{Name: "echo-app", Module: "example.com/echo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range projectFileNames() {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		if strings.HasSuffix(name, ".go") {
+			formatted, err := format.Source(data)
+			assert.NoError(t, err, name)
+			assert.Equal(t, string(formatted), string(data), "%s is not formatted", name)
+		}
+	}
+
+	goMod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	assert.Contains(t, string(goMod), "module example.com/echo\n")
+	manifest, _ := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	assert.Contains(t, string(manifest), `"slave": "echo-app"`)
+
+	assert.Error(t, generate(dir, project{Name: "echo-app", Module: "echo-app"}), "the directory exists")
+}
+
+func TestGenerateInvalidName(t *testing.T) {
+	for _, name := range []string{"", "1app", "my app", "../app"} {
+		assert.Error(t, generate(filepath.Join(os.TempDir(), "never-created"), project{Name: name}), name)
+	}
+}
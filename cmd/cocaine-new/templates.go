@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+)
+
+// projectFiles are templates of files of a project by their names
+var projectFiles = map[string]string{
+	"go.mod":           goModTemplate,
+	"main.go":          mainTemplate,
+	"handlers.go":      handlersTemplate,
+	"handlers_test.go": handlersTestTemplate,
+	"manifest.json":    manifestTemplate,
+	"Dockerfile":       dockerfileTemplate,
+}
+
+func projectFileNames() []string {
+	names := make([]string, 0, len(projectFiles))
+	for name := range projectFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const goModTemplate = `module {{.Module}}
+
+go 1.21
+`
+
+const mainTemplate = `package main
+
+import (
+	"log"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+func main() {
+	w, err := cocaine.NewWorker()
+	if err == cocaine.ErrShowVersion {
+		return
+	}
+	if err == cocaine.ErrNoCocaineEndpoint {
+		// started without cocaine-runtime, see Worker.RunStandalone
+		log.Printf("{{.Name}}: no cocaine endpoint is given, running standalone")
+		w, err = cocaine.NewStandaloneWorker()
+	}
+	if err != nil {
+		log.Fatalf("{{.Name}}: unable to create a worker: %v", err)
+	}
+
+	w.OnTyped("ping", Ping)
+
+	if err := w.Run(nil); err != nil {
+		log.Fatalf("{{.Name}}: %v", err)
+	}
+}
+`
+
+const handlersTemplate = `package main
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// PingRequest is the request of the ping event
+type PingRequest struct {
+	Name string
+}
+
+// PingResponse is the reply to PingRequest
+type PingResponse struct {
+	Greeting string
+}
+
+// Ping greets the caller
+func Ping(ctx context.Context, req PingRequest) (PingResponse, error) {
+	if req.Name == "" {
+		return PingResponse{}, &cocaine.ServiceError{Code: cocaine.ErrorBadRequest, Message: "name is required"}
+	}
+
+	return PingResponse{Greeting: fmt.Sprintf("Hello, %s!", req.Name)}, nil
+}
+`
+
+const handlersTestTemplate = `package main
+
+import (
+	"testing"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+	"github.com/cocaine/cocaine-framework-go/cocaine12/cocainetest"
+)
+
+func callPing(t *testing.T, req PingRequest) *cocainetest.Response {
+	handler, err := cocaine.TypedHandler(Ping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload []byte
+	if err := codec.NewEncoderBytes(&payload, new(codec.MsgpackHandle)).Encode(req); err != nil {
+		t.Fatal(err)
+	}
+
+	request := cocainetest.NewRequest()
+	request.Write(payload)
+	response := cocainetest.NewResponse()
+	handler(context.Background(), request, response)
+	return response
+}
+
+func TestPing(t *testing.T) {
+	response := callPing(t, PingRequest{Name: "cocaine"})
+	if response.Err != nil {
+		t.Fatalf("ping has failed: %s", response.Err.Msg)
+	}
+
+	var reply PingResponse
+	if err := codec.NewDecoderBytes(response.Bytes(), new(codec.MsgpackHandle)).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Greeting != "Hello, cocaine!" {
+		t.Errorf("unexpected greeting %q", reply.Greeting)
+	}
+}
+
+func TestPingWithoutName(t *testing.T) {
+	response := callPing(t, PingRequest{})
+	if response.Err == nil || response.Err.Code != cocaine.ErrorBadRequest {
+		t.Errorf("ping without a name must be a bad request, got %+v", response.Err)
+	}
+}
+`
+
+const manifestTemplate = `{
+    "slave": "{{.Name}}"
+}
+`
+
+const dockerfileTemplate = `FROM golang:1.21 AS build
+WORKDIR /src
+COPY . .
+RUN go mod tidy && CGO_ENABLED=0 go build -o /out/{{.Name}} .
+
+FROM debian:stable-slim
+WORKDIR /app
+COPY --from=build /out/{{.Name}} /app/{{.Name}}
+COPY manifest.json /app/manifest.json
+CMD ["/app/{{.Name}}"]
+`
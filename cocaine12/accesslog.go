@@ -0,0 +1,288 @@
+package cocaine12
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// AccessLogFormat defines how access log entries are rendered
+type AccessLogFormat int
+
+const (
+	// AccessLogLogfmt renders entries as key=value pairs
+	AccessLogLogfmt AccessLogFormat = iota
+	// AccessLogJSON renders entries as JSON objects
+	AccessLogJSON
+)
+
+// Outcomes of requests reported by AccessLog
+const (
+	AccessOutcomeOK    = "ok"
+	AccessOutcomeError = "error"
+	AccessOutcomePanic = "panic"
+	// the handler has returned without closing the response
+	AccessOutcomeOpen = "open"
+)
+
+// AccessLog logs every request with its event, trace_id, duration,
+// bytes read and written and the outcome. See AccessLog.Interceptor
+type AccessLog struct {
+	format AccessLogFormat
+
+	mu  sync.Mutex
+	out io.Writer
+
+	logger Logger
+	clock  Clock
+}
+
+// NewAccessLog creates AccessLog writing an entry per line to out
+func NewAccessLog(out io.Writer, format AccessLogFormat) *AccessLog {
+	return &AccessLog{
+		format: format,
+		out:    out,
+		clock:  realClock{},
+	}
+}
+
+// NewAccessLogWithLogger creates AccessLog writing entries
+// through the logger with InfoLevel
+func NewAccessLogWithLogger(logger Logger, format AccessLogFormat) *AccessLog {
+	return &AccessLog{
+		format: format,
+		logger: logger,
+		clock:  realClock{},
+	}
+}
+
+// Interceptor logs a request once its handler returns. See Worker.Use
+func (a *AccessLog) Interceptor() Interceptor {
+	return func(event string, handler EventHandler) EventHandler {
+		return func(ctx context.Context, req Request, res Response) {
+			start := a.clock.Now()
+			creq := &countingRequest{Request: req}
+			cres := &countingResponse{Response: res}
+
+			defer func() {
+				outcome := cres.outcome()
+				recoverInfo := recover()
+				if recoverInfo != nil {
+					outcome = AccessOutcomePanic
+				}
+
+				a.log(ctx, event, a.clock.Now().Sub(start), creq, cres, outcome)
+
+				if recoverInfo != nil {
+					// let the worker reply with ErrorPanicInHandler
+					panic(recoverInfo)
+				}
+			}()
+
+			handler(ctx, creq, cres)
+		}
+	}
+}
+
+type accessLogField struct {
+	key   string
+	value interface{}
+}
+
+func (a *AccessLog) log(ctx context.Context, event string, duration time.Duration,
+	req *countingRequest, res *countingResponse, outcome string) {
+	var traceID string
+	if traceInfo, ok := TraceInfoFromContext(ctx); ok {
+		traceID = fmt.Sprintf("%x", traceInfo.trace)
+	}
+
+	fields := []accessLogField{
+		{"event", event},
+		{"trace_id", traceID},
+		{"duration_ms", float64(duration) / float64(time.Millisecond)},
+		{"bytes_in", atomic.LoadInt64(&req.read)},
+		{"bytes_out", atomic.LoadInt64(&res.written)},
+		{"outcome", outcome},
+	}
+	if outcome == AccessOutcomeError {
+		fields = append(fields, accessLogField{"code", res.code})
+	}
+
+	var line string
+	switch a.format {
+	case AccessLogJSON:
+		line = formatJSON(fields)
+	default:
+		line = formatLogfmt(fields)
+	}
+
+	if a.logger != nil {
+		a.logger.Info(line)
+		return
+	}
+
+	a.mu.Lock()
+	io.WriteString(a.out, line+"\n")
+	a.mu.Unlock()
+}
+
+func formatLogfmt(fields []accessLogField) string {
+	var buf bytes.Buffer
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(field.key)
+		buf.WriteByte('=')
+
+		switch value := field.value.(type) {
+		case string:
+			if value == "" || strings.ContainsAny(value, " =\"\\") {
+				value = strconv.Quote(value)
+			}
+			buf.WriteString(value)
+		case float64:
+			buf.WriteString(strconv.FormatFloat(value, 'f', 3, 64))
+		default:
+			fmt.Fprint(&buf, value)
+		}
+	}
+	return buf.String()
+}
+
+func formatJSON(fields []accessLogField) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		if value, ok := field.value.(float64); ok {
+			buf.WriteString(strconv.FormatFloat(value, 'f', 3, 64))
+			continue
+		}
+		value, _ := json.Marshal(field.value)
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// countingRequest counts bytes read by a handler.
+// A handler may read the request from another goroutine.
+type countingRequest struct {
+	Request
+	read int64
+}
+
+func (r *countingRequest) Read(ctx context.Context) ([]byte, error) {
+	data, err := r.Request.Read(ctx)
+	atomic.AddInt64(&r.read, int64(len(data)))
+	return data, err
+}
+
+// countingResponse counts bytes written by a handler and how it's finished
+type countingResponse struct {
+	Response
+	written int64
+
+	mu     sync.Mutex
+	closed bool
+	failed bool
+	code   int
+}
+
+func (r *countingResponse) Write(data []byte) (int, error) {
+	n, err := r.Response.Write(data)
+	atomic.AddInt64(&r.written, int64(n))
+	return n, err
+}
+
+func (r *countingResponse) ZeroCopyWrite(data []byte) error {
+	err := r.Response.ZeroCopyWrite(data)
+	if err == nil {
+		atomic.AddInt64(&r.written, int64(len(data)))
+	}
+	return err
+}
+
+func (r *countingResponse) WriteWithMetadata(data []byte, md Metadata) error {
+	writer, ok := r.Response.(MetadataWriter)
+	if !ok {
+		_, err := r.Write(data)
+		return err
+	}
+
+	err := writer.WriteWithMetadata(data, md)
+	if err == nil {
+		atomic.AddInt64(&r.written, int64(len(data)))
+	}
+	return err
+}
+
+func (r *countingResponse) Close() error {
+	return r.CloseWithMetadata(nil)
+}
+
+func (r *countingResponse) CloseWithMetadata(md Metadata) error {
+	var err error
+	if writer, ok := r.Response.(MetadataWriter); ok {
+		err = writer.CloseWithMetadata(md)
+	} else {
+		err = r.Response.Close()
+	}
+
+	if err == nil {
+		r.mu.Lock()
+		r.closed = true
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *countingResponse) ErrorMsg(code int, message string) error {
+	return r.errorMsgWithHeaders(cworkererrorcategory, code, message, nil)
+}
+
+func (r *countingResponse) errorMsgWithHeaders(category, code int, message string, headers []Header) error {
+	var err error
+	if sender, ok := r.Response.(errorWithHeadersSender); ok {
+		err = sender.errorMsgWithHeaders(category, code, message, headers)
+	} else {
+		err = r.Response.ErrorMsg(code, message)
+	}
+
+	if err == nil {
+		r.mu.Lock()
+		r.failed = true
+		r.code = code
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *countingResponse) outcome() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.failed:
+		return AccessOutcomeError
+	case r.closed:
+		return AccessOutcomeOK
+	default:
+		return AccessOutcomeOpen
+	}
+}
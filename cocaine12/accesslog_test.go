@@ -0,0 +1,61 @@
+package cocaine12
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAccessLogLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	clock := NewManualClock(time.Now())
+	accessLog := NewAccessLog(&buf, AccessLogLogfmt)
+	accessLog.clock = clock
+
+	handler := accessLog.Interceptor()("echo", func(ctx context.Context, req Request, res Response) {
+		data, _ := req.Read(ctx)
+		clock.Advance(1500 * time.Microsecond)
+		res.Write(data)
+		res.Write(data)
+		res.Close()
+	})
+
+	ctx := AttachTraceInfo(context.Background(), NewTraceInfo(0xabc, 1, 0))
+	req := &testRequest{[]byte("ping")}
+	handler(ctx, req, new(testResponse))
+	assert.Equal(t,
+		"event=echo trace_id=abc duration_ms=1.500 bytes_in=4 bytes_out=8 outcome=ok\n",
+		buf.String())
+
+	buf.Reset()
+	handler = accessLog.Interceptor()("fail", func(ctx context.Context, req Request, res Response) {
+		res.ErrorMsg(ErrorBadRequest, "bad request")
+	})
+	handler(context.Background(), &testRequest{}, new(testResponse))
+	assert.Equal(t,
+		"event=fail trace_id=\"\" duration_ms=0.000 bytes_in=0 bytes_out=0 outcome=error code=400\n",
+		buf.String())
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := NewAccessLog(&buf, AccessLogJSON)
+
+	handler := accessLog.Interceptor()("panic", func(ctx context.Context, req Request, res Response) {
+		panic("oops")
+	})
+	assert.Panics(t, func() {
+		handler(context.Background(), &testRequest{}, new(testResponse))
+	})
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "panic", entry["event"])
+	assert.Equal(t, AccessOutcomePanic, entry["outcome"])
+	assert.Equal(t, float64(0), entry["bytes_out"])
+	assert.Contains(t, entry, "duration_ms")
+}
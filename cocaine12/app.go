@@ -0,0 +1,173 @@
+package cocaine12
+
+import (
+	"io"
+
+	"golang.org/x/net/context"
+)
+
+const enqueueMethod = "enqueue"
+
+// App is a client of a Cocaine application. It wraps the `enqueue`
+// protocol of applications, so events are invoked without
+// the plumbing of Service channels.
+type App struct {
+	service *Service
+}
+
+// NewApp connects to the application. The endpoints of the locator
+// are taken from defaults if they are empty.
+func NewApp(ctx context.Context, name string, endpoints []string) (*App, error) {
+	service, err := NewService(ctx, name, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	return &App{service: service}, nil
+}
+
+// Service returns the underlying Service, e.g. to set credentials
+func (a *App) Service() *Service {
+	return a.service
+}
+
+// Enqueue invokes the event of the application. The payload is sent
+// as the first chunk of the request unless it's nil. More chunks can be
+// sent with Stream.Write, then the request must be closed with CloseSend.
+func (a *App) Enqueue(ctx context.Context, event string, payload []byte) (Stream, error) {
+	ch, err := a.service.Call(ctx, enqueueMethod, event)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stream{ch: ch}
+	if payload != nil {
+		if err := s.Write(ctx, payload); err != nil {
+			s.Abort(err)
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Close closes the connection to the application
+func (a *App) Close() {
+	a.service.Close()
+}
+
+// Stream is a session of an event invoked by App.Enqueue
+type Stream interface {
+	// Write sends a chunk of the request
+	Write(ctx context.Context, data []byte) error
+	// CloseSend notifies the handler that the request is complete.
+	// The response still can be received after that.
+	CloseSend(ctx context.Context) error
+	// Recv returns the next chunk of the response.
+	// It returns io.EOF once the response is closed
+	// and *ErrRequest if the handler has replied with an error.
+	Recv(ctx context.Context) ([]byte, error)
+	// ReadAll closes the request and returns the whole response
+	ReadAll(ctx context.Context) ([]byte, error)
+	// Header returns metadata received with chunks of the response so far.
+	// See MetadataWriter
+	Header() Metadata
+	// Trailer returns metadata received with the close of the response
+	Trailer() Metadata
+}
+
+// Aborter is implemented by streams of App. Abort cancels the request
+// if the response is not needed anymore: the handler gets ErrorCancelled
+// and the stream doesn't hold the connection waiting to be read.
+type Aborter interface {
+	Abort(reason error)
+}
+
+type stream struct {
+	ch Channel
+	// the response is over with it
+	err error
+
+	header  Metadata
+	trailer Metadata
+}
+
+func (s *stream) Write(ctx context.Context, data []byte) error {
+	return s.ch.Call(ctx, "write", data)
+}
+
+func (s *stream) CloseSend(ctx context.Context) error {
+	return s.ch.Seal(ctx)
+}
+
+func (s *stream) Recv(ctx context.Context) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	res, err := s.ch.Get(ctx)
+	if err != nil {
+		if err == ErrStreamIsClosed {
+			s.err = io.EOF
+			return nil, s.err
+		}
+		return nil, err
+	}
+
+	if err := res.Err(); err != nil {
+		s.err = err
+		return nil, err
+	}
+
+	var md Metadata
+	if sr, ok := res.(*serviceRes); ok {
+		md = metadataFromHeaders(sr.headers)
+	}
+
+	if s.ch.Closed() {
+		s.trailer = md
+		s.err = io.EOF
+		return nil, s.err
+	}
+
+	if md != nil {
+		s.header = JoinMetadata(s.header, md)
+	}
+
+	var chunk []byte
+	if err := res.ExtractTuple(&chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+func (s *stream) Abort(reason error) {
+	if ch, ok := s.ch.(*channel); ok {
+		ch.abort(reason)
+	}
+}
+
+func (s *stream) Header() Metadata {
+	return s.header
+}
+
+func (s *stream) Trailer() Metadata {
+	return s.trailer
+}
+
+func (s *stream) ReadAll(ctx context.Context) ([]byte, error) {
+	if err := s.CloseSend(ctx); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	for {
+		chunk, err := s.Recv(ctx)
+		switch err {
+		case nil:
+			body = append(body, chunk...)
+		case io.EOF:
+			return body, nil
+		default:
+			return body, err
+		}
+	}
+}
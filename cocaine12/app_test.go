@@ -0,0 +1,127 @@
+package cocaine12
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newTestApp(t *testing.T) (*App, socketIO) {
+	info, err := NewServiceInfo([]string{"127.0.0.1:10053"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, peer := newTestService(t, "echo")
+	service.ServiceInfo = info
+	go service.loop()
+	return &App{service: service}, peer
+}
+
+func TestAppEnqueue(t *testing.T) {
+	app, peer := newTestApp(t)
+	defer app.Close()
+
+	ctx := context.Background()
+	stream, err := app.Enqueue(ctx, "ping", []byte("data"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	invoke := <-peer.Read()
+	assert.Equal(t, uint64(0), invoke.MsgType)
+	assert.Equal(t, []interface{}{[]byte("ping")}, invoke.Payload)
+	session := invoke.Session
+
+	chunk := <-peer.Read()
+	assert.Equal(t, session, chunk.Session)
+	assert.Equal(t, []interface{}{[]byte("data")}, chunk.Payload)
+
+	peer.Write() <- newChunkV1(session, []byte("pong"))
+	peer.Write() <- newChunkV1(session, []byte("!"))
+	peer.Write() <- newChokeV1(session)
+
+	body, err := stream.ReadAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("pong!"), body)
+
+	// the request is sealed by ReadAll
+	sealed := <-peer.Read()
+	assert.Equal(t, session, sealed.Session)
+	assert.Equal(t, uint64(v1Close), sealed.MsgType)
+
+	_, err = stream.Recv(ctx)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestAppEnqueueError(t *testing.T) {
+	app, peer := newTestApp(t)
+	defer app.Close()
+
+	ctx := context.Background()
+	stream, err := app.Enqueue(ctx, "ping", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	invoke := <-peer.Read()
+	peer.Write() <- newErrorV1(invoke.Session, 42, ErrorBadRequest, "bad request")
+
+	_, err = stream.Recv(ctx)
+	if assert.IsType(t, &ErrRequest{}, err) {
+		assert.Equal(t, ErrorBadRequest, err.(*ErrRequest).Code)
+	}
+
+	_, err = stream.Recv(ctx)
+	assert.IsType(t, &ErrRequest{}, err)
+}
+
+func TestAppEnqueueMetadata(t *testing.T) {
+	app, peer := newTestApp(t)
+	defer app.Close()
+
+	ctx := context.Background()
+	stream, err := app.Enqueue(ctx, "ping", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	session := (<-peer.Read()).Session
+
+	chunk := newChunkV1(session, []byte("pong"))
+	chunk.Headers = DefaultHeaderTable.Encode(Pairs("x-header", "h").headers())
+	choke := newChokeV1(session)
+	choke.Headers = DefaultHeaderTable.Encode(Pairs("x-trailer", "t").headers())
+	peer.Write() <- chunk
+	peer.Write() <- choke
+
+	data, err := stream.Recv(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("pong"), data)
+	assert.Equal(t, Pairs("x-header", "h"), stream.Header())
+	assert.Nil(t, stream.Trailer())
+
+	_, err = stream.Recv(ctx)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, Pairs("x-trailer", "t"), stream.Trailer())
+}
+
+func TestAppEnqueueAbort(t *testing.T) {
+	app, peer := newTestApp(t)
+	defer app.Close()
+
+	stream, err := app.Enqueue(context.Background(), "ping", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	session := (<-peer.Read()).Session
+
+	stream.(Aborter).Abort(context.Canceled)
+	aborted := <-peer.Read()
+	assert.Equal(t, session, aborted.Session)
+	assert.Equal(t, uint64(v1Error), aborted.MsgType)
+	code, _ := errorCodeOf(t, aborted)
+	assert.Equal(t, ErrorCancelled, code)
+}
@@ -5,11 +5,16 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ugorji/go/codec"
 )
 
+// maxWriteBatch limits the number of pending messages
+// flushed to the connection with a single write
+const maxWriteBatch = 64
+
 var (
 	mhAsocket = codec.MsgpackHandle{
 		BasicHandle: codec.BasicHandle{
@@ -37,14 +42,25 @@ type asyncBuff struct {
 	in  chan *Message
 	out chan *Message
 
+	// the input is not read while so many messages are pending.
+	// Zero means no limit
+	limit int
+
 	stop chan (<-chan time.Time)
 	wait chan struct{}
 }
 
 func newAsyncBuf() *asyncBuff {
+	return newBoundedAsyncBuf(0)
+}
+
+// newBoundedAsyncBuf creates the buffer which blocks senders
+// once the limit of pending messages is reached
+func newBoundedAsyncBuf(limit int) *asyncBuff {
 	buf := &asyncBuff{
-		in:  make(chan *Message),
-		out: make(chan *Message),
+		in:    make(chan *Message),
+		out:   make(chan *Message),
+		limit: limit,
 
 		// to stop my loop
 		stop: make(chan (<-chan time.Time)),
@@ -86,8 +102,14 @@ func (bf *asyncBuff) loop() {
 			var (
 				candidate *Message
 				out       chan *Message
+				in        = input
 			)
 
+			if bf.limit > 0 && len(pending) >= bf.limit {
+				// block the sender until the receiver catches up
+				in = nil
+			}
+
 			if len(pending) > 0 {
 				// mark the first message as a candidate to be sent
 				// and unlock the sending state
@@ -101,7 +123,7 @@ func (bf *asyncBuff) loop() {
 
 			select {
 			// get a message from a sender
-			case incoming, open := <-input:
+			case incoming, open := <-in:
 				if open {
 					pending = append(pending, incoming)
 				} else {
@@ -157,6 +179,29 @@ func (bf *asyncBuff) Drain(d time.Duration) error {
 	return bf.Stop()
 }
 
+// handoffTap keeps bytes read from a connection which have not formed
+// a complete frame yet, so the connection can be handed off in the middle
+// of a frame. It's used by the readloop goroutine only.
+type handoffTap struct {
+	r       io.Reader
+	pending []byte
+	// the greatest session read from the connection
+	maxSession uint64
+}
+
+func (t *handoffTap) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.pending = append(t.pending, p[:n]...)
+	return n, err
+}
+
+func (t *handoffTap) consume(frame []byte, msg *Message) {
+	t.pending = t.pending[len(frame):]
+	if msg.Session > t.maxSession {
+		t.maxSession = msg.Session
+	}
+}
+
 // Biderectional socket
 type asyncRWSocket struct {
 	sync.Mutex
@@ -164,17 +209,102 @@ type asyncRWSocket struct {
 	upstreamBuf   *asyncBuff
 	downstreamBuf *asyncBuff
 	closed        chan struct{} // broadcast channel
+
+	// wmu guards wbuf and serializes writes with rebinding
+	wmu  sync.Mutex
+	wbuf *bufio.Writer
+
+	// set if SocketOptions.Handoff is enabled
+	tap *handoffTap
+	// closed to make the readloop exit leaving the connection open
+	detach   chan struct{}
+	detached chan *handoffTap
+
+	wire wireCounters
+}
+
+// wireCounters counts traffic of connections
+type wireCounters struct {
+	bytesRead    uint64
+	bytesWritten uint64
+	// unix nanoseconds, accessed atomically
+	lastRead  int64
+	lastWrite int64
+
+	mu            sync.Mutex
+	framesRead    map[uint64]uint64
+	framesWritten map[uint64]uint64
+}
+
+// traffic of all connections of the process
+var totalWire wireCounters
+
+func (c *wireCounters) frameRead(msgType uint64) {
+	c.mu.Lock()
+	if c.framesRead == nil {
+		c.framesRead = make(map[uint64]uint64)
+	}
+	c.framesRead[msgType]++
+	c.mu.Unlock()
+}
+
+func (c *wireCounters) frameWritten(msgType uint64) {
+	c.mu.Lock()
+	if c.framesWritten == nil {
+		c.framesWritten = make(map[uint64]uint64)
+	}
+	c.framesWritten[msgType]++
+	c.mu.Unlock()
+}
+
+type countingReader struct {
+	r        io.Reader
+	counters *wireCounters
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		atomic.AddUint64(&c.counters.bytesRead, uint64(n))
+		atomic.StoreInt64(&c.counters.lastRead, time.Now().UnixNano())
+		atomic.AddUint64(&totalWire.bytesRead, uint64(n))
+	}
+	return n, err
+}
+
+type countingWriter struct {
+	w        io.Writer
+	counters *wireCounters
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		atomic.AddUint64(&c.counters.bytesWritten, uint64(n))
+		atomic.StoreInt64(&c.counters.lastWrite, time.Now().UnixNano())
+		atomic.AddUint64(&totalWire.bytesWritten, uint64(n))
+	}
+	return n, err
 }
 
 func newAsyncRW(conn io.ReadWriteCloser) (*asyncRWSocket, error) {
 	sock := &asyncRWSocket{
 		conn:          conn,
 		upstreamBuf:   newAsyncBuf(),
-		downstreamBuf: newAsyncBuf(),
+		downstreamBuf: newBoundedAsyncBuf(GetSocketOptions().ReadQueueSize),
 		closed:        make(chan struct{}),
+
+		detach:   make(chan struct{}),
+		detached: make(chan *handoffTap, 1),
+	}
+	sock.wbuf = bufio.NewWriter(&countingWriter{conn, &sock.wire})
+
+	r := &countingReader{conn, &sock.wire}
+	if GetSocketOptions().Handoff {
+		sock.tap = &handoffTap{r: r}
 	}
 
-	sock.readloop()
+	sock.readloop(r, sock.tap)
 	sock.writeloop()
 
 	return sock, nil
@@ -189,16 +319,29 @@ func newTCPConnection(address string, timeout time.Duration) (socketIO, error) {
 }
 
 func newAsyncConnection(family string, address string, timeout time.Duration) (socketIO, error) {
+	opts := GetSocketOptions()
 	dialer := net.Dialer{
 		Timeout:   timeout,
 		DualStack: true,
+		KeepAlive: opts.KeepAlive,
 	}
 
 	conn, err := dialer.Dial(family, address)
 	if err != nil {
 		return nil, err
 	}
-	return newAsyncRW(conn)
+
+	if err := opts.apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	sock, err := newAsyncRW(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapChaos(sock), nil
 }
 
 func (sock *asyncRWSocket) Close() {
@@ -242,10 +385,43 @@ func (sock *asyncRWSocket) Send(msg *Message) {
 
 func (sock *asyncRWSocket) writeloop() {
 	go func() {
-		var buf = bufio.NewWriter(sock.conn)
-		encoder := codec.NewEncoder(buf, hAsocket)
+		var (
+			encoder = codec.NewEncoder(sock.wbuf, hAsocket)
+			batch   = make([]*Message, 0, maxWriteBatch)
+		)
+
 		for incoming := range sock.upstreamBuf.out {
-			err := encoder.Encode(incoming)
+			batch = append(batch[:0], incoming)
+
+			sock.wmu.Lock()
+			err := sock.encode(encoder, incoming)
+			// messages sent back-to-back are flushed with a single write
+		BATCH:
+			for err == nil && len(batch) < maxWriteBatch {
+				select {
+				case next, ok := <-sock.upstreamBuf.out:
+					if !ok {
+						break BATCH
+					}
+					batch = append(batch, next)
+					err = sock.encode(encoder, next)
+				default:
+					break BATCH
+				}
+			}
+			if err == nil {
+				err = sock.wbuf.Flush()
+			}
+			sock.wmu.Unlock()
+
+			if err == nil {
+				for _, msg := range batch {
+					sock.wire.frameWritten(msg.MsgType)
+					if msg.written != nil {
+						msg.written()
+					}
+				}
+			}
 			if err != nil {
 				sock.close()
 				// blackhole all pending writes. See #31
@@ -256,23 +432,115 @@ func (sock *asyncRWSocket) writeloop() {
 				}()
 				return
 			}
-			buf.Flush()
 		}
 	}()
 }
 
-func (sock *asyncRWSocket) readloop() {
+func (sock *asyncRWSocket) encode(encoder *codec.Encoder, msg *Message) error {
+	traceWire(WireSent, msg)
+	return encoder.Encode(msg)
+}
+
+func (sock *asyncRWSocket) readloop(conn io.Reader, tap *handoffTap) {
 	go func() {
-		decoder := codec.NewDecoder(bufio.NewReader(sock.conn), hAsocket)
+		var (
+			opts = GetSocketOptions()
+			r    = conn
+		)
+		if tap != nil {
+			r = tap
+		}
+		frames := newFrameReader(r, opts.MaxFrameSize, opts.MaxFrameDepth)
+		frames.maxHeaderListSize = opts.MaxHeaderListSize
+
 		for {
-			var message *Message
-			err := decoder.Decode(&message)
+			message, err := sock.readMessage(frames, tap)
 			if err != nil {
+				select {
+				case <-sock.detach:
+					// the connection is being handed off
+					sock.detached <- tap
+					return
+				default:
+				}
+
 				close(sock.downstreamBuf.in)
 				sock.close()
 				return
 			}
-			sock.downstreamBuf.in <- message
+			traceWire(WireReceived, message)
+			sock.wire.frameRead(message.MsgType)
+			select {
+			case sock.downstreamBuf.in <- message:
+			case <-sock.closed:
+				// the buffer might be full and stopped
+				close(sock.downstreamBuf.in)
+				return
+			}
 		}
 	}()
 }
+
+func (sock *asyncRWSocket) readMessage(frames *frameReader, tap *handoffTap) (*Message, error) {
+	if tap == nil {
+		return frames.readMessage()
+	}
+
+	frame, err := frames.next()
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := frames.decodeMessage(frame)
+	if err != nil {
+		return nil, err
+	}
+	tap.consume(frame, message)
+	return message, nil
+}
+
+type deadlineReader interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// detachReader stops the readloop leaving the connection open.
+// It returns bytes of an incomplete frame read from the connection
+// and the greatest session read before.
+func (sock *asyncRWSocket) detachReader() ([]byte, uint64, error) {
+	conn, ok := sock.conn.(deadlineReader)
+	if !ok || sock.tap == nil {
+		return nil, 0, ErrHandoffUnsupported
+	}
+
+	close(sock.detach)
+	// interrupt the blocked read
+	if err := conn.SetReadDeadline(time.Now()); err != nil {
+		return nil, 0, err
+	}
+
+	select {
+	case tap := <-sock.detached:
+		return tap.pending, tap.maxSession, nil
+	case <-sock.closed:
+		return nil, 0, ErrConnectionLost
+	}
+}
+
+// rebind pauses writes, calls prepare and makes the socket use conn
+// if prepare succeeds. The reader must be detached before.
+func (sock *asyncRWSocket) rebind(conn io.ReadWriteCloser, prepare func() error) error {
+	sock.wmu.Lock()
+	defer sock.wmu.Unlock()
+
+	if err := prepare(); err != nil {
+		return err
+	}
+
+	sock.Lock()
+	sock.conn = conn
+	sock.Unlock()
+
+	sock.wbuf.Reset(&countingWriter{conn, &sock.wire})
+	sock.readloop(&countingReader{conn, &sock.wire}, nil)
+	return nil
+}
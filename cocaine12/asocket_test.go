@@ -41,3 +41,25 @@ func TestASocketConnect(t *testing.T) {
 	_, err = newUnixConnection("unix.sock", time.Second)
 	assert.Error(t, err)
 }
+
+func TestASocketBoundedBuffer(t *testing.T) {
+	buff := newBoundedAsyncBuf(2)
+	defer buff.Stop()
+
+	msg := &Message{}
+	buff.in <- msg
+	buff.in <- msg
+
+	select {
+	case buff.in <- msg:
+		t.Fatal("the buffer must be full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-buff.out
+	select {
+	case buff.in <- msg:
+	case <-time.After(time.Second):
+		t.Fatal("the buffer must accept a message")
+	}
+}
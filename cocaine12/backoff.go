@@ -0,0 +1,89 @@
+package cocaine12
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// the hint sent along with ErrorOverloaded by the worker
+	overloadRetryAfter = "1"
+	// bounds hints of misbehaving peers
+	maxRetryAfter = time.Minute
+)
+
+// parseRetryAfter returns the backoff hinted by RetryAfterHeader
+func parseRetryAfter(headers CocaineHeaders) (time.Duration, bool) {
+	if len(headers) == 0 {
+		return 0, false
+	}
+
+	value, ok := findHeader(headers, RetryAfterHeader)
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(string(value))
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+
+	backoff := time.Duration(seconds) * time.Second
+	if backoff > maxRetryAfter {
+		backoff = maxRetryAfter
+	}
+	return backoff, true
+}
+
+// learnBackoff makes new calls of the method wait if the upstream
+// has hinted so by RetryAfterHeader in the reply to its call.
+// Other methods are not delayed, as they might be served by other workers.
+func (service *Service) learnBackoff(rx Channel, msg *Message) {
+	ch, ok := rx.(*channel)
+	if !ok {
+		return
+	}
+
+	backoff, ok := parseRetryAfter(msg.Headers)
+	if !ok {
+		return
+	}
+
+	until := time.Now().Add(backoff)
+	service.backoffMu.Lock()
+	if service.backoffs == nil {
+		service.backoffs = make(map[string]time.Time)
+	}
+	if until.After(service.backoffs[ch.method]) {
+		service.backoffs[ch.method] = until
+	}
+	service.backoffMu.Unlock()
+}
+
+// waitBackoff delays a call of the method until the backoff hinted
+// by the upstream has passed. It returns ctx.Err() if ctx is done earlier.
+func (service *Service) waitBackoff(ctx context.Context, method string) error {
+	service.backoffMu.Lock()
+	until, ok := service.backoffs[method]
+	if ok && !until.After(time.Now()) {
+		delete(service.backoffs, method)
+	}
+	service.backoffMu.Unlock()
+
+	wait := until.Sub(time.Now())
+	if !ok || wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func retryAfterHeaders(value string) CocaineHeaders {
+	return DefaultHeaderTable.Encode([]Header{{Name: RetryAfterHeader, Value: []byte(value)}})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	backoff, ok := parseRetryAfter(retryAfterHeaders("2"))
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, backoff)
+
+	backoff, _ = parseRetryAfter(retryAfterHeaders("3600"))
+	assert.Equal(t, maxRetryAfter, backoff)
+
+	for _, headers := range []CocaineHeaders{nil, retryAfterHeaders("soon"), retryAfterHeaders("0")} {
+		_, ok = parseRetryAfter(headers)
+		assert.False(t, ok)
+	}
+}
+
+func TestServiceHonorsRetryAfter(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	ch, err := service.Call(context.Background(), "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := newErrorV1((<-peer.Read()).Session, cworkererrorcategory, ErrorOverloaded, "worker is overloaded")
+	reply.Headers = retryAfterHeaders("1")
+	peer.Write() <- reply
+	ch.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = service.Call(ctx, "ping")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = service.Go(ctx, "ping").Result()
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	start := time.Now()
+	_, err = service.Call(context.Background(), "ping")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) > 500*time.Millisecond, "the call must be delayed")
+}
+
+func TestServiceRetryAfterIsPerMethod(t *testing.T) {
+	info, err := NewServiceInfo([]string{"127.0.0.1:10053"}, Method{Name: "ping"}, Method{Name: "find"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, peer := newTestService(t, "storage")
+	service.ServiceInfo = info
+	go service.loop()
+	defer service.Close()
+
+	ch, err := service.Call(context.Background(), "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := newErrorV1((<-peer.Read()).Session, cworkererrorcategory, ErrorOverloaded, "worker is overloaded")
+	reply.Headers = retryAfterHeaders("1")
+	peer.Write() <- reply
+	ch.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = service.Call(ctx, "find")
+	assert.NoError(t, err, "other methods must not be delayed")
+	_, err = service.Call(ctx, "ping")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestWorkerOverloadHintsRetryAfter(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	w.SetConcurrencyLimiter(NewAIMDLimiter(1, 1, 1, time.Second))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			close(started)
+			<-release
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "test")
+	<-started
+	sock2.Write() <- newInvokeV1(3, "test")
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, 3, v1Error)
+	backoff, ok := parseRetryAfter(msg.Headers)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, backoff)
+}
@@ -0,0 +1,87 @@
+package cocaine12
+
+import (
+	"golang.org/x/net/context"
+)
+
+// Batch collects calls of a Service to send them back-to-back.
+// Messages sent together are flushed to the connection with a single
+// write, so N small calls cost about one round trip.
+type Batch struct {
+	service *Service
+	ctx     context.Context
+	calls   []batchCall
+}
+
+type batchCall struct {
+	name string
+	args []interface{}
+}
+
+// BatchResult is the reply to a call of Batch
+type BatchResult struct {
+	Result ServiceResult
+	Err    error
+}
+
+// Batch starts collecting calls made with ctx
+func (service *Service) Batch(ctx context.Context) *Batch {
+	return &Batch{
+		service: service,
+		ctx:     ctx,
+	}
+}
+
+// Add appends the call to the batch. Nothing is sent until Do.
+func (b *Batch) Add(name string, args ...interface{}) *Batch {
+	b.calls = append(b.calls, batchCall{name: name, args: args})
+	return b
+}
+
+// Len returns the number of collected calls
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Do sends all calls and waits for their replies. Results are returned
+// in the order of calls. If ctx is done, the calls still pending
+// get its error.
+func (b *Batch) Do() []BatchResult {
+	futures := make([]*CallFuture, len(b.calls))
+	for i, call := range b.calls {
+		futures[i] = b.service.Go(b.ctx, call.name, call.args...)
+	}
+
+	results := make([]BatchResult, len(futures))
+	for i, f := range futures {
+		results[i].Result, results[i].Err = b.wait(f)
+	}
+	return results
+}
+
+func (b *Batch) wait(f *CallFuture) (ServiceResult, error) {
+	// a ready reply wins over ctx, select picks randomly
+	select {
+	case <-f.Done():
+		return f.Result()
+	default:
+	}
+
+	select {
+	case <-f.Done():
+		return f.Result()
+	case <-b.ctx.Done():
+		ch := f.Channel()
+		if ch == nil {
+			// the call has failed before anything is sent
+			return f.Result()
+		}
+
+		// Get cancels the call upstream as ctx is done
+		res, err := ch.Get(b.ctx)
+		if err == nil {
+			err = res.Err()
+		}
+		return res, err
+	}
+}
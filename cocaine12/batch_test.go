@@ -0,0 +1,66 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestServiceBatch(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	batch := service.Batch(context.Background()).
+		Add("ping", "a").
+		Add("unknown").
+		Add("ping", "b")
+	assert.Equal(t, 3, batch.Len())
+
+	go func() {
+		first, second := <-peer.Read(), <-peer.Read()
+		peer.Write() <- newErrorV1(second.Session, 42, ErrorNotReady, "not ready")
+		peer.Write() <- &Message{CommonMessageInfo{first.Session, 0}, []interface{}{"A"}, nil, nil}
+	}()
+
+	results := batch.Do()
+	if !assert.Len(t, results, 3) {
+		return
+	}
+
+	var reply string
+	if assert.NoError(t, results[0].Err) {
+		assert.NoError(t, results[0].Result.ExtractTuple(&reply))
+		assert.Equal(t, "A", reply)
+	}
+	assert.Error(t, results[1].Err)
+	assert.IsType(t, &ErrRequest{}, results[2].Err)
+}
+
+func TestServiceBatchCancel(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	results := service.Batch(ctx).Add("ping").Do()
+	<-peer.Read()
+	assert.Equal(t, context.DeadlineExceeded, results[0].Err)
+}
+
+func TestServiceBatchFailedCallCancelled(t *testing.T) {
+	service, _ := newTestStorage(t)
+	defer service.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 100; i++ {
+		results := service.Batch(ctx).Add("unknown").Do()
+		if assert.Error(t, results[0].Err) {
+			assert.NotEqual(t, context.Canceled, results[0].Err)
+		}
+	}
+}
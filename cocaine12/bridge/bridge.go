@@ -109,6 +109,11 @@ func NewBridge(cfg *BridgeConfig, logger cocaine.Logger) (*Bridge, error) {
 		httpRequest.URL.Scheme = "http"
 		httpRequest.URL.Host = endpoint
 
+		// let the application continue the trace
+		if traceInfo, ok := cocaine.TraceInfoFromContext(ctx); ok {
+			cocaine.InjectTraceHeaders(traceInfo, httpRequest.Header)
+		}
+
 		appResp, err := http.DefaultClient.Do(httpRequest)
 		if err != nil {
 			response.Write(cocaine.WriteHead(http.StatusInternalServerError, cocaine.Headers{}))
@@ -0,0 +1,119 @@
+package cocaine12
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// handlers are expected to wait for IO mostly,
+	// so many of them share a CPU
+	defaultHandlersPerCPU = 64
+)
+
+// CPUQuota returns the number of CPUs the process is limited to
+// by the cgroup CPU quota. Both cgroup v1 and v2 are supported.
+// It returns false if there is no quota.
+func CPUQuota() (float64, bool) {
+	return readCPUQuota(cgroupRoot)
+}
+
+func readCPUQuota(root string) (float64, bool) {
+	// cgroup v2: "$MAX $PERIOD", where $MAX may be "max"
+	if data, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+
+	// cgroup v1: the quota is -1 if there is no limit
+	quota, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+
+	period, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quotaStr, periodStr string) (float64, bool) {
+	quota, err := strconv.ParseInt(quotaStr, 10, 64)
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+
+	period, err := strconv.ParseInt(periodStr, 10, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+
+	return float64(quota) / float64(period), true
+}
+
+// quotaCPUs rounds the quota up, as a fraction of a CPU
+// still needs a thread to run on
+func quotaCPUs(quota float64) int {
+	cpus := int(math.Ceil(quota))
+	if cpus < 1 {
+		cpus = 1
+	}
+	return cpus
+}
+
+// DefaultConcurrency returns the number of handlers which is sane
+// to run at once according to the CPU quota or the number of CPUs
+func DefaultConcurrency() int {
+	cpus := runtime.NumCPU()
+	if quota, ok := CPUQuota(); ok && quotaCPUs(quota) < cpus {
+		cpus = quotaCPUs(quota)
+	}
+	return cpus * defaultHandlersPerCPU
+}
+
+// LimitToCPUQuota adapts the worker to a cgroup CPU quota, as cocaine
+// isolates may spawn workers inside containers. It lowers GOMAXPROCS
+// to the quota unless it's set by the environment variable, otherwise
+// the Go scheduler runs a thread for every CPU of the host and the process
+// gets throttled. Handlers are limited to DefaultConcurrency.
+// It returns false if there is no quota, nothing is changed then.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) LimitToCPUQuota() bool {
+	limiter := adjustToCPUQuota()
+	if limiter == nil {
+		return false
+	}
+	w.limiter = limiter
+	return true
+}
+
+// adjustToCPUQuota lowers GOMAXPROCS to the CPU quota
+// and returns the limiter for handlers if there is a quota
+func adjustToCPUQuota() ConcurrencyLimiter {
+	quota, ok := CPUQuota()
+	if !ok {
+		return nil
+	}
+
+	cpus := quotaCPUs(quota)
+	if os.Getenv("GOMAXPROCS") == "" && cpus < runtime.GOMAXPROCS(0) {
+		runtime.GOMAXPROCS(cpus)
+	}
+
+	// min and max are equal to keep the limit fixed
+	limit := cpus * defaultHandlersPerCPU
+	return NewAIMDLimiter(limit, limit, limit, time.Second)
+}
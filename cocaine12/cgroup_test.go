@@ -0,0 +1,71 @@
+package cocaine12
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCgroupFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadCPUQuota(t *testing.T) {
+	for name, c := range map[string]struct {
+		files map[string]string
+		quota float64
+		ok    bool
+	}{
+		"v2": {
+			files: map[string]string{"cpu.max": "150000 100000\n"},
+			quota: 1.5, ok: true,
+		},
+		"v2 unlimited": {
+			files: map[string]string{"cpu.max": "max 100000\n"},
+		},
+		"v1": {
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "50000\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+			quota: 0.5, ok: true,
+		},
+		"v1 unlimited": {
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "-1\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+		},
+		"no cgroup": {},
+	} {
+		root, err := ioutil.TempDir("", "cgroup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+
+		for file, content := range c.files {
+			writeCgroupFile(t, root, file, content)
+		}
+
+		quota, ok := readCPUQuota(root)
+		assert.Equal(t, c.ok, ok, name)
+		assert.Equal(t, c.quota, quota, name)
+	}
+}
+
+func TestQuotaCPUs(t *testing.T) {
+	assert.Equal(t, 1, quotaCPUs(0.1))
+	assert.Equal(t, 2, quotaCPUs(1.5))
+	assert.Equal(t, 4, quotaCPUs(4))
+	assert.True(t, DefaultConcurrency() >= defaultHandlersPerCPU)
+}
@@ -1,8 +1,11 @@
 package cocaine12
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -15,26 +18,110 @@ type Channel interface {
 type Rx interface {
 	Get(context.Context) (ServiceResult, error)
 	Closed() bool
-	push(ServiceResult)
+	// push returns a channel which is closed once the reader has drained
+	// the queue, if the queue is full. See Service.SetRxWatermarks
+	push(ServiceResult) <-chan struct{}
 }
 
 type Tx interface {
 	Call(ctx context.Context, name string, args ...interface{}) error
+	// Seal notifies the other side that no more chunks will be sent.
+	// The channel still can receive data after that.
+	Seal(ctx context.Context) error
 }
 
+// SlowConsumerTx is implemented by channels of Service which detect
+// a peer not draining the stream. Type-assert a Tx to use it:
+//
+//	if tx, ok := ch.(SlowConsumerTx); ok {
+//		tx.SetWriteTimeout(time.Second)
+//	}
+type SlowConsumerTx interface {
+	Tx
+	// SetWriteTimeout limits how long a message sent via the channel
+	// may wait to be written to the connection. If the peer doesn't drain
+	// messages in time, the next calls return ErrSlowConsumer.
+	// Zero disables the limit.
+	SetWriteTimeout(timeout time.Duration)
+	// OnSlowConsumer attaches the handler which is called once
+	// the write timeout is exceeded, so expensive streaming can be aborted.
+	// It's called in a separate goroutine.
+	OnSlowConsumer(handler func())
+}
+
+const (
+	sealMethod  = "close"
+	abortMethod = "error"
+)
+
+var (
+	// ErrNotSealable means that the current protocol of a stream
+	// has no terminal `close` message
+	ErrNotSealable = errors.New("stream can not be sealed")
+	// ErrSlowConsumer means that the peer has not drained
+	// the messages sent via a channel within the write timeout
+	ErrSlowConsumer = errors.New("the peer does not read the stream in time")
+)
+
 type channel struct {
 	// we call when data frame arrives
 	traceReceived CloseSpan
 	// we call when data is sent
 	traceSent CloseSpan
+	// optional, called after a result is pushed
+	notify func()
+	// the name of the called method
+	method string
+	// the context of the call, Get aborts the call once it's done
+	ctx context.Context
 
 	rx
 	tx
 }
 
-func (ch *channel) push(res ServiceResult) {
+func (ch *channel) push(res ServiceResult) <-chan struct{} {
 	ch.traceReceived()
-	ch.rx.push(res)
+	resume := ch.rx.push(res)
+	if ch.notify != nil {
+		ch.notify()
+	}
+	return resume
+}
+
+// Get cancels the request upstream if it fails as the context
+// of the call is done or the default deadline of the method has passed,
+// so the worker can stop handling it. If only ctx is done, e.g. it polls
+// results with a short timeout, the call goes on and Get may be retried.
+func (ch *channel) Get(ctx context.Context) (ServiceResult, error) {
+	ctx, cancel := withDefaultDeadline(ctx, ch.rx.deadline)
+	defer cancel()
+
+	res, err := ch.rx.Get(ctx)
+	if err != nil && err == ctx.Err() {
+		if callErr := ch.callErr(); callErr != nil {
+			ch.abort(callErr)
+		}
+	}
+	return res, err
+}
+
+// abort cancels the call upstream as nobody reads the rest of results
+func (ch *channel) abort(reason error) {
+	ch.rx.release()
+	ch.tx.abort(reason)
+}
+
+// callErr returns the error of the call once its context is done
+// or the default deadline has passed
+func (ch *channel) callErr() error {
+	if err := ch.ctx.Err(); err != nil {
+		return err
+	}
+
+	if !ch.rx.deadline.IsZero() && !time.Now().Before(ch.rx.deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
 }
 
 func (ch *channel) Call(ctx context.Context, name string, args ...interface{}) error {
@@ -42,6 +129,11 @@ func (ch *channel) Call(ctx context.Context, name string, args ...interface{}) e
 	return ch.tx.Call(ctx, name, args...)
 }
 
+func (ch *channel) Seal(ctx context.Context) error {
+	ch.traceSent()
+	return ch.tx.Seal(ctx)
+}
+
 type rx struct {
 	pushBuffer chan ServiceResult
 	rxTree     *streamDescription
@@ -49,6 +141,21 @@ type rx struct {
 	sync.Mutex
 	queue []ServiceResult
 	done  bool
+
+	// once highWatermark results are queued, the service stops reading
+	// the connection until Get drains the queue to lowWatermark.
+	// Zero highWatermark means no limit
+	highWatermark int
+	lowWatermark  int
+	// closed once the queue is drained, it's set while the queue is full
+	resume chan struct{}
+	// set once the channel is not read anymore,
+	// so it must not stop the service from reading
+	released bool
+
+	// Get waits until it if its context has no deadline.
+	// See Service.SetMethodTimeouts
+	deadline time.Time
 }
 
 func (rx *rx) Get(ctx context.Context) (ServiceResult, error) {
@@ -62,16 +169,7 @@ func (rx *rx) Get(ctx context.Context) (ServiceResult, error) {
 	select {
 	case res = <-rx.pushBuffer:
 	default:
-		rx.Lock()
-		if len(rx.queue) > 0 {
-			res = rx.queue[0]
-			select {
-			case rx.pushBuffer <- res:
-				rx.queue = rx.queue[1:]
-			default:
-			}
-		}
-		rx.Unlock()
+		rx.refill()
 
 		select {
 		case res = <-rx.pushBuffer:
@@ -79,10 +177,19 @@ func (rx *rx) Get(ctx context.Context) (ServiceResult, error) {
 			return nil, ctx.Err()
 		}
 	}
+	rx.refill()
+
+	if rx.rxTree == nil {
+		return res, nil
+	}
 
 	treeMap := *(rx.rxTree)
 	method, _, _ := res.Result()
-	temp := treeMap[method]
+	temp, ok := treeMap[method]
+	if !ok || temp == nil {
+		rx.done = true
+		return nil, fmt.Errorf("unexpected message type %d", method)
+	}
 
 	switch temp.Description.Type() {
 	case emptyDispatch:
@@ -105,10 +212,16 @@ func (rx *rx) Get(ctx context.Context) (ServiceResult, error) {
 			return res, err
 		}
 
+		var details []byte
+		if sr, ok := res.(*serviceRes); ok {
+			details = errorDetails(sr.headers)
+		}
+
 		res.setError(&ErrRequest{
 			Message:  message,
 			Category: catAndCode[0],
 			Code:     catAndCode[1],
+			Details:  details,
 		})
 	}
 
@@ -119,15 +232,61 @@ func (rx *rx) Closed() bool {
 	return rx.done
 }
 
-func (rx *rx) push(res ServiceResult) {
+// push never blocks, as it's called by the loop reading the connection.
+// Once highWatermark results are queued, it returns the channel which
+// is closed when Get drains the queue to lowWatermark, so the loop stops
+// reading until then. Errors never stop it.
+func (rx *rx) push(res ServiceResult) <-chan struct{} {
 	rx.Lock()
+	defer rx.Unlock()
+
 	rx.queue = append(rx.queue, res)
 	select {
 	case rx.pushBuffer <- rx.queue[0]:
 		rx.queue = rx.queue[1:]
 	default:
 	}
-	rx.Unlock()
+
+	if rx.released || rx.highWatermark <= 0 || len(rx.queue) < rx.highWatermark || res.Err() != nil {
+		return nil
+	}
+
+	if rx.resume == nil {
+		rx.resume = make(chan struct{})
+	}
+	return rx.resume
+}
+
+// refill moves the next queued result to pushBuffer
+// and resumes reading once the queue is drained
+func (rx *rx) refill() {
+	rx.Lock()
+	defer rx.Unlock()
+
+	if len(rx.queue) > 0 {
+		select {
+		case rx.pushBuffer <- rx.queue[0]:
+			rx.queue = rx.queue[1:]
+		default:
+		}
+	}
+
+	if rx.resume != nil && len(rx.queue) <= rx.lowWatermark {
+		close(rx.resume)
+		rx.resume = nil
+	}
+}
+
+// release resumes reading regardless of the queue
+func (rx *rx) release() {
+	rx.Lock()
+	defer rx.Unlock()
+
+	rx.released = true
+	if rx.resume != nil {
+		close(rx.resume)
+		rx.resume = nil
+	}
 }
 
 type tx struct {
@@ -137,6 +296,11 @@ type tx struct {
 	done    bool
 
 	headers CocaineHeaders
+
+	writeTimeout time.Duration
+	onSlow       func()
+	// set by a timer once a message is not written in time
+	slow int32
 }
 
 func (tx *tx) Call(ctx context.Context, name string, args ...interface{}) error {
@@ -144,6 +308,10 @@ func (tx *tx) Call(ctx context.Context, name string, args ...interface{}) error
 		return fmt.Errorf("tx is done")
 	}
 
+	if atomic.LoadInt32(&tx.slow) == 1 {
+		return ErrSlowConsumer
+	}
+
 	method, err := tx.txTree.MethodByName(name)
 	if err != nil {
 		return err
@@ -169,6 +337,71 @@ func (tx *tx) Call(ctx context.Context, name string, args ...interface{}) error
 		Headers:           tx.headers,
 	}
 
+	if tx.writeTimeout > 0 {
+		tx.watchWrite(msg)
+	}
+
 	tx.service.sendMsg(msg)
 	return nil
 }
+
+func (tx *tx) SetWriteTimeout(timeout time.Duration) {
+	tx.writeTimeout = timeout
+}
+
+func (tx *tx) OnSlowConsumer(handler func()) {
+	tx.onSlow = handler
+}
+
+// watchWrite marks the channel as slow if msg
+// is not written to the connection within the write timeout
+func (tx *tx) watchWrite(msg *Message) {
+	handler := tx.onSlow
+	timer := time.AfterFunc(tx.writeTimeout, func() {
+		if atomic.CompareAndSwapInt32(&tx.slow, 0, 1) && handler != nil {
+			handler()
+		}
+	})
+	msg.written = func() {
+		timer.Stop()
+	}
+}
+
+// Seal sends the terminal `close` message of the current protocol,
+// like CloseSend of gRPC. It half-closes the channel:
+// a response stream is not affected.
+func (tx *tx) Seal(ctx context.Context) error {
+	if tx.done {
+		return ErrStreamIsClosed
+	}
+
+	if tx.txTree == nil {
+		return ErrNotSealable
+	}
+
+	method, err := tx.txTree.MethodByName(sealMethod)
+	if err != nil {
+		return ErrNotSealable
+	}
+
+	if (*tx.txTree)[method].Description.Type() != emptyDispatch {
+		return ErrNotSealable
+	}
+
+	return tx.Call(ctx, sealMethod)
+}
+
+// abort sends the terminal `error` message of the current protocol
+// if there is one. Primitive protocols can't be aborted.
+func (tx *tx) abort(reason error) {
+	if tx.done || tx.txTree == nil {
+		return
+	}
+
+	method, err := tx.txTree.MethodByName(abortMethod)
+	if err != nil || (*tx.txTree)[method].Description.Type() != emptyDispatch {
+		return
+	}
+
+	tx.Call(context.Background(), abortMethod, [2]int{cworkererrorcategory, ErrorCancelled}, reason.Error())
+}
@@ -0,0 +1,302 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTxSeal(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	streaming := &streamDescription{
+		0: &StreamDescriptionItem{"write", nil},
+		1: &StreamDescriptionItem{"error", &streamDescription{}},
+		2: &StreamDescriptionItem{"close", &streamDescription{}},
+	}
+
+	ctx := context.Background()
+	sealable := &tx{
+		service: &Service{socketIO: sock},
+		txTree:  streaming,
+		id:      10,
+	}
+
+	assert.NoError(t, sealable.Call(ctx, "write", "data"))
+	checkTypeAndSession(t, <-sock2.Read(), 10, 0)
+
+	assert.NoError(t, sealable.Seal(ctx))
+	checkTypeAndSession(t, <-sock2.Read(), 10, 2)
+
+	assert.Equal(t, ErrStreamIsClosed, sealable.Seal(ctx))
+
+	primitive := &tx{
+		service: &Service{socketIO: sock},
+		txTree:  &streamDescription{0: &StreamDescriptionItem{"value", &streamDescription{}}},
+		id:      11,
+	}
+	assert.Equal(t, ErrNotSealable, primitive.Seal(ctx))
+}
+
+func TestChannelCancel(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	streaming := &streamDescription{
+		0: &StreamDescriptionItem{"write", nil},
+		1: &StreamDescriptionItem{"error", &streamDescription{}},
+		2: &StreamDescriptionItem{"close", &streamDescription{}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := &channel{
+		traceReceived: closeDummySpan,
+		traceSent:     closeDummySpan,
+		ctx:           ctx,
+		rx: rx{
+			pushBuffer: make(chan ServiceResult, 1),
+		},
+		tx: tx{
+			service: &Service{socketIO: sock},
+			txTree:  streaming,
+			id:      10,
+		},
+	}
+
+	cancel()
+
+	_, err := ch.Get(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, 10, 1)
+	var perr struct {
+		CodeInfo [2]int
+		Message  string
+	}
+	assert.NoError(t, convertPayload(msg.Payload, &perr))
+	assert.Equal(t, [2]int{cworkererrorcategory, ErrorCancelled}, perr.CodeInfo)
+
+	// the stream is closed, so it's aborted only once
+	_, err = ch.Get(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Error(t, ch.Call(ctx, "write", "data"))
+}
+
+func TestChannelGetTimeout(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	streaming := &streamDescription{
+		0: &StreamDescriptionItem{"write", nil},
+		1: &StreamDescriptionItem{"error", &streamDescription{}},
+		2: &StreamDescriptionItem{"close", &streamDescription{}},
+	}
+
+	newChannel := func(deadline time.Time) *channel {
+		return &channel{
+			traceReceived: closeDummySpan,
+			traceSent:     closeDummySpan,
+			ctx:           context.Background(),
+			rx: rx{
+				pushBuffer: make(chan ServiceResult, 1),
+				rxTree:     streaming,
+				deadline:   deadline,
+			},
+			tx: tx{
+				service: &Service{socketIO: sock},
+				txTree:  streaming,
+				id:      10,
+			},
+		}
+	}
+
+	// Get polls with a timeout of its own
+	ch := newChannel(time.Time{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err := ch.Get(ctx)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	select {
+	case msg := <-sock2.Read():
+		t.Fatalf("the call must not be aborted by a poll timeout: %v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the retry gets the result
+	ch.push(&serviceRes{payload: []interface{}{"pong"}, method: 0})
+	res, err := ch.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, res.Err())
+
+	// the default deadline of the method belongs to the call
+	ch = newChannel(time.Now().Add(10 * time.Millisecond))
+	_, err = ch.Get(context.Background())
+	assert.Equal(t, context.DeadlineExceeded, err)
+	checkTypeAndSession(t, <-sock2.Read(), 10, 1)
+}
+
+func TestRxWatermarks(t *testing.T) {
+	r := &rx{
+		pushBuffer:    make(chan ServiceResult, 1),
+		highWatermark: 2,
+		lowWatermark:  1,
+	}
+
+	// the first result goes to the buffer, the next ones are queued
+	assert.Nil(t, r.push(&serviceRes{method: 0}))
+	assert.Nil(t, r.push(&serviceRes{method: 0}))
+	resume := r.push(&serviceRes{method: 0})
+	if !assert.NotNil(t, resume, "the queue is full") {
+		return
+	}
+	assert.Equal(t, resume, r.push(&serviceRes{method: 0}))
+	assert.Nil(t, r.push(&serviceRes{method: 1, err: ErrStreamIsClosed}), "errors never stop reading")
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := r.Get(ctx)
+		assert.NoError(t, err)
+		select {
+		case <-resume:
+			t.Fatal("the queue is not drained to the low watermark yet")
+		default:
+		}
+	}
+
+	_, err := r.Get(ctx)
+	assert.NoError(t, err)
+	select {
+	case <-resume:
+	default:
+		t.Fatal("the queue is drained")
+	}
+}
+
+func TestRxRelease(t *testing.T) {
+	r := &rx{
+		pushBuffer:    make(chan ServiceResult, 1),
+		highWatermark: 1,
+	}
+
+	r.push(&serviceRes{method: 0})
+	resume := r.push(&serviceRes{method: 0})
+	if !assert.NotNil(t, resume) {
+		return
+	}
+
+	r.release()
+	select {
+	case <-resume:
+	default:
+		t.Fatal("a released channel must not stop reading")
+	}
+	assert.Nil(t, r.push(&serviceRes{method: 0}))
+}
+
+func TestTxWriteTimeout(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	defer sock.Close()
+
+	ch := &channel{
+		traceReceived: closeDummySpan,
+		traceSent:     closeDummySpan,
+		tx: tx{
+			service: &Service{socketIO: sock},
+			txTree:  &streamDescription{0: &StreamDescriptionItem{"write", nil}},
+			id:      10,
+		},
+	}
+
+	var channel Channel = ch
+	tx, ok := channel.(SlowConsumerTx)
+	if !ok {
+		t.Fatal("channels of Service must detect slow consumers")
+	}
+
+	slow := make(chan struct{})
+	tx.SetWriteTimeout(50 * time.Millisecond)
+	tx.OnSlowConsumer(func() {
+		close(slow)
+	})
+
+	ctx := context.Background()
+	// the peer reads in time
+	frames := newFrameReader(in, 0, 0)
+	assert.NoError(t, ch.Call(ctx, "write", "first"))
+	_, err := frames.readMessage()
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, ch.Call(ctx, "write", "second"))
+
+	// the peer stops reading
+	select {
+	case <-slow:
+	case <-time.After(time.Second):
+		t.Fatal("the slow consumer is not detected")
+	}
+	assert.Equal(t, ErrSlowConsumer, ch.Call(ctx, "write", "third"))
+}
+
+func TestServiceRxBackpressure(t *testing.T) {
+	app, peer := newTestApp(t)
+	defer app.Close()
+	app.Service().SetRxWatermarks(2, 1)
+
+	ctx := context.Background()
+	slow, err := app.Enqueue(ctx, "slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowSession := (<-peer.Read()).Session
+
+	fast, err := app.Enqueue(ctx, "fast", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastSession := (<-peer.Read()).Session
+
+	// nobody reads the slow stream, so the connection is not read
+	// after its queue has got two chunks
+	for i := 0; i < 4; i++ {
+		peer.Write() <- newChunkV1(slowSession, []byte("chunk"))
+	}
+	peer.Write() <- newChunkV1(fastSession, []byte("pong"))
+
+	received := make(chan []byte, 1)
+	go func() {
+		data, _ := fast.Recv(ctx)
+		received <- data
+	}()
+
+	select {
+	case <-received:
+		t.Fatal("the connection must not be read over the high watermark")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for i := 0; i < 4; i++ {
+		_, err = slow.Recv(ctx)
+		assert.NoError(t, err)
+	}
+
+	select {
+	case data := <-received:
+		assert.Equal(t, []byte("pong"), data)
+	case <-time.After(time.Second):
+		t.Fatal("reading must resume once the stream is drained")
+	}
+}
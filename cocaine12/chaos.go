@@ -0,0 +1,174 @@
+package cocaine12
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// messages are held in the reorder window no longer than this
+	chaosReorderFlush = time.Millisecond * 10
+)
+
+// ChaosPolicy describes faults injected into incoming traffic
+// of connections. It's intended for tests of application resilience
+// to misbehavior of cocaine-runtime and services.
+type ChaosPolicy struct {
+	// Latency is added to every incoming message
+	Latency time.Duration
+	// Jitter is the upper bound of a random latency added to Latency
+	Jitter time.Duration
+	// ReorderWindow is the number of incoming messages shuffled together.
+	// Messages of the same session keep their order
+	ReorderWindow int
+	// DropHeartbeats makes the worker miss heartbeats of cocaine-runtime
+	DropHeartbeats bool
+	// AbortAfter closes a connection after the duration. Zero disables it
+	AbortAfter time.Duration
+	// Seed initializes the random generator to reproduce a run
+	Seed int64
+}
+
+var (
+	chaosMu     sync.RWMutex
+	chaosPolicy *ChaosPolicy
+)
+
+// SetChaosPolicy enables fault injection for new connections
+// of both the worker and service clients. nil disables it.
+func SetChaosPolicy(policy *ChaosPolicy) {
+	chaosMu.Lock()
+	chaosPolicy = policy
+	chaosMu.Unlock()
+}
+
+func getChaosPolicy() *ChaosPolicy {
+	chaosMu.RLock()
+	defer chaosMu.RUnlock()
+	return chaosPolicy
+}
+
+// chaosIO injects faults into messages read from the underlying socket
+type chaosIO struct {
+	socketIO
+	policy ChaosPolicy
+	rnd    *rand.Rand
+	read   chan *Message
+}
+
+func wrapChaos(sock socketIO) socketIO {
+	policy := getChaosPolicy()
+	if policy == nil {
+		return sock
+	}
+	return newChaosIO(sock, *policy)
+}
+
+func newChaosIO(sock socketIO, policy ChaosPolicy) *chaosIO {
+	c := &chaosIO{
+		socketIO: sock,
+		policy:   policy,
+		rnd:      rand.New(rand.NewSource(policy.Seed)),
+		read:     make(chan *Message),
+	}
+
+	if policy.AbortAfter > 0 {
+		time.AfterFunc(policy.AbortAfter, sock.Close)
+	}
+
+	go c.loop()
+	return c
+}
+
+func (c *chaosIO) Read() chan *Message {
+	return c.read
+}
+
+func isHeartbeat(msg *Message) bool {
+	return msg.Session == v1UtilitySession && msg.MsgType == v1Heartbeat && len(msg.Payload) == 0
+}
+
+func (c *chaosIO) loop() {
+	defer close(c.read)
+
+	var (
+		input   = c.socketIO.Read()
+		pending []*Message
+		flush   <-chan time.Time
+	)
+
+	for input != nil || len(pending) > 0 {
+		var (
+			out   chan *Message
+			first *Message
+			next  int
+		)
+
+		// release messages when the window is full or has been held long enough
+		if len(pending) > 0 && (input == nil || len(pending) >= c.policy.ReorderWindow || flush == nil) {
+			out = c.read
+			next = c.pickNext(pending)
+			first = pending[next]
+		}
+
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				input = nil
+				continue
+			}
+
+			if c.policy.DropHeartbeats && isHeartbeat(msg) {
+				continue
+			}
+
+			c.delay()
+			pending = append(pending, msg)
+			if c.policy.ReorderWindow > 1 {
+				flush = time.After(chaosReorderFlush)
+			}
+
+		case out <- first:
+			pending = append(pending[:next], pending[next+1:]...)
+
+		case <-flush:
+			flush = nil
+
+		case <-c.IsClosed():
+			return
+		}
+	}
+}
+
+func (c *chaosIO) delay() {
+	latency := c.policy.Latency
+	if c.policy.Jitter > 0 {
+		latency += time.Duration(c.rnd.Int63n(int64(c.policy.Jitter)))
+	}
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// pickNext chooses a random message which is the first of its session,
+// so reordering never breaks the order inside a session
+func (c *chaosIO) pickNext(pending []*Message) int {
+	if c.policy.ReorderWindow <= 1 {
+		return 0
+	}
+
+	var (
+		seen       = make(map[uint64]bool, len(pending))
+		candidates []int
+	)
+	for i, msg := range pending {
+		if !seen[msg.Session] {
+			seen[msg.Session] = true
+			candidates = append(candidates, i)
+		}
+	}
+
+	return candidates[c.rnd.Intn(len(candidates))]
+}
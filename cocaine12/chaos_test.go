@@ -0,0 +1,64 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosReorderKeepsSessionOrder(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock2.Close()
+
+	chaos := newChaosIO(sock, ChaosPolicy{ReorderWindow: 8, Seed: 42, DropHeartbeats: true})
+	defer chaos.Close()
+
+	var sent []*Message
+	for i := 0; i < 4; i++ {
+		sent = append(sent, newChunkV1(2, []byte{byte(i)}), newChunkV1(3, []byte{byte(i)}))
+	}
+
+	sock2.Write() <- newHeartbeatV1()
+	for _, msg := range sent {
+		sock2.Write() <- msg
+	}
+
+	var received = make(map[uint64][]byte)
+	for i := 0; i < len(sent); i++ {
+		select {
+		case msg := <-chaos.Read():
+			if isHeartbeat(msg) {
+				t.Fatal("heartbeat is not dropped")
+			}
+			received[msg.Session] = append(received[msg.Session], msg.Payload[0].([]byte)...)
+		case <-time.After(time.Second):
+			t.Fatal("message is lost")
+		}
+	}
+
+	assert.Equal(t, []byte{0, 1, 2, 3}, received[2])
+	assert.Equal(t, []byte{0, 1, 2, 3}, received[3])
+}
+
+func TestChaosLatencyAndAbort(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock2.Close()
+
+	chaos := newChaosIO(sock, ChaosPolicy{Latency: 50 * time.Millisecond, AbortAfter: 200 * time.Millisecond})
+
+	start := time.Now()
+	sock2.Write() <- newChunkV1(2, []byte("data"))
+	<-chaos.Read()
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	select {
+	case <-chaos.IsClosed():
+	case <-time.After(time.Second):
+		t.Fatal("connection is not aborted")
+	}
+}
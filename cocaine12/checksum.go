@@ -0,0 +1,46 @@
+package cocaine12
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+)
+
+// ChecksumHeader carries CRC-32C of a chunk as 8 hex digits
+const ChecksumHeader = "x-checksum-crc32c"
+
+// ErrChecksumMismatch means that a chunk has been corrupted on its way
+var ErrChecksumMismatch = errors.New("chunk checksum mismatch")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// EnableChecksums allows/disallows the worker to attach ChecksumHeader
+// to response chunks, so clients can detect data damaged by buggy proxies
+// or custom transports. It's disabled by default. Checksums of incoming
+// chunks are verified regardless of this setting.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) EnableChecksums(enable bool) {
+	w.checksums = enable
+}
+
+func checksumHeader(data []byte) Header {
+	return Header{Name: ChecksumHeader, Value: []byte(chunkChecksum(data))}
+}
+
+func chunkChecksum(data []byte) string {
+	sum := crc32.Checksum(data, crc32cTable)
+	return hex.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)})
+}
+
+// verifyChecksum checks data against ChecksumHeader if it's attached
+func verifyChecksum(headers CocaineHeaders, data []byte) error {
+	expected, ok := findHeader(headers, ChecksumHeader)
+	if !ok {
+		return nil
+	}
+
+	if string(expected) != chunkChecksum(data) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
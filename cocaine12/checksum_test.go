@@ -0,0 +1,71 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestChunkChecksum(t *testing.T) {
+	// the check value of CRC-32C
+	assert.Equal(t, "e3069283", chunkChecksum([]byte("123456789")))
+
+	headers := DefaultHeaderTable.Encode([]Header{checksumHeader([]byte("data"))})
+	assert.NoError(t, verifyChecksum(headers, []byte("data")))
+	assert.Equal(t, ErrChecksumMismatch, verifyChecksum(headers, []byte("data!")))
+	assert.NoError(t, verifyChecksum(nil, []byte("data")))
+
+	msg := newChunkV1(2, []byte("dat"))
+	msg.Headers = headers
+	assert.Equal(t, ErrChecksumMismatch, decodePayload(msg))
+}
+
+func TestWorkerChecksums(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	w.EnableChecksums(true)
+
+	go w.Run(map[string]EventHandler{
+		"echo": func(ctx context.Context, req Request, res Response) {
+			data, err := req.Read(ctx)
+			if err != nil {
+				res.ErrorMsg(ErrorBadRequest, err.Error())
+				return
+			}
+			res.Write(data)
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "echo")
+	chunk := newChunkV1(2, []byte("ping"))
+	chunk.Headers = DefaultHeaderTable.Encode([]Header{checksumHeader([]byte("ping"))})
+	sock2.Write() <- chunk
+
+	reply := <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Write)
+	checksum, ok := findHeader(reply.Headers, ChecksumHeader)
+	assert.True(t, ok)
+	assert.Equal(t, chunkChecksum([]byte("ping")), string(checksum))
+	assert.NoError(t, decodePayload(reply))
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	// a chunk damaged on its way
+	sock2.Write() <- newInvokeV1(3, "echo")
+	chunk = newChunkV1(3, []byte("pong"))
+	chunk.Headers = DefaultHeaderTable.Encode([]Header{checksumHeader([]byte("ping"))})
+	sock2.Write() <- chunk
+
+	reply = <-sock2.Read()
+	checkTypeAndSession(t, reply, 3, v1Error)
+}
@@ -0,0 +1,143 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+
+	timer := clock.NewTimer(time.Second)
+	after := clock.After(2 * time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+
+	assert.False(t, timer.Reset(time.Second), "fired timer is not active")
+	assert.True(t, timer.Stop())
+
+	clock.Advance(time.Second)
+	<-after
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestClockTimeoutContext(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+
+	ctx, cancel := withClockTimeout(context.Background(), clock, time.Second)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Second), deadline)
+	assert.NoError(t, ctx.Err())
+
+	clock.Advance(time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context must be done once the clock passes the deadline")
+	}
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+
+	ctx, cancel = withClockTimeout(context.Background(), clock, time.Second)
+	cancel()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestWorkerManualClockDisown(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	// the runtime replies, so the next heartbeat is sent
+	sock2.Write() <- newHeartbeatV1()
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(heartbeatTimeout)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	// no reply this time
+	clock.Advance(disownTimeout)
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrDisowned, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is not disowned")
+	}
+}
+
+func TestWorkerHeartbeatMissed(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.SetHeartbeatInterval(20 * time.Second)
+	w.SetDisownTimeout(4 * time.Second)
+
+	missed := make(chan time.Duration, 1)
+	w.OnHeartbeatMissed(func(elapsed time.Duration) {
+		missed <- elapsed
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	clock.Advance(2 * time.Second)
+	select {
+	case elapsed := <-missed:
+		assert.Equal(t, 2*time.Second, elapsed)
+	case <-time.After(time.Second):
+		t.Fatal("missed heartbeat is not reported")
+	}
+
+	clock.Advance(2 * time.Second)
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrDisowned, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is not disowned")
+	}
+}
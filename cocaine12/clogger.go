@@ -2,7 +2,9 @@ package cocaine12
 
 import (
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -23,14 +25,55 @@ type attrPair struct {
 }
 
 func formatFields(f Fields) []attrPair {
+	f = redactFields(f)
 	formatted := make([]attrPair, 0, len(f))
 	for k, v := range f {
-		formatted = append(formatted, attrPair{k, v})
+		formatted = append(formatted, attrPair{k, attrValue(v)})
 	}
 
 	return formatted
 }
 
+// attrValue converts a field value to a type of attributes
+// of the logging service: integers, floats and bools are sent natively,
+// time.Time and time.Duration are sent as integer microseconds,
+// other values are formatted to strings
+func attrValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int64, uint64, float64:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		// since the Unix epoch
+		return v.UnixNano() / int64(time.Microsecond)
+	case time.Duration:
+		return int64(v / time.Microsecond)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 func newCocaineLogger(ctx context.Context, name string, endpoints ...string) (Logger, error) {
 	service, err := NewService(ctx, name, endpoints)
 	if err != nil {
@@ -81,7 +124,7 @@ func (c *cocaineLogger) Verbosity(ctx context.Context) (level Severity) {
 }
 
 func (c *cocaineLogger) V(level Severity) bool {
-	return level >= c.severity.get()
+	return runtimeSeverity(level) >= c.severity.get()
 }
 
 func (c *cocaineLogger) WithFields(fields Fields) *Entry {
@@ -91,23 +134,57 @@ func (c *cocaineLogger) WithFields(fields Fields) *Entry {
 	}
 }
 
+func (c *cocaineLogger) Writer(level Severity) io.Writer {
+	return newLogWriter(c, defaultFields, level)
+}
+
 func (c *cocaineLogger) log(level Severity, fields Fields, msg string, args ...interface{}) {
+	severity := runtimeSeverity(level)
+
 	var methodArgs []interface{}
 	if len(args) > 0 {
-		methodArgs = []interface{}{level, c.prefix, fmt.Sprintf(msg, args...), formatFields(fields)}
+		methodArgs = []interface{}{severity, c.prefix, fmt.Sprintf(msg, args...), formatFields(fields)}
 	} else {
-		methodArgs = []interface{}{level, c.prefix, msg, formatFields(fields)}
+		methodArgs = []interface{}{severity, c.prefix, msg, formatFields(fields)}
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// the worker is going to shut down after a fatal entry,
+	// so make sure it's written
+	var flushed chan struct{}
+	var written func()
+	if level == FatalLevel {
+		flushed = make(chan struct{})
+		written = func() { close(flushed) }
+	}
 
+	// sessions must reach the runtime in the order they are allocated
+	c.mu.Lock()
 	loggermsg := &Message{
 		CommonMessageInfo: CommonMessageInfo{c.Service.sessions.Next(), loggerEmit},
 		Payload:           methodArgs,
+		written:           written,
 	}
-
 	c.Service.sendMsg(loggermsg)
+	c.mu.Unlock()
+
+	if flushed != nil {
+		select {
+		case <-flushed:
+		case <-time.After(fatalFlushTimeout):
+		}
+	}
+}
+
+func (c *cocaineLogger) Trace(args ...interface{}) {
+	if c.V(TraceLevel) {
+		c.log(TraceLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
+
+func (c *cocaineLogger) Tracef(msg string, args ...interface{}) {
+	if c.V(TraceLevel) {
+		c.log(TraceLevel, defaultFields, msg, args...)
+	}
 }
 
 func (c *cocaineLogger) Debug(args ...interface{}) {
@@ -157,3 +234,14 @@ func (c *cocaineLogger) Errf(msg string, args ...interface{}) {
 		c.log(ErrorLevel, defaultFields, msg, args...)
 	}
 }
+
+func (c *cocaineLogger) Fatal(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	c.log(FatalLevel, defaultFields, msg)
+	terminateOnFatal(msg)
+}
+
+func (c *cocaineLogger) Fatalf(msg string, args ...interface{}) {
+	c.log(FatalLevel, defaultFields, msg, args...)
+	terminateOnFatal(fmt.Sprintf(msg, args...))
+}
@@ -0,0 +1,235 @@
+package cocaine12
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Headers negotiating compression of chunks
+const (
+	// AcceptEncodingHeader lists encodings a client is able to decode,
+	// e.g. "zstd, gzip"
+	AcceptEncodingHeader = "accept-encoding"
+	// ContentEncodingHeader is attached to a compressed chunk
+	ContentEncodingHeader = "content-encoding"
+)
+
+// Supported encodings of chunks
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+const defaultCompressionThreshold = 1024
+
+// ErrDecompressedTooLarge means that a compressed chunk
+// is decompressed into more than MaxFrameSize bytes
+var ErrDecompressedTooLarge = errors.New("decompressed chunk exceeds the maximum frame size")
+
+// Compression configures compression of response chunks
+type Compression struct {
+	// Encodings in the order of preference. The first one accepted
+	// by a client is used
+	Encodings []string
+	// Chunks smaller than Threshold bytes are sent as they are,
+	// 1024 bytes by default
+	Threshold int
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+
+	gzipWriters = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+)
+
+func initZstd() {
+	decoderOptions := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+	if maxSize := GetSocketOptions().MaxFrameSize; maxSize > 0 {
+		decoderOptions = append(decoderOptions, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	}
+
+	// both are safe for concurrent EncodeAll and DecodeAll
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, decoderOptions...)
+}
+
+// SetCompression enables compression of response chunks for clients,
+// which send AcceptEncodingHeader. Compressed chunks are marked with
+// ContentEncodingHeader. Incoming compressed chunks are decompressed
+// regardless of this setting.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetCompression(compression Compression) error {
+	for _, encoding := range compression.Encodings {
+		if !isSupportedEncoding(encoding) {
+			return fmt.Errorf("unsupported encoding %s", encoding)
+		}
+	}
+
+	if compression.Threshold <= 0 {
+		compression.Threshold = defaultCompressionThreshold
+	}
+	w.compression = compression
+	return nil
+}
+
+func isSupportedEncoding(encoding string) bool {
+	return encoding == EncodingGzip || encoding == EncodingZstd
+}
+
+// negotiateEncoding returns the preferred encoding accepted by the client
+func (c *Compression) negotiateEncoding(md Metadata) string {
+	if len(c.Encodings) == 0 {
+		return ""
+	}
+
+	accepted := make(map[string]bool)
+	for _, value := range md.Get(AcceptEncodingHeader) {
+		for _, encoding := range strings.Split(value, ",") {
+			// quality values are ignored
+			if semicolon := strings.IndexByte(encoding, ';'); semicolon >= 0 {
+				encoding = encoding[:semicolon]
+			}
+			accepted[strings.ToLower(strings.TrimSpace(encoding))] = true
+		}
+	}
+
+	for _, encoding := range c.Encodings {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func compressChunk(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		zstdOnce.Do(initZstd)
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		gz := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(gz)
+
+		gz.Reset(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %s", encoding)
+	}
+}
+
+// decompressChunk decompresses data unless it exceeds MaxFrameSize
+func decompressChunk(encoding string, data []byte) ([]byte, error) {
+	maxSize := int64(GetSocketOptions().MaxFrameSize)
+
+	switch encoding {
+	case EncodingZstd:
+		zstdOnce.Do(initZstd)
+		decoded, err := zstdDecoder.DecodeAll(data, nil)
+		if err == zstd.ErrDecoderSizeExceeded {
+			return nil, ErrDecompressedTooLarge
+		} else if err != nil {
+			return nil, err
+		}
+		if maxSize > 0 && int64(len(decoded)) > maxSize {
+			return nil, ErrDecompressedTooLarge
+		}
+		return decoded, nil
+	case EncodingGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		var r io.Reader = gz
+		if maxSize > 0 {
+			r = io.LimitReader(gz, maxSize+1)
+		}
+		decoded, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if maxSize > 0 && int64(len(decoded)) > maxSize {
+			return nil, ErrDecompressedTooLarge
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %s", encoding)
+	}
+}
+
+// findHeader returns the value of the first header with the name
+func findHeader(headers CocaineHeaders, name string) ([]byte, bool) {
+	for _, raw := range headers {
+		decoded, err := DefaultHeaderTable.Decode(CocaineHeaders{raw})
+		if err == nil && decoded[0].Name == name {
+			return decoded[0].Value, true
+		}
+	}
+	return nil, false
+}
+
+// contentEncoding returns the value of ContentEncodingHeader if any
+func contentEncoding(headers CocaineHeaders) string {
+	encoding, _ := findHeader(headers, ContentEncodingHeader)
+	return string(encoding)
+}
+
+// decodePayload verifies a chunk of a reply to a Service
+// and replaces it with the decompressed data if it's compressed
+func decodePayload(msg *Message) error {
+	if len(msg.Headers) == 0 || len(msg.Payload) != 1 {
+		return nil
+	}
+
+	data, ok := msg.Payload[0].([]byte)
+	if !ok {
+		return nil
+	}
+
+	decoded, err := decodeChunk(msg, data)
+	if err != nil {
+		return err
+	}
+	msg.Payload[0] = decoded
+	return nil
+}
+
+// decodeChunk returns the data of a chunk verifying its checksum
+// and decompressing it if needed
+func decodeChunk(msg *Message, data []byte) ([]byte, error) {
+	if len(msg.Headers) == 0 {
+		return data, nil
+	}
+
+	if err := verifyChecksum(msg.Headers, data); err != nil {
+		return nil, err
+	}
+
+	encoding := contentEncoding(msg.Headers)
+	if encoding == "" || encoding == "identity" {
+		return data, nil
+	}
+	return decompressChunk(encoding, data)
+}
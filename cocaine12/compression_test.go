@@ -0,0 +1,108 @@
+package cocaine12
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	c := Compression{Encodings: []string{EncodingZstd, EncodingGzip}}
+
+	assert.Equal(t, "", c.negotiateEncoding(nil))
+	assert.Equal(t, "", c.negotiateEncoding(Pairs(AcceptEncodingHeader, "br")))
+	assert.Equal(t, EncodingGzip, c.negotiateEncoding(Pairs(AcceptEncodingHeader, "br, gzip;q=0.5")))
+	assert.Equal(t, EncodingZstd, c.negotiateEncoding(Pairs(AcceptEncodingHeader, "gzip, ZSTD")))
+
+	var disabled Compression
+	assert.Equal(t, "", disabled.negotiateEncoding(Pairs(AcceptEncodingHeader, "gzip")))
+}
+
+func TestCompressChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("cocaine "), 1000)
+	for _, encoding := range []string{EncodingZstd, EncodingGzip} {
+		compressed, err := compressChunk(encoding, data)
+		if !assert.NoError(t, err, encoding) {
+			continue
+		}
+		assert.True(t, len(compressed) < len(data), encoding)
+
+		decompressed, err := decompressChunk(encoding, compressed)
+		assert.NoError(t, err, encoding)
+		assert.Equal(t, data, decompressed, encoding)
+	}
+
+	_, err := compressChunk("br", data)
+	assert.Error(t, err)
+
+	var w WorkerNG
+	assert.Error(t, w.SetCompression(Compression{Encodings: []string{"br"}}))
+	assert.NoError(t, w.SetCompression(Compression{Encodings: []string{EncodingGzip}}))
+	assert.Equal(t, defaultCompressionThreshold, w.compression.Threshold)
+}
+
+func TestDecompressChunkLimit(t *testing.T) {
+	compressed, _ := compressChunk(EncodingGzip, make([]byte, 2048))
+
+	opts := GetSocketOptions()
+	defer SetSocketOptions(opts)
+	limited := opts
+	limited.MaxFrameSize = 1024
+	SetSocketOptions(limited)
+
+	_, err := decompressChunk(EncodingGzip, compressed)
+	assert.Equal(t, ErrDecompressedTooLarge, err)
+}
+
+func TestWorkerCompression(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	w.SetCompression(Compression{Encodings: []string{EncodingZstd, EncodingGzip}, Threshold: 16})
+
+	go w.Run(map[string]EventHandler{
+		"echo": func(ctx context.Context, req Request, res Response) {
+			data, err := req.Read(ctx)
+			if err != nil {
+				res.ErrorMsg(ErrorBadRequest, err.Error())
+				return
+			}
+			res.Write(data)
+			res.Write([]byte("small"))
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	data := bytes.Repeat([]byte("A"), 1000)
+	compressed, _ := compressChunk(EncodingGzip, data)
+
+	invoke := newInvokeV1(2, "echo")
+	invoke.Headers = DefaultHeaderTable.Encode([]Header{{Name: AcceptEncodingHeader, Value: []byte("gzip")}})
+	sock2.Write() <- invoke
+	chunk := newChunkV1(2, compressed)
+	chunk.Headers = DefaultHeaderTable.Encode([]Header{{Name: ContentEncodingHeader, Value: []byte(EncodingGzip)}})
+	sock2.Write() <- chunk
+
+	reply := <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Write)
+	assert.Equal(t, EncodingGzip, contentEncoding(reply.Headers))
+	assert.NoError(t, decodePayload(reply))
+	assert.Equal(t, []interface{}{data}, reply.Payload)
+
+	reply = <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Write)
+	assert.Empty(t, reply.Headers)
+	assert.Equal(t, []interface{}{[]byte("small")}, reply.Payload)
+
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+}
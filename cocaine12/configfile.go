@@ -0,0 +1,114 @@
+package cocaine12
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	configKey   = "COCAINE_CONFIG"
+	appNameKey  = "COCAINE_APP"
+	endpointKey = "COCAINE_ENDPOINT"
+	protocolKey = "COCAINE_PROTOCOL"
+	uuidKey     = "COCAINE_UUID"
+)
+
+// fileValues describes a YAML or JSON configuration file of a worker.
+// Locators can be given either as a list or as a comma separated string.
+type fileValues struct {
+	App      string      `json:"app" yaml:"app"`
+	Endpoint string      `json:"endpoint" yaml:"endpoint"`
+	Locators interface{} `json:"locators" yaml:"locators"`
+	Protocol *int        `json:"protocol" yaml:"protocol"`
+	UUID     string      `json:"uuid" yaml:"uuid"`
+	Debug    *bool       `json:"debug" yaml:"debug"`
+}
+
+// loadConfigFile reads a file and applies it on top of values.
+// The format is chosen by the extension: .json or .yaml/.yml
+func loadConfigFile(path string, values *defaultValues) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file fileValues
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(body, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &file)
+	default:
+		return fmt.Errorf("unsupported config format: %s", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config %s: %v", path, err)
+	}
+
+	if file.App != "" {
+		values.appName = file.App
+	}
+	if file.Endpoint != "" {
+		values.endpoint = file.Endpoint
+	}
+	if file.UUID != "" {
+		values.uuid = file.UUID
+	}
+	if file.Protocol != nil {
+		values.protocol = *file.Protocol
+	}
+	if file.Debug != nil {
+		values.debug = *file.Debug
+	}
+
+	switch locators := file.Locators.(type) {
+	case nil:
+	case string:
+		values.locators = parseLocators(locators)
+	case []interface{}:
+		parsed := make([]string, 0, len(locators))
+		for _, locator := range locators {
+			parsed = append(parsed, fmt.Sprint(locator))
+		}
+		values.locators = parsed
+	default:
+		return fmt.Errorf("unable to parse config %s: invalid locators %v", path, locators)
+	}
+
+	return nil
+}
+
+// loadEnvironment applies COCAINE_* environment variables on top of values
+func loadEnvironment(values *defaultValues) error {
+	if app := os.Getenv(appNameKey); app != "" {
+		values.appName = app
+	}
+	if endpoint := os.Getenv(endpointKey); endpoint != "" {
+		values.endpoint = endpoint
+	}
+	if uuid := os.Getenv(uuidKey); uuid != "" {
+		values.uuid = uuid
+	}
+	if locators := os.Getenv(locatorsKey); locators != "" {
+		values.locators = parseLocators(locators)
+	}
+	if debug := os.Getenv("DEBUG"); debug != "" {
+		values.debug = strings.ToUpper(debug) == "DEBUG"
+	}
+	if protocol := os.Getenv(protocolKey); protocol != "" {
+		version, err := strconv.Atoi(protocol)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", protocolKey, err)
+		}
+		values.protocol = version
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package cocaine12
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats describes traffic of a connection
+type ConnStats struct {
+	BytesRead    uint64
+	BytesWritten uint64
+	// FramesRead and FramesWritten count frames by their message types.
+	// Types depend on the protocol of the connection.
+	FramesRead    map[uint64]uint64
+	FramesWritten map[uint64]uint64
+	// LastRead and LastWrite are zero if nothing has been transferred yet
+	LastRead  time.Time
+	LastWrite time.Time
+}
+
+func (c *wireCounters) snapshot() ConnStats {
+	stats := ConnStats{
+		BytesRead:     atomic.LoadUint64(&c.bytesRead),
+		BytesWritten:  atomic.LoadUint64(&c.bytesWritten),
+		FramesRead:    make(map[uint64]uint64),
+		FramesWritten: make(map[uint64]uint64),
+	}
+
+	if last := atomic.LoadInt64(&c.lastRead); last != 0 {
+		stats.LastRead = time.Unix(0, last)
+	}
+	if last := atomic.LoadInt64(&c.lastWrite); last != 0 {
+		stats.LastWrite = time.Unix(0, last)
+	}
+
+	c.mu.Lock()
+	for msgType, n := range c.framesRead {
+		stats.FramesRead[msgType] = n
+	}
+	for msgType, n := range c.framesWritten {
+		stats.FramesWritten[msgType] = n
+	}
+	c.mu.Unlock()
+
+	return stats
+}
+
+// connStatsOf returns stats of the connection under wrappers of sock
+func connStatsOf(sock socketIO) (ConnStats, bool) {
+	switch s := sock.(type) {
+	case *asyncRWSocket:
+		return s.wire.snapshot(), true
+	case *chaosIO:
+		return connStatsOf(s.socketIO)
+	case *recordingIO:
+		return connStatsOf(s.socketIO)
+	}
+	return ConnStats{}, false
+}
+
+// ConnStats returns stats of the connection to cocaine-runtime
+func (w *WorkerNG) ConnStats() ConnStats {
+	stats, _ := connStatsOf(w.connection())
+	return stats
+}
+
+// ConnStats returns stats of the current connection of the service.
+// They are reset on reconnection.
+func (service *Service) ConnStats() ConnStats {
+	service.mutex.RLock()
+	defer service.mutex.RUnlock()
+
+	stats, _ := connStatsOf(service.socketIO)
+	return stats
+}
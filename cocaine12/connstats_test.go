@@ -0,0 +1,61 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnStats(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	stats, ok := connStatsOf(sock)
+	assert.True(t, ok)
+	assert.True(t, stats.LastRead.IsZero())
+
+	start := time.Now()
+	sock2.Write() <- newInvokeV1(2, "test")
+	sock2.Write() <- newChunkV1(2, []byte("data"))
+	sock2.Write() <- newChokeV1(2)
+	for i := 0; i < 3; i++ {
+		<-sock.Read()
+	}
+
+	stats, _ = connStatsOf(&chaosIO{socketIO: sock})
+	assert.Equal(t, map[uint64]uint64{v1Invoke: 2, v1Close: 1}, stats.FramesRead)
+	assert.Empty(t, stats.FramesWritten)
+	assert.True(t, stats.BytesRead > 0)
+	assert.False(t, stats.LastRead.Before(start.Truncate(time.Millisecond)))
+	assert.True(t, stats.LastWrite.IsZero())
+
+	_, ok = connStatsOf(&testSocket{})
+	assert.False(t, ok)
+}
+
+func TestWorkerConnStats(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+
+	// the frame is counted once it's flushed
+	stats := w.ConnStats()
+	deadline := time.Now().Add(time.Second)
+	for len(stats.FramesWritten) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		stats = w.ConnStats()
+	}
+	assert.Equal(t, uint64(1), stats.FramesWritten[v1Handshake])
+	assert.True(t, stats.BytesWritten > 0)
+	assert.False(t, stats.LastWrite.IsZero())
+}
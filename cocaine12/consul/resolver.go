@@ -0,0 +1,263 @@
+// Package consul provides a cocaine12.Resolver which discovers
+// Cocaine services registered in Consul
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const (
+	// MethodsMeta is a key of service metadata listing methods
+	// of a service separated by commas, e.g. "read,write,find*".
+	// Streaming methods are marked by the `*` suffix.
+	// Services without it are considered as applications.
+	MethodsMeta = "cocaine-methods"
+
+	defaultAddress  = "127.0.0.1:8500"
+	defaultWaitTime = time.Minute * 5
+
+	retryMinDelay = time.Millisecond * 100
+	retryMaxDelay = time.Second * 10
+)
+
+// Config configures Resolver
+type Config struct {
+	// Address of a Consul agent, 127.0.0.1:8500 by default
+	Address string
+	// Datacenter to query, the datacenter of the agent by default
+	Datacenter string
+	// Tag filters service instances
+	Tag string
+	// Token is an ACL token
+	Token string
+	// WaitTime limits a blocking query, 5m by default
+	WaitTime time.Duration
+	// AllowStale allows any Consul server to reply
+	AllowStale bool
+
+	HTTPClient *http.Client
+}
+
+// Resolver resolves services to instances passing health checks.
+// Instances are watched with blocking queries after the first resolve,
+// so later resolves don't go to Consul.
+type Resolver struct {
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	watchers map[string]*watcher
+}
+
+type watcher struct {
+	ready chan struct{}
+
+	mu    sync.RWMutex
+	info  *cocaine.ServiceInfo
+	err   error
+	index uint64
+}
+
+// NewResolver creates a Resolver. Close must be called
+// to stop watching services.
+func NewResolver(config Config) *Resolver {
+	if config.Address == "" {
+		config.Address = defaultAddress
+	}
+
+	if config.WaitTime <= 0 {
+		config.WaitTime = defaultWaitTime
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Resolver{
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+		watchers: make(map[string]*watcher),
+	}
+}
+
+// Resolve returns healthy instances of the service.
+// The first call blocks until Consul replies.
+func (r *Resolver) Resolve(ctx context.Context, name string) (*cocaine.ServiceInfo, error) {
+	r.mu.Lock()
+	w, ok := r.watchers[name]
+	if !ok {
+		w = &watcher{ready: make(chan struct{})}
+		r.watchers[name] = w
+		go r.watch(name, w)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.info, w.err
+}
+
+// Close stops watching services
+func (r *Resolver) Close() {
+	r.cancel()
+}
+
+func (r *Resolver) watch(name string, w *watcher) {
+	var (
+		delay = retryMinDelay
+		once  sync.Once
+	)
+
+	for {
+		entries, index, err := r.query(r.ctx, name, w.index)
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		w.mu.Lock()
+		switch {
+		case err == nil:
+			// no healthy instances is a result rather than a failure,
+			// so the last known instances are dropped
+			w.info, w.err = serviceInfo(name, entries)
+			// Consul asks to reset an index going backwards
+			if index < w.index {
+				index = 0
+			}
+			w.index = index
+		case w.info == nil:
+			// the last known instances are kept on failures
+			w.err = err
+		}
+		w.mu.Unlock()
+		once.Do(func() { close(w.ready) })
+
+		if err == nil {
+			// blocking queries might return early,
+			// so they are rate limited anyway
+			delay = retryMinDelay
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-r.ctx.Done():
+			return
+		}
+
+		if err == nil {
+			continue
+		}
+
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+type healthEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+}
+
+// query returns healthy instances of the service and the index of the reply
+func (r *Resolver) query(ctx context.Context, name string, index uint64) ([]healthEntry, uint64, error) {
+	params := url.Values{"passing": {"true"}}
+	if r.config.Datacenter != "" {
+		params.Set("dc", r.config.Datacenter)
+	}
+	if r.config.Tag != "" {
+		params.Set("tag", r.config.Tag)
+	}
+	if r.config.AllowStale {
+		params.Set("stale", "")
+	}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%dms", r.config.WaitTime/time.Millisecond))
+	}
+
+	endpoint := url.URL{
+		Scheme:   "http",
+		Host:     r.config.Address,
+		Path:     "/v1/health/service/" + name,
+		RawQuery: params.Encode(),
+	}
+
+	req, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.config.Token != "" {
+		req.Header.Set("X-Consul-Token", r.config.Token)
+	}
+
+	resp, err := ctxhttp.Do(ctx, r.config.HTTPClient, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul replied %s", resp.Status)
+	}
+
+	var entries []healthEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, err
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return entries, newIndex, nil
+}
+
+func serviceInfo(name string, entries []healthEntry) (*cocaine.ServiceInfo, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("service %s has no healthy instances", name)
+	}
+
+	var (
+		endpoints = make([]string, 0, len(entries))
+		methods   []cocaine.Method
+	)
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		endpoints = append(endpoints, net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)))
+
+		if methods == nil {
+			methods = cocaine.ParseMethods(strings.Split(entry.Service.Meta[MethodsMeta], ",")...)
+		}
+	}
+
+	return cocaine.NewServiceInfo(endpoints, methods...)
+}
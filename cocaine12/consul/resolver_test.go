@@ -0,0 +1,143 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   int
+	port    int
+	changed chan struct{}
+	queries chan string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case f.queries <- r.URL.String():
+	default:
+	}
+
+	if r.URL.Query().Get("index") != "" {
+		select {
+		case <-f.changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	index, port := f.index, f.port
+	f.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", fmt.Sprint(index))
+	if port == 0 {
+		// all instances are gone
+		w.Write([]byte("[]"))
+		return
+	}
+	fmt.Fprintf(w, `[{
+		"Node": {"Address": "10.0.0.1"},
+		"Service": {"Address": "", "Port": %d, "Meta": {"cocaine-methods": "read, find*"}}
+	}]`, port)
+}
+
+func TestResolver(t *testing.T) {
+	consul := &fakeConsul{
+		index:   10,
+		port:    10054,
+		changed: make(chan struct{}),
+		queries: make(chan string, 10),
+	}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	resolver := NewResolver(Config{
+		Address: strings.TrimPrefix(server.URL, "http://"),
+		Tag:     "cocaine",
+	})
+	defer resolver.Close()
+
+	info, err := resolver.Resolve(context.Background(), "storage")
+	assert.NoError(t, err)
+	assert.Equal(t, []cocaine.EndpointItem{{IP: "10.0.0.1", Port: 10054}}, info.Endpoints)
+	_, err = info.API.MethodByName("find")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/v1/health/service/storage?passing=true&tag=cocaine", <-consul.queries)
+	assert.Contains(t, <-consul.queries, "index=10")
+
+	consul.mu.Lock()
+	consul.index, consul.port = 11, 10055
+	consul.mu.Unlock()
+	close(consul.changed)
+
+	deadline := time.After(time.Second)
+	for {
+		info, err = resolver.Resolve(context.Background(), "storage")
+		assert.NoError(t, err)
+		if info.Endpoints[0].Port == 10055 {
+			break
+		}
+
+		select {
+		case <-deadline:
+			t.Fatal("the update has not been received")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestResolverFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	resolver := NewResolver(Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	defer resolver.Close()
+
+	_, err := resolver.Resolve(context.Background(), "storage")
+	assert.EqualError(t, err, "service storage has no healthy instances")
+}
+
+func TestResolverInstancesGone(t *testing.T) {
+	consul := &fakeConsul{
+		index:   10,
+		port:    10054,
+		changed: make(chan struct{}),
+		queries: make(chan string, 10),
+	}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	resolver := NewResolver(Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	defer resolver.Close()
+
+	_, err := resolver.Resolve(context.Background(), "storage")
+	assert.NoError(t, err)
+	<-consul.queries
+	<-consul.queries
+
+	consul.mu.Lock()
+	consul.index, consul.port = 11, 0
+	consul.mu.Unlock()
+	close(consul.changed)
+
+	// the index of the empty reply is used by the next query
+	assert.Contains(t, <-consul.queries, "index=11")
+	info, err := resolver.Resolve(context.Background(), "storage")
+	assert.Nil(t, info)
+	assert.EqualError(t, err, "service storage has no healthy instances")
+}
@@ -0,0 +1,164 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// TVMServiceTicketHeader carries a TVM2 service ticket
+	TVMServiceTicketHeader = "x-ya-service-ticket"
+
+	defaultTicketRefreshBefore = time.Minute
+)
+
+// ErrNoTicket means that a TicketFetcher has returned an empty ticket
+var ErrNoTicket = errors.New("empty ticket")
+
+// CredentialsProvider returns authorization headers for an outgoing
+// call to the service. It's invoked for every call, so implementations
+// are expected to cache credentials.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context, service string) ([]Header, error)
+}
+
+// CredentialsFunc is an adapter to use ordinary functions as CredentialsProvider
+type CredentialsFunc func(ctx context.Context, service string) ([]Header, error)
+
+// Credentials calls f(ctx, service)
+func (f CredentialsFunc) Credentials(ctx context.Context, service string) ([]Header, error) {
+	return f(ctx, service)
+}
+
+var (
+	credentialsMu       sync.RWMutex
+	credentialsProvider CredentialsProvider
+)
+
+// SetCredentialsProvider sets the provider used by services
+// which don't have their own one. Nil disables it.
+func SetCredentialsProvider(provider CredentialsProvider) {
+	credentialsMu.Lock()
+	credentialsProvider = provider
+	credentialsMu.Unlock()
+}
+
+func getCredentialsProvider() CredentialsProvider {
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+	return credentialsProvider
+}
+
+// TicketFetcher obtains a fresh ticket for the service, e.g. from tvmtool
+type TicketFetcher func(ctx context.Context, service string) (ticket string, expires time.Time, err error)
+
+// TicketProvider is a CredentialsProvider which caches tickets per service.
+// A ticket is refreshed in the background when it's about to expire.
+// If the refresh fails, the cached ticket is used until it expires.
+type TicketProvider struct {
+	header        string
+	fetch         TicketFetcher
+	refreshBefore time.Duration
+	clock         Clock
+
+	mu      sync.Mutex
+	tickets map[string]*cachedTicket
+}
+
+type cachedTicket struct {
+	// serializes synchronous fetches
+	sync.Mutex
+
+	ticket     string
+	expires    time.Time
+	refreshing bool
+}
+
+// NewTicketProvider creates a provider which sends tickets in the header
+func NewTicketProvider(header string, fetch TicketFetcher) *TicketProvider {
+	return &TicketProvider{
+		header:        header,
+		fetch:         fetch,
+		refreshBefore: defaultTicketRefreshBefore,
+		clock:         realClock{},
+		tickets:       make(map[string]*cachedTicket),
+	}
+}
+
+// NewTVMProvider creates a provider of TVM2 service tickets
+func NewTVMProvider(fetch TicketFetcher) *TicketProvider {
+	return NewTicketProvider(TVMServiceTicketHeader, fetch)
+}
+
+// SetRefreshBefore sets how long before the expiration a ticket is refreshed
+func (p *TicketProvider) SetRefreshBefore(d time.Duration) {
+	p.mu.Lock()
+	p.refreshBefore = d
+	p.mu.Unlock()
+}
+
+// Credentials returns the cached ticket of the service fetching it if needed
+func (p *TicketProvider) Credentials(ctx context.Context, service string) ([]Header, error) {
+	p.mu.Lock()
+	entry, ok := p.tickets[service]
+	if !ok {
+		entry = new(cachedTicket)
+		p.tickets[service] = entry
+	}
+	refreshBefore := p.refreshBefore
+	p.mu.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	now := p.clock.Now()
+	if entry.ticket == "" || !now.Before(entry.expires) {
+		if err := p.refresh(ctx, service, entry); err != nil {
+			return nil, err
+		}
+	} else if !entry.refreshing && !now.Before(entry.expires.Add(-refreshBefore)) {
+		entry.refreshing = true
+		go p.refreshAsync(service, entry)
+	}
+
+	return []Header{{Name: p.header, Value: []byte(entry.ticket), Sensitive: true}}, nil
+}
+
+// Invalidate drops the cached ticket of the service,
+// e.g. after the service has rejected it
+func (p *TicketProvider) Invalidate(service string) {
+	p.mu.Lock()
+	delete(p.tickets, service)
+	p.mu.Unlock()
+}
+
+// refresh must be called with the entry locked
+func (p *TicketProvider) refresh(ctx context.Context, service string, entry *cachedTicket) error {
+	ticket, expires, err := p.fetch(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	if ticket == "" {
+		return ErrNoTicket
+	}
+
+	entry.ticket, entry.expires = ticket, expires
+	return nil
+}
+
+func (p *TicketProvider) refreshAsync(service string, entry *cachedTicket) {
+	ticket, expires, err := p.fetch(context.Background(), service)
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	entry.refreshing = false
+	// keep the current ticket on failures, it's still valid
+	if err == nil && ticket != "" {
+		entry.ticket, entry.expires = ticket, expires
+	}
+}
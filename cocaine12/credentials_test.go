@@ -0,0 +1,110 @@
+package cocaine12
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTicketProvider(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches = 0
+		fail    = false
+		start   = time.Unix(1000, 0)
+		clock   = NewManualClock(start)
+	)
+
+	provider := NewTVMProvider(func(ctx context.Context, service string) (string, time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return "", time.Time{}, errors.New("tvm is down")
+		}
+
+		fetches++
+		return fmt.Sprintf("%s-%d", service, fetches), clock.Now().Add(10 * time.Minute), nil
+	})
+	provider.clock = clock
+
+	ticket := func() string {
+		headers, err := provider.Credentials(context.Background(), "storage")
+		if !assert.NoError(t, err) || !assert.Len(t, headers, 1) {
+			return ""
+		}
+		assert.Equal(t, TVMServiceTicketHeader, headers[0].Name)
+		assert.True(t, headers[0].Sensitive)
+		return string(headers[0].Value)
+	}
+
+	assert.Equal(t, "storage-1", ticket())
+	assert.Equal(t, "storage-1", ticket())
+
+	// close to the expiration the cached ticket is returned
+	// while a new one is being fetched
+	clock.Advance(9*time.Minute + 30*time.Second)
+	assert.Equal(t, "storage-1", ticket())
+	waitTicketRefresh(provider, "storage")
+	assert.Equal(t, "storage-2", ticket())
+
+	// failed refreshes keep the valid ticket
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	clock.Advance(9*time.Minute + 30*time.Second)
+	assert.Equal(t, "storage-2", ticket())
+	waitTicketRefresh(provider, "storage")
+	assert.Equal(t, "storage-2", ticket())
+	waitTicketRefresh(provider, "storage")
+
+	// the expired ticket is never returned
+	clock.Advance(time.Minute)
+	_, err := provider.Credentials(context.Background(), "storage")
+	assert.Error(t, err)
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	assert.Equal(t, "storage-3", ticket())
+
+	provider.Invalidate("storage")
+	assert.Equal(t, "storage-4", ticket())
+}
+
+func waitTicketRefresh(p *TicketProvider, service string) {
+	p.mu.Lock()
+	entry := p.tickets[service]
+	p.mu.Unlock()
+
+	for {
+		entry.Lock()
+		refreshing := entry.refreshing
+		entry.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCredentialsHeaders(t *testing.T) {
+	provider := CredentialsFunc(func(ctx context.Context, service string) ([]Header, error) {
+		return []Header{{Name: TVMServiceTicketHeader, Value: []byte("ticket")}}, nil
+	})
+
+	headers, err := provider.Credentials(context.Background(), "storage")
+	assert.NoError(t, err)
+
+	encoded := DefaultHeaderTable.Encode(headers)
+	assert.Contains(t, formatHeaders(encoded), redactedValue)
+
+	decoded, err := DefaultHeaderTable.Decode(encoded)
+	assert.NoError(t, err)
+	assert.True(t, decoded[0].Sensitive)
+	assert.Equal(t, []byte("ticket"), decoded[0].Value)
+}
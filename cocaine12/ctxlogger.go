@@ -0,0 +1,55 @@
+package cocaine12
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	EventNameValue = "worker.event"
+	LoggerValue    = "logger.logger"
+)
+
+// EventFromContext returns the name of the event handled with ctx
+func EventFromContext(ctx context.Context) (string, bool) {
+	event, ok := ctx.Value(EventNameValue).(string)
+	return event, ok
+}
+
+func withEventName(ctx context.Context, event string) context.Context {
+	return context.WithValue(ctx, EventNameValue, event)
+}
+
+// NewContextWithLogger attaches the logger to be returned by LoggerFromContext
+func NewContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, LoggerValue, logger)
+}
+
+// LoggerFromContext returns a logger which adds trace_id, span_id
+// and the event name of the request handled with ctx to every entry,
+// so entries written by a handler are correlated with its request.
+// It's based on the logger attached by NewContextWithLogger,
+// the logger of the trace or the shared one, so it must not be closed.
+func LoggerFromContext(ctx context.Context) Logger {
+	traceInfo := getTraceInfo(ctx)
+
+	logger, ok := ctx.Value(LoggerValue).(Logger)
+	switch {
+	case ok:
+	case traceInfo != nil:
+		logger = traceInfo.getLog()
+	default:
+		logger = getTraceLogger()
+	}
+
+	fields := make(Fields, 3)
+	if traceInfo != nil {
+		fields["trace_id"] = fmt.Sprintf("%x", traceInfo.trace)
+		fields["span_id"] = fmt.Sprintf("%x", traceInfo.span)
+	}
+	if event, ok := EventFromContext(ctx); ok {
+		fields["event"] = event
+	}
+	return logger.WithFields(fields)
+}
@@ -0,0 +1,55 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	logger, _ := newFallbackLogger()
+	ctx := NewContextWithLogger(context.Background(), logger)
+	ctx = AttachTraceInfo(ctx, NewTraceInfo(0xabc, 0xdef, 0x1))
+	ctx = withEventName(ctx, "echo")
+
+	entry, ok := LoggerFromContext(ctx).(*Entry)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, logger, entry.Logger)
+	assert.Equal(t, Fields{"trace_id": "abc", "span_id": "def", "event": "echo"}, entry.Fields)
+
+	entry = LoggerFromContext(NewContextWithLogger(context.Background(), logger)).(*Entry)
+	assert.Empty(t, entry.Fields)
+}
+
+func TestWorkerEventInContext(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	go w.Run(map[string]EventHandler{
+		"echo": func(ctx context.Context, req Request, res Response) {
+			event, _ := EventFromContext(ctx)
+			res.Write([]byte(event))
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "echo")
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, 2, v1Write)
+
+	var data []interface{}
+	assert.NoError(t, convertPayload(msg.Payload, &data))
+	assert.Equal(t, []interface{}{[]byte("echo")}, data)
+}
@@ -0,0 +1,74 @@
+package cocaine12
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TimeoutHeader carries the number of milliseconds the caller waits
+// for the reply. Service sends it if the context of a call has
+// a deadline, the worker sets the deadline of the handler context from it.
+const TimeoutHeader = "x-request-timeout"
+
+// Deadline returns the time the caller stops waiting for the reply.
+// ok is false if there is no deadline
+func Deadline(ctx context.Context) (deadline time.Time, ok bool) {
+	return ctx.Deadline()
+}
+
+// Remaining returns the time left before the deadline minus the margin,
+// which covers the latency of the reply. It's zero if the budget
+// is exhausted, so the handler should stop working.
+// ok is false if there is no deadline
+func Remaining(ctx context.Context, margin time.Duration) (remaining time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	if remaining = deadline.Sub(time.Now()) - margin; remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// WithReducedDeadline returns a context for downstream calls,
+// which expires the margin earlier than ctx, so there is time left
+// to handle their result. ctx is returned as is if it has no deadline.
+func WithReducedDeadline(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// timeoutHeader returns TimeoutHeader for the deadline of ctx
+func timeoutHeader(ctx context.Context) (Header, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return Header{}, false
+	}
+
+	timeout := deadline.Sub(time.Now()) / time.Millisecond
+	if timeout < 1 {
+		timeout = 1
+	}
+	return Header{Name: TimeoutHeader, Value: []byte(strconv.FormatInt(int64(timeout), 10))}, true
+}
+
+// requestTimeout parses TimeoutHeader of an incoming request
+func requestTimeout(md Metadata) (time.Duration, bool) {
+	values := md.Get(TimeoutHeader)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
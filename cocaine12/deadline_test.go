@@ -0,0 +1,83 @@
+package cocaine12
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRemaining(t *testing.T) {
+	_, ok := Remaining(context.Background(), time.Second)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	remaining, ok := Remaining(ctx, 10*time.Second)
+	assert.True(t, ok)
+	assert.True(t, remaining > 49*time.Second && remaining <= 50*time.Second)
+
+	remaining, _ = Remaining(ctx, time.Hour)
+	assert.Equal(t, time.Duration(0), remaining)
+
+	reduced, cancelReduced := WithReducedDeadline(ctx, 10*time.Second)
+	defer cancelReduced()
+	deadline, _ := Deadline(ctx)
+	reducedDeadline, _ := Deadline(reduced)
+	assert.Equal(t, deadline.Add(-10*time.Second), reducedDeadline)
+}
+
+func TestServiceSendsTimeout(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// a stale value of the incoming request is replaced
+	ctx = NewOutgoingContext(ctx, Pairs(TimeoutHeader, "60000"))
+
+	if _, err := service.Call(ctx, "ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	md := metadataFromHeaders((<-peer.Read()).Headers)
+	if assert.Len(t, md.Get(TimeoutHeader), 1) {
+		timeout, err := strconv.Atoi(md.Get(TimeoutHeader)[0])
+		assert.NoError(t, err)
+		assert.True(t, timeout > 0 && timeout <= 1000)
+	}
+}
+
+func TestWorkerAppliesTimeout(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	remaining := make(chan time.Duration, 1)
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			r, _ := Remaining(ctx, 0)
+			remaining <- r
+			res.Close()
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	invoke := newInvokeV1(2, "test")
+	invoke.Headers = DefaultHeaderTable.Encode([]Header{{Name: TimeoutHeader, Value: []byte("500")}})
+	sock2.Write() <- invoke
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	r := <-remaining
+	assert.True(t, r > 0 && r <= 500*time.Millisecond)
+}
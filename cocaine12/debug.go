@@ -0,0 +1,97 @@
+package cocaine12
+
+import (
+	"bufio"
+	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// DebugEvent is the name of the built-in debug event
+	DebugEvent = "_debug"
+
+	debugBufferSize       = 64 * 1024
+	defaultCPUProfileTime = 30 * time.Second
+)
+
+// debugHandler serves runtime information. The first chunk
+// of a request is a command with optional arguments separated by spaces:
+//
+//	goroutine|heap|allocs|threadcreate|block|mutex [debug] - pprof profile
+//	profile [seconds] - CPU profile
+//	stack - stacks of all goroutines in the text form
+//	events - names of registered events
+func debugHandler(handlers *EventHandlers) EventHandler {
+	return func(ctx context.Context, request Request, response Response) {
+		data, err := request.Read(ctx)
+		if err != nil {
+			response.ErrorMsg(ErrorBadRequest, fmt.Sprintf("unable to read a debug command: %v", err))
+			return
+		}
+
+		args := strings.Fields(string(data))
+		if len(args) == 0 {
+			response.ErrorMsg(ErrorBadRequest, "empty debug command")
+			return
+		}
+
+		// pprof writes a lot of tiny chunks
+		w := bufio.NewWriterSize(response, debugBufferSize)
+
+		switch command := args[0]; command {
+		case "profile":
+			duration := defaultCPUProfileTime
+			if len(args) > 1 {
+				seconds, err := strconv.Atoi(args[1])
+				if err != nil || seconds <= 0 {
+					response.ErrorMsg(ErrorBadRequest, fmt.Sprintf("invalid duration %s", args[1]))
+					return
+				}
+				duration = time.Duration(seconds) * time.Second
+			}
+
+			if err := pprof.StartCPUProfile(w); err != nil {
+				response.ErrorMsg(ErrorBadRequest, err.Error())
+				return
+			}
+
+			select {
+			case <-time.After(duration):
+			case <-ctx.Done():
+			}
+			pprof.StopCPUProfile()
+
+		case "stack":
+			w.Write(dumpStack())
+
+		case "events":
+			for _, event := range handlers.eventNames() {
+				fmt.Fprintln(w, event)
+			}
+
+		default:
+			profile := pprof.Lookup(command)
+			if profile == nil {
+				response.ErrorMsg(ErrorBadRequest, fmt.Sprintf("unknown debug command %s", command))
+				return
+			}
+
+			debug := 0
+			if len(args) > 1 {
+				debug, _ = strconv.Atoi(args[1])
+			}
+
+			if err := profile.WriteTo(w, debug); err != nil {
+				response.ErrorMsg(ErrorBadRequest, err.Error())
+				return
+			}
+		}
+
+		w.Flush()
+	}
+}
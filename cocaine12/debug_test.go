@@ -0,0 +1,61 @@
+package cocaine12
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type testResponse struct {
+	bytes.Buffer
+	code    int
+	message string
+	closed  bool
+}
+
+func (r *testResponse) ZeroCopyWrite(data []byte) error {
+	_, err := r.Write(data)
+	return err
+}
+
+func (r *testResponse) ErrorMsg(code int, message string) error {
+	r.code, r.message = code, message
+	return r.Close()
+}
+
+func (r *testResponse) Close() error {
+	r.closed = true
+	return nil
+}
+
+type testRequest [][]byte
+
+func (r *testRequest) Read(ctx context.Context) ([]byte, error) {
+	if len(*r) == 0 {
+		return nil, ErrStreamIsClosed
+	}
+	chunk := (*r)[0]
+	*r = (*r)[1:]
+	return chunk, nil
+}
+
+func TestDebugHandler(t *testing.T) {
+	handlers := NewEventHandlers()
+	handlers.On("echo", nil)
+	handlers.On(DebugEvent, debugHandler(handlers))
+	ctx := context.Background()
+
+	res := new(testResponse)
+	handlers.Call(ctx, DebugEvent, &testRequest{[]byte("events")}, res)
+	assert.Equal(t, DebugEvent+"\necho\n", res.String())
+
+	res = new(testResponse)
+	handlers.Call(ctx, DebugEvent, &testRequest{[]byte("goroutine 1")}, res)
+	assert.Contains(t, res.String(), "goroutine profile")
+
+	res = new(testResponse)
+	handlers.Call(ctx, DebugEvent, &testRequest{[]byte("unknown")}, res)
+	assert.Equal(t, ErrorBadRequest, res.code)
+}
@@ -1,12 +1,15 @@
 package cocaine12
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
 	"sync"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -14,6 +17,7 @@ const (
 	defaultLocatorEndpoint = "localhost:10053"
 	tokenTypeKey           = "COCAINE_APP_TOKEN_TYPE"
 	tokenBodyKey           = "COCAINE_APP_TOKEN_BODY"
+	locatorsKey            = "COCAINE_LOCATORS"
 )
 
 type defaultValues struct {
@@ -31,7 +35,14 @@ func (d *defaultValues) ApplicationName() string {
 }
 
 func (d *defaultValues) Endpoint() string {
-	return d.endpoint
+	if endpoints := d.Endpoints(); len(endpoints) > 0 {
+		return endpoints[0]
+	}
+	return ""
+}
+
+func (d *defaultValues) Endpoints() []string {
+	return splitEndpoints(d.endpoint)
 }
 
 func (d *defaultValues) Debug() bool {
@@ -65,7 +76,11 @@ func (d *defaultValues) Token() Token {
 type DefaultValues interface {
 	ApplicationName() string
 	Debug() bool
+	// Endpoint returns the first of Endpoints
 	Endpoint() string
+	// Endpoints returns endpoints of cocaine-runtime. Several ones
+	// are passed comma separated, e.g. "[::1]:10054,127.0.0.1:10054"
+	Endpoints() []string
 	Locators() []string
 	Protocol() int
 	UUID() string
@@ -73,23 +88,97 @@ type DefaultValues interface {
 	Token() Token
 }
 
-var (
-	initDefaultValues sync.Once
-	storedDefaults    DefaultValues
+const (
+	// DefaultValuesValue is the context key of DefaultValues
+	DefaultValuesValue = "cocaine.defaults"
+)
 
-	parseDefaultValues = func() {
-		storedDefaults = newDefaults(os.Args[1:], "cocaine")
-	}
+// ErrShowVersion is returned by ParseArgs if --showcocaineversion is passed.
+// The program is expected to print FrameworkVersion and exit.
+var ErrShowVersion = errors.New("the framework version is requested")
+
+var (
+	defaultsMu     sync.Mutex
+	storedDefaults DefaultValues
+	// storedDefaults are set by SetDefaults or taken from os.Args by a worker
+	defaultsSet bool
 )
 
-// GetDefaults returns DefaultValues
+// GetDefaults returns DefaultValues set by SetDefaults.
+// If nothing has been set, values are built lazily from
+// a config file and environment variables. Command line flags
+// are never read here: use ParseArgs and SetDefaults to apply them.
 func GetDefaults() DefaultValues {
-	// lazy init
-	initDefaultValues.Do(parseDefaultValues)
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	if storedDefaults == nil {
+		storedDefaults = newDefaults(nil, "cocaine")
+	}
 
 	return storedDefaults
 }
 
+// SetDefaults replaces DefaultValues used by the package.
+// It should be called before workers and services are created.
+func SetDefaults(values DefaultValues) {
+	defaultsMu.Lock()
+	storedDefaults, defaultsSet = values, true
+	defaultsMu.Unlock()
+}
+
+// ParseArgs builds DefaultValues from the given arguments
+// on top of a config file and environment variables.
+// Only cocaine flags are taken from args, the rest are skipped.
+// It returns ErrShowVersion if --showcocaineversion is among them.
+func ParseArgs(args []string) (DefaultValues, error) {
+	return parseDefaults(args, "cocaine")
+}
+
+// FrameworkVersion returns the version of the framework
+func FrameworkVersion() string {
+	return frameworkVersion
+}
+
+// workerDefaults returns DefaultValues for a worker spawned by
+// cocaine-runtime, which passes them as flags. Unless SetDefaults
+// has been called, they are parsed from os.Args once.
+func workerDefaults() (DefaultValues, error) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	if defaultsSet {
+		return storedDefaults, nil
+	}
+
+	values, err := parseDefaults(os.Args[1:], "cocaine")
+	switch err {
+	case nil:
+	case ErrShowVersion:
+		fmt.Fprintf(os.Stderr, "Built with Cocaine framework %s\n", frameworkVersion)
+		return nil, err
+	default:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+
+	storedDefaults, defaultsSet = values, true
+	return storedDefaults, nil
+}
+
+// WithDefaults attaches DefaultValues to the context
+func WithDefaults(ctx context.Context, values DefaultValues) context.Context {
+	return context.WithValue(ctx, DefaultValuesValue, values)
+}
+
+// FromContext returns DefaultValues attached to the context
+// or the package ones
+func FromContext(ctx context.Context) DefaultValues {
+	if values, ok := ctx.Value(DefaultValuesValue).(DefaultValues); ok {
+		return values
+	}
+	return GetDefaults()
+}
+
 type locatorsType []string
 
 func (l *locatorsType) Set(value string) error {
@@ -109,32 +198,127 @@ func parseLocators(arg string) []string {
 	return strings.Split(arg, ",")
 }
 
+// cocaineFlags maps names of flags of the framework
+// to whether they are boolean
+var cocaineFlags = map[string]bool{
+	"app":                false,
+	"endpoint":           false,
+	"locator":            false,
+	"protocol":           false,
+	"uuid":               false,
+	"config":             false,
+	"showcocaineversion": true,
+}
+
+// filterArgs keeps only cocaine flags and their values
+func filterArgs(args []string) []string {
+	var filtered []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		hasValue := strings.IndexRune(name, '=') != -1
+		if hasValue {
+			name = name[:strings.IndexRune(name, '=')]
+		}
+
+		isBool, known := cocaineFlags[name]
+		if !known {
+			continue
+		}
+
+		filtered = append(filtered, arg)
+		if !isBool && !hasValue && i+1 < len(args) {
+			i++
+			filtered = append(filtered, args[i])
+		}
+	}
+
+	return filtered
+}
+
 func newDefaults(args []string, setname string) *defaultValues {
+	values, err := parseDefaults(args, setname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	return values
+}
+
+// parseDefaults builds values from several sources.
+// Each next source overrides the previous one:
+// built-in defaults, a config file, environment variables, flags.
+// The config file is set by --config flag or COCAINE_CONFIG variable.
+// Values are returned even if some source is invalid.
+func parseDefaults(args []string, setname string) (*defaultValues, error) {
 	var (
-		values = new(defaultValues)
+		values  = new(defaultValues)
+		flagged = new(defaultValues)
 
+		configPath  string
 		showVersion bool
-	)
 
-	values.locators = []string{defaultLocatorEndpoint}
-	values.debug = strings.ToUpper(os.Getenv("DEBUG")) == "DEBUG"
+		errs []string
+	)
 
 	flagSet := flag.NewFlagSet(setname, flag.ContinueOnError)
 	flagSet.SetOutput(ioutil.Discard)
-	flagSet.StringVar(&values.appName, "app", "gostandalone", "application name")
-	flagSet.StringVar(&values.endpoint, "endpoint", "", "unix socket path to connect to the Cocaine")
-	flagSet.Var(&values.locators, "locator", "default endpoints of locators")
-	flagSet.IntVar(&values.protocol, "protocol", defaultProtocolVersion, "protocol version")
-	flagSet.StringVar(&values.uuid, "uuid", "", "UUID")
+	flagSet.StringVar(&flagged.appName, "app", "", "application name")
+	flagSet.StringVar(&flagged.endpoint, "endpoint", "", "unix socket path or comma separated endpoints to connect to the Cocaine")
+	flagSet.Var(&flagged.locators, "locator", "default endpoints of locators")
+	flagSet.IntVar(&flagged.protocol, "protocol", defaultProtocolVersion, "protocol version")
+	flagSet.StringVar(&flagged.uuid, "uuid", "", "UUID")
+	flagSet.StringVar(&configPath, "config", os.Getenv(configKey), "path to YAML or JSON config")
 	flagSet.BoolVar(&showVersion, "showcocaineversion", false, "print framework version")
-	flagSet.Parse(args)
+	if err := flagSet.Parse(filterArgs(args)); err != nil {
+		errs = append(errs, fmt.Sprintf("unable to parse args: %v", err))
+	}
+
+	values.appName = "gostandalone"
+	values.locators = []string{defaultLocatorEndpoint}
+	values.protocol = defaultProtocolVersion
+
+	if configPath != "" {
+		if err := loadConfigFile(configPath, values); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to load config: %v", err))
+		}
+	}
+
+	if err := loadEnvironment(values); err != nil {
+		errs = append(errs, fmt.Sprintf("unable to load environment: %v", err))
+	}
+
+	flagSet.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "app":
+			values.appName = flagged.appName
+		case "endpoint":
+			values.endpoint = flagged.endpoint
+		case "locator":
+			values.locators = flagged.locators
+		case "protocol":
+			values.protocol = flagged.protocol
+		case "uuid":
+			values.uuid = flagged.uuid
+		}
+	})
 
 	values.token = Token{os.Getenv(tokenTypeKey), os.Getenv(tokenBodyKey)}
 
 	if showVersion {
-		fmt.Fprintf(os.Stderr, "Built with Cocaine framework %s\n", frameworkVersion)
-		os.Exit(0)
+		return values, ErrShowVersion
 	}
 
-	return values
+	if len(errs) > 0 {
+		return values, errors.New(strings.Join(errs, "; "))
+	}
+
+	return values, nil
 }
@@ -1,10 +1,13 @@
 package cocaine12
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestParseLocators(t *testing.T) {
@@ -44,3 +47,113 @@ func TestParseToken(t *testing.T) {
 	assert.Equal(t, "TVM", def.Token().Type(), "invalid token type")
 	assert.Equal(t, "very_secret", def.Token().Body(), "invalid token body")
 }
+
+func TestParseLocatorsFromEnv(t *testing.T) {
+	os.Setenv("COCAINE_LOCATORS", "host1:10053,host2:10053")
+	defer os.Unsetenv("COCAINE_LOCATORS")
+
+	def := newDefaults([]string{}, "test")
+	assert.Equal(t, []string{"host1:10053", "host2:10053"}, def.Locators(), "invalid locators")
+
+	def = newDefaults([]string{"--locator", "host3:10053"}, "test")
+	assert.Equal(t, []string{"host3:10053"}, def.Locators(), "flag must override env")
+}
+
+func TestParseEnvironment(t *testing.T) {
+	os.Setenv("COCAINE_APP", "envapp")
+	os.Setenv("COCAINE_UUID", "envuuid")
+	os.Setenv("COCAINE_ENDPOINT", "/run/env.sock")
+	os.Setenv("COCAINE_PROTOCOL", "1")
+	defer func() {
+		for _, key := range []string{"COCAINE_APP", "COCAINE_UUID", "COCAINE_ENDPOINT", "COCAINE_PROTOCOL"} {
+			os.Unsetenv(key)
+		}
+	}()
+
+	def := newDefaults([]string{"--uuid", "flaguuid"}, "test")
+	assert.Equal(t, "envapp", def.ApplicationName())
+	assert.Equal(t, "flaguuid", def.UUID(), "flag must override env")
+	assert.Equal(t, "/run/env.sock", def.Endpoint())
+	assert.Equal(t, 1, def.Protocol())
+}
+
+func TestParseConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cocaine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlPath := filepath.Join(dir, "worker.yaml")
+	ioutil.WriteFile(yamlPath, []byte("app: yamlapp\nprotocol: 1\nlocators:\n  - host1:10053\n  - host2:10053\n"), 0644)
+
+	def := newDefaults([]string{"--config", yamlPath}, "test")
+	assert.Equal(t, "yamlapp", def.ApplicationName())
+	assert.Equal(t, 1, def.Protocol())
+	assert.Equal(t, []string{"host1:10053", "host2:10053"}, def.Locators())
+
+	jsonPath := filepath.Join(dir, "worker.json")
+	ioutil.WriteFile(jsonPath, []byte(`{"app": "jsonapp", "locators": "host3:10053,host4:10053"}`), 0644)
+
+	os.Setenv("COCAINE_CONFIG", jsonPath)
+	os.Setenv("COCAINE_APP", "envapp")
+	defer os.Unsetenv("COCAINE_CONFIG")
+	defer os.Unsetenv("COCAINE_APP")
+
+	def = newDefaults([]string{}, "test")
+	assert.Equal(t, "envapp", def.ApplicationName(), "env must override file")
+	assert.Equal(t, 0, def.Protocol())
+	assert.Equal(t, []string{"host3:10053", "host4:10053"}, def.Locators())
+}
+
+func TestParseArgsSkipsForeignFlags(t *testing.T) {
+	args := []string{"-v", "--port", "8080", "--app=myapp", "--verbose",
+		"--uuid", "uuid", "positional", "--", "--endpoint", "/ignored"}
+
+	assert.Equal(t, []string{"--app=myapp", "--uuid", "uuid"}, filterArgs(args))
+
+	def, err := ParseArgs(args)
+	assert.NoError(t, err)
+	assert.Equal(t, "myapp", def.ApplicationName())
+	assert.Equal(t, "uuid", def.UUID())
+	assert.Equal(t, "", def.Endpoint())
+
+	_, err = ParseArgs([]string{"--protocol", "abc"})
+	assert.Error(t, err)
+}
+
+func TestDefaultsFromContext(t *testing.T) {
+	def, err := ParseArgs([]string{"--app", "ctxapp"})
+	assert.NoError(t, err)
+
+	ctx := WithDefaults(context.Background(), def)
+	assert.Equal(t, "ctxapp", FromContext(ctx).ApplicationName())
+	assert.Equal(t, GetDefaults(), FromContext(context.Background()))
+}
+
+func TestParseArgsShowVersion(t *testing.T) {
+	def, err := ParseArgs([]string{"--showcocaineversion", "--app", "app"})
+	assert.Equal(t, ErrShowVersion, err)
+	assert.Equal(t, "app", def.ApplicationName())
+}
+
+func TestGetDefaultsIgnoresArgs(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"worker", "--app", "argsapp", "--showcocaineversion"}
+	defer func() { os.Args = args }()
+
+	defaultsMu.Lock()
+	stored, set := storedDefaults, defaultsSet
+	storedDefaults, defaultsSet = nil, false
+	defaultsMu.Unlock()
+	defer func() {
+		defaultsMu.Lock()
+		storedDefaults, defaultsSet = stored, set
+		defaultsMu.Unlock()
+	}()
+
+	assert.Equal(t, "gostandalone", GetDefaults().ApplicationName())
+
+	_, err := workerDefaults()
+	assert.Equal(t, ErrShowVersion, err)
+}
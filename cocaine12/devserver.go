@@ -0,0 +1,276 @@
+package cocaine12
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// DevErrorCodeHeader carries the code of an error replied
+	// by a handler served by DevServer
+	DevErrorCodeHeader = "X-Cocaine-Error-Code"
+	// DevErrorMessageHeader carries the message of the error
+	DevErrorMessageHeader = "X-Cocaine-Error-Message"
+)
+
+// DevServer serves events over plain HTTP for local development,
+// so handlers can be exercised with curl without cocaine-runtime:
+//
+//	curl -d 'ping' http://localhost:8080/echo
+//
+// The path is the name of an event, the body of a request is sent
+// as a single chunk. These query parameters change it:
+//
+//	chunks=lines - every line of the body is a separate chunk
+//	format=json - chunks of the request are converted from JSON
+//	              to msgpack and chunks of the response backwards,
+//	              as typed handlers expect
+//
+// Headers of an HTTP request are the incoming metadata. Chunks
+// of a response are streamed as they are written. An error is replied
+// with DevErrorCodeHeader and DevErrorMessageHeader, as trailers
+// if the response is already streamed.
+type DevServer struct {
+	handlers *EventHandlers
+}
+
+// NewDevServer creates DevServer invoking the handlers
+func NewDevServer(handlers *EventHandlers) *DevServer {
+	return &DevServer{handlers: handlers}
+}
+
+// ServeDev serves the handlers by DevServer at addr until it fails.
+// Only loopback addresses are accepted, e.g. "localhost:8080".
+func ServeDev(addr string, handlers map[string]EventHandler) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%s is not a loopback address", addr)
+	}
+
+	return http.ListenAndServe(addr, NewDevServer(NewEventHandlersFromMap(handlers)))
+}
+
+func (s *DevServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	event := strings.TrimPrefix(r.URL.Path, "/")
+	if event == "" {
+		s.serveEvents(w)
+		return
+	}
+
+	query := r.URL.Query()
+	jsonFormat := query.Get("format") == "json"
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var chunks [][]byte
+	if query.Get("chunks") == "lines" {
+		for _, line := range bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n")) {
+			chunks = append(chunks, line)
+		}
+	} else if len(body) > 0 {
+		chunks = [][]byte{body}
+	}
+
+	if jsonFormat {
+		for i, chunk := range chunks {
+			if chunks[i], err = jsonToMsgpack(chunk); err != nil {
+				http.Error(w, fmt.Sprintf("chunk %d is not JSON: %v", i, err), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ctx := withEventName(r.Context(), event)
+	md := Metadata{}
+	for name, values := range r.Header {
+		md.Append(name, values...)
+	}
+	ctx = NewIncomingContext(ctx, md)
+	if timeout, ok := requestTimeout(md); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	request := &devRequest{chunks: chunks}
+	response := newDevResponse(w, jsonFormat)
+	defer trapRecoverAndClose(ctx, event, response, true, nil)
+	s.handlers.Call(ctx, event, request, response)
+}
+
+// serveEvents lists registered events
+func (s *DevServer) serveEvents(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.handlers.events())
+}
+
+type devRequest struct {
+	chunks [][]byte
+}
+
+func (r *devRequest) Read(ctx context.Context) ([]byte, error) {
+	if len(r.chunks) == 0 {
+		return nil, ErrStreamIsClosed
+	}
+
+	var chunk []byte
+	chunk, r.chunks = r.chunks[0], r.chunks[1:]
+	return chunk, nil
+}
+
+type devResponse struct {
+	w          http.ResponseWriter
+	jsonFormat bool
+	started    bool
+	closed     bool
+}
+
+func newDevResponse(w http.ResponseWriter, jsonFormat bool) *devResponse {
+	// errors are known only at the end of a streamed response
+	w.Header().Set("Trailer", DevErrorCodeHeader+", "+DevErrorMessageHeader)
+	return &devResponse{w: w, jsonFormat: jsonFormat}
+}
+
+func (r *devResponse) Write(data []byte) (int, error) {
+	if err := r.ZeroCopyWrite(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (r *devResponse) ZeroCopyWrite(data []byte) error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+
+	if r.jsonFormat {
+		converted, err := msgpackToJSON(data)
+		if err != nil {
+			return err
+		}
+		data = append(converted, '\n')
+	}
+
+	r.started = true
+	if _, err := r.w.Write(data); err != nil {
+		return err
+	}
+	if flusher, ok := r.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (r *devResponse) Close() error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+	r.closed = true
+	return nil
+}
+
+func (r *devResponse) ErrorMsg(code int, message string) error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+	r.closed = true
+
+	header := r.w.Header()
+	header.Set(DevErrorCodeHeader, strconv.Itoa(code))
+	header.Set(DevErrorMessageHeader, message)
+	if !r.started {
+		http.Error(r.w, message, devErrorStatus(code))
+	}
+	return nil
+}
+
+func devErrorStatus(code int) int {
+	switch code {
+	case ErrorNoEventHandler:
+		return http.StatusNotFound
+	case ErrorBadRequest:
+		return http.StatusBadRequest
+	case ErrorOverloaded, ErrorNotReady:
+		return http.StatusServiceUnavailable
+	case ErrorDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return marshalPayload(msgpackCompatible(v))
+}
+
+// msgpackCompatible packs integers of JSON as integers,
+// so they can be unpacked to integer fields
+func msgpackCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = msgpackCompatible(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = msgpackCompatible(value)
+		}
+	}
+	return v
+}
+
+func msgpackToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := unmarshalPayload(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCompatible(v))
+}
+
+// jsonCompatible converts maps with interface{} keys
+// and raw bytes unpacked by msgpack
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(jsonCompatible(k))] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+		return v
+	case []byte:
+		return string(v)
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package cocaine12
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newTestDevServer() *httptest.Server {
+	handlers := NewEventHandlers()
+	handlers.On("echo", func(ctx context.Context, req Request, res Response) {
+		for {
+			chunk, err := req.Read(ctx)
+			if err != nil {
+				return
+			}
+			res.Write(chunk)
+		}
+	})
+	handlers.On("meta", func(ctx context.Context, req Request, res Response) {
+		md, _ := FromIncomingContext(ctx)
+		_, hasDeadline := ctx.Deadline()
+		res.Write([]byte(md.Get("X-Trace")[0] + " " + strconv.FormatBool(hasDeadline)))
+	})
+	handlers.On("sum", func(ctx context.Context, req Request, res Response) {
+		chunk, _ := req.Read(ctx)
+		var args struct {
+			A, B int
+		}
+		if err := unmarshalPayload(chunk, &args); err != nil {
+			res.ErrorMsg(ErrorBadRequest, err.Error())
+			return
+		}
+		payload, _ := marshalPayload(map[string]int{"sum": args.A + args.B})
+		res.Write(payload)
+	})
+	handlers.On("fail", func(ctx context.Context, req Request, res Response) {
+		res.Write([]byte("partial"))
+		res.ErrorMsg(ErrorInternal, "broken")
+	})
+	handlers.On("panic", func(ctx context.Context, req Request, res Response) {
+		panic("boom")
+	})
+	return httptest.NewServer(NewDevServer(handlers))
+}
+
+func devPost(t *testing.T, url, body string, header http.Header) (*http.Response, string) {
+	r, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestDevServer(t *testing.T) {
+	server := newTestDevServer()
+	defer server.Close()
+
+	resp, body := devPost(t, server.URL+"/echo", "ping", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ping", body)
+
+	_, body = devPost(t, server.URL+"/echo?chunks=lines", "a\nb\n", nil)
+	assert.Equal(t, "ab", body)
+
+	_, body = devPost(t, server.URL+"/meta", "", http.Header{
+		"X-Trace":           {"abc"},
+		"X-Request-Timeout": {"1000"},
+	})
+	assert.Equal(t, "abc true", body)
+
+	_, body = devPost(t, server.URL+"/sum?format=json", `{"A": 1, "B": 2}`, nil)
+	assert.Equal(t, "{\"sum\":3}\n", body)
+
+	resp, _ = devPost(t, server.URL+"/sum?format=json", `{`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = devPost(t, server.URL+"/", "", nil)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, "echo")
+}
+
+func TestDevServerErrors(t *testing.T) {
+	server := newTestDevServer()
+	defer server.Close()
+
+	resp, _ := devPost(t, server.URL+"/missing", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, strconv.Itoa(ErrorNoEventHandler), resp.Header.Get(DevErrorCodeHeader))
+
+	resp, _ = devPost(t, server.URL+"/panic", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get(DevErrorMessageHeader), "boom")
+
+	resp, body := devPost(t, server.URL+"/fail", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "partial", body)
+	assert.Equal(t, strconv.Itoa(ErrorInternal), resp.Trailer.Get(DevErrorCodeHeader))
+	assert.Equal(t, "broken", resp.Trailer.Get(DevErrorMessageHeader))
+}
+
+func TestServeDevLoopbackOnly(t *testing.T) {
+	assert.Error(t, ServeDev("0.0.0.0:0", nil))
+	assert.Error(t, ServeDev("example.com:80", nil))
+}
@@ -0,0 +1,130 @@
+package cocaine12
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	// a delay before the next endpoint is tried
+	// if the previous one has not answered yet. See RFC 8305
+	happyEyeballsDelay = 300 * time.Millisecond
+)
+
+type dialResult struct {
+	index int
+	sock  socketIO
+	err   error
+}
+
+// dialHappyEyeballs dials addresses in parallel with staggered starts.
+// The next attempt begins either after the stagger delay or as soon as
+// the previous attempt fails. The first established connection wins,
+// the others are closed. It returns the index of the winner.
+// If all attempts fail, it returns errors in the same order as addresses.
+func dialHappyEyeballs(family string, addresses []string, timeout time.Duration, stagger time.Duration) (socketIO, int, []error) {
+	var errs = make([]error, len(addresses))
+	if len(addresses) == 0 {
+		return nil, -1, errs
+	}
+
+	var (
+		// buffered to never block losers
+		results = make(chan dialResult, len(addresses))
+		next    = 0
+		pending = 0
+
+		staggerTimer <-chan time.Time
+	)
+
+	launch := func() {
+		index := next
+		next++
+		pending++
+		go func() {
+			sock, err := newAsyncConnection(family, addresses[index], timeout)
+			results <- dialResult{index, sock, err}
+		}()
+
+		if next < len(addresses) {
+			staggerTimer = time.After(stagger)
+		} else {
+			staggerTimer = nil
+		}
+	}
+
+	launch()
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				go closeLosers(results, pending)
+				return res.sock, res.index, errs
+			}
+
+			errs[res.index] = res.err
+			if next < len(addresses) {
+				launch()
+			}
+
+		case <-staggerTimer:
+			launch()
+		}
+	}
+
+	return nil, -1, errs
+}
+
+func closeLosers(results <-chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.err == nil {
+			res.sock.Close()
+		}
+	}
+}
+
+// interleaveFamilies reorders endpoints to alternate IPv6 and IPv4
+// addresses keeping the relative order within a family,
+// so a broken family doesn't delay the other one for long.
+func interleaveFamilies(endpoints []EndpointItem) []EndpointItem {
+	var v6, v4 []EndpointItem
+	for _, endpoint := range endpoints {
+		if isIPv6(endpoint.IP) {
+			v6 = append(v6, endpoint)
+		} else {
+			v4 = append(v4, endpoint)
+		}
+	}
+
+	if len(v6) == 0 || len(v4) == 0 {
+		return endpoints
+	}
+
+	var (
+		result = make([]EndpointItem, 0, len(endpoints))
+		first  = v6
+		second = v4
+	)
+
+	// respect the family preferred by the locator
+	if !isIPv6(endpoints[0].IP) {
+		first, second = v4, v6
+	}
+
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			result = append(result, first[i])
+		}
+		if i < len(second) {
+			result = append(result, second[i])
+		}
+	}
+
+	return result
+}
+
+func isIPv6(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
@@ -0,0 +1,53 @@
+package cocaine12
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialHappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the port is free after the listener is closed
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	sock, index, errs := dialHappyEyeballs("tcp", []string{closedAddr, ln.Addr().String()}, time.Second, time.Second)
+	if !assert.NotNil(t, sock) {
+		t.Fatal(errs)
+	}
+	assert.Equal(t, 1, index)
+	assert.Error(t, errs[0])
+	sock.Close()
+
+	sock, index, errs = dialHappyEyeballs("tcp", []string{closedAddr, closedAddr}, time.Second, time.Millisecond)
+	assert.Nil(t, sock)
+	assert.Equal(t, -1, index)
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.Error(t, errs[1])
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	endpoints := []EndpointItem{
+		{"::1", 1}, {"::2", 1}, {"::3", 1}, {"127.0.0.1", 1}, {"127.0.0.2", 1},
+	}
+
+	assert.Equal(t, []EndpointItem{
+		{"::1", 1}, {"127.0.0.1", 1}, {"::2", 1}, {"127.0.0.2", 1}, {"::3", 1},
+	}, interleaveFamilies(endpoints))
+
+	v4only := []EndpointItem{{"127.0.0.1", 1}, {"127.0.0.2", 1}}
+	assert.Equal(t, v4only, interleaveFamilies(v4only))
+}
@@ -0,0 +1,162 @@
+package cocaine12
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// how often hostnames of locators are re-resolved in the background
+	locatorResolveInterval = time.Minute
+)
+
+var locatorHosts = newHostsCache(net.LookupHost, locatorResolveInterval)
+
+type resolvedHost struct {
+	addresses  []string
+	resolvedAt time.Time
+}
+
+// hostsCache keeps addresses of hostnames of locators.
+// Hostnames are re-resolved periodically while locators are open,
+// so a locator can be moved to another address without restarting
+// workers. If DNS is unavailable, the last known addresses are used.
+type hostsCache struct {
+	mu       sync.Mutex
+	lookup   func(host string) ([]string, error)
+	interval time.Duration
+	hosts    map[string]resolvedHost
+
+	// the number of open locators, the refresh loop
+	// runs until stop is closed by the last one
+	users int
+	stop  chan struct{}
+}
+
+func newHostsCache(lookup func(host string) ([]string, error), interval time.Duration) *hostsCache {
+	return &hostsCache{
+		lookup:   lookup,
+		interval: interval,
+		hosts:    make(map[string]resolvedHost),
+	}
+}
+
+// expand replaces endpoints with hostnames by resolved addresses.
+// It returns the index of the original endpoint for every address.
+func (c *hostsCache) expand(endpoints []string) ([]string, []int) {
+	var (
+		addresses = make([]string, 0, len(endpoints))
+		origins   = make([]int, 0, len(endpoints))
+	)
+
+	for i, endpoint := range endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || net.ParseIP(host) != nil {
+			addresses = append(addresses, endpoint)
+			origins = append(origins, i)
+			continue
+		}
+
+		resolved := c.resolve(host)
+		if len(resolved) == 0 {
+			// let the dialer report the error
+			addresses = append(addresses, endpoint)
+			origins = append(origins, i)
+			continue
+		}
+
+		for _, address := range resolved {
+			addresses = append(addresses, net.JoinHostPort(address, port))
+			origins = append(origins, i)
+		}
+	}
+
+	return addresses, origins
+}
+
+// acquire starts refreshing hostnames in the background
+// if it's the first open locator
+func (c *hostsCache) acquire() {
+	c.mu.Lock()
+	c.users++
+	if c.users == 1 {
+		c.stop = make(chan struct{})
+		go c.refreshLoop(c.stop)
+	}
+	c.mu.Unlock()
+}
+
+// release stops the refresh loop once the last locator is closed
+func (c *hostsCache) release() {
+	c.mu.Lock()
+	c.users--
+	if c.users == 0 {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.mu.Unlock()
+}
+
+func (c *hostsCache) resolve(host string) []string {
+	c.mu.Lock()
+	cached, ok := c.hosts[host]
+	c.mu.Unlock()
+
+	if ok && time.Since(cached.resolvedAt) < c.interval {
+		return cached.addresses
+	}
+
+	return c.refresh(host, cached.addresses)
+}
+
+// invalidate makes the next expand re-resolve all hostnames.
+// Known addresses are still used if DNS fails.
+func (c *hostsCache) invalidate() {
+	c.mu.Lock()
+	for host, resolved := range c.hosts {
+		resolved.resolvedAt = time.Time{}
+		c.hosts[host] = resolved
+	}
+	c.mu.Unlock()
+}
+
+func (c *hostsCache) refresh(host string, fallback []string) []string {
+	addresses, err := c.lookup(host)
+	if err != nil || len(addresses) == 0 {
+		return fallback
+	}
+
+	c.mu.Lock()
+	c.hosts[host] = resolvedHost{
+		addresses:  addresses,
+		resolvedAt: time.Now(),
+	}
+	c.mu.Unlock()
+
+	return addresses
+}
+
+func (c *hostsCache) refreshLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		c.mu.Lock()
+		var known = make(map[string][]string, len(c.hosts))
+		for host, resolved := range c.hosts {
+			known[host] = resolved.addresses
+		}
+		c.mu.Unlock()
+
+		for host, addresses := range known {
+			c.refresh(host, addresses)
+		}
+	}
+}
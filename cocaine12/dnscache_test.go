@@ -0,0 +1,72 @@
+package cocaine12
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostsCacheExpand(t *testing.T) {
+	var (
+		lookups  = 0
+		response = []string{"10.0.0.1", "::1"}
+		failure  error
+	)
+
+	c := newHostsCache(func(host string) ([]string, error) {
+		lookups++
+		return response, failure
+	}, time.Hour)
+
+	addresses, origins := c.expand([]string{"127.0.0.1:10053", "locator.local:10053"})
+	assert.Equal(t, []string{"127.0.0.1:10053", "10.0.0.1:10053", "[::1]:10053"}, addresses)
+	assert.Equal(t, []int{0, 1, 1}, origins)
+	assert.Equal(t, 1, lookups)
+
+	// cached
+	c.expand([]string{"locator.local:10053"})
+	assert.Equal(t, 1, lookups)
+
+	// re-resolved after invalidation
+	response = []string{"10.0.0.2"}
+	c.invalidate()
+	addresses, _ = c.expand([]string{"locator.local:10053"})
+	assert.Equal(t, []string{"10.0.0.2:10053"}, addresses)
+	assert.Equal(t, 2, lookups)
+
+	// the last known addresses are used if DNS fails
+	failure = errors.New("dns failure")
+	c.invalidate()
+	addresses, _ = c.expand([]string{"locator.local:10053"})
+	assert.Equal(t, []string{"10.0.0.2:10053"}, addresses)
+}
+
+func TestHostsCacheRefreshLoop(t *testing.T) {
+	lookups := make(chan string, 100)
+	c := newHostsCache(func(host string) ([]string, error) {
+		lookups <- host
+		return []string{"10.0.0.1"}, nil
+	}, 10*time.Millisecond)
+
+	c.expand([]string{"locator.local:10053"})
+	<-lookups
+
+	c.acquire()
+	c.acquire()
+	c.release()
+	select {
+	case <-lookups:
+	case <-time.After(time.Second):
+		t.Fatal("hostnames must be refreshed while a locator is open")
+	}
+
+	c.release()
+	time.Sleep(20 * time.Millisecond)
+	for len(lookups) > 0 {
+		<-lookups
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, len(lookups), "the refresh loop must stop with the last locator")
+}
@@ -0,0 +1,181 @@
+package cocaine12
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+type dialFunc func(family, address string, timeout time.Duration) (socketIO, error)
+
+// splitEndpoints splits a comma separated list of endpoints
+func splitEndpoints(value string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(value, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// endpointFamily returns "tcp" for host:port endpoints
+// and "unix" for paths of sockets
+func endpointFamily(endpoint string) string {
+	if strings.HasPrefix(endpoint, "/") {
+		return "unix"
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return "tcp"
+	}
+	return "unix"
+}
+
+func isIPv6Endpoint(endpoint string) bool {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// dialEndpoints connects to the first available endpoint trying them
+// in order. Adjacent TCP endpoints of different IP families, e.g.
+// "[::1]:10054,127.0.0.1:10054", are dialed in parallel. Other endpoints
+// are returned as fallbacks in the order they should be tried next.
+func dialEndpoints(endpoints []string, timeout time.Duration, dial dialFunc) (socketIO, string, []string, error) {
+	var errs []string
+	for i := 0; i < len(endpoints); {
+		group := endpoints[i : i+1]
+		if i+1 < len(endpoints) &&
+			endpointFamily(endpoints[i]) == "tcp" && endpointFamily(endpoints[i+1]) == "tcp" &&
+			isIPv6Endpoint(endpoints[i]) != isIPv6Endpoint(endpoints[i+1]) {
+			group = endpoints[i : i+2]
+		}
+
+		sock, winner, err := dialParallel(group, timeout, dial)
+		if err == nil {
+			connected := i + winner
+			var fallbacks []string
+			for j := 1; j < len(endpoints); j++ {
+				fallbacks = append(fallbacks, endpoints[(connected+j)%len(endpoints)])
+			}
+			return sock, endpoints[connected], fallbacks, nil
+		}
+
+		errs = append(errs, err.Error())
+		i += len(group)
+	}
+
+	if len(errs) == 0 {
+		return nil, "", nil, ErrNoCocaineEndpoint
+	}
+	return nil, "", nil, fmt.Errorf("unable to connect to Cocaine: %s", strings.Join(errs, "; "))
+}
+
+// dialParallel returns the first established connection
+// and the index of its endpoint
+func dialParallel(endpoints []string, timeout time.Duration, dial dialFunc) (socketIO, int, error) {
+	type result struct {
+		sock  socketIO
+		index int
+		err   error
+	}
+
+	results := make(chan result, len(endpoints))
+	for i, endpoint := range endpoints {
+		go func(i int, endpoint string) {
+			sock, err := dial(endpointFamily(endpoint), endpoint, timeout)
+			if err != nil {
+				err = fmt.Errorf("%s: %v", endpoint, err)
+			}
+			results <- result{sock, i, err}
+		}(i, endpoint)
+	}
+
+	var errs []string
+	for received := 1; received <= len(endpoints); received++ {
+		res := <-results
+		if res.err != nil {
+			errs = append(errs, res.err.Error())
+			continue
+		}
+
+		// late connections are closed in background
+		go func(left int) {
+			for ; left > 0; left-- {
+				if res := <-results; res.err == nil {
+					res.sock.Close()
+				}
+			}
+		}(len(endpoints) - received)
+		return res.sock, res.index, nil
+	}
+	return nil, 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// failover connects to the next fallback endpoint once the worker
+// is disowned. Requests received from the lost runtime are aborted.
+// It returns false if there is no available fallback.
+func (w *WorkerNG) failover() bool {
+	if len(w.fallbacks) == 0 || w.isStopped() {
+		return false
+	}
+
+	sock, endpoint, fallbacks, err := dialEndpoints(w.fallbacks, coreConnectionTimeout, w.dial)
+	if err != nil {
+		fmt.Printf("unable to fail over: %v\n", err)
+		return false
+	}
+	fmt.Printf("disowned by %s, failing over to %s\n", w.endpoint, endpoint)
+
+	// the lost runtime may come back later
+	w.fallbacks = append(fallbacks, w.endpoint)
+	if err := w.switchConn(sock, endpoint); err != nil {
+		fmt.Printf("unable to fail over to %s: %v\n", endpoint, err)
+		return false
+	}
+	return true
+}
+
+// switchConn makes the worker talk to the runtime via the new connection
+// introducing itself with the same id. Requests received via
+// the previous connection are aborted, as their replies are lost.
+func (w *WorkerNG) switchConn(sock socketIO, endpoint string) error {
+	previous := w.setConn(sock)
+	previous.Close()
+	w.endpoint = endpoint
+
+	for session, reqStream := range w.sessions {
+		reqStream.abort()
+		reqStream.Close()
+		w.removeSession(session)
+	}
+
+	if err := w.sendHandshake(); err != nil {
+		return err
+	}
+	w.onHeartbeatTimeout()
+	return nil
+}
+
+// setConn replaces the connection to cocaine-runtime
+// and returns the previous one
+func (w *WorkerNG) setConn(conn socketIO) socketIO {
+	w.connMu.Lock()
+	defer w.connMu.Unlock()
+	previous := w.conn
+	w.conn = conn
+	return previous
+}
+
+// connection returns the current connection to cocaine-runtime.
+// The loop of the worker may access w.conn directly,
+// as only it replaces the connection.
+func (w *WorkerNG) connection() socketIO {
+	w.connMu.RLock()
+	defer w.connMu.RUnlock()
+	return w.conn
+}
@@ -0,0 +1,121 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitEndpoints(t *testing.T) {
+	assert.Equal(t, []string{"/run/cocaine.sock"}, splitEndpoints("/run/cocaine.sock"))
+	assert.Equal(t, []string{"[::1]:10054", "127.0.0.1:10054"}, splitEndpoints("[::1]:10054, 127.0.0.1:10054,"))
+	assert.Empty(t, splitEndpoints(""))
+
+	assert.Equal(t, "unix", endpointFamily("/run/cocaine.sock"))
+	assert.Equal(t, "unix", endpointFamily("cocaine.sock"))
+	assert.Equal(t, "tcp", endpointFamily("[::1]:10054"))
+	assert.True(t, isIPv6Endpoint("[::1]:10054"))
+	assert.False(t, isIPv6Endpoint("127.0.0.1:10054"))
+}
+
+// testDialer connects to available endpoints only
+type testDialer struct {
+	mu        sync.Mutex
+	available map[string]bool
+	dialed    []string
+	peers     chan socketIO
+}
+
+func newTestDialer(available ...string) *testDialer {
+	d := &testDialer{
+		available: make(map[string]bool),
+		peers:     make(chan socketIO, len(available)),
+	}
+	for _, endpoint := range available {
+		d.available[endpoint] = true
+	}
+	return d
+}
+
+func (d *testDialer) dial(family, address string, timeout time.Duration) (socketIO, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, address)
+	available := d.available[address]
+	d.mu.Unlock()
+
+	if !available {
+		return nil, errors.New("connection refused")
+	}
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	peer, _ := newAsyncRW(in)
+	d.peers <- peer
+	return sock, nil
+}
+
+func TestDialEndpoints(t *testing.T) {
+	endpoints := []string{"/run/down.sock", "[::1]:10054", "127.0.0.1:10054", "/run/up.sock"}
+	dialer := newTestDialer("127.0.0.1:10054", "/run/up.sock")
+
+	sock, endpoint, fallbacks, err := dialEndpoints(endpoints, time.Second, dialer.dial)
+	if assert.NoError(t, err) {
+		sock.Close()
+	}
+	assert.Equal(t, "127.0.0.1:10054", endpoint)
+	assert.Equal(t, []string{"/run/up.sock", "/run/down.sock", "[::1]:10054"}, fallbacks)
+	dialer.mu.Lock()
+	assert.NotContains(t, dialer.dialed, "/run/up.sock")
+	dialer.mu.Unlock()
+
+	_, _, _, err = dialEndpoints([]string{"/run/down.sock"}, time.Second, dialer.dial)
+	assert.Error(t, err)
+}
+
+func TestWorkerFailover(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	dialer := newTestDialer("/run/fallback.sock")
+	w.impl.dial = dialer.dial
+	w.impl.endpoint = "/run/cocaine.sock"
+	w.impl.fallbacks = []string{"/run/fallback.sock"}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	// the runtime is gone
+	clock.Advance(disownTimeout)
+
+	select {
+	case peer := <-dialer.peers:
+		checkTypeAndSession(t, <-peer.Read(), v1UtilitySession, v1Handshake)
+		checkTypeAndSession(t, <-peer.Read(), v1UtilitySession, v1Heartbeat)
+	case <-time.After(time.Second):
+		t.Fatal("worker has not failed over")
+	}
+
+	select {
+	case err := <-result:
+		t.Fatalf("worker has stopped: %v", err)
+	default:
+	}
+	assert.Equal(t, []string{"/run/cocaine.sock"}, w.impl.fallbacks)
+}
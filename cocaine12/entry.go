@@ -2,6 +2,7 @@ package cocaine12
 
 import (
 	"fmt"
+	"io"
 )
 
 type Entry struct {
@@ -12,6 +13,33 @@ type Entry struct {
 // just for the type check
 var _ EntryLogger = &Entry{}
 
+// WithFields returns a derived Entry with fields added to the fields
+// of the entry. Values of the added fields override existing ones.
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(e.Fields)+len(fields))
+	for k, v := range e.Fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &Entry{
+		Logger: e.Logger,
+		Fields: merged,
+	}
+}
+
+// Writer returns io.Writer logging lines with the fields of the entry
+func (e *Entry) Writer(level Severity) io.Writer {
+	return newLogWriter(e.Logger, e.Fields, level)
+}
+
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	e.log(FatalLevel, e.Fields, format, args...)
+	terminateOnFatal(fmt.Sprintf(format, args...))
+}
+
 func (e *Entry) Errf(format string, args ...interface{}) {
 	if e.V(ErrorLevel) {
 		e.log(ErrorLevel, e.Fields, format, args...)
@@ -36,6 +64,18 @@ func (e *Entry) Debugf(format string, args ...interface{}) {
 	}
 }
 
+func (e *Entry) Tracef(format string, args ...interface{}) {
+	if e.V(TraceLevel) {
+		e.log(TraceLevel, e.Fields, format, args...)
+	}
+}
+
+func (e *Entry) Fatal(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	e.log(FatalLevel, e.Fields, msg)
+	terminateOnFatal(msg)
+}
+
 func (e *Entry) Err(args ...interface{}) {
 	if e.V(ErrorLevel) {
 		e.log(ErrorLevel, e.Fields, fmt.Sprint(args...))
@@ -59,3 +99,9 @@ func (e *Entry) Debug(args ...interface{}) {
 		e.log(DebugLevel, e.Fields, fmt.Sprint(args...))
 	}
 }
+
+func (e *Entry) Trace(args ...interface{}) {
+	if e.V(TraceLevel) {
+		e.log(TraceLevel, e.Fields, fmt.Sprint(args...))
+	}
+}
@@ -0,0 +1,51 @@
+package cocaine12
+
+import (
+	"fmt"
+)
+
+// ErrorDetailsHeader carries the msgpack encoded details of an error.
+// See ErrorWithDetails
+const ErrorDetailsHeader = "x-error-details"
+
+// ErrorWithDetails sends the error along with the details object packed
+// by msgpack, like details of gRPC status. Clients receive them
+// in ErrRequest.Details and decode them by ErrRequest.DecodeDetails.
+// The details are dropped if the response is wrapped by a type
+// which can't pass headers.
+func ErrorWithDetails(response Response, category, code int, message string, details interface{}) error {
+	buf, err := marshalPayload(details)
+	if err != nil {
+		return fmt.Errorf("unable to pack error details: %v", err)
+	}
+
+	return sendError(response, category, code, message, []Header{
+		{Name: ErrorDetailsHeader, Value: buf},
+	})
+}
+
+// sendError sends the error with the category and headers if the response
+// is able to, otherwise the error is sent by Response.ErrorMsg
+func sendError(response Response, category, code int, message string, headers []Header) error {
+	if sender, ok := response.(errorWithHeadersSender); ok {
+		return sender.errorMsgWithHeaders(category, code, message, headers)
+	}
+	return response.ErrorMsg(code, message)
+}
+
+// DecodeDetails unpacks the details of the error into target.
+// It returns false if the error has no details
+func (e *ErrRequest) DecodeDetails(target interface{}) (bool, error) {
+	if len(e.Details) == 0 {
+		return false, nil
+	}
+	return true, unmarshalPayload(e.Details, target)
+}
+
+func errorDetails(headers CocaineHeaders) []byte {
+	if len(headers) == 0 {
+		return nil
+	}
+	details, _ := findHeader(headers, ErrorDetailsHeader)
+	return details
+}
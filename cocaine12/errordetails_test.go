@@ -0,0 +1,70 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type quotaDetails struct {
+	Quota string
+	Limit int
+}
+
+func TestErrorWithDetails(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			ErrorWithDetails(res, 7, ErrorOverloaded, "quota exceeded", quotaDetails{"rps", 100})
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "test")
+	reply := <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Error)
+
+	// the reply is passed to a client
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	ch, err := service.Call(context.Background(), "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply.Session = (<-peer.Read()).Session
+	peer.Write() <- reply
+
+	res, err := ch.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.Err()
+	if !assert.IsType(t, &ErrRequest{}, err) {
+		return
+	}
+	errRequest := err.(*ErrRequest)
+	assert.Equal(t, 7, errRequest.Category)
+	assert.Equal(t, ErrorOverloaded, errRequest.Code)
+	assert.Equal(t, "quota exceeded", errRequest.Message)
+
+	var details quotaDetails
+	ok, err := errRequest.DecodeDetails(&details)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, quotaDetails{"rps", 100}, details)
+
+	ok, _ = (&ErrRequest{}).DecodeDetails(&details)
+	assert.False(t, ok)
+}
@@ -0,0 +1,116 @@
+package cocaine12
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// ErrorKind classifies failures reported to ErrorReporter
+type ErrorKind int
+
+const (
+	// ErrorKindPanic means that a handler has panicked
+	ErrorKindPanic ErrorKind = iota
+	// ErrorKindProtocol means that cocaine-runtime has sent
+	// a message violating the protocol
+	ErrorKindProtocol
+	// ErrorKindDisown means that the worker has been disowned
+	// by cocaine-runtime and is going to exit
+	ErrorKindDisown
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindPanic:
+		return "panic"
+	case ErrorKindProtocol:
+		return "protocol"
+	case ErrorKindDisown:
+		return "disown"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrorReport describes a failure of the worker
+type ErrorReport struct {
+	Kind ErrorKind
+	// Err describes the failure. For panics it's built from the recovered value
+	Err error
+	// Recovered is the value passed to panic
+	Recovered interface{}
+	// Stack of the panicked handler
+	Stack []byte
+	// Event and Session of the request, if any
+	Event   string
+	Session uint64
+	// Metadata carries headers of the request
+	Metadata Metadata
+	// TraceInfo of the request if it's traced
+	TraceInfo *TraceInfo
+}
+
+// ErrorReporter receives failures of the worker, e.g. to send them
+// to a crash reporting service. Report is called from the goroutine
+// of the worker or a handler, so it must not block for long.
+type ErrorReporter interface {
+	Report(ctx context.Context, report *ErrorReport)
+}
+
+// SetErrorReporter sets the reporter notified about panics of handlers,
+// protocol violations and disowns.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetErrorReporter(reporter ErrorReporter) {
+	w.errorReporter = reporter
+}
+
+func (w *WorkerNG) reportError(ctx context.Context, report *ErrorReport) {
+	if w.errorReporter == nil {
+		return
+	}
+
+	if report.Metadata == nil {
+		report.Metadata, _ = FromIncomingContext(ctx)
+	}
+	if report.TraceInfo == nil {
+		report.TraceInfo = getTraceInfo(ctx)
+	}
+	w.errorReporter.Report(ctx, report)
+}
+
+// panicReporter returns a callback for trapRecoverAndClose
+func (w *WorkerNG) panicReporter(ctx context.Context, event string, session uint64) func(interface{}, []byte) {
+	if w.errorReporter == nil {
+		return nil
+	}
+
+	return func(recoverInfo interface{}, stack []byte) {
+		w.reportError(ctx, &ErrorReport{
+			Kind:      ErrorKindPanic,
+			Err:       fmt.Errorf("panic in handler of event '%s': %v", event, recoverInfo),
+			Recovered: recoverInfo,
+			Stack:     stack,
+			Event:     event,
+			Session:   session,
+		})
+	}
+}
+
+// reportProtocolError reports a message which the worker has failed to dispatch
+func (w *WorkerNG) reportProtocolError(msg *Message, err error) {
+	if w.errorReporter == nil {
+		return
+	}
+
+	report := &ErrorReport{
+		Kind:     ErrorKindProtocol,
+		Err:      err,
+		Session:  msg.Session,
+		Metadata: metadataFromHeaders(msg.Headers),
+	}
+	if traceInfo, err := msg.Headers.getTraceData(); err == nil {
+		report.TraceInfo = &traceInfo
+	}
+	w.reportError(context.Background(), report)
+}
@@ -0,0 +1,66 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type reportCollector chan *ErrorReport
+
+func (c reportCollector) Report(ctx context.Context, report *ErrorReport) {
+	c <- report
+}
+
+func TestWorkerReportsErrors(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	reports := make(reportCollector, 2)
+	w.SetErrorReporter(reports)
+
+	go w.Run(map[string]EventHandler{
+		"panic": func(ctx context.Context, req Request, res Response) {
+			panic("oops")
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	invoke := newInvokeV1(2, "panic")
+	invoke.Headers = DefaultHeaderTable.Encode([]Header{{Name: "x-request-id", Value: []byte("1")}})
+	sock2.Write() <- invoke
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Error)
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, ErrorKindPanic, report.Kind)
+		assert.Equal(t, "oops", report.Recovered)
+		assert.Equal(t, "panic", report.Event)
+		assert.Equal(t, uint64(2), report.Session)
+		assert.Equal(t, []string{"1"}, report.Metadata.Get("x-request-id"))
+		assert.Contains(t, string(report.Stack), "TestWorkerReportsErrors")
+	case <-time.After(time.Second):
+		t.Fatal("panic is not reported")
+	}
+
+	// a new session must start from invoke
+	sock2.Write() <- newChokeV1(3)
+	select {
+	case report := <-reports:
+		assert.Equal(t, ErrorKindProtocol, report.Kind)
+		assert.Equal(t, uint64(3), report.Session)
+		assert.Error(t, report.Err)
+	case <-time.After(time.Second):
+		t.Fatal("protocol violation is not reported")
+	}
+}
@@ -0,0 +1,85 @@
+package cocaine12
+
+import (
+	"reflect"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ErrorCode is the category and code of a protocol error
+type ErrorCode struct {
+	Category, Code int
+}
+
+type errorTranslations struct {
+	mu     sync.RWMutex
+	values map[error]ErrorCode
+	types  map[reflect.Type]ErrorCode
+}
+
+var registeredErrors = &errorTranslations{
+	values: map[error]ErrorCode{
+		context.DeadlineExceeded: {cworkererrorcategory, ErrorDeadlineExceeded},
+		context.Canceled:         {cworkererrorcategory, ErrorCancelled},
+	},
+	types: map[reflect.Type]ErrorCode{
+		reflect.TypeOf((*PayloadTooLargeError)(nil)): {cworkererrorcategory, ErrorPayloadTooLarge},
+	},
+}
+
+// RegisterError makes typed handlers reply with the category and code
+// if they return target, e.g. sql.ErrNoRows, or an error wrapping it.
+// It overrides the previous registration of target.
+// target must be comparable, like errors created by errors.New.
+func RegisterError(target error, category, code int) {
+	registeredErrors.mu.Lock()
+	registeredErrors.values[target] = ErrorCode{category, code}
+	registeredErrors.mu.Unlock()
+}
+
+// RegisterErrorType makes typed handlers reply with the category and code
+// if they return an error of the same type as example, e.g. (*os.PathError)(nil),
+// or an error wrapping it. Errors registered by RegisterError are matched first.
+func RegisterErrorType(example error, category, code int) {
+	registeredErrors.mu.Lock()
+	registeredErrors.types[reflect.TypeOf(example)] = ErrorCode{category, code}
+	registeredErrors.mu.Unlock()
+}
+
+// translateError looks up err and errors wrapped by it
+// by Unwrap() error method
+func translateError(err error) (ErrorCode, bool) {
+	registeredErrors.mu.RLock()
+	defer registeredErrors.mu.RUnlock()
+
+	for e := err; e != nil; e = unwrapError(e) {
+		if isComparable(e) {
+			if code, ok := registeredErrors.values[e]; ok {
+				return code, true
+			}
+		}
+	}
+
+	for e := err; e != nil; e = unwrapError(e) {
+		if code, ok := registeredErrors.types[reflect.TypeOf(e)]; ok {
+			return code, true
+		}
+	}
+
+	return ErrorCode{}, false
+}
+
+func unwrapError(err error) error {
+	if wrapper, ok := err.(interface {
+		Unwrap() error
+	}); ok {
+		return wrapper.Unwrap()
+	}
+	return nil
+}
+
+// isComparable reports whether err can be a key of a map
+func isComparable(err error) bool {
+	return reflect.TypeOf(err).Comparable()
+}
@@ -0,0 +1,60 @@
+package cocaine12
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+var errNoRows = errors.New("no rows in result set")
+
+type wrappedError struct {
+	err error
+}
+
+func (w *wrappedError) Error() string { return "query: " + w.err.Error() }
+func (w *wrappedError) Unwrap() error { return w.err }
+
+func TestTranslateError(t *testing.T) {
+	RegisterError(errNoRows, 10, 404)
+	RegisterErrorType((*os.PathError)(nil), 10, 500)
+	defer func() {
+		registeredErrors.mu.Lock()
+		delete(registeredErrors.values, errNoRows)
+		delete(registeredErrors.types, reflect.TypeOf((*os.PathError)(nil)))
+		registeredErrors.mu.Unlock()
+	}()
+
+	code, ok := translateError(errNoRows)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCode{10, 404}, code)
+
+	code, ok = translateError(&wrappedError{errNoRows})
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCode{10, 404}, code)
+
+	code, ok = translateError(&wrappedError{&os.PathError{Op: "open", Path: "/", Err: os.ErrPermission}})
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCode{10, 500}, code)
+
+	_, ok = translateError(errors.New("unknown"))
+	assert.False(t, ok)
+}
+
+func TestTypedHandlerTranslatesErrors(t *testing.T) {
+	handler, err := TypedHandler(func(ctx context.Context, req sumRequest) (*sumResponse, error) {
+		return nil, context.DeadlineExceeded
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := new(testResponse)
+	handler(context.Background(), &testRequest{packTyped(t, sumRequest{A: 1})}, res)
+	assert.Equal(t, ErrorDeadlineExceeded, res.code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), res.message)
+}
@@ -0,0 +1,157 @@
+// Package etcd provides a cocaine12.Resolver which discovers Cocaine
+// services in etcd v3 and lease-backed registration of services there.
+// It talks to the JSON gateway of etcd, so no gRPC client is required.
+//
+// A service instance is a key <prefix><service>/<host:port>
+// with methods of the service separated by commas as the value,
+// see cocaine12.ParseMethods. An empty value means an application.
+package etcd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	// DefaultPrefix is a prefix of keys of service instances
+	DefaultPrefix = "/cocaine/services/"
+
+	defaultEndpoint = "http://127.0.0.1:2379"
+)
+
+// ErrNoEndpoints means that all etcd endpoints have failed
+var ErrNoEndpoints = errors.New("no etcd endpoints are available")
+
+// Config configures the client of etcd
+type Config struct {
+	// Endpoints of etcd, http://127.0.0.1:2379 by default
+	Endpoints []string
+	// Prefix of keys, DefaultPrefix by default
+	Prefix string
+
+	HTTPClient *http.Client
+}
+
+func (c *Config) setDefaults() {
+	if len(c.Endpoints) == 0 {
+		c.Endpoints = []string{defaultEndpoint}
+	}
+
+	if c.Prefix == "" {
+		c.Prefix = DefaultPrefix
+	}
+
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+}
+
+func (c *Config) serviceKey(service string) string {
+	return c.Prefix + service + "/"
+}
+
+// client calls the JSON gateway of etcd trying endpoints in turn
+type client struct {
+	config Config
+	next   uint32
+}
+
+func newClient(config Config) *client {
+	config.setDefaults()
+	return &client{config: config}
+}
+
+func (c *client) call(ctx context.Context, method string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error = ErrNoEndpoints
+	for range c.config.Endpoints {
+		i := atomic.AddUint32(&c.next, 1) % uint32(len(c.config.Endpoints))
+		endpoint := strings.TrimSuffix(c.config.Endpoints[i], "/")
+
+		req, err := http.NewRequest("POST", endpoint+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := ctxhttp.Do(ctx, c.config.HTTPClient, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		err = decodeResponse(resp, response)
+		resp.Body.Close()
+		return err
+	}
+
+	return lastErr
+}
+
+func decodeResponse(resp *http.Response, response interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		if failure.Message == "" {
+			failure.Message = failure.Error
+		}
+		return fmt.Errorf("etcd replied %s: %s", resp.Status, failure.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// int64String is int64 encoded as a string by the gateway
+type int64String int64
+
+func (i int64String) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+func (i *int64String) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	*i = int64String(value)
+	return err
+}
+
+func encodeKey(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+func decodeKey(key string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	return string(data), err
+}
+
+// prefixEnd returns the end of the range of keys with the prefix
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	// all keys
+	return "\x00"
+}
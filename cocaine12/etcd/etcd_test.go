@@ -0,0 +1,167 @@
+package etcd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// fakeEtcd implements a subset of the JSON gateway
+type fakeEtcd struct {
+	mu     sync.Mutex
+	kvs    map[string]string
+	leases map[int64]string
+	lastID int64
+	alive  int
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{
+		kvs:    make(map[string]string),
+		leases: make(map[int64]string),
+	}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var reply interface{} = struct{}{}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req rangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		from, _ := decodeKey(req.Key)
+		to, _ := decodeKey(req.RangeEnd)
+
+		var resp rangeResponse
+		for k, v := range f.kvs {
+			if k >= from && k < to {
+				resp.Kvs = append(resp.Kvs, keyValue{encodeKey(k), encodeKey(v)})
+			}
+		}
+		reply = resp
+
+	case "/v3/kv/put":
+		var req putRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		key, _ := decodeKey(req.Key)
+		value, _ := decodeKey(req.Value)
+		f.kvs[key] = value
+		f.leases[int64(req.Lease)] = key
+
+	case "/v3/lease/grant":
+		f.lastID++
+		reply = leaseResponse{ID: int64String(f.lastID), TTL: 1}
+
+	case "/v3/lease/keepalive":
+		var req leaseRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.alive++
+
+		var ttl int64String
+		if _, ok := f.leases[int64(req.ID)]; ok {
+			ttl = 1
+		}
+		reply = keepAliveResponse{Result: leaseResponse{ID: req.ID, TTL: ttl}}
+
+	case "/v3/lease/revoke":
+		var req leaseRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		delete(f.kvs, f.leases[int64(req.ID)])
+		delete(f.leases, int64(req.ID))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "unknown method"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(reply)
+}
+
+// expire drops all leases like etcd does after TTL
+func (f *fakeEtcd) expire() {
+	f.mu.Lock()
+	for id, key := range f.leases {
+		delete(f.kvs, key)
+		delete(f.leases, id)
+	}
+	f.mu.Unlock()
+}
+
+func (f *fakeEtcd) keepAlives() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.alive
+}
+
+func TestRegisterAndResolve(t *testing.T) {
+	etcd := newFakeEtcd()
+	server := httptest.NewServer(etcd)
+	defer server.Close()
+
+	config := Config{Endpoints: []string{"http://127.0.0.1:1", server.URL}}
+	resolver := NewResolver(config)
+
+	_, err := resolver.Resolve(context.Background(), "storage")
+	assert.EqualError(t, err, "service storage has no registered instances")
+
+	registrar, err := Register(context.Background(), config, Registration{
+		Service:  "storage",
+		Endpoint: "10.0.0.1:10054",
+		Methods:  []cocaine.Method{{Name: "read"}, {Name: "find", Streaming: true}},
+		TTL:      30 * time.Millisecond,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// another service with a common prefix
+	other, err := Register(context.Background(), config, Registration{Service: "storage-v2", Endpoint: "10.0.0.2:10054"})
+	if assert.NoError(t, err) {
+		defer other.Close()
+	}
+
+	info, err := resolver.Resolve(context.Background(), "storage")
+	assert.NoError(t, err)
+	assert.Equal(t, []cocaine.EndpointItem{{IP: "10.0.0.1", Port: 10054}}, info.Endpoints)
+	find, err := info.API.MethodByName("find")
+	assert.NoError(t, err)
+	assert.Equal(t, "find", info.API[find].Name)
+
+	// the expired registration is restored
+	etcd.expire()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err = resolver.Resolve(context.Background(), "storage"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the registration has not been restored")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, etcd.keepAlives() > 0)
+	assert.NoError(t, registrar.Err())
+
+	assert.NoError(t, registrar.Close())
+	assert.NoError(t, registrar.Close(), "a double close must not panic")
+	_, err = resolver.Resolve(context.Background(), "storage")
+	assert.Error(t, err)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, "/cocaine/services/storage0", prefixEnd("/cocaine/services/storage/"))
+	assert.Equal(t, "b", prefixEnd("a\xff"))
+	assert.True(t, strings.HasPrefix(prefixEnd("\xff"), "\x00"))
+}
@@ -0,0 +1,195 @@
+package etcd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const defaultTTL = 10 * time.Second
+
+// Registration describes an instance of a service
+type Registration struct {
+	Service string
+	// Endpoint in host:port format
+	Endpoint string
+	// Methods of the service, an application is registered if there are none
+	Methods []cocaine.Method
+	// TTL of the lease, 10s by default. The lease is kept alive
+	// every TTL/3, so the instance disappears within TTL if the process dies.
+	TTL time.Duration
+}
+
+// Registrar keeps a registration alive
+type Registrar struct {
+	client *client
+	key    string
+	value  string
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	lease int64String
+	err   error
+
+	stop chan struct{}
+	done chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type grantRequest struct {
+	TTL int64 `json:"TTL"`
+}
+
+type leaseRequest struct {
+	ID int64String `json:"ID"`
+}
+
+type leaseResponse struct {
+	ID  int64String `json:"ID"`
+	TTL int64String `json:"TTL"`
+}
+
+type keepAliveResponse struct {
+	Result leaseResponse `json:"result"`
+}
+
+type putRequest struct {
+	Key   string      `json:"key"`
+	Value string      `json:"value"`
+	Lease int64String `json:"lease"`
+}
+
+// Register puts the instance into etcd and keeps it alive until
+// Registrar.Close is called. If the lease expires, e.g. after
+// a network partition, the instance is registered again.
+func Register(ctx context.Context, config Config, registration Registration) (*Registrar, error) {
+	if registration.TTL <= 0 {
+		registration.TTL = defaultTTL
+	}
+
+	var names = make([]string, 0, len(registration.Methods))
+	for _, method := range registration.Methods {
+		if method.Streaming {
+			names = append(names, method.Name+"*")
+		} else {
+			names = append(names, method.Name)
+		}
+	}
+
+	c := newClient(config)
+	r := &Registrar{
+		client: c,
+		key:    c.config.serviceKey(registration.Service) + registration.Endpoint,
+		value:  strings.Join(names, ","),
+		ttl:    registration.TTL,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := r.register(ctx); err != nil {
+		return nil, err
+	}
+
+	go r.keepAlive()
+	return r, nil
+}
+
+// Err returns the last error of keeping the registration alive
+func (r *Registrar) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close revokes the lease, so the instance disappears immediately.
+// Next calls return the result of the first one.
+func (r *Registrar) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.close()
+	})
+	return r.closeErr
+}
+
+func (r *Registrar) close() error {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	lease := r.lease
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.ttl)
+	defer cancel()
+	return r.client.call(ctx, "/v3/lease/revoke", leaseRequest{ID: lease}, &struct{}{})
+}
+
+func (r *Registrar) register(ctx context.Context) error {
+	var lease leaseResponse
+	// etcd counts TTL in seconds
+	ttl := int64((r.ttl + time.Second - 1) / time.Second)
+	err := r.client.call(ctx, "/v3/lease/grant", grantRequest{TTL: ttl}, &lease)
+	if err != nil {
+		return err
+	}
+
+	err = r.client.call(ctx, "/v3/kv/put", putRequest{
+		Key:   encodeKey(r.key),
+		Value: encodeKey(r.value),
+		Lease: lease.ID,
+	}, &struct{}{})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.lease = lease.ID
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registrar) keepAlive() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+
+		err := r.refresh()
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registrar) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.ttl/3)
+	defer cancel()
+
+	r.mu.Lock()
+	lease := r.lease
+	r.mu.Unlock()
+
+	var response keepAliveResponse
+	err := r.client.call(ctx, "/v3/lease/keepalive", leaseRequest{ID: lease}, &response)
+	if err != nil {
+		return err
+	}
+
+	// etcd replies with zero TTL to expired leases
+	if response.Result.TTL <= 0 {
+		return r.register(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package etcd
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// Resolver resolves services to instances registered in etcd
+type Resolver struct {
+	client *client
+}
+
+// NewResolver creates a Resolver
+func NewResolver(config Config) *Resolver {
+	return &Resolver{client: newClient(config)}
+}
+
+type rangeRequest struct {
+	Key      string `json:"key"`
+	RangeEnd string `json:"range_end,omitempty"`
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type rangeResponse struct {
+	Kvs []keyValue `json:"kvs"`
+}
+
+// Resolve returns registered instances of the service.
+// Methods are taken from the first instance.
+func (r *Resolver) Resolve(ctx context.Context, name string) (*cocaine.ServiceInfo, error) {
+	prefix := r.client.config.serviceKey(name)
+
+	var response rangeResponse
+	err := r.client.call(ctx, "/v3/kv/range", rangeRequest{
+		Key:      encodeKey(prefix),
+		RangeEnd: encodeKey(prefixEnd(prefix)),
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Kvs) == 0 {
+		return nil, fmt.Errorf("service %s has no registered instances", name)
+	}
+
+	var (
+		endpoints = make([]string, 0, len(response.Kvs))
+		methods   []cocaine.Method
+	)
+	for i, kv := range response.Kvs {
+		key, err := decodeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, strings.TrimPrefix(key, prefix))
+
+		if i == 0 {
+			value, err := decodeKey(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			methods = cocaine.ParseMethods(strings.Split(value, ",")...)
+		}
+	}
+
+	return cocaine.NewServiceInfo(endpoints, methods...)
+}
@@ -0,0 +1,61 @@
+package cocaine12
+
+import (
+	"sort"
+	"strings"
+)
+
+// EventWildcard ends the name of an event making it a pattern,
+// which matches all events with the prefix before it, e.g. "images.*"
+// matches "images.resize" and "images.crop.square", "*" matches any event.
+// An event is handled by the handler of its exact name if any, otherwise
+// by the handler of the longest pattern matching it and by the fallback
+// handler as the last resort.
+const EventWildcard = "*"
+
+func isEventPattern(name string) bool {
+	return strings.HasSuffix(name, EventWildcard)
+}
+
+func matchEventPattern(pattern, event string) bool {
+	return strings.HasPrefix(event, strings.TrimSuffix(pattern, EventWildcard))
+}
+
+// eventPatterns are kept sorted from the most specific pattern
+type eventPatterns []string
+
+func (p eventPatterns) add(pattern string) eventPatterns {
+	for _, existing := range p {
+		if existing == pattern {
+			return p
+		}
+	}
+
+	p = append(p, pattern)
+	sort.Slice(p, func(i, j int) bool {
+		if len(p[i]) != len(p[j]) {
+			return len(p[i]) > len(p[j])
+		}
+		return p[i] < p[j]
+	})
+	return p
+}
+
+func (p eventPatterns) remove(pattern string) eventPatterns {
+	for i, existing := range p {
+		if existing == pattern {
+			return append(p[:i], p[i+1:]...)
+		}
+	}
+	return p
+}
+
+// match returns the most specific pattern matching the event
+func (p eventPatterns) match(event string) (string, bool) {
+	for _, pattern := range p {
+		if matchEventPattern(pattern, event) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package cocaine12
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExpvarName is the name of the variable published by PublishExpvar
+const ExpvarName = "cocaine"
+
+var (
+	publishExpvarOnce sync.Once
+
+	expvarMu       sync.Mutex
+	expvarWorker   *WorkerNG
+	expvarHandlers *EventHandlers
+
+	// number of successful reconnections of services
+	totalReconnects uint64
+	// number of channels of all services
+	openChannels int64
+)
+
+// PublishExpvar publishes internals of the framework as the ExpvarName
+// variable of expvar: bytes read and written by connections,
+// reconnections of services and the number of their open channels.
+// Use Worker.PublishExpvar to add statistics of the worker.
+// The variable is served by net/http/pprof handlers at /debug/vars.
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		expvar.Publish(ExpvarName, expvar.Func(expvarSnapshot))
+	})
+}
+
+// PublishExpvar publishes statistics of the worker along with the ones
+// of PublishExpvar: running handlers, open incoming channels,
+// per-event statistics and the latency of heartbeats
+func (w *WorkerNG) PublishExpvar() {
+	publishWorkerExpvar(w, nil)
+}
+
+func publishWorkerExpvar(w *WorkerNG, handlers *EventHandlers) {
+	expvarMu.Lock()
+	expvarWorker, expvarHandlers = w, handlers
+	expvarMu.Unlock()
+
+	PublishExpvar()
+}
+
+func expvarSnapshot() interface{} {
+	snapshot := map[string]interface{}{
+		"bytes_read":       atomic.LoadUint64(&totalWire.bytesRead),
+		"bytes_written":    atomic.LoadUint64(&totalWire.bytesWritten),
+		"reconnects":       atomic.LoadUint64(&totalReconnects),
+		"service_channels": atomic.LoadInt64(&openChannels),
+	}
+
+	expvarMu.Lock()
+	w, handlers := expvarWorker, expvarHandlers
+	expvarMu.Unlock()
+
+	if w == nil {
+		return snapshot
+	}
+
+	// QueueDepth is the number of sessions published by the loop
+	load := w.Load()
+	handlerCounts := map[string]interface{}{
+		"in_flight": load.InFlight,
+	}
+	if handlers != nil {
+		handlerCounts["registered"] = len(handlers.eventNames())
+	}
+	snapshot["handlers"] = handlerCounts
+	snapshot["channels"] = load.QueueDepth
+	snapshot["events"] = w.Stats()
+	snapshot["heartbeat_latency_ms"] = float64(w.HeartbeatLatency()) / float64(time.Millisecond)
+
+	return snapshot
+}
+
+// HeartbeatLatency returns how long cocaine-runtime took
+// to reply to the last heartbeat
+func (w *WorkerNG) HeartbeatLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&w.heartbeatLatency))
+}
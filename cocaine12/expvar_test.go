@@ -0,0 +1,94 @@
+package cocaine12
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestWireCounters(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	total := atomic.LoadUint64(&totalWire.bytesRead)
+	sock2.Write() <- newInvokeV1(2, "test")
+	<-sock.Read()
+
+	read := atomic.LoadUint64(&sock.wire.bytesRead)
+	assert.True(t, read > 0)
+	assert.True(t, atomic.LoadUint64(&totalWire.bytesRead) >= total+read)
+}
+
+type expvarSnapshotJSON struct {
+	Handlers struct {
+		Registered int
+	}
+	Events             map[string]EventStats
+	HeartbeatLatencyMs float64 `json:"heartbeat_latency_ms"`
+	Channels           int
+}
+
+func readExpvar(t *testing.T) expvarSnapshotJSON {
+	var snapshot expvarSnapshotJSON
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(ExpvarName).String()), &snapshot))
+	return snapshot
+}
+
+func TestPublishExpvar(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	clock := NewManualClock(time.Now())
+	w.impl.SetClock(clock)
+	w.PublishExpvar()
+
+	release := make(chan struct{})
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			<-release
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	// the heartbeat is timed before it's sent
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	clock.Advance(20 * time.Millisecond)
+	sock2.Write() <- newHeartbeatV1()
+	sock2.Write() <- newInvokeV1(2, "test")
+
+	// the number of sessions is published by the loop
+	deadline := time.Now().Add(time.Second)
+	for readExpvar(t).Channels != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1, readExpvar(t).Channels)
+
+	close(release)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	// the call is counted once the handler has returned
+	snapshot := readExpvar(t)
+	for snapshot.Events["test"].Calls == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		snapshot = readExpvar(t)
+	}
+	// "test" and InfoEvent
+	assert.Equal(t, 2, snapshot.Handlers.Registered)
+	assert.Equal(t, uint64(1), snapshot.Events["test"].Calls)
+	assert.Equal(t, float64(20), snapshot.HeartbeatLatencyMs)
+}
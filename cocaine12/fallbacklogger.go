@@ -3,7 +3,9 @@ package cocaine12
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -25,18 +27,28 @@ func (f *fallbackLogger) WithFields(fields Fields) *Entry {
 	}
 }
 
+func (f *fallbackLogger) Writer(level Severity) io.Writer {
+	return newLogWriter(f, defaultFields, level)
+}
+
 func (f *fallbackLogger) formatFields(fields Fields) string {
 	if len(fields) == 0 {
 		return "[ ]"
 	}
 
+	fields = redactFields(fields)
+
 	var b bytes.Buffer
 	b.WriteByte('[')
 	b.WriteByte(' ')
 	for k, v := range fields {
 		b.WriteString(k)
 		b.WriteByte('=')
-		b.WriteString(fmt.Sprint(v))
+		if t, ok := v.(time.Time); ok {
+			b.WriteString(t.Format(time.RFC3339Nano))
+		} else {
+			b.WriteString(fmt.Sprint(v))
+		}
 		b.WriteByte(' ')
 	}
 	b.WriteByte(']')
@@ -45,7 +57,7 @@ func (f *fallbackLogger) formatFields(fields Fields) string {
 }
 
 func (f *fallbackLogger) V(level Severity) bool {
-	return level >= f.severity.get()
+	return runtimeSeverity(level) >= f.severity.get()
 }
 
 func (f *fallbackLogger) log(level Severity, fields Fields, msg string, args ...interface{}) {
@@ -60,6 +72,17 @@ func (f *fallbackLogger) log(level Severity, fields Fields, msg string, args ...
 	}
 }
 
+func (f *fallbackLogger) Fatalf(format string, args ...interface{}) {
+	f.log(FatalLevel, defaultFields, format, args...)
+	terminateOnFatal(fmt.Sprintf(format, args...))
+}
+
+func (f *fallbackLogger) Fatal(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	f.log(FatalLevel, defaultFields, msg)
+	terminateOnFatal(msg)
+}
+
 func (f *fallbackLogger) Errf(format string, args ...interface{}) {
 	f.log(ErrorLevel, defaultFields, format, args...)
 }
@@ -92,6 +115,14 @@ func (f *fallbackLogger) Debug(args ...interface{}) {
 	f.log(DebugLevel, defaultFields, fmt.Sprint(args...))
 }
 
+func (f *fallbackLogger) Tracef(format string, args ...interface{}) {
+	f.log(TraceLevel, defaultFields, format, args...)
+}
+
+func (f *fallbackLogger) Trace(args ...interface{}) {
+	f.log(TraceLevel, defaultFields, fmt.Sprint(args...))
+}
+
 func (f *fallbackLogger) Verbosity(context.Context) Severity {
 	return f.severity.get()
 }
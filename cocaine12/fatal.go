@@ -0,0 +1,55 @@
+package cocaine12
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// how long a fatal entry is waited to be written
+const fatalFlushTimeout = time.Second * 5
+
+var (
+	fatalMu sync.Mutex
+	// running workers shut down on fatal entries
+	fatalWorkers = make(map[*WorkerNG]struct{})
+	// the process exits if no worker is running
+	fatalExit = os.Exit
+)
+
+// terminateOnFatal is called after a fatal entry is written
+func terminateOnFatal(message string) {
+	fatalMu.Lock()
+	defer fatalMu.Unlock()
+
+	if len(fatalWorkers) == 0 {
+		fatalExit(1)
+		return
+	}
+
+	for w := range fatalWorkers {
+		w.onFatal(message)
+	}
+}
+
+func registerFatalWorker(w *WorkerNG) {
+	fatalMu.Lock()
+	fatalWorkers[w] = struct{}{}
+	fatalMu.Unlock()
+}
+
+func unregisterFatalWorker(w *WorkerNG) {
+	fatalMu.Lock()
+	delete(fatalWorkers, w)
+	fatalMu.Unlock()
+}
+
+// onFatal makes the loop shut down the worker like recycling does.
+// It's called from any goroutine and never blocks.
+func (w *WorkerNG) onFatal(message string) {
+	select {
+	case w.fatals <- message:
+	default:
+		// the worker is already shutting down
+	}
+}
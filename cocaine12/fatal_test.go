@@ -0,0 +1,90 @@
+package cocaine12
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRuntimeSeverity(t *testing.T) {
+	assert.Equal(t, DebugLevel, runtimeSeverity(TraceLevel))
+	assert.Equal(t, Severity(ErrorLevel), runtimeSeverity(FatalLevel))
+	assert.Equal(t, WarnLevel, runtimeSeverity(WarnLevel))
+
+	SetSeverityMapping(map[Severity]Severity{TraceLevel: InfoLevel})
+	defer SetSeverityMapping(map[Severity]Severity{
+		TraceLevel: DebugLevel,
+		FatalLevel: ErrorLevel,
+	})
+	assert.Equal(t, InfoLevel, runtimeSeverity(TraceLevel))
+	// out of the range of the runtime
+	assert.Equal(t, Severity(ErrorLevel), runtimeSeverity(FatalLevel))
+
+	logger := &fallbackLogger{severity: InfoLevel}
+	assert.True(t, logger.V(TraceLevel))
+}
+
+func TestFatalWithoutWorker(t *testing.T) {
+	var code int
+	fatalExit = func(c int) { code = c }
+	defer func() { fatalExit = os.Exit }()
+
+	// workers of other tests may still be running
+	fatalMu.Lock()
+	running := fatalWorkers
+	fatalWorkers = make(map[*WorkerNG]struct{})
+	fatalMu.Unlock()
+	defer func() {
+		fatalMu.Lock()
+		fatalWorkers = running
+		fatalMu.Unlock()
+	}()
+
+	recorder := new(recordingLogger)
+	recorder.WithFields(Fields{"a": 1}).Fatalf("unable to %s", "start")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, []recordedEntry{
+		{FatalLevel, Fields{"a": 1}, "unable to start"},
+	}, recorder.recorded())
+}
+
+func TestWorkerFatal(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	recorder := new(recordingLogger)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(map[string]EventHandler{
+			"fatal": func(ctx context.Context, req Request, res Response) {
+				recorder.WithFields(nil).Fatal("storage is corrupted")
+				res.Close()
+			},
+		})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "fatal")
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, v1UtilitySession, v1Terminate)
+	assert.Equal(t, "fatal error: storage is corrupted", parseTerminationReason(msg).Message)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is still running")
+	}
+}
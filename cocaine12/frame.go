@@ -0,0 +1,506 @@
+package cocaine12
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	defaultMaxFrameSize      = 128 * 1024 * 1024
+	defaultMaxFrameDepth     = 64
+	defaultMaxHeaderListSize = 64 * 1024
+
+	// an overhead of a header entry, see SETTINGS_MAX_HEADER_LIST_SIZE of HTTP/2
+	headerEntryOverhead = 32
+)
+
+var (
+	// ErrFrameTooLarge means that an incoming message exceeds MaxFrameSize
+	ErrFrameTooLarge = errors.New("frame exceeds the maximum size")
+	// ErrFrameTooDeep means that an incoming message is nested deeper than MaxFrameDepth
+	ErrFrameTooDeep = errors.New("frame exceeds the maximum depth")
+	// ErrMalformedFrame means that an incoming message is not a valid msgpack
+	ErrMalformedFrame = errors.New("malformed frame")
+	// ErrHeaderListTooLarge means that headers of an incoming message exceed MaxHeaderListSize
+	ErrHeaderListTooLarge = errors.New("header list exceeds the maximum size")
+)
+
+// frameReader cuts a stream into msgpack objects checking their size
+// and nesting before they are decoded, so a malicious peer can not make
+// the decoder allocate huge buffers by declaring huge lengths.
+type frameReader struct {
+	r        *bufio.Reader
+	maxSize  int
+	maxDepth int
+	// limits decoded headers of a message, zero disables the limit
+	maxHeaderListSize int
+
+	buf []byte
+	// bytes of the current message read so far
+	size uint64
+	// holds numbers of the envelope read by take
+	scratch [8]byte
+}
+
+func newFrameReader(r io.Reader, maxSize, maxDepth int) *frameReader {
+	return &frameReader{
+		r:        bufio.NewReader(r),
+		maxSize:  maxSize,
+		maxDepth: maxDepth,
+	}
+}
+
+// next returns raw bytes of the next msgpack object.
+// A new buffer is allocated for every object, as the decoder
+// doesn't copy byte slices out of it.
+func (f *frameReader) next() ([]byte, error) {
+	f.buf, f.size = nil, 0
+	if err := f.readObject(1); err != nil {
+		return nil, err
+	}
+	return f.buf, nil
+}
+
+// readMessage reads the next message right from the stream.
+// The session and the type are parsed as they arrive and chunks of bytes,
+// which most payloads consist of, are read into slices of their exact
+// size, so the frame is never buffered as a whole. Other items of
+// the payload and headers are cut by readObject and decoded one by one.
+func (f *frameReader) readMessage() (*Message, error) {
+	f.buf, f.size = nil, 0
+
+	fields, err := f.readArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg Message
+	for i := uint64(0); i < fields; i++ {
+		switch i {
+		case 0:
+			msg.Session, err = f.readUint64()
+		case 1:
+			msg.MsgType, err = f.readUint64()
+		case 2:
+			msg.Payload, err = f.readPayload()
+		case 3:
+			err = f.decodeObject(2, &msg.Headers)
+		default:
+			// unknown fields are skipped as the decoder does
+			f.buf = nil
+			err = f.readObject(2)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.maxHeaderListSize > 0 && headerListSize(msg.Headers) > f.maxHeaderListSize {
+		return nil, ErrHeaderListTooLarge
+	}
+
+	return &msg, nil
+}
+
+// readPayload reads an array of the payload at the depth 2
+func (f *frameReader) readPayload() ([]interface{}, error) {
+	code, err := f.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if code[0] == 0xc0 {
+		_, err = f.take(1)
+		return nil, err
+	}
+
+	n, err := f.readArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	// items of the payload are at the depth 3
+	if n > 0 && f.maxDepth > 0 && f.maxDepth < 3 {
+		return nil, ErrFrameTooDeep
+	}
+
+	// every item takes one byte at least
+	if err = f.reserve(n); err != nil {
+		return nil, err
+	}
+
+	payload := make([]interface{}, n)
+	for i := range payload {
+		data, isBytes, err := f.readBytesObject()
+		if err != nil {
+			return nil, err
+		}
+
+		if isBytes {
+			payload[i] = data
+			continue
+		}
+
+		if err = f.decodeObject(3, &payload[i]); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+// readBytesObject reads str or bin into a new slice if the next object
+// is of these types
+func (f *frameReader) readBytesObject() ([]byte, bool, error) {
+	code, err := f.r.Peek(1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var length uint64
+	switch c := code[0]; {
+	case c >= 0xa0 && c <= 0xbf:
+		if _, err = f.take(1); err != nil {
+			return nil, true, err
+		}
+		length = uint64(c & 0x1f)
+	case c == 0xc4, c == 0xd9:
+		length, err = f.takeLength(1)
+	case c == 0xc5, c == 0xda:
+		length, err = f.takeLength(2)
+	case c == 0xc6, c == 0xdb:
+		length, err = f.takeLength(4)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	if err = f.reserve(length); err != nil {
+		return nil, true, err
+	}
+	// the decoder unpacks empty ones as nil too
+	var data []byte
+	if length > 0 {
+		data = make([]byte, length)
+		if _, err = io.ReadFull(f.r, data); err != nil {
+			return nil, true, err
+		}
+		f.size += length
+	}
+	return data, true, nil
+}
+
+// decodeObject cuts the next object at the depth and decodes it into v.
+// It never panics on corrupted input.
+func (f *frameReader) decodeObject(depth int, v interface{}) (err error) {
+	// a new buffer for every object, as the decoder keeps
+	// data of unknown extensions pointing into it
+	f.buf = nil
+	if err = f.readObject(depth); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: %v", ErrMalformedFrame, r)
+		}
+	}()
+	return codec.NewDecoderBytes(f.buf, hAsocket).Decode(v)
+}
+
+// readArrayLen reads a header of an array
+func (f *frameReader) readArrayLen() (uint64, error) {
+	b, err := f.take(1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch code := b[0]; {
+	case code >= 0x90 && code <= 0x9f:
+		return uint64(code & 0x0f), nil
+	case code == 0xdc, code == 0xdd:
+		return f.takeUint(2 << (code - 0xdc))
+	default:
+		return 0, ErrMalformedFrame
+	}
+}
+
+// readUint64 reads a non-negative integer or nil as zero
+func (f *frameReader) readUint64() (uint64, error) {
+	b, err := f.take(1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch code := b[0]; {
+	case code <= 0x7f:
+		return uint64(code), nil
+	case code == 0xc0:
+		return 0, nil
+	case code >= 0xcc && code <= 0xcf:
+		return f.takeUint(1 << (code - 0xcc))
+	case code >= 0xd0 && code <= 0xd3:
+		size := 1 << (code - 0xd0)
+		v, err := f.takeUint(size)
+		if err != nil {
+			return 0, err
+		}
+		// the sign bit of the integer
+		if v>>uint(size*8-1) != 0 {
+			return 0, ErrMalformedFrame
+		}
+		return v, nil
+	default:
+		return 0, ErrMalformedFrame
+	}
+}
+
+// takeLength skips the type byte of str or bin and reads its length
+func (f *frameReader) takeLength(size int) (uint64, error) {
+	if _, err := f.take(1); err != nil {
+		return 0, err
+	}
+	return f.takeUint(size)
+}
+
+// takeUint reads a big-endian integer of 1, 2, 4 or 8 bytes
+func (f *frameReader) takeUint(size int) (uint64, error) {
+	b, err := f.take(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// take reads up to 8 bytes of the current message without buffering them
+func (f *frameReader) take(n int) ([]byte, error) {
+	if err := f.reserve(uint64(n)); err != nil {
+		return nil, err
+	}
+
+	b := f.scratch[:n]
+	if _, err := io.ReadFull(f.r, b); err != nil {
+		return nil, err
+	}
+	f.size += uint64(n)
+	return b, nil
+}
+
+// decodeMessage decodes a frame returned by next
+func (f *frameReader) decodeMessage(frame []byte) (*Message, error) {
+	msg, err := decodeFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.maxHeaderListSize > 0 && headerListSize(msg.Headers) > f.maxHeaderListSize {
+		return nil, ErrHeaderListTooLarge
+	}
+
+	return msg, nil
+}
+
+// headerListSize counts the size of headers as HTTP/2 does:
+// lengths of a name and a value plus 32 bytes of overhead for each entry.
+// Indices are counted as names from DefaultHeaderTable.
+func headerListSize(headers CocaineHeaders) int {
+	var size = 0
+	for _, raw := range headers {
+		size += headerEntryOverhead
+
+		tuple, ok := raw.([]interface{})
+		if !ok || len(tuple) < 3 {
+			continue
+		}
+
+		switch key := tuple[1].(type) {
+		case string:
+			size += len(key)
+		case []byte:
+			size += len(key)
+		default:
+			if index, isIndex := headerIndex(key); isIndex {
+				name, _ := DefaultHeaderTable.Name(index)
+				size += len(name)
+			}
+		}
+
+		switch value := tuple[2].(type) {
+		case string:
+			size += len(value)
+		case []byte:
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// decodeFrame decodes a message from a complete msgpack object.
+// It never panics on corrupted input.
+func decodeFrame(frame []byte) (msg *Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, err = nil, fmt.Errorf("%v: %v", ErrMalformedFrame, r)
+		}
+	}()
+
+	if err = codec.NewDecoderBytes(frame, hAsocket).Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	// msgpack nil is decoded as nil pointer
+	if msg == nil {
+		return nil, ErrMalformedFrame
+	}
+
+	return msg, nil
+}
+
+// reserve checks that n more bytes fit into the size limit
+func (f *frameReader) reserve(n uint64) error {
+	if f.maxSize > 0 && f.size+n > uint64(f.maxSize) {
+		return ErrFrameTooLarge
+	}
+	return nil
+}
+
+func (f *frameReader) read(n uint64) ([]byte, error) {
+	if err := f.reserve(n); err != nil {
+		return nil, err
+	}
+
+	start := len(f.buf)
+	f.buf = append(f.buf, make([]byte, n)...)
+	if _, err := io.ReadFull(f.r, f.buf[start:]); err != nil {
+		return nil, err
+	}
+	f.size += n
+	return f.buf[start:], nil
+}
+
+func (f *frameReader) readUint(size int) (uint64, error) {
+	b, err := f.read(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	default:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+}
+
+func (f *frameReader) readObject(depth int) error {
+	if f.maxDepth > 0 && depth > f.maxDepth {
+		return ErrFrameTooDeep
+	}
+
+	b, err := f.read(1)
+	if err != nil {
+		return err
+	}
+
+	code := b[0]
+	switch {
+	case code <= 0x7f, code >= 0xe0:
+		// fixint
+		return nil
+	case code <= 0x8f:
+		return f.readItems(uint64(code&0x0f)*2, depth)
+	case code <= 0x9f:
+		return f.readItems(uint64(code&0x0f), depth)
+	case code <= 0xbf:
+		// fixstr
+		_, err = f.read(uint64(code & 0x1f))
+		return err
+	}
+
+	switch code {
+	case 0xc0, 0xc2, 0xc3:
+		// nil, false, true
+		return nil
+	case 0xcc, 0xd0:
+		_, err = f.read(1)
+	case 0xcd, 0xd1:
+		_, err = f.read(2)
+	case 0xca, 0xce, 0xd2:
+		_, err = f.read(4)
+	case 0xcb, 0xcf, 0xd3:
+		_, err = f.read(8)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext: a type and 1, 2, 4, 8 or 16 bytes of data
+		_, err = f.read(1 + 1<<(code-0xd4))
+	case 0xc4, 0xd9:
+		err = f.readBytes(1, 0)
+	case 0xc5, 0xda:
+		err = f.readBytes(2, 0)
+	case 0xc6, 0xdb:
+		err = f.readBytes(4, 0)
+	case 0xc7:
+		err = f.readBytes(1, 1)
+	case 0xc8:
+		err = f.readBytes(2, 1)
+	case 0xc9:
+		err = f.readBytes(4, 1)
+	case 0xdc, 0xdd:
+		var n uint64
+		if n, err = f.readUint(2 << (code - 0xdc)); err == nil {
+			err = f.readItems(n, depth)
+		}
+	case 0xde, 0xdf:
+		var n uint64
+		if n, err = f.readUint(2 << (code - 0xde)); err == nil {
+			err = f.readItems(n*2, depth)
+		}
+	default:
+		return ErrMalformedFrame
+	}
+
+	return err
+}
+
+// readBytes reads a length of lenSize bytes followed by
+// extra bytes (an ext type) and the data itself
+func (f *frameReader) readBytes(lenSize int, extra uint64) error {
+	n, err := f.readUint(lenSize)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.read(extra + n)
+	return err
+}
+
+func (f *frameReader) readItems(n uint64, depth int) error {
+	// every item takes one byte at least
+	if err := f.reserve(n); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		if err := f.readObject(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
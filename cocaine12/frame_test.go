@@ -0,0 +1,222 @@
+package cocaine12
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+func TestFrameReader(t *testing.T) {
+	var (
+		buf  []byte
+		msgs = []interface{}{
+			newInvokeV1(2, "event"),
+			newChunkV1(2, bytes.Repeat([]byte("A"), 70000)),
+			newErrorV1(2, 42, 100, "error"),
+			map[string]interface{}{"float": 1.5, "neg": -100, "big": uint64(1 << 40), "nil": nil},
+		}
+	)
+
+	var frames [][]byte
+	for _, msg := range msgs {
+		var frame []byte
+		codec.NewEncoderBytes(&frame, hAsocket).MustEncode(msg)
+		frames = append(frames, frame)
+		buf = append(buf, frame...)
+	}
+
+	r := newFrameReader(bytes.NewReader(buf), defaultMaxFrameSize, defaultMaxFrameDepth)
+	for _, expected := range frames {
+		frame, err := r.next()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, frame)
+	}
+}
+
+func TestFrameReaderLimits(t *testing.T) {
+	var frame []byte
+	codec.NewEncoderBytes(&frame, hAsocket).MustEncode(newChunkV1(2, make([]byte, 1024)))
+
+	_, err := newFrameReader(bytes.NewReader(frame), 1000, 0).next()
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	// array32 declaring 2^32-1 items without data
+	_, err = newFrameReader(bytes.NewReader([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}), 1024, 0).next()
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	// bin32 declaring 4GB
+	_, err = newFrameReader(bytes.NewReader([]byte{0xc6, 0xff, 0xff, 0xff, 0xff}), 1024, 0).next()
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	nested := append(bytes.Repeat([]byte{0x91}, 100), 0x01)
+	_, err = newFrameReader(bytes.NewReader(nested), 0, 10).next()
+	assert.Equal(t, ErrFrameTooDeep, err)
+
+	_, err = newFrameReader(bytes.NewReader(nested), 0, 0).next()
+	assert.NoError(t, err)
+
+	_, err = newFrameReader(bytes.NewReader([]byte{0xc1}), 0, 0).next()
+	assert.Equal(t, ErrMalformedFrame, err)
+}
+
+func TestFrameReaderHeaderListSize(t *testing.T) {
+	msg := newChunkV1(2, []byte("data"))
+	msg.Headers = CocaineHeaders{
+		[]interface{}{false, "x-request-id", "abcdef"},
+		[]interface{}{false, traceId, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+	// (32 + 12 + 6) + (32 + len("trace_id") + 8)
+	assert.Equal(t, 98, headerListSize(msg.Headers))
+
+	var frame []byte
+	codec.NewEncoderBytes(&frame, hAsocket).MustEncode(msg)
+
+	r := newFrameReader(bytes.NewReader(frame), 0, 0)
+	r.maxHeaderListSize = 97
+	_, err := r.readMessage()
+	assert.Equal(t, ErrHeaderListTooLarge, err)
+
+	r = newFrameReader(bytes.NewReader(frame), 0, 0)
+	r.maxHeaderListSize = 98
+	decoded, err := r.readMessage()
+	assert.NoError(t, err)
+	assert.Len(t, decoded.Headers, 2)
+
+	r = newFrameReader(bytes.NewReader(frame), 0, 0)
+	_, err = r.readMessage()
+	assert.NoError(t, err)
+}
+
+// nopProtocolHandler exercises worker-side parsing of messages
+type nopProtocolHandler struct{}
+
+func (nopProtocolHandler) onChoke(msg *Message)     {}
+func (nopProtocolHandler) onChunk(msg *Message)     {}
+func (nopProtocolHandler) onError(msg *Message)     {}
+func (nopProtocolHandler) onHeartbeat(msg *Message) {}
+func (nopProtocolHandler) onTerminate(msg *Message) { parseTerminationReason(msg) }
+func (nopProtocolHandler) onInvoke(msg *Message) error {
+	getEventName(msg)
+	msg.Headers.getTraceData()
+	return nil
+}
+
+func dispatchFuzzMessage(msg *Message) {
+	newV1Protocol().onMessage(nopProtocolHandler{}, msg)
+
+	req := newRequest(newV1Protocol())
+	go func() {
+		req.push(msg)
+		req.Close()
+	}()
+	req.Read(context.Background())
+}
+
+func TestDecodeFrameCorrupted(t *testing.T) {
+	var frame []byte
+	codec.NewEncoderBytes(&frame, hAsocket).MustEncode(newErrorV1(2, 42, 100, "error"))
+
+	// every truncated or mutated frame must be rejected or decoded without a panic
+	for i := 0; i < len(frame); i++ {
+		if _, err := newFrameReader(bytes.NewReader(frame[:i]), 0, 0).readMessage(); err == nil {
+			t.Fatalf("truncated frame %x is accepted", frame[:i])
+		}
+
+		for _, b := range []byte{0x00, 0xc0, 0xc1, 0xff, 0x90, 0xdc} {
+			mutated := append([]byte(nil), frame...)
+			mutated[i] = b
+			if msg, err := newFrameReader(bytes.NewReader(mutated), 0, 0).readMessage(); err == nil {
+				dispatchFuzzMessage(msg)
+			}
+		}
+	}
+
+	for _, input := range [][]byte{{0xc0}, {0x90}, {0x93, 0x02, 0x00, 0x90}, {0x93, 0x02, 0x00, 0xc0}} {
+		if msg, err := decodeFrame(input); err == nil {
+			dispatchFuzzMessage(msg)
+		}
+	}
+}
+
+func TestFrameReaderReadMessage(t *testing.T) {
+	withHeaders := newErrorV1(3, 42, 100, "error")
+	withHeaders.Headers = CocaineHeaders{[]interface{}{false, "x-request-id", []byte("1")}}
+
+	msgs := []*Message{
+		newInvokeV1(2, "event"),
+		newChunkV1(2, bytes.Repeat([]byte("A"), 70000)),
+		newChunkV1(2, []byte{}),
+		withHeaders,
+		{CommonMessageInfo: CommonMessageInfo{Session: 1 << 40, MsgType: 0}, Payload: []interface{}{int64(-1), 1.5, nil, map[interface{}]interface{}{"a": []interface{}{"b"}}}},
+		{CommonMessageInfo: CommonMessageInfo{Session: 4, MsgType: 2}},
+	}
+
+	var buf []byte
+	for _, msg := range msgs {
+		var frame []byte
+		codec.NewEncoderBytes(&frame, hAsocket).MustEncode(msg)
+		buf = append(buf, frame...)
+	}
+	// legacy raw 16 chunk of an old runtime
+	buf = append(buf, 0x93, 0x05, 0x00, 0x91, 0xda, 0x00, 0x02, 'o', 'k')
+
+	r := newFrameReader(bytes.NewReader(buf), defaultMaxFrameSize, defaultMaxFrameDepth)
+	for _, msg := range msgs {
+		var frame []byte
+		codec.NewEncoderBytes(&frame, hAsocket).MustEncode(msg)
+		expected, err := decodeFrame(frame)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		decoded, err := r.readMessage()
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, decoded)
+		}
+	}
+
+	decoded, err := r.readMessage()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{[]byte("ok")}, decoded.Payload)
+	}
+}
+
+func TestFrameReaderReadMessageLimits(t *testing.T) {
+	var frame []byte
+	codec.NewEncoderBytes(&frame, hAsocket).MustEncode(newChunkV1(2, make([]byte, 1024)))
+
+	_, err := newFrameReader(bytes.NewReader(frame), 1000, 0).readMessage()
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	// bin32 declaring 4GB
+	_, err = newFrameReader(bytes.NewReader([]byte{0x93, 0x02, 0x00, 0x91, 0xc6, 0xff, 0xff, 0xff, 0xff}), 1024, 0).readMessage()
+	assert.Equal(t, ErrFrameTooLarge, err)
+
+	nested := append([]byte{0x93, 0x02, 0x00}, bytes.Repeat([]byte{0x91}, 100)...)
+	_, err = newFrameReader(bytes.NewReader(append(nested, 0x01)), 0, 10).readMessage()
+	assert.Equal(t, ErrFrameTooDeep, err)
+
+	// a negative session
+	_, err = newFrameReader(bytes.NewReader([]byte{0x93, 0xff, 0x00, 0x90}), 0, 0).readMessage()
+	assert.Equal(t, ErrMalformedFrame, err)
+}
+
+func BenchmarkFrameReaderChunk(b *testing.B) {
+	var frame []byte
+	codec.NewEncoderBytes(&frame, hAsocket).MustEncode(newChunkV1(2, make([]byte, 4096)))
+	stream := bytes.Repeat(frame, b.N)
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	r := newFrameReader(bytes.NewReader(stream), 0, 0)
+	for i := 0; i < b.N; i++ {
+		if _, err := r.readMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cocaine12
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// CallFuture is a pending call started by Service.Go
+type CallFuture struct {
+	// the reply may arrive before the call returns the channel,
+	// so done is closed once both have happened
+	mu      sync.Mutex
+	ch      Channel
+	arrived bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	resultOnce sync.Once
+	res        ServiceResult
+	err        error
+}
+
+// Go calls the method asynchronously. No goroutine is spawned for the call:
+// the future is completed by the connection once the reply arrives, so
+// many calls can be fanned out and their Done channels selected over.
+// ctx is not watched after the call is sent, select on ctx.Done()
+// along with Done() to stop waiting. Like Call, it waits for the backoff
+// hinted by the upstream before the call is sent.
+func (service *Service) Go(ctx context.Context, name string, args ...interface{}) *CallFuture {
+	f := &CallFuture{
+		done: make(chan struct{}),
+	}
+
+	if err := service.waitBackoff(ctx, name); err != nil {
+		f.fail(err)
+		return f
+	}
+
+	if err := service.reconnectIfNeeded(ctx); err != nil {
+		f.fail(err)
+		return f
+	}
+
+	ch, err := service.callNotify(ctx, f.complete, name, args...)
+	if err != nil {
+		f.fail(err)
+		return f
+	}
+	service.shadow(ctx, name, args...)
+
+	f.mu.Lock()
+	f.ch = ch
+	arrived := f.arrived
+	f.mu.Unlock()
+
+	if arrived {
+		f.close()
+	}
+	return f
+}
+
+// Done is closed once the result is available
+func (f *CallFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the reply and returns it. An error reply of
+// the service is returned as the error. It may be called several times.
+func (f *CallFuture) Result() (ServiceResult, error) {
+	<-f.done
+	f.resultOnce.Do(func() {
+		if f.ch == nil {
+			return
+		}
+
+		f.res, f.err = f.ch.Get(context.Background())
+		if f.err == nil {
+			f.err = f.res.Err()
+		}
+	})
+	return f.res, f.err
+}
+
+// Channel returns the channel of the call to receive further results
+// of a streaming method. It's nil if the call has failed.
+func (f *CallFuture) Channel() Channel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ch
+}
+
+// complete is called by the channel after a result is pushed
+func (f *CallFuture) complete() {
+	f.mu.Lock()
+	f.arrived = true
+	ready := f.ch != nil
+	f.mu.Unlock()
+
+	if ready {
+		f.close()
+	}
+}
+
+func (f *CallFuture) fail(err error) {
+	f.resultOnce.Do(func() {
+		f.err = err
+	})
+	f.close()
+}
+
+func (f *CallFuture) close() {
+	f.closeOnce.Do(func() {
+		close(f.done)
+	})
+}
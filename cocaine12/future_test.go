@@ -0,0 +1,86 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newTestStorage(t *testing.T) (*Service, socketIO) {
+	info, err := NewServiceInfo([]string{"127.0.0.1:10053"}, Method{Name: "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, peer := newTestService(t, "storage")
+	service.ServiceInfo = info
+	go service.loop()
+	return service, peer
+}
+
+func TestServiceGo(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	ctx := context.Background()
+	futures := make([]*CallFuture, 3)
+	for i := range futures {
+		futures[i] = service.Go(ctx, "ping", i)
+	}
+
+	sessions := make([]uint64, len(futures))
+	for i := range sessions {
+		sessions[i] = (<-peer.Read()).Session
+	}
+
+	for _, f := range futures {
+		select {
+		case <-f.Done():
+			t.Fatal("future is completed before the reply")
+		default:
+		}
+	}
+
+	// replies in the reverse order
+	peer.Write() <- &Message{CommonMessageInfo{sessions[2], 0}, []interface{}{"third"}, nil, nil}
+	peer.Write() <- newErrorV1(sessions[1], 42, ErrorBadRequest, "bad request")
+	peer.Write() <- &Message{CommonMessageInfo{sessions[0], 0}, []interface{}{"first"}, nil, nil}
+
+	select {
+	case <-futures[2].Done():
+	case <-time.After(time.Second):
+		t.Fatal("future is not completed")
+	}
+
+	res, err := futures[2].Result()
+	if assert.NoError(t, err) {
+		var reply string
+		assert.NoError(t, res.ExtractTuple(&reply))
+		assert.Equal(t, "third", reply)
+	}
+
+	_, err = futures[1].Result()
+	if assert.IsType(t, &ErrRequest{}, err) {
+		assert.Equal(t, ErrorBadRequest, err.(*ErrRequest).Code)
+	}
+
+	res, err = futures[0].Result()
+	assert.NoError(t, err)
+	// the result is kept
+	res2, err := futures[0].Result()
+	assert.NoError(t, err)
+	assert.Equal(t, res, res2)
+}
+
+func TestServiceGoUnknownMethod(t *testing.T) {
+	service, _ := newTestStorage(t)
+	defer service.Close()
+
+	f := service.Go(context.Background(), "unknown")
+	<-f.Done()
+	_, err := f.Result()
+	assert.Error(t, err)
+	assert.Nil(t, f.Channel())
+}
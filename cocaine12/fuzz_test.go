@@ -0,0 +1,41 @@
+//go:build go1.18
+// +build go1.18
+
+package cocaine12
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+// FuzzDecodeFrame feeds arbitrary bytes from the wire
+// through the frame reader, the decoder and the dispatcher.
+// Run with: go test -fuzz=FuzzDecodeFrame
+func FuzzDecodeFrame(f *testing.F) {
+	for _, msg := range []*Message{
+		newHandshakeV1("uuid"),
+		newHeartbeatV1(),
+		newInvokeV1(2, "event"),
+		newChunkV1(2, []byte("data")),
+		newErrorV1(2, 42, 100, "error"),
+		newChokeV1(2),
+		newTerminateV1(TerminationReason{TerminationNormal, "stop"}),
+	} {
+		var frame []byte
+		codec.NewEncoderBytes(&frame, hAsocket).MustEncode(msg)
+		f.Add(frame)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := newFrameReader(bytes.NewReader(data), 1024*1024, defaultMaxFrameDepth)
+		for {
+			msg, err := r.readMessage()
+			if err != nil {
+				return
+			}
+			dispatchFuzzMessage(msg)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package grpcbridge
+
+import (
+	"io"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// Enqueuer invokes events of an application. It's implemented by *cocaine.App
+type Enqueuer interface {
+	Enqueue(ctx context.Context, event string, payload []byte) (cocaine.Stream, error)
+}
+
+// ClientOption configures ClientConn
+type ClientOption func(*ClientConn)
+
+// WithClientCodec sets the codec of messages. Protobuf is used by default.
+func WithClientCodec(codec encoding.Codec) ClientOption {
+	return func(c *ClientConn) {
+		c.codec = codec
+	}
+}
+
+// ClientConn implements grpc.ClientConnInterface by invoking events
+// of an application served by Server, so generated gRPC clients
+// call the application:
+//
+//	app, err := cocaine.NewApp(ctx, "greeter", nil)
+//	client := pb.NewGreeterClient(grpcbridge.NewClientConn(app))
+//
+// Outgoing gRPC metadata is sent along with the outgoing cocaine metadata.
+// Headers are received with the first message of the response
+// and trailers with its end or the error, see grpc.Header and grpc.Trailer.
+type ClientConn struct {
+	app   Enqueuer
+	codec encoding.Codec
+}
+
+var _ grpc.ClientConnInterface = (*ClientConn)(nil)
+
+// NewClientConn creates ClientConn calling the application
+func NewClientConn(app Enqueuer, opts ...ClientOption) *ClientConn {
+	c := &ClientConn{
+		app:   app,
+		codec: encoding.GetCodec(defaultCodec),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+var unaryStreamDesc = &grpc.StreamDesc{}
+
+// Invoke performs a unary call
+func (c *ClientConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	cs, err := c.NewStream(ctx, unaryStreamDesc, method, opts...)
+	if err != nil {
+		return err
+	}
+
+	stream := cs.(*clientStream)
+	defer stream.fillCallOptions(opts)
+
+	if err := stream.SendMsg(args); err != nil {
+		stream.abort(err)
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		stream.abort(err)
+		return err
+	}
+	return stream.RecvMsg(reply)
+}
+
+// NewStream begins a streaming call
+func (c *ClientConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx = outgoingContext(ctx)
+	stream, err := c.app.Enqueue(ctx, method, nil)
+	if err != nil {
+		return nil, fromCocaineError(err)
+	}
+
+	return &clientStream{
+		ctx:    ctx,
+		desc:   desc,
+		codec:  c.codec,
+		stream: stream,
+	}, nil
+}
+
+// outgoingContext adds outgoing gRPC metadata to the outgoing cocaine one
+func outgoingContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	outgoing, _ := cocaine.FromOutgoingContext(ctx)
+	return cocaine.NewOutgoingContext(ctx, cocaine.JoinMetadata(outgoing, cocaine.Metadata(md)))
+}
+
+// clientStream implements grpc.ClientStream over an enqueued event
+type clientStream struct {
+	ctx    context.Context
+	desc   *grpc.StreamDesc
+	codec  encoding.Codec
+	stream cocaine.Stream
+
+	// guards receiving and the fields below, so Header
+	// may be called while RecvMsg is waiting
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+	// the first message or the end of the response has been received
+	headerReady bool
+	// the error of the first receive if headers haven't arrived
+	headerErr error
+	// received by Header for the next RecvMsg
+	peeked *received
+}
+
+type received struct {
+	chunk []byte
+	err   error
+}
+
+// Header waits for the first message or the end of the response.
+// The message is kept for RecvMsg.
+func (s *clientStream) Header() (metadata.MD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.headerReady {
+		chunk, err := s.receive()
+		s.peeked = &received{chunk, err}
+	}
+	return s.header, s.headerErr
+}
+
+func (s *clientStream) Trailer() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trailer
+}
+
+func (s *clientStream) CloseSend() error {
+	if err := s.stream.CloseSend(s.ctx); err != nil {
+		return fromCocaineError(err)
+	}
+	return nil
+}
+
+func (s *clientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	buf, err := s.codec.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "grpcbridge: error while marshaling: %v", err)
+	}
+
+	if err := s.stream.Write(s.ctx, buf); err != nil {
+		return fromCocaineError(err)
+	}
+	return nil
+}
+
+// RecvMsg returns io.EOF once the response is over. The response
+// of a call without streaming of the server must be a single message.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.recv(m)
+	if s.desc.ServerStreams {
+		return err
+	}
+	if err == io.EOF {
+		return status.Error(codes.Internal, "grpcbridge: the response has no message")
+	}
+	if err != nil {
+		return err
+	}
+
+	switch err := s.recv(nil); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return status.Error(codes.Internal, "grpcbridge: cardinality violation: expected <EOF> for non server-streaming RPCs, but received another message")
+	default:
+		return err
+	}
+}
+
+// recv skips decoding if m is nil
+func (s *clientStream) recv(m interface{}) error {
+	chunk, err := s.next()
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		return nil
+	}
+	if err := s.codec.Unmarshal(chunk, m); err != nil {
+		return status.Errorf(codes.Internal, "grpcbridge: failed to unmarshal the received message: %v", err)
+	}
+	return nil
+}
+
+// next returns the message received by Header if any or receives one
+func (s *clientStream) next() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p := s.peeked; p != nil {
+		s.peeked = nil
+		return p.chunk, p.err
+	}
+	return s.receive()
+}
+
+// receive learns headers and trailers along with the message,
+// mu must be held
+func (s *clientStream) receive() ([]byte, error) {
+	chunk, err := s.stream.Recv(s.ctx)
+	switch {
+	case err == io.EOF:
+		if md := s.stream.Trailer(); len(md) > 0 {
+			s.trailer = copyMD(md)
+		}
+	case err != nil:
+		s.learnDetails(err)
+		err = fromCocaineError(err)
+	default:
+		if md := s.stream.Header(); len(md) > 0 {
+			s.header = copyMD(md)
+		}
+	}
+
+	if !s.headerReady {
+		s.headerReady = true
+		if err != nil && err != io.EOF && s.header == nil {
+			s.headerErr = err
+		}
+	}
+	return chunk, err
+}
+
+// abort cancels the request as the response is not going to be read
+func (s *clientStream) abort(err error) {
+	if aborter, ok := s.stream.(cocaine.Aborter); ok {
+		aborter.Abort(err)
+	}
+}
+
+// learnDetails takes headers and trailers from the error of Server
+func (s *clientStream) learnDetails(err error) {
+	reqErr, ok := err.(*cocaine.ErrRequest)
+	if !ok || reqErr.Category != ErrorCategory {
+		return
+	}
+
+	var details statusDetails
+	if ok, err := reqErr.DecodeDetails(&details); !ok || err != nil {
+		return
+	}
+	s.header = copyMD(details.Header)
+	s.trailer = copyMD(details.Trailer)
+}
+
+func (s *clientStream) fillCallOptions(opts []grpc.CallOption) {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case grpc.HeaderCallOption:
+			*opt.HeaderAddr = s.header
+		case grpc.TrailerCallOption:
+			*opt.TrailerAddr = s.trailer
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package grpcbridge
+
+import (
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+	"github.com/cocaine/cocaine-framework-go/cocaine12/cocainetest"
+)
+
+// loopback enqueues events to handlers of Server in place
+type loopback struct {
+	handlers map[string]cocaine.EventHandler
+}
+
+func (l loopback) Enqueue(ctx context.Context, event string, payload []byte) (cocaine.Stream, error) {
+	md, _ := cocaine.FromOutgoingContext(ctx)
+	return &loopbackStream{
+		ctx:     cocaine.NewIncomingContext(context.Background(), md),
+		handler: l.handlers[event],
+		req:     cocainetest.NewRequest(),
+	}, nil
+}
+
+type loopbackStream struct {
+	ctx     context.Context
+	handler cocaine.EventHandler
+	req     *cocainetest.Request
+	res     chunkResponse
+
+	header  cocaine.Metadata
+	trailer cocaine.Metadata
+}
+
+func (s *loopbackStream) Write(ctx context.Context, data []byte) error {
+	s.req.Write(data)
+	return nil
+}
+
+func (s *loopbackStream) CloseSend(ctx context.Context) error {
+	s.handler(s.ctx, s.req, &s.res)
+	return nil
+}
+
+func (s *loopbackStream) Recv(ctx context.Context) ([]byte, error) {
+	if len(s.res.chunks) == 0 {
+		if s.res.err != nil {
+			return nil, s.res.err
+		}
+		s.trailer = s.res.trailer
+		return nil, io.EOF
+	}
+
+	var (
+		chunk []byte
+		md    cocaine.Metadata
+	)
+	chunk, s.res.chunks = s.res.chunks[0], s.res.chunks[1:]
+	md, s.res.headers = s.res.headers[0], s.res.headers[1:]
+	if md != nil {
+		s.header = cocaine.JoinMetadata(s.header, md)
+	}
+	return chunk, nil
+}
+
+func (s *loopbackStream) Header() cocaine.Metadata {
+	return s.header
+}
+
+func (s *loopbackStream) Trailer() cocaine.Metadata {
+	return s.trailer
+}
+
+func (s *loopbackStream) ReadAll(ctx context.Context) ([]byte, error) {
+	panic("not implemented")
+}
+
+// chunkResponse keeps boundaries of chunks and their metadata
+type chunkResponse struct {
+	chunks  [][]byte
+	headers []cocaine.Metadata
+	trailer cocaine.Metadata
+	err     error
+}
+
+func (r *chunkResponse) Write(data []byte) (int, error) {
+	return len(data), r.ZeroCopyWrite(append([]byte(nil), data...))
+}
+
+func (r *chunkResponse) ZeroCopyWrite(data []byte) error {
+	return r.WriteWithMetadata(data, nil)
+}
+
+func (r *chunkResponse) WriteWithMetadata(data []byte, md cocaine.Metadata) error {
+	r.chunks = append(r.chunks, data)
+	r.headers = append(r.headers, md)
+	return nil
+}
+
+func (r *chunkResponse) Close() error {
+	return syscall.EINVAL
+}
+
+func (r *chunkResponse) CloseWithMetadata(md cocaine.Metadata) error {
+	r.trailer = md
+	return nil
+}
+
+func (r *chunkResponse) ErrorMsg(code int, message string) error {
+	r.err = &cocaine.ErrRequest{Category: ErrorCategory, Code: code, Message: message}
+	return nil
+}
+
+func newTestClientConn() *ClientConn {
+	server := NewServer(WithCodec(stringCodec{}))
+	server.RegisterService(&echoDesc, echo{})
+	return NewClientConn(loopback{server.Handlers()}, WithClientCodec(stringCodec{}))
+}
+
+func TestClientConnInvoke(t *testing.T) {
+	conn := newTestClientConn()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "suffix", "?")
+	in, out := "hello", ""
+	var header, trailer metadata.MD
+	if assert.NoError(t, conn.Invoke(ctx, "/test.Echo/Echo", &in, &out, grpc.Header(&header), grpc.Trailer(&trailer))) {
+		assert.Equal(t, "hello?", out)
+		assert.Equal(t, []string{"hi"}, header.Get("greeting"))
+		assert.Equal(t, []string{"done"}, trailer.Get("reason"))
+	}
+
+	in = "fail"
+	err := conn.Invoke(ctx, "/test.Echo/Echo", &in, &out)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "failed", status.Convert(err).Message())
+}
+
+func TestClientConnStream(t *testing.T) {
+	conn := newTestClientConn()
+
+	ctx := context.Background()
+	desc := &grpc.StreamDesc{StreamName: "Split", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, "/test.Echo/Split")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	in := "a b"
+	assert.NoError(t, stream.SendMsg(&in))
+	assert.NoError(t, stream.CloseSend())
+
+	var words []string
+	for {
+		var word string
+		err := stream.RecvMsg(&word)
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		words = append(words, word)
+	}
+	assert.Equal(t, []string{"a", "b"}, words)
+
+	// a unary call can't be replied with a stream
+	in, out := "a b", ""
+	err = conn.Invoke(ctx, "/test.Echo/Split", &in, &out)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestClientStreamHeader(t *testing.T) {
+	conn := newTestClientConn()
+
+	ctx := context.Background()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{}, "/test.Echo/Echo")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	in := "hello"
+	assert.NoError(t, stream.SendMsg(&in))
+	assert.NoError(t, stream.CloseSend())
+
+	// headers come with the first message
+	header, err := stream.Header()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi"}, header.Get("greeting"))
+
+	var out string
+	assert.NoError(t, stream.RecvMsg(&out), "the message taken by Header must be received")
+	assert.Equal(t, "hello", out)
+	assert.Equal(t, []string{"done"}, stream.Trailer().Get("reason"))
+}
+
+// brokenEnqueuer enqueues streams which fail to send the request
+type brokenEnqueuer struct {
+	stream *brokenStream
+}
+
+func (e brokenEnqueuer) Enqueue(ctx context.Context, event string, payload []byte) (cocaine.Stream, error) {
+	return e.stream, nil
+}
+
+type brokenStream struct {
+	loopbackStream
+	aborted error
+}
+
+func (s *brokenStream) Write(ctx context.Context, data []byte) error {
+	return syscall.EPIPE
+}
+
+func (s *brokenStream) Abort(reason error) {
+	s.aborted = reason
+}
+
+func TestClientConnInvokeAbort(t *testing.T) {
+	stream := &brokenStream{}
+	conn := NewClientConn(brokenEnqueuer{stream}, WithClientCodec(stringCodec{}))
+
+	in, out := "hello", ""
+	assert.Error(t, conn.Invoke(context.Background(), "/test.Echo/Echo", &in, &out))
+	assert.Error(t, stream.aborted, "the request must be cancelled")
+}
@@ -0,0 +1,306 @@
+// Package grpcbridge runs gRPC services as Cocaine applications.
+//
+// Every method of a service registered in Server is served by the event
+// named after the full gRPC method, e.g. "/helloworld.Greeter/SayHello".
+// Each chunk of a request or a response is a single message encoded
+// by the codec of the Server, protobuf by default:
+//
+//	server := grpcbridge.NewServer()
+//	pb.RegisterGreeterServer(server, &greeter{})
+//	worker.Run(server.Handlers())
+//
+// Incoming metadata of a call is the metadata of the cocaine request.
+// A failed call is replied with the error of ErrorCategory whose code
+// is the gRPC code. Response headers and trailers set by the handler
+// are attached to the error as its details. If the call succeeds,
+// headers are sent as metadata of the first chunk and trailers
+// as metadata of the close, see cocaine.MetadataWriter.
+//
+// ClientConn calls such applications from generated gRPC clients.
+package grpcbridge
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const defaultCodec = "proto"
+
+// Option configures Server
+type Option func(*Server)
+
+// WithCodec sets the codec of messages. Protobuf is used by default.
+func WithCodec(codec encoding.Codec) Option {
+	return func(s *Server) {
+		s.codec = codec
+	}
+}
+
+// UnaryInterceptor sets the interceptor of unary calls
+func UnaryInterceptor(interceptor grpc.UnaryServerInterceptor) Option {
+	return func(s *Server) {
+		s.unaryInterceptor = interceptor
+	}
+}
+
+// StreamInterceptor sets the interceptor of streaming calls
+func StreamInterceptor(interceptor grpc.StreamServerInterceptor) Option {
+	return func(s *Server) {
+		s.streamInterceptor = interceptor
+	}
+}
+
+// Server implements grpc.ServiceRegistrar, so services are registered
+// in it by the generated code as in grpc.Server
+type Server struct {
+	codec             encoding.Codec
+	unaryInterceptor  grpc.UnaryServerInterceptor
+	streamInterceptor grpc.StreamServerInterceptor
+
+	mu       sync.Mutex
+	handlers map[string]cocaine.EventHandler
+}
+
+var _ grpc.ServiceRegistrar = (*Server)(nil)
+
+// NewServer creates a Server without services
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		codec:    encoding.GetCodec(defaultCodec),
+		handlers: make(map[string]cocaine.EventHandler),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RegisterService registers handlers of every method of the service.
+// It panics if impl doesn't implement the service as grpc.Server does.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	if impl != nil {
+		ht := reflect.TypeOf(desc.HandlerType).Elem()
+		if st := reflect.TypeOf(impl); !st.Implements(ht) {
+			panic(fmt.Sprintf("grpcbridge: Server.RegisterService found the handler of type %v that does not satisfy %v", st, ht))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, method := range desc.Methods {
+		fullMethod := fmt.Sprintf("/%s/%s", desc.ServiceName, method.MethodName)
+		s.handlers[fullMethod] = s.unaryHandler(impl, method, fullMethod)
+	}
+	for _, stream := range desc.Streams {
+		fullMethod := fmt.Sprintf("/%s/%s", desc.ServiceName, stream.StreamName)
+		s.handlers[fullMethod] = s.streamHandler(impl, stream, fullMethod)
+	}
+}
+
+// Handlers returns handlers of registered methods keyed by their events
+func (s *Server) Handlers() map[string]cocaine.EventHandler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	handlers := make(map[string]cocaine.EventHandler, len(s.handlers))
+	for event, handler := range s.handlers {
+		handlers[event] = handler
+	}
+	return handlers
+}
+
+func (s *Server) unaryHandler(impl interface{}, method grpc.MethodDesc, fullMethod string) cocaine.EventHandler {
+	return func(ctx context.Context, request cocaine.Request, response cocaine.Response) {
+		stream := s.newServerStream(ctx, fullMethod, request, response)
+		reply, err := method.Handler(impl, stream.Context(), stream.RecvMsg, s.unaryInterceptor)
+		if err == nil {
+			err = stream.SendMsg(reply)
+		}
+		stream.finish(err)
+	}
+}
+
+func (s *Server) streamHandler(impl interface{}, desc grpc.StreamDesc, fullMethod string) cocaine.EventHandler {
+	info := &grpc.StreamServerInfo{
+		FullMethod:     fullMethod,
+		IsClientStream: desc.ClientStreams,
+		IsServerStream: desc.ServerStreams,
+	}
+	return func(ctx context.Context, request cocaine.Request, response cocaine.Response) {
+		stream := s.newServerStream(ctx, fullMethod, request, response)
+		var err error
+		if s.streamInterceptor != nil {
+			err = s.streamInterceptor(impl, stream, info, desc.Handler)
+		} else {
+			err = desc.Handler(impl, stream)
+		}
+		stream.finish(err)
+	}
+}
+
+// serverStream implements grpc.ServerStream over a cocaine request
+type serverStream struct {
+	ctx      context.Context
+	method   string
+	codec    encoding.Codec
+	request  cocaine.Request
+	response cocaine.Response
+
+	mu         sync.Mutex
+	header     metadata.MD
+	trailer    metadata.MD
+	headerSent bool
+	// the header has gone with a message
+	headerWritten bool
+}
+
+func (s *Server) newServerStream(ctx context.Context, method string,
+	request cocaine.Request, response cocaine.Response) *serverStream {
+	stream := &serverStream{
+		method:   method,
+		codec:    s.codec,
+		request:  request,
+		response: response,
+	}
+
+	md, _ := cocaine.FromIncomingContext(ctx)
+	ctx = metadata.NewIncomingContext(ctx, copyMD(md))
+	stream.ctx = grpc.NewContextWithServerTransportStream(ctx, transportStream{stream})
+	return stream
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.headerSent {
+		return status.Error(codes.Internal, "grpcbridge: SetHeader called after headers were sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *serverStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.headerSent = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *serverStream) SetTrailer(md metadata.MD) {
+	s.mu.Lock()
+	s.trailer = metadata.Join(s.trailer, md)
+	s.mu.Unlock()
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	buf, err := s.codec.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "grpcbridge: error while marshaling: %v", err)
+	}
+
+	if header := s.takeHeader(); len(header) > 0 {
+		err = writeWithMetadata(s.response, buf, header)
+	} else {
+		err = s.response.ZeroCopyWrite(buf)
+	}
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return nil
+}
+
+// takeHeader returns the header unless it has been written already
+func (s *serverStream) takeHeader() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.headerSent = true
+	if s.headerWritten {
+		return nil
+	}
+	s.headerWritten = true
+	return s.header
+}
+
+// RecvMsg returns io.EOF once the client has closed the request
+func (s *serverStream) RecvMsg(m interface{}) error {
+	data, err := s.request.Read(s.ctx)
+	if err == cocaine.ErrStreamIsClosed {
+		return io.EOF
+	}
+	if err != nil {
+		return fromCocaineError(err)
+	}
+
+	if err := s.codec.Unmarshal(data, m); err != nil {
+		return status.Errorf(codes.Internal, "grpcbridge: failed to unmarshal the received message: %v", err)
+	}
+	return nil
+}
+
+// finish replies with the status of the call if it has failed.
+// Otherwise the response is closed with the trailer, which carries
+// the header too if no message has been sent.
+func (s *serverStream) finish(err error) {
+	if err == nil {
+		md := metadata.Join(s.takeHeader(), s.trailerMD())
+		if writer, ok := s.response.(cocaine.MetadataWriter); ok && len(md) > 0 {
+			writer.CloseWithMetadata(cocaine.Metadata(md))
+		}
+		return
+	}
+
+	s.mu.Lock()
+	details := statusDetails{Header: s.header, Trailer: s.trailer}
+	s.mu.Unlock()
+
+	st := toStatus(err)
+	cocaine.ErrorWithDetails(s.response, ErrorCategory, int(st.Code()), st.Message(), details)
+}
+
+func (s *serverStream) trailerMD() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trailer
+}
+
+// writeWithMetadata drops md if the response can't send it
+func writeWithMetadata(response cocaine.Response, data []byte, md metadata.MD) error {
+	if writer, ok := response.(cocaine.MetadataWriter); ok {
+		return writer.WriteWithMetadata(data, cocaine.Metadata(md))
+	}
+	return response.ZeroCopyWrite(data)
+}
+
+// transportStream lets handlers use grpc.SetHeader and friends
+type transportStream struct {
+	*serverStream
+}
+
+func (t transportStream) Method() string {
+	return t.method
+}
+
+func (t transportStream) SetTrailer(md metadata.MD) error {
+	t.serverStream.SetTrailer(md)
+	return nil
+}
@@ -0,0 +1,179 @@
+package grpcbridge
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+	"github.com/cocaine/cocaine-framework-go/cocaine12/cocainetest"
+)
+
+// stringCodec encodes *string messages as they are
+type stringCodec struct{}
+
+func (stringCodec) Marshal(v interface{}) ([]byte, error) {
+	s, ok := v.(*string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T", v)
+	}
+	return []byte(*s), nil
+}
+
+func (stringCodec) Unmarshal(data []byte, v interface{}) error {
+	s, ok := v.(*string)
+	if !ok {
+		return fmt.Errorf("unexpected %T", v)
+	}
+	*s = string(data)
+	return nil
+}
+
+func (stringCodec) Name() string {
+	return "string"
+}
+
+type echoServer interface {
+	Echo(ctx context.Context, in *string) (*string, error)
+	Split(in *string, stream grpc.ServerStream) error
+}
+
+type echo struct{}
+
+func (echo) Echo(ctx context.Context, in *string) (*string, error) {
+	if *in == "fail" {
+		grpc.SetTrailer(ctx, metadata.Pairs("reason", "asked"))
+		return nil, status.Error(codes.FailedPrecondition, "failed")
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs("greeting", "hi"))
+	grpc.SetTrailer(ctx, metadata.Pairs("reason", "done"))
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	out := *in + strings.Join(md.Get("suffix"), "")
+	return &out, nil
+}
+
+func (echo) Split(in *string, stream grpc.ServerStream) error {
+	for _, word := range strings.Fields(*in) {
+		if err := stream.SendMsg(&word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*echoServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(string)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(echoServer).Echo(ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Echo/Echo"}
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.(echoServer).Echo(ctx, req.(*string))
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Split",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			in := new(string)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			return srv.(echoServer).Split(in, stream)
+		},
+		ServerStreams: true,
+	}},
+}
+
+func call(handler cocaine.EventHandler, ctx context.Context, chunks ...string) *cocainetest.Response {
+	req := cocainetest.NewRequest()
+	for _, chunk := range chunks {
+		req.Write([]byte(chunk))
+	}
+	res := cocainetest.NewResponse()
+	handler(ctx, req, res)
+	return res
+}
+
+func TestServerUnary(t *testing.T) {
+	var intercepted []string
+	server := NewServer(
+		WithCodec(stringCodec{}),
+		UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			intercepted = append(intercepted, info.FullMethod)
+			return handler(ctx, req)
+		}),
+	)
+	server.RegisterService(&echoDesc, echo{})
+
+	handlers := server.Handlers()
+	if !assert.Len(t, handlers, 2) {
+		return
+	}
+
+	ctx := cocaine.NewIncomingContext(context.Background(), cocaine.Pairs("suffix", "!"))
+	res := call(handlers["/test.Echo/Echo"], ctx, "hello")
+	assert.Nil(t, res.Err)
+	assert.Equal(t, "hello!", res.String())
+	assert.Equal(t, []string{"/test.Echo/Echo"}, intercepted)
+
+	res = call(handlers["/test.Echo/Echo"], context.Background(), "fail")
+	if assert.NotNil(t, res.Err) {
+		assert.Equal(t, int(codes.FailedPrecondition), res.Err.Code)
+		assert.Equal(t, "failed", res.Err.Msg)
+	}
+
+	// no request
+	res = call(handlers["/test.Echo/Echo"], context.Background())
+	if assert.NotNil(t, res.Err) {
+		assert.Equal(t, int(codes.Unknown), res.Err.Code)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	server := NewServer(WithCodec(stringCodec{}))
+	server.RegisterService(&echoDesc, echo{})
+
+	res := call(server.Handlers()["/test.Echo/Split"], context.Background(), "a b c")
+	assert.Nil(t, res.Err)
+	assert.Equal(t, "abc", res.String())
+}
+
+func TestServerRejectsWrongImplementation(t *testing.T) {
+	assert.Panics(t, func() {
+		NewServer().RegisterService(&echoDesc, struct{}{})
+	})
+}
+
+func TestFromCocaineError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{&cocaine.ErrRequest{Category: ErrorCategory, Code: int(codes.NotFound)}, codes.NotFound},
+		{&cocaine.ErrRequest{Category: 42, Code: cocaine.ErrorNoEventHandler}, codes.Unimplemented},
+		{&cocaine.ErrRequest{Category: 42, Code: cocaine.ErrorPanicInHandler}, codes.Unknown},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{io.ErrUnexpectedEOF, codes.Unavailable},
+	} {
+		assert.Equal(t, tc.code, status.Code(fromCocaineError(tc.err)), "%v", tc.err)
+	}
+}
@@ -0,0 +1,68 @@
+package grpcbridge
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// ErrorCategory is the category of cocaine errors carrying gRPC statuses.
+// The code of such an error is the gRPC code.
+const ErrorCategory = 44
+
+// statusDetails are sent as the details of a cocaine error,
+// since metadata of a response has nowhere else to go
+type statusDetails struct {
+	Header  map[string][]string
+	Trailer map[string][]string
+}
+
+// toStatus converts an error returned by a gRPC handler
+func toStatus(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	return status.FromContextError(err)
+}
+
+// fromCocaineError converts an error of a cocaine stream to a gRPC status
+// error. Errors sent by the other side of the bridge keep their codes.
+func fromCocaineError(err error) error {
+	switch err {
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+
+	reqErr, ok := err.(*cocaine.ErrRequest)
+	if !ok {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	switch {
+	case reqErr.Category == ErrorCategory:
+		return status.Error(codes.Code(reqErr.Code), reqErr.Message)
+	case reqErr.Code == cocaine.ErrorCancelled:
+		return status.Error(codes.Canceled, reqErr.Message)
+	case reqErr.Code == cocaine.ErrorDeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, reqErr.Message)
+	case reqErr.Code == cocaine.ErrorNoEventHandler:
+		return status.Error(codes.Unimplemented, reqErr.Message)
+	case reqErr.Code == cocaine.ErrorBadRequest:
+		return status.Error(codes.InvalidArgument, reqErr.Message)
+	case reqErr.Code == cocaine.ErrorOverloaded:
+		return status.Error(codes.ResourceExhausted, reqErr.Message)
+	}
+	return status.Error(codes.Unknown, reqErr.Message)
+}
+
+// copyMD converts metadata of cocaine and gRPC to each other
+func copyMD(md map[string][]string) metadata.MD {
+	result := metadata.MD{}
+	for k, v := range md {
+		result[k] = append([]string(nil), v...)
+	}
+	return result
+}
@@ -3,6 +3,7 @@ package cocaine12
 import (
 	"errors"
 	"io"
+	"sync"
 	"syscall"
 
 	"golang.org/x/net/context"
@@ -13,6 +14,21 @@ type request struct {
 	fromWorker chan *Message
 	toHandler  chan *Message
 	closed     chan struct{}
+	cancel     context.CancelFunc
+
+	// once highWatermark messages are queued for the handler,
+	// push returns a channel closed when it has read them down
+	// to lowWatermark. Zero highWatermark means no limit
+	highWatermark int
+	lowWatermark  int
+
+	// guards queued, resume and released
+	mu sync.Mutex
+	// messages pushed but not read by the handler yet
+	queued int
+	resume chan struct{}
+	// the handler is not going to read anymore
+	released bool
 }
 
 const (
@@ -35,11 +51,18 @@ var (
 )
 
 func newRequest(mtd messageTypeDetector) *request {
+	return newBoundedRequest(mtd, 0, 0)
+}
+
+func newBoundedRequest(mtd messageTypeDetector, high, low int) *request {
 	request := &request{
 		messageTypeDetector: mtd,
 		fromWorker:          make(chan *Message),
 		toHandler:           make(chan *Message),
 		closed:              make(chan struct{}),
+		cancel:              func() {},
+		highWatermark:       high,
+		lowWatermark:        low,
 	}
 
 	go loop(
@@ -49,6 +72,8 @@ func newRequest(mtd messageTypeDetector) *request {
 		request.toHandler,
 		// onclose
 		request.closed,
+		// ondelivered
+		request.delivered,
 	)
 
 	return request
@@ -65,8 +90,11 @@ func (request *request) Read(ctx context.Context) ([]byte, error) {
 		}
 
 		if request.isChunk(msg) {
+			if len(msg.Payload) == 0 {
+				return nil, ErrBadPayload
+			}
 			if result, isByte := msg.Payload[0].([]byte); isByte {
-				return result, nil
+				return decodeChunk(msg, result)
 			}
 			return nil, ErrBadPayload
 		}
@@ -89,25 +117,83 @@ func (request *request) Read(ctx context.Context) ([]byte, error) {
 			Message:  perr.Message,
 			Category: perr.CodeInfo[0],
 			Code:     perr.CodeInfo[1],
+			Details:  errorDetails(msg.Headers),
 		}
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
 
-func (request *request) push(msg *Message) {
+// push queues msg for the handler. If the queue has reached
+// the high watermark, it returns a channel which is closed
+// once the handler has read it down to the low one
+func (request *request) push(msg *Message) <-chan struct{} {
+	request.mu.Lock()
+	request.queued++
+	var resume chan struct{}
+	if !request.released && request.highWatermark > 0 && request.queued >= request.highWatermark {
+		if request.resume == nil {
+			request.resume = make(chan struct{})
+		}
+		resume = request.resume
+	}
+	request.mu.Unlock()
+
 	request.fromWorker <- msg
+	return resume
+}
+
+// delivered is called by the loop once the handler has read a message
+func (request *request) delivered() {
+	request.mu.Lock()
+	request.queued--
+	if request.resume != nil && request.queued <= request.lowWatermark {
+		close(request.resume)
+		request.resume = nil
+	}
+	request.mu.Unlock()
+}
+
+// release stops throttling the worker
+// as the handler is not going to read the request
+func (request *request) release() {
+	request.mu.Lock()
+	request.released = true
+	if request.resume != nil {
+		close(request.resume)
+		request.resume = nil
+	}
+	request.mu.Unlock()
 }
 
 func (request *request) Close() {
 	close(request.closed)
 }
 
+func (request *request) abort() {
+	request.cancel()
+}
+
 type response struct {
 	handlerProtocolGenerator
 	session  uint64
 	toWorker asyncSender
-	closed   bool
+
+	// guards closed and failed, a response may be replied by the loop,
+	// e.g. with ErrorPayloadTooLarge, while the handler writes it
+	mu     sync.Mutex
+	closed bool
+	// an error has been sent
+	failed bool
+	// chunks of compressionThreshold bytes or larger
+	// are compressed with encoding if it's set
+	encoding             string
+	compressionThreshold int
+	// chunks carry ChecksumHeader
+	checksums bool
+	// header values of huffmanThreshold bytes or longer
+	// are Huffman coded if it's set
+	huffmanThreshold int
 }
 
 func newResponse(h handlerProtocolGenerator, session uint64, toWorker asyncSender) *response {
@@ -137,55 +223,124 @@ func (r *response) Write(data []byte) (n int, err error) {
 // ZeroCopyWrite sends data to a client.
 // Response takes the ownership of the buffer, so provided buffer must not be edited.
 func (r *response) ZeroCopyWrite(data []byte) error {
-	if r.isClosed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+
+	r.toWorker.Send(r.newDataChunk(data, nil))
+	return nil
+}
+
+// WriteWithMetadata sends a chunk of data with md as its headers
+func (r *response) WriteWithMetadata(data []byte, md Metadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
 		return io.ErrClosedPipe
 	}
 
-	r.toWorker.Send(r.newChunk(r.session, data))
+	r.toWorker.Send(r.newDataChunk(append([]byte(nil), data...), md.headers()))
 	return nil
 }
 
+// newDataChunk returns a chunk of data compressed with the negotiated
+// encoding and carrying its checksum if they are enabled. Data is sent
+// as it is if the compression doesn't make it smaller.
+func (r *response) newDataChunk(data []byte, headers []Header) *Message {
+	if r.encoding != "" && len(data) >= r.compressionThreshold {
+		compressed, err := compressChunk(r.encoding, data)
+		if err == nil && len(compressed) < len(data) {
+			data = compressed
+			headers = append(headers, Header{Name: ContentEncodingHeader, Value: []byte(r.encoding)})
+		}
+	}
+
+	// the checksum covers bytes on the wire
+	if r.checksums {
+		headers = append(headers, checksumHeader(data))
+	}
+
+	msg := r.newChunk(r.session, data)
+	if len(headers) > 0 {
+		msg.Headers = r.encodeHeaders(headers)
+	}
+	return msg
+}
+
 // Notify a client about finishing the datastream.
+// It seals only the response: the request is still readable
+// until the client closes it too.
 func (r *response) Close() error {
-	if r.isClosed() {
+	return r.CloseWithMetadata(nil)
+}
+
+// CloseWithMetadata closes the response with md as headers of the choke
+func (r *response) CloseWithMetadata(md Metadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
 		// we treat it as a network connection
 		return syscall.EINVAL
 	}
 
-	r.close()
-	r.toWorker.Send(r.newChoke(r.session))
+	r.closed = true
+	msg := r.newChoke(r.session)
+	if headers := md.headers(); len(headers) > 0 {
+		msg.Headers = r.encodeHeaders(headers)
+	}
+	r.toWorker.Send(msg)
 	return nil
 }
 
 // Send error to a client. Specify code and message, which describes this error.
 func (r *response) ErrorMsg(code int, message string) error {
-	if r.isClosed() {
+	return r.errorMsgWithHeaders(cworkererrorcategory, code, message, nil)
+}
+
+// errorMsgWithHeaders sends the error along with headers, e.g. RetryAfterHeader
+func (r *response) errorMsgWithHeaders(category, code int, message string, headers []Header) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
 		return io.ErrClosedPipe
 	}
 
-	r.close()
-	r.toWorker.Send(r.newError(
+	r.closed = true
+	r.failed = true
+	msg := r.newError(
 		// current session number
 		r.session,
 		// category
-		cworkererrorcategory,
+		category,
 		// error code
 		code,
 		// error message
 		message,
-	))
+	)
+	if len(headers) > 0 {
+		msg.Headers = r.encodeHeaders(headers)
+	}
+	r.toWorker.Send(msg)
 	return nil
 }
 
-func (r *response) close() {
-	r.closed = true
+func (r *response) encodeHeaders(headers []Header) CocaineHeaders {
+	if r.huffmanThreshold > 0 {
+		return DefaultHeaderTable.EncodeHuffman(headers, r.huffmanThreshold)
+	}
+	return DefaultHeaderTable.Encode(headers)
 }
 
-func (r *response) isClosed() bool {
-	return r.closed
+// isFailed reports whether an error has been sent
+func (r *response) isFailed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
 }
 
-func loop(input <-chan *Message, output chan *Message, onclose <-chan struct{}) {
+func loop(input <-chan *Message, output chan *Message, onclose <-chan struct{}, ondelivered func()) {
 	defer close(output)
 
 	var (
@@ -223,6 +378,7 @@ func loop(input <-chan *Message, output chan *Message, onclose <-chan struct{})
 			// it should be done
 			// without memory copy/allocate
 			pending = pending[1:]
+			ondelivered()
 
 		case <-closed:
 			// It will be triggered on
@@ -0,0 +1,380 @@
+package cocaine12
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// HandoffEnv names the environment variable with the path of a unix socket.
+	// If it's set, NewWorker takes over the connection to cocaine-runtime
+	// from the predecessor listening there instead of connecting.
+	HandoffEnv = "COCAINE_HANDOFF"
+
+	handoffDialTimeout   = time.Second * 10
+	handoffRetryInterval = time.Millisecond * 100
+	// limits the size of the state received from a predecessor
+	handoffMaxStateSize = 16 * 1024 * 1024
+)
+
+// ErrHandoffUnsupported means that the connection to cocaine-runtime
+// can not be handed off. SocketOptions.Handoff must be set before
+// the worker is created and protocol v1 must be used.
+var ErrHandoffUnsupported = errors.New("the connection can not be handed off")
+
+// handoffState is passed to a successor along with the connection
+type handoffState struct {
+	ID       string
+	Protocol int
+	Debug    bool
+	// sessions up to this one are handled by the predecessor
+	MaxSession uint64
+	// bytes of an incomplete frame read from the connection
+	Pending []byte
+}
+
+type handoffRequest struct {
+	link *net.UnixConn
+	done chan error
+}
+
+// handoffConn reads the bytes received from a predecessor
+// before the ones from the connection
+type handoffConn struct {
+	*net.UnixConn
+	r io.Reader
+}
+
+func (c *handoffConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Handoff passes the connection to cocaine-runtime to a successor process
+// started with HandoffEnv set to path, e.g. a new version of the application.
+// It listens on path and returns once the successor has taken over.
+// Requests in flight are completed by this worker and their replies
+// are forwarded via the successor, new ones are handled by the successor.
+// Run returns nil when all requests in flight are done.
+//
+// SocketOptions.Handoff must be set before the worker is created.
+func (w *WorkerNG) Handoff(ctx context.Context, path string) error {
+	if w.ProtocolVersion() != v1 {
+		return ErrHandoffUnsupported
+	}
+
+	if sock, ok := w.conn.(*asyncRWSocket); !ok || sock.tap == nil {
+		return ErrHandoffUnsupported
+	}
+
+	// remove a socket left by a crashed worker,
+	// anything else at path is not ours to remove
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	accepted := make(chan *net.UnixConn, 1)
+	go func() {
+		// fails once the listener is closed
+		link, err := listener.AcceptUnix()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- link
+	}()
+
+	var link *net.UnixConn
+	select {
+	case link = <-accepted:
+		if link == nil {
+			return fmt.Errorf("unable to accept a successor on %s", path)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	req := handoffRequest{link: link, done: make(chan error, 1)}
+	select {
+	case w.handoffs <- req:
+	case <-w.stopped:
+		link.Close()
+		return ErrConnectionLost
+	case <-ctx.Done():
+		link.Close()
+		return ctx.Err()
+	}
+
+	if err := <-req.done; err != nil {
+		link.Close()
+		return err
+	}
+	return nil
+}
+
+// handoff is called by the loop, so no message
+// is dispatched while the connection is being passed
+func (w *WorkerNG) handoff(link *net.UnixConn) error {
+	sock := w.conn.(*asyncRWSocket)
+	original := sock.conn
+	// the successor takes over unix sockets only,
+	// so nothing is detached for other connections
+	runtime, ok := unixConnOf(original)
+	if !ok {
+		return ErrHandoffUnsupported
+	}
+
+	// it's a duplicate of the descriptor
+	file, err := runtime.File()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pending, maxSession, err := sock.detachReader()
+	if err != nil {
+		// nothing is read from cocaine-runtime anymore
+		w.Stop()
+		return err
+	}
+
+	state := handoffState{
+		ID:         w.id,
+		Protocol:   w.ProtocolVersion(),
+		Debug:      w.debug,
+		MaxSession: maxSession,
+		Pending:    pending,
+	}
+
+	if err := sock.rebind(link, func() error { return sendHandoff(link, file, &state) }); err != nil {
+		w.Stop()
+		return err
+	}
+	// the successor owns the connection now
+	original.Close()
+
+	w.handedOff = true
+	w.heartbeatTimer.Stop()
+	w.disownTimer.Stop()
+	w.missTimer.Stop()
+	return nil
+}
+
+// unixConnOf returns the unix socket of the connection to cocaine-runtime,
+// which may have been taken over from a predecessor
+func unixConnOf(conn io.ReadWriteCloser) (*net.UnixConn, bool) {
+	switch c := conn.(type) {
+	case *net.UnixConn:
+		return c, true
+	case *handoffConn:
+		return c.UnixConn, true
+	}
+	return nil, false
+}
+
+// forwardToPredecessor passes messages of requests started by
+// the predecessor to it. Utility messages are handled by the successor.
+func (w *WorkerNG) forwardToPredecessor(msg *Message) bool {
+	if w.predecessor == nil || msg.Session == v1UtilitySession || msg.Session > w.predecessorSession {
+		return false
+	}
+
+	w.predecessor.Send(msg)
+	return true
+}
+
+// NewWorkerNGFromHandoff takes over the connection to cocaine-runtime
+// from the predecessor listening on path. See WorkerNG.Handoff
+func NewWorkerNGFromHandoff(path string) (*WorkerNG, error) {
+	defaults, err := workerDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := dialHandoff(path, handoffDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to the predecessor via %s: %v", path, err)
+	}
+
+	runtime, state, err := receiveHandoff(link)
+	if err != nil {
+		link.Close()
+		return nil, fmt.Errorf("unable to take over the connection: %v", err)
+	}
+
+	tokenManager, err := NewTokenManager(defaults.ApplicationName(), defaults.Token())
+	if err != nil {
+		link.Close()
+		runtime.Close()
+		return nil, fmt.Errorf("unable to create token manager: %v", err)
+	}
+
+	w, err := newWorkerNGFromHandoff(runtime, link, state, tokenManager)
+	if err != nil {
+		// sockets may be closed already
+		link.Close()
+		runtime.Close()
+		tokenManager.Stop()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func newWorkerNGFromHandoff(runtime *net.UnixConn, link io.ReadWriteCloser, state *handoffState, tokenManager TokenManager) (*WorkerNG, error) {
+	sock, err := newAsyncRW(&handoffConn{
+		UnixConn: runtime,
+		r:        io.MultiReader(bytes.NewReader(state.Pending), runtime),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	predecessor, err := newAsyncRW(link)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+
+	w, err := makeWorkerNG(sock, state.ID, state.Protocol, state.Debug, tokenManager)
+	if err != nil {
+		sock.Close()
+		predecessor.Close()
+		return nil, err
+	}
+
+	w.predecessor = predecessor
+	w.predecessorSession = state.MaxSession
+	if dispatcher, ok := w.dispatcher.(*v1Protocol); ok {
+		// sessions of the predecessor must not be taken for new ones
+		dispatcher.maxSession = state.MaxSession
+	}
+	// the predecessor has introduced the worker
+	w.probes.handshakeSent()
+
+	return w, nil
+}
+
+func dialHandoff(path string, timeout time.Duration) (*net.UnixConn, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		// the predecessor may not be listening yet
+		link, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+		if err == nil || time.Now().After(deadline) {
+			return link, err
+		}
+		time.Sleep(handoffRetryInterval)
+	}
+}
+
+// sendHandoff sends the descriptor along with the size of the state,
+// then the state itself
+func sendHandoff(link *net.UnixConn, file *os.File, state *handoffState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	if _, _, err := link.WriteMsgUnix(header[:], syscall.UnixRights(int(file.Fd())), nil); err != nil {
+		return err
+	}
+
+	_, err = link.Write(data)
+	return err
+}
+
+func receiveHandoff(link *net.UnixConn) (*net.UnixConn, *handoffState, error) {
+	var (
+		header [4]byte
+		oob    = make([]byte, syscall.CmsgSpace(4))
+	)
+
+	n, oobn, _, _, err := link.ReadMsgUnix(header[:], oob)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runtime, err := parseHandoffRights(oob[:oobn])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := io.ReadFull(link, header[n:]); err != nil {
+		runtime.Close()
+		return nil, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > handoffMaxStateSize {
+		runtime.Close()
+		return nil, nil, fmt.Errorf("state is too large: %d", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(link, data); err != nil {
+		runtime.Close()
+		return nil, nil, err
+	}
+
+	var state handoffState
+	if err := json.Unmarshal(data, &state); err != nil {
+		runtime.Close()
+		return nil, nil, err
+	}
+
+	return runtime, &state, nil
+}
+
+func parseHandoffRights(oob []byte) (*net.UnixConn, error) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("a descriptor is expected")
+	}
+
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("one descriptor is expected, got %d", len(fds))
+	}
+
+	file := os.NewFile(uintptr(fds[0]), "cocaine-runtime")
+	defer file.Close()
+
+	conn, err := net.FileConn(file)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("a unix socket is expected, got %T", conn)
+	}
+	return runtime, nil
+}
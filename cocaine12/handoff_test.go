@@ -0,0 +1,287 @@
+package cocaine12
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+func encodeTestMessage(t *testing.T, msg *Message) []byte {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, hAsocket).Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestWorkerHandoff(t *testing.T) {
+	opts := GetSocketOptions()
+	defer SetSocketOptions(opts)
+	handoffOpts := opts
+	handoffOpts.Handoff = true
+	SetSocketOptions(handoffOpts)
+
+	dir, err := ioutil.TempDir("", "handoff")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "runtime.sock"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	runtime, err := listener.Accept()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer runtime.Close()
+
+	// replies of the application except utility messages
+	replies := make(chan *Message, 10)
+	go func() {
+		frames := newFrameReader(runtime, 0, 0)
+		for {
+			msg, err := frames.readMessage()
+			if err != nil {
+				close(replies)
+				return
+			}
+			if msg.Session != v1UtilitySession {
+				replies <- msg
+			}
+		}
+	}()
+
+	sock, _ := newAsyncRW(conn)
+	predecessor, err := newWorker(sock, "uuid", v1, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	predecessorDone := make(chan error, 1)
+	go func() {
+		predecessorDone <- predecessor.Run(map[string]EventHandler{
+			"echo": func(ctx context.Context, req Request, res Response) {
+				data, _ := req.Read(ctx)
+				res.Write(data)
+				res.Close()
+			},
+		})
+	}()
+
+	runtime.Write(encodeTestMessage(t, newInvokeV1(2, "echo")))
+	for predecessor.impl.Load().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the successor must get the rest of the frame read by the predecessor
+	invoke := encodeTestMessage(t, newInvokeV1(3, "ping"))
+	runtime.Write(invoke[:len(invoke)/2])
+
+	path := filepath.Join(dir, "handoff.sock")
+	successorDone := make(chan error, 1)
+	successors := make(chan *Worker, 1)
+	go func() {
+		link, err := dialHandoff(path, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		conn, state, err := receiveHandoff(link)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "uuid", state.ID)
+		assert.Equal(t, uint64(2), state.MaxSession)
+
+		impl, err := newWorkerNGFromHandoff(conn, link, state, new(NullTokenManager))
+		if !assert.NoError(t, err) {
+			return
+		}
+		successor := &Worker{impl, NewEventHandlers(), nil, newHealthChecks()}
+		successors <- successor
+
+		successorDone <- successor.Run(map[string]EventHandler{
+			"ping": func(ctx context.Context, req Request, res Response) {
+				req.Read(ctx)
+				res.Write([]byte("pong"))
+				res.Close()
+			},
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !assert.NoError(t, predecessor.Handoff(ctx, path)) {
+		return
+	}
+
+	runtime.Write(invoke[len(invoke)/2:])
+	runtime.Write(encodeTestMessage(t, newChunkV1(3, []byte("ping"))))
+	runtime.Write(encodeTestMessage(t, newChokeV1(3)))
+	runtime.Write(encodeTestMessage(t, newChunkV1(2, []byte("echo"))))
+	runtime.Write(encodeTestMessage(t, newChokeV1(2)))
+
+	chunks := make(map[uint64][]byte)
+	for closed := 0; closed < 2; {
+		select {
+		case msg := <-replies:
+			switch msg.MsgType {
+			case v1Write:
+				chunks[msg.Session] = msg.Payload[0].([]byte)
+			case v1Close:
+				closed++
+			default:
+				t.Fatalf("unexpected message %v", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("replies have not arrived")
+		}
+	}
+	assert.Equal(t, []byte("echo"), chunks[2])
+	assert.Equal(t, []byte("pong"), chunks[3])
+
+	select {
+	case err := <-predecessorDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the predecessor has not finished")
+	}
+
+	successor := <-successors
+	assert.Nil(t, successor.impl.liveness())
+	successor.Stop()
+	assert.NoError(t, <-successorDone)
+}
+
+func TestWorkerHandoffUnsupported(t *testing.T) {
+	in, _ := testConn()
+	sock, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", v1, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = w.Handoff(context.Background(), filepath.Join(os.TempDir(), "unused.sock"))
+	assert.Equal(t, ErrHandoffUnsupported, err)
+}
+
+func TestWorkerHandoffTCP(t *testing.T) {
+	opts := GetSocketOptions()
+	defer SetSocketOptions(opts)
+	handoffOpts := opts
+	handoffOpts.Handoff = true
+	SetSocketOptions(handoffOpts)
+
+	dir, err := ioutil.TempDir("", "handoff")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	runtime, err := listener.Accept()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer runtime.Close()
+
+	sock, err := newAsyncRW(conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w, err := newWorker(sock, "uuid", v1, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			res.Write([]byte("OK"))
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	path := filepath.Join(dir, "handoff.sock")
+	handoffDone := make(chan error, 1)
+	go func() {
+		handoffDone <- w.Handoff(context.Background(), path)
+	}()
+
+	link, err := dialHandoff(path, time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer link.Close()
+	assert.Equal(t, ErrHandoffUnsupported, <-handoffDone)
+
+	// the worker keeps reading the connection
+	frames := newFrameReader(runtime, 0, 0)
+	runtime.Write(encodeTestMessage(t, newInvokeV1(2, "test")))
+	for {
+		msg, err := frames.readMessage()
+		if !assert.NoError(t, err) {
+			return
+		}
+		if msg.Session == 2 {
+			checkTypeAndSession(t, msg, 2, v1Write)
+			return
+		}
+	}
+}
+
+func TestWorkerHandoffKeepsFiles(t *testing.T) {
+	opts := GetSocketOptions()
+	defer SetSocketOptions(opts)
+	handoffOpts := opts
+	handoffOpts.Handoff = true
+	SetSocketOptions(handoffOpts)
+
+	dir, err := ioutil.TempDir("", "handoff")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data")
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600)) {
+		return
+	}
+
+	in, _ := testConn()
+	sock, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", v1, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Error(t, w.Handoff(context.Background(), path))
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), data, "only sockets are removed")
+}
@@ -0,0 +1,272 @@
+package cocaine12
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Names of headers with predefined indices
+const (
+	TraceIDHeader  = "trace_id"
+	SpanIDHeader   = "span_id"
+	ParentIDHeader = "parent_id"
+)
+
+const redactedValue = "<redacted>"
+
+// Header is a decoded Cocaine header
+type Header struct {
+	Name  string
+	Value []byte
+	// Sensitive headers are never stored by peers
+	// and their values are redacted in logs and wire dumps
+	Sensitive bool
+}
+
+func (h Header) String() string {
+	if h.Sensitive {
+		return fmt.Sprintf("%s: %s", h.Name, redactedValue)
+	}
+	return fmt.Sprintf("%s: %s", h.Name, h.Value)
+}
+
+// HeaderTable maps names of headers to static indices agreed with
+// cocaine-runtime. A header from the table is sent as its index
+// instead of the name.
+type HeaderTable struct {
+	mu        sync.RWMutex
+	byIndex   map[uint64]string
+	byName    map[string]uint64
+	sensitive map[string]bool
+}
+
+// NewHeaderTable creates a table with the predefined trace headers.
+// Authorization, cookie and TVM ticket headers are sensitive.
+func NewHeaderTable() *HeaderTable {
+	t := &HeaderTable{
+		byIndex:   make(map[uint64]string),
+		byName:    make(map[string]uint64),
+		sensitive: make(map[string]bool),
+	}
+
+	t.Register(traceId, TraceIDHeader)
+	t.Register(spanId, SpanIDHeader)
+	t.Register(parentId, ParentIDHeader)
+
+	for _, name := range []string{"authorization", "proxy-authorization", "cookie", "set-cookie", TVMServiceTicketHeader} {
+		t.MarkSensitive(name)
+	}
+	return t
+}
+
+// DefaultHeaderTable is used to encode and decode headers of all messages
+var DefaultHeaderTable = NewHeaderTable()
+
+// RegisterStaticHeader adds an app-specific entry to DefaultHeaderTable
+func RegisterStaticHeader(index uint64, name string) error {
+	return DefaultHeaderTable.Register(index, name)
+}
+
+// Register adds the entry. Both the index and the name must be unused
+// unless the same entry is registered again.
+func (t *HeaderTable) Register(index uint64, name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if existing, ok := t.byIndex[index]; ok {
+		if existing == name {
+			return nil
+		}
+		return fmt.Errorf("header index %d is already used by %s", index, existing)
+	}
+
+	if existing, ok := t.byName[name]; ok {
+		return fmt.Errorf("header %s already has index %d", name, existing)
+	}
+
+	t.byIndex[index] = name
+	t.byName[name] = index
+	return nil
+}
+
+// MarkSensitive makes headers with the name sensitive.
+// Names are case insensitive.
+func (t *HeaderTable) MarkSensitive(name string) {
+	t.mu.Lock()
+	t.sensitive[strings.ToLower(name)] = true
+	t.mu.Unlock()
+}
+
+// IsSensitive tells if headers with the name are sensitive
+func (t *HeaderTable) IsSensitive(name string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.sensitive[strings.ToLower(name)]
+}
+
+// Index returns the index of the header name
+func (t *HeaderTable) Index(name string) (uint64, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	index, ok := t.byName[name]
+	return index, ok
+}
+
+// Name returns the header name of the index
+func (t *HeaderTable) Name(index uint64) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	name, ok := t.byIndex[index]
+	return name, ok
+}
+
+// Encode packs headers as Cocaine header tuples
+// replacing names from the table by indices.
+// The first element of a tuple asks the peer to store the header
+// in its dynamic table. It's never set, sensitive headers in particular.
+func (t *HeaderTable) Encode(headers []Header) CocaineHeaders {
+	return t.encode(headers, false, 0)
+}
+
+// EncodeHuffman packs headers like Encode, but values of threshold bytes
+// or longer are coded with the static Huffman code of HPACK (RFC 7541)
+// if it makes them shorter. Tuples of such values have the fourth
+// element set to true. Peers must be able to decode them,
+// see ExtensionHuffman.
+func (t *HeaderTable) EncodeHuffman(headers []Header, threshold int) CocaineHeaders {
+	return t.encode(headers, true, threshold)
+}
+
+func (t *HeaderTable) encode(headers []Header, huffman bool, threshold int) CocaineHeaders {
+	var result = make(CocaineHeaders, 0, len(headers))
+	for _, header := range headers {
+		var key interface{} = header.Name
+		if index, ok := t.Index(header.Name); ok {
+			key = index
+		}
+
+		if huffman && len(header.Value) >= threshold {
+			if coded, ok := huffmanEncode(header.Value); ok {
+				result = append(result, []interface{}{false, key, coded, true})
+				continue
+			}
+		}
+
+		result = append(result, []interface{}{false, key, header.Value})
+	}
+	return result
+}
+
+// Decode unpacks Cocaine header tuples decoding Huffman coded values.
+// Malformed tuples and unknown indices are reported as an error.
+func (t *HeaderTable) Decode(headers CocaineHeaders) ([]Header, error) {
+	var result = make([]Header, 0, len(headers))
+	for _, raw := range headers {
+		tuple, ok := raw.([]interface{})
+		if !ok || (len(tuple) != 3 && len(tuple) != 4) {
+			return nil, ErrInvalidHeaderLength
+		}
+
+		var header Header
+		switch key := tuple[1].(type) {
+		case string:
+			header.Name = key
+		case []byte:
+			header.Name = string(key)
+		default:
+			index, isIndex := headerIndex(key)
+			if !isIndex {
+				return nil, ErrInvalidHeaderType
+			}
+
+			if header.Name, ok = t.Name(index); !ok {
+				return nil, fmt.Errorf("unknown header index %d", index)
+			}
+		}
+
+		switch value := tuple[2].(type) {
+		case []byte:
+			header.Value = value
+		case string:
+			header.Value = []byte(value)
+		default:
+			return nil, ErrInvalidHeaderType
+		}
+
+		if len(tuple) == 4 {
+			coded, isBool := tuple[3].(bool)
+			if !isBool {
+				return nil, ErrInvalidHeaderType
+			}
+
+			if coded {
+				value, err := huffmanDecode(header.Value)
+				if err != nil {
+					return nil, fmt.Errorf("malformed value of header %s: %v", header.Name, err)
+				}
+				header.Value = value
+			}
+		}
+
+		header.Sensitive = t.IsSensitive(header.Name)
+		result = append(result, header)
+	}
+
+	return result, nil
+}
+
+// formatHeaders prints headers redacting sensitive values
+func formatHeaders(headers CocaineHeaders) string {
+	if len(headers) == 0 {
+		return "[]"
+	}
+
+	decoded, err := DefaultHeaderTable.Decode(headers)
+	if err != nil {
+		// values can't be checked, so none of them is printed
+		return fmt.Sprintf("[%d undecodable headers]", len(headers))
+	}
+	return fmt.Sprint(decoded)
+}
+
+// redactFields hides values of fields named as sensitive headers
+func redactFields(fields Fields) Fields {
+	var redacted Fields
+	for k := range fields {
+		if !DefaultHeaderTable.IsSensitive(k) {
+			continue
+		}
+
+		if redacted == nil {
+			redacted = make(Fields, len(fields))
+			for k, v := range fields {
+				redacted[k] = v
+			}
+		}
+		redacted[k] = redactedValue
+	}
+
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
+
+func headerIndex(key interface{}) (uint64, bool) {
+	switch index := key.(type) {
+	case uint:
+		return uint64(index), true
+	case uint32:
+		return uint64(index), true
+	case uint64:
+		return index, true
+	case int:
+		return uint64(index), true
+	case int32:
+		return uint64(index), true
+	case int64:
+		return uint64(index), true
+	}
+	return 0, false
+}
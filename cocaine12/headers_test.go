@@ -0,0 +1,64 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+func TestHeaderTable(t *testing.T) {
+	table := NewHeaderTable()
+	assert.NoError(t, table.Register(100, "x-request-id"))
+	assert.NoError(t, table.Register(100, "x-request-id"), "the same entry can be registered twice")
+	assert.Error(t, table.Register(100, "x-other"))
+	assert.Error(t, table.Register(101, TraceIDHeader))
+
+	headers := []Header{
+		{Name: TraceIDHeader, Value: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{Name: "x-request-id", Value: []byte("req")},
+		{Name: "x-custom", Value: []byte("custom")},
+	}
+
+	encoded := table.Encode(headers)
+	assert.Equal(t, uint64(traceId), encoded[0].([]interface{})[1])
+	assert.Equal(t, uint64(100), encoded[1].([]interface{})[1])
+	assert.Equal(t, "x-custom", encoded[2].([]interface{})[1])
+
+	// emulate the wire
+	var buf []byte
+	codec.NewEncoderBytes(&buf, hAsocket).MustEncode(encoded)
+	var received CocaineHeaders
+	codec.NewDecoderBytes(buf, hAsocket).MustDecode(&received)
+
+	decoded, err := table.Decode(received)
+	assert.NoError(t, err)
+	assert.Equal(t, headers, decoded)
+
+	_, err = NewHeaderTable().Decode(received)
+	assert.Error(t, err, "index 100 is unknown to the default table")
+}
+
+func TestSensitiveHeaders(t *testing.T) {
+	table := NewHeaderTable()
+	table.MarkSensitive("X-Secret")
+	assert.True(t, table.IsSensitive("x-secret"))
+	assert.True(t, table.IsSensitive("Authorization"))
+	assert.False(t, table.IsSensitive("x-request-id"))
+
+	decoded, err := table.Decode(table.Encode([]Header{
+		{Name: "x-secret", Value: []byte("password")},
+		{Name: "x-request-id", Value: []byte("req")},
+	}))
+	assert.NoError(t, err)
+	assert.True(t, decoded[0].Sensitive)
+	assert.Equal(t, "x-secret: <redacted>", decoded[0].String())
+	assert.Equal(t, "x-request-id: req", decoded[1].String())
+
+	dump := formatHeaders(DefaultHeaderTable.Encode([]Header{{Name: "authorization", Value: []byte("OAuth token")}}))
+	assert.NotContains(t, dump, "token")
+
+	fields := Fields{"authorization": "OAuth token", "user": "name"}
+	assert.Equal(t, Fields{"authorization": redactedValue, "user": "name"}, redactFields(fields))
+	assert.Equal(t, "OAuth token", fields["authorization"], "original fields must not be changed")
+}
@@ -0,0 +1,150 @@
+package cocaine12
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// HealthEvent is the name of the built-in event reporting
+	// results of health checks
+	HealthEvent = "_health"
+
+	healthCheckTimeout = 5 * time.Second
+)
+
+// HealthCheck reports whether a dependency of the application is ready.
+// It must respect the context deadline.
+type HealthCheck func(ctx context.Context) error
+
+// HealthStatus is the aggregated result of health checks.
+// It's sent packed by msgpack as the reply to HealthEvent.
+type HealthStatus struct {
+	// Ready is true if all checks have passed
+	Ready bool
+	// Checks maps a check name to an error message.
+	// The message is empty for passed checks
+	Checks map[string]string
+}
+
+// UnpackHealthStatus unpacks HealthStatus from the reply to HealthEvent
+func UnpackHealthStatus(data []byte) (*HealthStatus, error) {
+	var status HealthStatus
+	if err := unmarshalPayload(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+type healthChecks struct {
+	mu     sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+func newHealthChecks() *healthChecks {
+	return &healthChecks{
+		checks: make(map[string]HealthCheck),
+	}
+}
+
+func (h *healthChecks) add(name string, check HealthCheck) {
+	h.mu.Lock()
+	h.checks[name] = check
+	h.mu.Unlock()
+}
+
+func (h *healthChecks) remove(name string) {
+	h.mu.Lock()
+	delete(h.checks, name)
+	h.mu.Unlock()
+}
+
+// run executes all checks in parallel
+func (h *healthChecks) run(ctx context.Context) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	h.mu.RLock()
+	var checks = make(map[string]HealthCheck, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	var (
+		results = make(chan result, len(checks))
+		status  = HealthStatus{
+			Ready:  true,
+			Checks: make(map[string]string, len(checks)),
+		}
+	)
+
+	for name, check := range checks {
+		go func(name string, check HealthCheck) {
+			results <- result{name, check(ctx)}
+		}(name, check)
+	}
+
+	for name := range checks {
+		// a check might ignore the context
+		// so it's reported as failed on the timeout
+		status.Checks[name] = "health check timed out"
+	}
+
+	for i := 0; i < len(checks); i++ {
+		select {
+		case res := <-results:
+			status.Checks[res.name] = ""
+			if res.err != nil {
+				status.Checks[res.name] = res.err.Error()
+			}
+		case <-ctx.Done():
+			i = len(checks)
+		}
+	}
+
+	for _, message := range status.Checks {
+		if message != "" {
+			status.Ready = false
+		}
+	}
+
+	return status
+}
+
+func (s *HealthStatus) failed() string {
+	var failed []string
+	for name, message := range s.Checks {
+		if message != "" {
+			failed = append(failed, name+": "+message)
+		}
+	}
+	sort.Strings(failed)
+	return strings.Join(failed, "; ")
+}
+
+func healthHandler(h *healthChecks) EventHandler {
+	return func(ctx context.Context, request Request, response Response) {
+		status := h.run(ctx)
+
+		buf, err := marshalPayload(status)
+		if err != nil {
+			response.ErrorMsg(ErrorNotReady, err.Error())
+			return
+		}
+
+		response.ZeroCopyWrite(buf)
+		if !status.Ready {
+			response.ErrorMsg(ErrorNotReady, status.failed())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cocaine12
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestHealthChecks(t *testing.T) {
+	h := newHealthChecks()
+	ctx := context.Background()
+
+	status := h.run(ctx)
+	assert.True(t, status.Ready)
+
+	h.add("db", func(ctx context.Context) error { return nil })
+	h.add("cache", func(ctx context.Context) error { return errors.New("not warmed up") })
+
+	status = h.run(ctx)
+	assert.False(t, status.Ready)
+	assert.Equal(t, map[string]string{"db": "", "cache": "not warmed up"}, status.Checks)
+
+	res := new(testResponse)
+	healthHandler(h)(ctx, &testRequest{}, res)
+	assert.Equal(t, ErrorNotReady, res.code)
+	assert.Equal(t, "cache: not warmed up", res.message)
+
+	unpacked, err := UnpackHealthStatus(res.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, &status, unpacked)
+
+	h.remove("cache")
+	res = new(testResponse)
+	healthHandler(h)(ctx, &testRequest{}, res)
+	assert.Equal(t, 0, res.code)
+	unpacked, err = UnpackHealthStatus(res.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, unpacked.Ready)
+}
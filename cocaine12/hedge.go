@@ -0,0 +1,94 @@
+package cocaine12
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNoServices means that HedgedCall has got nothing to call
+var ErrNoServices = errors.New("at least one service is required")
+
+// HedgedCall calls the method of the first service and waits for the reply.
+// If there is no reply after the delay or the call has failed, the same call
+// is issued to the next service. The first reply wins, calls still in flight
+// are cancelled. Services are expected to be connected to different endpoints.
+//
+// Hedging is safe for read-only methods only, as the request
+// can be handled more than once.
+func HedgedCall(ctx context.Context, delay time.Duration, services []*Service, name string, args ...interface{}) (ServiceResult, error) {
+	if len(services) == 0 {
+		return nil, ErrNoServices
+	}
+
+	return hedge(ctx, delay, len(services), func(ctx context.Context, i int) (ServiceResult, error) {
+		ch, err := services[i].Call(ctx, name, args...)
+		if err != nil {
+			return nil, err
+		}
+		return ch.Get(ctx)
+	})
+}
+
+type hedgedResult struct {
+	res ServiceResult
+	err error
+}
+
+// hedge runs up to n attempts starting the next one after the delay
+// or a failure of the previous. It returns the first successful result.
+func hedge(ctx context.Context, delay time.Duration, n int, attempt func(ctx context.Context, i int) (ServiceResult, error)) (ServiceResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancels the attempts which have lost
+	defer cancel()
+
+	var (
+		results  = make(chan hedgedResult, n)
+		started  = 0
+		finished = 0
+		lastErr  error
+	)
+
+	start := func() {
+		go func(i int) {
+			res, err := attempt(ctx, i)
+			results <- hedgedResult{res, err}
+		}(started)
+		started++
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	start()
+	for {
+		select {
+		case result := <-results:
+			finished++
+			if result.err == nil {
+				return result.res, nil
+			}
+
+			lastErr = result.err
+			if started < n {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				start()
+				timer.Reset(delay)
+			} else if finished == started {
+				return nil, lastErr
+			}
+
+		case <-timer.C:
+			if started < n {
+				start()
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
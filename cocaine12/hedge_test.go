@@ -0,0 +1,64 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestHedge(t *testing.T) {
+	var cancelled int32
+
+	// the first attempt hangs, the second one replies
+	res, err := hedge(context.Background(), 10*time.Millisecond, 3, func(ctx context.Context, i int) (ServiceResult, error) {
+		if i == 0 {
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return nil, ctx.Err()
+		}
+		return &serviceRes{method: uint64(i)}, nil
+	})
+	assert.NoError(t, err)
+	method, _, _ := res.Result()
+	assert.Equal(t, uint64(1), method)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+}
+
+func TestHedgeFailures(t *testing.T) {
+	var attempts int32
+
+	// failures start the next attempt without waiting for the delay
+	start := time.Now()
+	res, err := hedge(context.Background(), time.Hour, 3, func(ctx context.Context, i int) (ServiceResult, error) {
+		atomic.AddInt32(&attempts, 1)
+		if i < 2 {
+			return nil, errors.New("unavailable")
+		}
+		return &serviceRes{method: uint64(i)}, nil
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+	assert.True(t, time.Since(start) < time.Second)
+
+	_, err = hedge(context.Background(), time.Hour, 2, func(ctx context.Context, i int) (ServiceResult, error) {
+		return nil, errors.New("unavailable")
+	})
+	assert.EqualError(t, err, "unavailable")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = hedge(ctx, time.Millisecond, 2, func(ctx context.Context, i int) (ServiceResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = HedgedCall(context.Background(), time.Millisecond, nil, "method")
+	assert.Equal(t, ErrNoServices, err)
+}
@@ -0,0 +1,94 @@
+package cocaine12
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// every power of two range is split into this number of sub-buckets,
+	// so the relative error of a recorded value is less than 1/histSubBuckets
+	histSubBucketsBits = 4
+	histSubBuckets     = 1 << histSubBucketsBits
+	// values are stored in microseconds, it covers about 2^40us
+	histBuckets = (40 + 1) * histSubBuckets
+)
+
+// histogram is a lock-free log-linear histogram of durations
+// in the spirit of HdrHistogram. Recording is a single atomic increment.
+type histogram struct {
+	counts [histBuckets]uint64
+}
+
+func (h *histogram) record(d time.Duration) {
+	atomic.AddUint64(&h.counts[histIndex(durationToMicros(d))], 1)
+}
+
+// quantiles returns values for the given sorted quantiles
+func (h *histogram) quantiles(qs ...float64) []time.Duration {
+	var (
+		counts [histBuckets]uint64
+		total  uint64
+		result = make([]time.Duration, len(qs))
+	)
+
+	for i := range h.counts {
+		counts[i] = atomic.LoadUint64(&h.counts[i])
+		total += counts[i]
+	}
+
+	if total == 0 {
+		return result
+	}
+
+	var (
+		seen uint64
+		j    = 0
+	)
+
+	for i := 0; i < histBuckets && j < len(qs); i++ {
+		seen += counts[i]
+		for j < len(qs) && float64(seen) >= qs[j]*float64(total) {
+			result[j] = time.Duration(histValue(i)) * time.Microsecond
+			j++
+		}
+	}
+
+	return result
+}
+
+func durationToMicros(d time.Duration) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	return uint64(d / time.Microsecond)
+}
+
+func histIndex(value uint64) int {
+	if value < histSubBuckets {
+		return int(value)
+	}
+
+	var bitLen uint
+	for v := value; v != 0; v >>= 1 {
+		bitLen++
+	}
+
+	shift := bitLen - histSubBucketsBits - 1
+	index := int(shift+1)*histSubBuckets + int((value>>shift)-histSubBuckets)
+	if index >= histBuckets {
+		return histBuckets - 1
+	}
+	return index
+}
+
+// histValue returns the middle of the range of the bucket
+func histValue(index int) uint64 {
+	if index < histSubBuckets {
+		return uint64(index)
+	}
+
+	shift := uint(index/histSubBuckets - 1)
+	low := uint64(index%histSubBuckets+histSubBuckets) << shift
+	return low + (uint64(1)<<shift)/2
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/ugorji/go/codec"
 )
 
@@ -74,3 +77,29 @@ func BenchmarkHTTPDecoder(b *testing.B) {
 		UnpackProxyRequest(out)
 	}
 }
+
+func TestHTTPStreamedBody(t *testing.T) {
+	raw := packTestReq([]interface{}{"POST", "/upload", "1.1",
+		[][2]string{{"Content-Length", "11"}}, []byte("hello")})
+
+	r, err := unpackProxyRequest(raw, strings.NewReader(" world"))
+	if err != nil {
+		t.Fatalf("unable to unpack request %v", err)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, "hello world", string(b))
+	assert.Equal(t, int64(11), r.ContentLength)
+
+	// the whole body is in the first chunk
+	raw = packTestReq([]interface{}{"POST", "/upload", "1.1",
+		[][2]string{{"Content-Length", "5"}}, []byte("hello")})
+	r, err = unpackProxyRequest(raw, strings.NewReader(" world"))
+	if err != nil {
+		t.Fatalf("unable to unpack request %v", err)
+	}
+	b, _ = ioutil.ReadAll(r.Body)
+	assert.Equal(t, "hello", string(b))
+
+	assert.True(t, isBodyStreamed(http.Header{"Transfer-Encoding": {"chunked"}}, 10))
+	assert.False(t, isBodyStreamed(http.Header{}, 10))
+}
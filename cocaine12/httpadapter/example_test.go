@@ -0,0 +1,37 @@
+package httpadapter_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+	"github.com/cocaine/cocaine-framework-go/cocaine12/httpadapter"
+)
+
+func Example() {
+	r := chi.NewRouter()
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "user "+chi.URLParam(r, "id"))
+	})
+	r.Get("/static/{name}", func(w http.ResponseWriter, r *http.Request) {
+		httpadapter.ServeFile(w, r, "/var/www/"+chi.URLParam(r, "name"))
+	})
+
+	handler := httpadapter.Chain(r,
+		httpadapter.InjectRequestID,
+		httpadapter.CORS(httpadapter.CORSOptions{AllowedOrigins: []string{"*"}}),
+		httpadapter.LimitBody(1<<20),
+		httpadapter.Gzip(gzip.DefaultCompression),
+	)
+
+	worker, err := cocaine.NewWorker()
+	if err != nil {
+		panic(err)
+	}
+	worker.Run(map[string]cocaine.EventHandler{
+		"http": cocaine.WrapHandler(handler),
+	})
+}
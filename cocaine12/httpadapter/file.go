@@ -0,0 +1,76 @@
+package httpadapter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ServeFile replies with the contents of the file at path. Unlike
+// http.ServeFile it never lists directories or redirects, the path
+// is usually built by the application itself.
+//
+// Range requests and conditional requests by If-Modified-Since
+// and If-None-Match are served by http.ServeContent. The ETag
+// is derived from the modification time and the size of the file
+// unless the handler sets it. The file is streamed from disk
+// in chunks, so it's never kept in memory at whole.
+func ServeFile(w http.ResponseWriter, r *http.Request, path string) {
+	if containsDotDot(r.URL.Path) {
+		http.Error(w, "invalid URL path", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if w.Header().Get("Etag") == "" {
+		w.Header().Set("Etag", fileETag(info))
+	}
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
+
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func containsDotDot(v string) bool {
+	if !strings.Contains(v, "..") {
+		return false
+	}
+	for _, ent := range strings.FieldsFunc(v, isSlashRune) {
+		if ent == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func isSlashRune(r rune) bool { return r == '/' || r == '\\' }
@@ -0,0 +1,78 @@
+package httpadapter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "httpadapter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "file.txt")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func serveFile(path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/file.txt", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	ServeFile(w, r, path)
+	return w
+}
+
+func TestServeFile(t *testing.T) {
+	path, cleanup := newTestFile(t, "0123456789")
+	defer cleanup()
+
+	w := serveFile(path, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+	etag := w.Header().Get("Etag")
+	assert.NotEmpty(t, etag)
+
+	w = serveFile(path, map[string]string{"Range": "bytes=2-4"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "234", w.Body.String())
+	assert.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+
+	w = serveFile(path, map[string]string{"Range": "bytes=20-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+	w = serveFile(path, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = serveFile(path, map[string]string{
+		"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+	})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestServeFileErrors(t *testing.T) {
+	path, cleanup := newTestFile(t, "")
+	defer cleanup()
+
+	assert.Equal(t, http.StatusNotFound, serveFile(path+".missing", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveFile(filepath.Dir(path), nil).Code)
+
+	r := httptest.NewRequest("GET", "/static/../secret", nil)
+	w := httptest.NewRecorder()
+	ServeFile(w, r, path)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
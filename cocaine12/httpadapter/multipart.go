@@ -0,0 +1,95 @@
+package httpadapter
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+)
+
+var (
+	// ErrPartTooLarge is returned by reads of a part
+	// beyond MultipartLimits.MaxPartSize
+	ErrPartTooLarge = errors.New("multipart: part is too large")
+	// ErrTooManyParts is returned by NextPart
+	// beyond MultipartLimits.MaxParts
+	ErrTooManyParts = errors.New("multipart: too many parts")
+)
+
+// MultipartLimits restricts a multipart body. Zero means no limit.
+type MultipartLimits struct {
+	MaxPartSize int64
+	MaxParts    int
+}
+
+// MultipartReader reads parts of a multipart/form-data body as they
+// arrive, so large uploads don't have to be kept in memory as
+// http.Request.ParseMultipartForm does. The body of a request
+// larger than a chunk is streamed by the cocaine HTTP adapter.
+type MultipartReader struct {
+	reader *multipart.Reader
+	limits MultipartLimits
+	parts  int
+}
+
+// NewMultipartReader returns the reader of the body of r.
+// It fails if r isn't multipart/form-data or multipart/mixed.
+func NewMultipartReader(r *http.Request, limits MultipartLimits) (*MultipartReader, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartReader{
+		reader: reader,
+		limits: limits,
+	}, nil
+}
+
+// NextPart returns the next part or io.EOF
+func (m *MultipartReader) NextPart() (*Part, error) {
+	if m.limits.MaxParts > 0 && m.parts >= m.limits.MaxParts {
+		return nil, ErrTooManyParts
+	}
+
+	part, err := m.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	m.parts++
+
+	return &Part{
+		Part:  part,
+		limit: m.limits.MaxPartSize,
+	}, nil
+}
+
+// Part is a part of a multipart body. Read fails with ErrPartTooLarge
+// once it's larger than MultipartLimits.MaxPartSize.
+type Part struct {
+	*multipart.Part
+	limit int64
+	read  int64
+}
+
+func (p *Part) Read(buf []byte) (int, error) {
+	if p.limit <= 0 {
+		return p.Part.Read(buf)
+	}
+
+	if p.read >= p.limit {
+		// the part may be exactly of the limit size
+		var probe [1]byte
+		n, err := p.Part.Read(probe[:])
+		if n > 0 {
+			return 0, ErrPartTooLarge
+		}
+		return 0, err
+	}
+
+	if left := p.limit - p.read; int64(len(buf)) > left {
+		buf = buf[:left]
+	}
+	n, err := p.Part.Read(buf)
+	p.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package httpadapter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMultipartRequest(t *testing.T, parts map[string]string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, name := range []string{"small", "exact", "large"} {
+		if content, ok := parts[name]; ok {
+			fw, err := w.CreateFormFile(name, name+".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(fw, content)
+		}
+	}
+	w.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestMultipartReader(t *testing.T) {
+	r := newMultipartRequest(t, map[string]string{
+		"small": "abc",
+		"exact": "abcde",
+		"large": strings.Repeat("x", 100),
+	})
+
+	reader, err := NewMultipartReader(r, MultipartLimits{MaxPartSize: 5})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, expected := range []string{"abc", "abcde"} {
+		part, err := reader.NextPart()
+		if !assert.NoError(t, err) {
+			return
+		}
+		content, err := ioutil.ReadAll(part)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	part, err := reader.NextPart()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "large", part.FormName())
+	content, err := ioutil.ReadAll(part)
+	assert.Equal(t, ErrPartTooLarge, err)
+	assert.Len(t, content, 5)
+}
+
+func TestMultipartReaderMaxParts(t *testing.T) {
+	r := newMultipartRequest(t, map[string]string{"small": "abc", "large": "abc"})
+
+	reader, err := NewMultipartReader(r, MultipartLimits{MaxParts: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = reader.NextPart()
+	assert.NoError(t, err)
+	_, err = reader.NextPart()
+	assert.Equal(t, ErrTooManyParts, err)
+}
+
+func TestMultipartReaderNotMultipart(t *testing.T) {
+	_, err := NewMultipartReader(httptest.NewRequest("POST", "/", nil), MultipartLimits{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package httpadapter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// conformance of routers mounted by cocaine.WrapHandler
+
+func newTestRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "user "+chi.URLParam(r, "id")+" "+r.URL.Query().Get("fields"))
+	})
+	r.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for _, part := range []string{"a", "b", "c"} {
+			io.WriteString(w, part)
+			w.(http.Flusher).Flush()
+		}
+	})
+	r.Get("/early", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html></html>")
+		// too late for both
+		w.Header().Set("X-Late", "1")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Get("/info", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI+" "+r.Proto+" "+r.Host)
+	})
+	return r
+}
+
+func serveTestRouter(t *testing.T, method, target string) *EventResponse {
+	r := httptest.NewRequest(method, target, nil)
+	res, err := ServeEvent(context.Background(), cocaine.WrapHandler(newTestRouter()), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRouterPathParams(t *testing.T) {
+	res := serveTestRouter(t, "GET", "/users/42?fields=name")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "user 42 name", string(res.Body()))
+	assert.Nil(t, res.Err)
+}
+
+func TestRouterNotFound(t *testing.T) {
+	res := serveTestRouter(t, "GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	res := serveTestRouter(t, "DELETE", "/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+	res = serveTestRouter(t, "POST", "/users")
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Empty(t, res.Chunks)
+}
+
+func TestRouterStreaming(t *testing.T) {
+	res := serveTestRouter(t, "GET", "/stream")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/plain", res.Header.Get("Content-Type"))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, res.Chunks)
+}
+
+func TestRouterEarlyWrite(t *testing.T) {
+	res := serveTestRouter(t, "GET", "/early")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Empty(t, res.Header.Get("X-Late"))
+	assert.Equal(t, "<html></html>", string(res.Body()))
+}
+
+func TestRouterRequestLine(t *testing.T) {
+	res := serveTestRouter(t, "GET", "http://example.com/info?a=1")
+	assert.Equal(t, "/info?a=1 HTTP/1.1 example.com", string(res.Body()))
+}
@@ -0,0 +1,163 @@
+package httpadapter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+var (
+	mhProxy = codec.MsgpackHandle{
+		BasicHandle: codec.BasicHandle{
+			EncodeOptions: codec.EncodeOptions{
+				StructToArray: true,
+			},
+		},
+	}
+	hProxy = &mhProxy
+)
+
+// EventResponse is the response of an HTTP event as the proxy receives it
+type EventResponse struct {
+	StatusCode int
+	Header     http.Header
+	// Chunks of the body as they are sent by the handler
+	Chunks [][]byte
+	// Err is set if the handler has replied with a cocaine error
+	Err *cocaine.ErrRequest
+}
+
+// Body returns the whole body
+func (r *EventResponse) Body() []byte {
+	return bytes.Join(r.Chunks, nil)
+}
+
+// ServeEvent invokes the handler of an HTTP event, e.g. made
+// by cocaine.WrapHandler, with the request packed as the cocaine HTTP
+// proxy does. It lets routers mounted on events be tested
+// without a runtime.
+func ServeEvent(ctx context.Context, handler cocaine.EventHandler, r *http.Request) (*EventResponse, error) {
+	packed, err := packRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(chunkRecorder)
+	handler(ctx, &chunkRequest{chunks: [][]byte{packed}}, res)
+
+	if len(res.chunks) == 0 {
+		if res.err != nil {
+			return &EventResponse{Err: res.err}, nil
+		}
+		return nil, fmt.Errorf("the handler has replied without a head")
+	}
+
+	var head struct {
+		Code    int
+		Headers cocaine.Headers
+	}
+	if err := codec.NewDecoderBytes(res.chunks[0], hProxy).Decode(&head); err != nil {
+		return nil, fmt.Errorf("unable to unpack the head of a response: %v", err)
+	}
+
+	return &EventResponse{
+		StatusCode: head.Code,
+		Header:     cocaine.HeadersCocaineToHTTP(head.Headers),
+		Chunks:     res.chunks[1:],
+		Err:        res.err,
+	}, nil
+}
+
+func packRequest(r *http.Request) ([]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	header := r.Header
+	if r.Host != "" && header.Get("Host") == "" {
+		header = cloneHeader(header)
+		header.Set("Host", r.Host)
+	}
+
+	var packed []byte
+	err := codec.NewEncoderBytes(&packed, hProxy).Encode([]interface{}{
+		r.Method,
+		r.URL.RequestURI(),
+		fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor),
+		cocaine.HeadersHTTPtoCocaine(header),
+		body,
+	})
+	return packed, err
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header)+1)
+	for k, v := range header {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+type chunkRequest struct {
+	chunks [][]byte
+}
+
+func (r *chunkRequest) Read(ctx context.Context) ([]byte, error) {
+	if len(r.chunks) == 0 {
+		return nil, cocaine.ErrStreamIsClosed
+	}
+
+	var chunk []byte
+	chunk, r.chunks = r.chunks[0], r.chunks[1:]
+	return chunk, nil
+}
+
+type chunkRecorder struct {
+	chunks [][]byte
+	err    *cocaine.ErrRequest
+	closed bool
+}
+
+func (r *chunkRecorder) Write(data []byte) (int, error) {
+	if err := r.ZeroCopyWrite(append([]byte(nil), data...)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (r *chunkRecorder) ZeroCopyWrite(data []byte) error {
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+	r.chunks = append(r.chunks, data)
+	return nil
+}
+
+func (r *chunkRecorder) Close() error {
+	if r.closed {
+		return syscall.EINVAL
+	}
+	r.closed = true
+	return nil
+}
+
+func (r *chunkRecorder) ErrorMsg(code int, message string) error {
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+	r.closed = true
+	r.err = &cocaine.ErrRequest{Code: code, Message: message}
+	return nil
+}
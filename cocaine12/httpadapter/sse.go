@@ -0,0 +1,152 @@
+// Package httpadapter provides helpers for HTTP handlers
+// wrapped by cocaine.WrapHandler and friends
+package httpadapter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LastEventIDHeader is sent by a client reconnecting to an event stream
+const LastEventIDHeader = "Last-Event-ID"
+
+// ErrEventStreamClosed is returned by writes to a closed EventStream
+var ErrEventStreamClosed = errors.New("event stream is closed")
+
+// Event is a message of Server-Sent Events.
+// Empty fields are omitted.
+type Event struct {
+	ID    string
+	Event string
+	// Data may consist of several lines
+	Data string
+	// Retry tells the client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+func (e Event) String() string {
+	var b bytes.Buffer
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry/time.Millisecond)
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// EventStream sends Server-Sent Events. Every event is sent
+// as a separate chunk through the cocaine HTTP proxy.
+// It's safe to send events from several goroutines.
+// A handler must Close the stream before it returns.
+type EventStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+	done    chan struct{}
+}
+
+// NewEventStream sends the header of an event stream. A comment
+// is sent every heartbeat interval to keep idle connections
+// unless heartbeat is zero. Heartbeats stop when the stream is closed
+// or the request is cancelled.
+func NewEventStream(w http.ResponseWriter, r *http.Request, heartbeat time.Duration) *EventStream {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// nginx must not buffer the stream
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &EventStream{
+		w:    w,
+		done: make(chan struct{}),
+	}
+	s.flusher, _ = w.(http.Flusher)
+	s.flush()
+
+	if heartbeat > 0 {
+		go s.heartbeat(r, heartbeat)
+	}
+	return s
+}
+
+// LastEventID returns the ID of the last event received by a client
+// before it has reconnected
+func LastEventID(r *http.Request) string {
+	return r.Header.Get(LastEventIDHeader)
+}
+
+// Send sends the event
+func (s *EventStream) Send(event Event) error {
+	return s.write(event.String())
+}
+
+// Comment sends a comment ignored by clients
+func (s *EventStream) Comment(text string) error {
+	return s.write(": " + strings.Replace(text, "\n", "\n: ", -1) + "\n\n")
+}
+
+// Close stops heartbeats. Further writes fail with ErrEventStreamClosed.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+}
+
+func (s *EventStream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrEventStreamClosed
+	}
+
+	if _, err := s.w.Write([]byte(data)); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *EventStream) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func (s *EventStream) heartbeat(r *http.Request, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		case <-r.Context().Done():
+			s.Close()
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
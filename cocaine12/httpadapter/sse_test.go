@@ -0,0 +1,59 @@
+package httpadapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestEventString(t *testing.T) {
+	assert.Equal(t, "data: hello\n\n", Event{Data: "hello"}.String())
+	assert.Equal(t, "id: 7\nevent: update\nretry: 1500\ndata: a\ndata: b\n\n", Event{
+		ID:    "7",
+		Event: "update",
+		Data:  "a\nb",
+		Retry: time.Millisecond * 1500,
+	}.String())
+}
+
+func TestEventStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set(LastEventIDHeader, "6")
+	assert.Equal(t, "6", LastEventID(r))
+
+	stream := NewEventStream(w, r, 0)
+	assert.NoError(t, stream.Send(Event{ID: "7", Data: "hello"}))
+	assert.NoError(t, stream.Comment("multi\nline"))
+	stream.Close()
+	assert.Equal(t, ErrEventStreamClosed, stream.Send(Event{Data: "late"}))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "id: 7\ndata: hello\n\n: multi\n: line\n\n", w.Body.String())
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+
+	stream := NewEventStream(w, r, time.Millisecond)
+	time.Sleep(time.Millisecond * 20)
+
+	// the stream is closed once the request is cancelled
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for stream.Comment("probe") == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, ErrEventStreamClosed, stream.Comment("probe"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), ": heartbeat\n\n"), w.Body.String())
+}
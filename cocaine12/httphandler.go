@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"golang.org/x/net/context"
 
@@ -30,6 +33,12 @@ type Headers [][2]string
 
 // UnpackProxyRequest unpacks a HTTPRequest from a serialized cocaine form
 func UnpackProxyRequest(raw []byte) (*http.Request, error) {
+	return unpackProxyRequest(raw, nil)
+}
+
+// unpackProxyRequest continues the body with rest if the proxy
+// sends it in following chunks
+func unpackProxyRequest(raw []byte, rest io.Reader) (*http.Request, error) {
 	var v struct {
 		Method  string
 		URI     string
@@ -49,6 +58,19 @@ func UnpackProxyRequest(raw []byte) (*http.Request, error) {
 
 	req.Header = HeadersCocaineToHTTP(v.Headers)
 	req.Host = req.Header.Get("Host")
+	// routers may rely on them as on requests of net/http server
+	req.RequestURI = v.URI
+	if major, minor, ok := http.ParseHTTPVersion("HTTP/" + v.Version); ok {
+		req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/"+v.Version, major, minor
+	}
+
+	if rest != nil && isBodyStreamed(req.Header, len(v.Body)) {
+		req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(v.Body), rest))
+		req.ContentLength = -1
+		if cl, err := strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64); err == nil {
+			req.ContentLength = cl
+		}
+	}
 
 	if xRealIP := req.Header.Get("X-Real-IP"); xRealIP != "" {
 		req.RemoteAddr = xRealIP
@@ -127,7 +149,7 @@ func WrapHandler(handler http.Handler) EventHandler {
 	return func(ctx context.Context, request Request, response Response) {
 		defer response.Close()
 
-		w, httpRequest, err := convertToHTTPFunc(ctx, request, response)
+		_, w, httpRequest, err := convertToHTTPFunc(ctx, request, response)
 		if err != nil {
 			return
 		}
@@ -141,7 +163,7 @@ func WrapHTTPFunc(handler func(ctx context.Context, w http.ResponseWriter, req *
 	return func(ctx context.Context, request Request, response Response) {
 		defer response.Close()
 
-		w, httpRequest, err := convertToHTTPFunc(ctx, request, response)
+		ctx, w, httpRequest, err := convertToHTTPFunc(ctx, request, response)
 		if err != nil {
 			return
 		}
@@ -160,7 +182,7 @@ func WrapHTTPFuncs(hfs map[string]func(ctx context.Context, w http.ResponseWrite
 	return handlers
 }
 
-func convertToHTTPFunc(ctx context.Context, request Request, response Response) (*ResponseWriter, *http.Request, error) {
+func convertToHTTPFunc(ctx context.Context, request Request, response Response) (context.Context, *ResponseWriter, *http.Request, error) {
 	// Read the first chunk
 	// It consists of method, uri, httpversion, headers, body.
 	// They are packed by msgpack
@@ -169,21 +191,31 @@ func convertToHTTPFunc(ctx context.Context, request Request, response Response)
 		if ctx.Err() != nil {
 			response.Write(WriteHead(http.StatusRequestTimeout, Headers{}))
 			response.Write([]byte("request was not received during a timeout"))
-			return nil, nil, ctx.Err()
+			return ctx, nil, nil, ctx.Err()
 		}
 
 		response.Write(WriteHead(http.StatusBadRequest, Headers{}))
 		response.Write([]byte("cannot process request " + err.Error()))
-		return nil, nil, err
+		return ctx, nil, nil, err
 	}
 
-	httpRequest, err := UnpackProxyRequest(msg)
+	httpRequest, err := unpackProxyRequest(msg, RequestReader(ctx, request))
 	if err != nil {
 		response.Write(WriteHead(http.StatusBadRequest, Headers{}))
 		response.Write([]byte("malformed request"))
-		return nil, nil, err
+		return ctx, nil, nil, err
 	}
 
+	// continue a trace started by a non-cocaine service
+	if getTraceInfo(ctx) == nil {
+		if traceInfo, ok := ExtractTraceHeaders(httpRequest.Header); ok {
+			ctx = AttachTraceInfo(ctx, traceInfo)
+		}
+	}
+
+	// handlers learn that the request is cancelled from its context
+	httpRequest = httpRequest.WithContext(ctx)
+
 	w := &ResponseWriter{
 		cRes:          response,
 		req:           httpRequest,
@@ -192,7 +224,7 @@ func convertToHTTPFunc(ctx context.Context, request Request, response Response)
 		wroteHeader:   false,
 	}
 
-	return w, httpRequest, nil
+	return ctx, w, httpRequest, nil
 }
 
 // WrapHandlerFunc provides opportunity for using Go web frameworks, which supports http.HandlerFunc interface
@@ -234,6 +266,17 @@ func WrapHandleFuncs(hfs map[string]http.HandlerFunc) map[string]EventHandler {
 	return handlers
 }
 
+// isBodyStreamed reports whether the body is longer than received
+// in the first chunk, so the rest of it comes in following chunks
+func isBodyStreamed(header http.Header, received int) bool {
+	if strings.Contains(strings.ToLower(header.Get("Transfer-Encoding")), "chunked") {
+		return true
+	}
+
+	cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	return err == nil && cl > int64(received)
+}
+
 // inspired by https://github.com/golang/go/blob/master/src/net/http/transport.go#L1238
 // gzipReader wraps a response body so it can lazily
 // call gzip.NewReader on the first call to Read
@@ -47,6 +47,14 @@ func (w *ResponseWriter) WriteHeader(code int) {
 	)
 }
 
+// Flush implements http.Flusher. Every write is sent as a chunk
+// at once, so it only sends the header if it hasn't been sent yet.
+func (w *ResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 func (w *ResponseWriter) finishRequest() {
 	if !w.wroteHeader {
 		w.WriteHeader(http.StatusOK)
@@ -82,6 +90,10 @@ func (w *ResponseWriter) WriteString(data string) (n int, err error) {
 
 func (w *ResponseWriter) write(data []byte, shouldCopy bool) (n int, err error) {
 	if !w.wroteHeader {
+		// as net/http does for handlers which write the body at once
+		if _, hasType := w.handlerHeader["Content-Type"]; !hasType && len(data) > 0 {
+			w.handlerHeader.Set("Content-Type", http.DetectContentType(data))
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 
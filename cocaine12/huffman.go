@@ -0,0 +1,38 @@
+package cocaine12
+
+import (
+	"bytes"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// DefaultHuffmanThreshold is the length of a header value
+// starting from which Huffman coding usually pays off
+const DefaultHuffmanThreshold = 32
+
+// SetHuffmanThreshold makes the worker code values of response headers
+// which are threshold bytes or longer, e.g. tokens, with the static
+// Huffman code of HPACK. Values are coded only if the runtime has
+// advertised ExtensionHuffman, as others can't decode them, whether
+// EnableRuntimeExtensions is on or not. Zero disables the coding,
+// which is the default. See DefaultHuffmanThreshold.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetHuffmanThreshold(threshold int) {
+	w.huffmanThreshold = threshold
+}
+
+// huffmanEncode returns false if the coding doesn't make the value shorter
+func huffmanEncode(value []byte) ([]byte, bool) {
+	if hpack.HuffmanEncodeLength(string(value)) >= uint64(len(value)) {
+		return nil, false
+	}
+	return hpack.AppendHuffmanString(nil, string(value)), true
+}
+
+func huffmanDecode(coded []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := hpack.HuffmanDecode(&buf, coded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+func TestHeaderTableHuffman(t *testing.T) {
+	token := []byte("OAuth 0123456789abcdef0123456789abcdef0123456789abcdef")
+	headers := []Header{
+		{Name: "authorization", Value: token, Sensitive: true},
+		{Name: "x-short", Value: []byte("abc")},
+		// binary data is longer after the coding
+		{Name: "x-binary", Value: []byte{0xff, 0xfe, 0xfd, 0xfc, 0xfb, 0xfa, 0xf9, 0xf8}},
+	}
+
+	encoded := DefaultHeaderTable.EncodeHuffman(headers, 8)
+	coded := encoded[0].([]interface{})
+	assert.Len(t, coded, 4)
+	assert.True(t, len(coded[2].([]byte)) < len(token))
+	assert.Len(t, encoded[1], 3, "short values are sent as they are")
+	assert.Len(t, encoded[2], 3, "values are sent as they are unless the coding makes them shorter")
+
+	// emulate the wire
+	var buf []byte
+	codec.NewEncoderBytes(&buf, hAsocket).MustEncode(encoded)
+	var received CocaineHeaders
+	codec.NewDecoderBytes(buf, hAsocket).MustDecode(&received)
+
+	decoded, err := DefaultHeaderTable.Decode(received)
+	assert.NoError(t, err)
+	assert.Equal(t, headers, decoded)
+
+	_, err = DefaultHeaderTable.Decode(CocaineHeaders{
+		[]interface{}{false, "x-broken", []byte{0xff, 0xff, 0xff, 0xff}, true},
+	})
+	assert.Error(t, err)
+}
+
+func TestWorkerHuffmanHeaders(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetHuffmanThreshold(DefaultHuffmanThreshold)
+
+	value := "0123456789abcdef0123456789abcdef0123456789abcdef"
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			res.(MetadataWriter).CloseWithMetadata(Pairs("x-token", value))
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	// the runtime is not known to decode Huffman coded values
+	sock2.Write() <- newInvokeV1(2, "test")
+	choke := <-sock2.Read()
+	checkTypeAndSession(t, choke, 2, v1Close)
+	assert.Len(t, choke.Headers[0], 3)
+
+	reply := newHeartbeatV1()
+	reply.Headers = DefaultHeaderTable.Encode([]Header{
+		{Name: RuntimeExtensionsHeader, Value: []byte("headers,huffman")},
+	})
+	sock2.Write() <- reply
+
+	deadline := time.Now().Add(time.Second)
+	for !w.RuntimeInfo().Known && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	sock2.Write() <- newInvokeV1(3, "test")
+	choke = <-sock2.Read()
+	checkTypeAndSession(t, choke, 3, v1Close)
+	assert.Len(t, choke.Headers[0], 4)
+	token, ok := findHeader(choke.Headers, "x-token")
+	assert.True(t, ok)
+	assert.Equal(t, value, string(token))
+}
@@ -0,0 +1,90 @@
+package cocaine12
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// InfoEvent is the name of the built-in event describing
+	// events of the application. See Worker.Events
+	InfoEvent = "_info"
+)
+
+// EventInfo describes a registered event.
+// A list of them is sent packed by msgpack as the reply to InfoEvent.
+type EventInfo struct {
+	Name string
+	// Request and Response are Go types of arguments of a handler
+	// registered by OnTyped. They are empty for other handlers
+	Request  string
+	Response string
+	Priority Priority
+	// Middleware lists interceptors wrapping the handler,
+	// the outermost first
+	Middleware []string
+}
+
+// UnpackEventInfo unpacks the list of events from the reply to InfoEvent
+func UnpackEventInfo(data []byte) ([]EventInfo, error) {
+	var events []EventInfo
+	if err := unmarshalPayload(data, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// closures of a function are named like pkg.Func.func1.2
+var closureSuffix = regexp.MustCompile(`(\.func\d+)(\.\d+)*$`)
+
+// interceptorName returns the name of the function
+// which has created the interceptor
+func interceptorName(interceptor Interceptor) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(interceptor).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return closureSuffix.ReplaceAllString(fn.Name(), "")
+}
+
+func (e *EventHandlers) events() []EventInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var middleware []string
+	for _, interceptor := range e.interceptors {
+		middleware = append(middleware, interceptorName(interceptor))
+	}
+
+	names := e.sortedNames()
+	events := make([]EventInfo, 0, len(names))
+	for _, name := range names {
+		description := e.descriptions[name]
+		info := EventInfo{
+			Name:       name,
+			Priority:   description.priority,
+			Middleware: middleware,
+		}
+		if signature := description.signature; signature != nil {
+			info.Request = signature.In(1).String()
+			info.Response = signature.Out(0).String()
+		}
+		events = append(events, info)
+	}
+	return events
+}
+
+func infoHandler(handlers *EventHandlers) EventHandler {
+	return func(ctx context.Context, request Request, response Response) {
+		buf, err := marshalPayload(handlers.events())
+		if err != nil {
+			response.ErrorMsg(ErrorInternal, fmt.Sprintf("unable to pack events: %v", err))
+			return
+		}
+		response.ZeroCopyWrite(buf)
+	}
+}
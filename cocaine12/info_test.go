@@ -0,0 +1,62 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func passThrough(event string, handler EventHandler) EventHandler {
+	return handler
+}
+
+func TestWorkerEvents(t *testing.T) {
+	_, out := testConn()
+	sock, _ := newAsyncRW(out)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	w.On("raw", func(ctx context.Context, req Request, res Response) {}, WithPriority(PriorityHigh))
+	w.OnTyped("typed", func(ctx context.Context, req sumRequest) (*sumResponse, error) {
+		return nil, nil
+	})
+	w.Use(passThrough)
+
+	events := w.Events()
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, EventInfo{
+			Name:       "raw",
+			Priority:   PriorityHigh,
+			Middleware: []string{"github.com/cocaine/cocaine-framework-go/cocaine12.passThrough"},
+		}, events[0])
+		assert.Equal(t, "typed", events[1].Name)
+		assert.Equal(t, "cocaine12.sumRequest", events[1].Request)
+		assert.Equal(t, "*cocaine12.sumResponse", events[1].Response)
+		assert.Equal(t, PriorityNormal, events[1].Priority)
+	}
+
+	// a raw handler replaces the typed one
+	w.On("typed", func(ctx context.Context, req Request, res Response) {})
+	assert.Empty(t, w.Events()[1].Request)
+}
+
+func TestInfoHandler(t *testing.T) {
+	handlers := NewEventHandlers()
+	handlers.On("echo", nil)
+	handlers.On(InfoEvent, infoHandler(handlers))
+
+	res := new(testResponse)
+	handlers.Call(context.Background(), InfoEvent, &testRequest{}, res)
+
+	events, err := UnpackEventInfo(res.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, InfoEvent, events[0].Name)
+		assert.Equal(t, "echo", events[1].Name)
+	}
+}
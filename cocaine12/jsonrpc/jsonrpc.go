@@ -0,0 +1,313 @@
+// Package jsonrpc serves JSON-RPC 2.0 over a cocaine event,
+// for clients which can't speak msgpack.
+//
+// Every chunk of a request is a JSON-RPC request or a batch of them.
+// Every response or a batch of responses is sent as a separate chunk,
+// notifications are not replied:
+//
+//	server := jsonrpc.NewServer()
+//	server.Register("echo", func(ctx context.Context, args EchoArgs) (string, error) {
+//		return args.Message, nil
+//	})
+//	worker.On("jsonrpc", server.Handler())
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const version = "2.0"
+
+// Codes of errors defined by the specification
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError is sent for errors of methods
+	// which are not *Error
+	CodeServerError = -32000
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Error is a JSON-RPC error. Methods return it to reply
+// with a specific code and data.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (%d)", e.Message, e.Code)
+}
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID is nil for notifications
+	ID *json.RawMessage `json:"id,omitempty"`
+}
+
+type response struct {
+	Version string           `json:"jsonrpc"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+type method struct {
+	fn      reflect.Value
+	argType reflect.Type
+}
+
+// Server routes JSON-RPC requests to registered methods
+type Server struct {
+	mu      sync.RWMutex
+	methods map[string]method
+}
+
+// NewServer creates a Server without methods
+func NewServer() *Server {
+	return &Server{
+		methods: make(map[string]method),
+	}
+}
+
+// Register registers fn as the method name. fn must be of the form
+//
+//	func(ctx context.Context, args MyArgs) (MyResult, error)
+//
+// Params of a request are unmarshaled into MyArgs, either from an object
+// or from an array of a single element unless MyArgs is a slice itself.
+// MyArgs implementing cocaine.Validator is checked before fn is called.
+func (s *Server) Register(name string, fn interface{}) error {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func ||
+		fnType.NumIn() != 2 || fnType.In(0) != contextType ||
+		fnType.NumOut() != 2 || fnType.Out(1) != errorType {
+		return fmt.Errorf("%v is not func(context.Context, Args) (Result, error)", fnType)
+	}
+
+	s.mu.Lock()
+	s.methods[name] = method{fn: fnValue, argType: fnType.In(1)}
+	s.mu.Unlock()
+	return nil
+}
+
+// RegisterMethods registers every exported method of rcvr of the form
+// accepted by Register as prefix.MethodName. It returns an error
+// if there are no such methods.
+func (s *Server) RegisterMethods(prefix string, rcvr interface{}) error {
+	value := reflect.ValueOf(rcvr)
+	registered := 0
+	for i := 0; i < value.NumMethod(); i++ {
+		name := prefix + "." + value.Type().Method(i).Name
+		if err := s.Register(name, value.Method(i).Interface()); err == nil {
+			registered++
+		}
+	}
+
+	if registered == 0 {
+		return fmt.Errorf("%T has no methods of the form func(context.Context, Args) (Result, error)", rcvr)
+	}
+	return nil
+}
+
+// Methods returns sorted names of registered methods
+func (s *Server) Methods() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handler returns the handler of an event serving JSON-RPC.
+// It replies to chunks until the request is closed.
+func (s *Server) Handler() cocaine.EventHandler {
+	return func(ctx context.Context, req cocaine.Request, res cocaine.Response) {
+		for {
+			chunk, err := req.Read(ctx)
+			if err != nil {
+				return
+			}
+
+			if reply := s.serve(ctx, chunk); reply != nil {
+				if err := res.ZeroCopyWrite(reply); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// serve returns nil if nothing has to be replied
+func (s *Server) serve(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return s.serveBatch(ctx, data)
+	}
+
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return marshalResponse(parseError(err))
+	}
+
+	res := s.call(ctx, &req)
+	if res == nil {
+		return nil
+	}
+	return marshalResponse(res)
+}
+
+func (s *Server) serveBatch(ctx context.Context, data []byte) []byte {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return marshalResponse(parseError(err))
+	}
+	if len(batch) == 0 {
+		return marshalResponse(errorResponse(nil, CodeInvalidRequest, "empty batch"))
+	}
+
+	var responses []*response
+	for _, raw := range batch {
+		var req request
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses = append(responses, errorResponse(nil, CodeInvalidRequest, err.Error()))
+			continue
+		}
+
+		if res := s.call(ctx, &req); res != nil {
+			responses = append(responses, res)
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+	return marshalResponse(responses)
+}
+
+// call returns nil for notifications unless they are malformed
+func (s *Server) call(ctx context.Context, req *request) *response {
+	res := s.invoke(ctx, req)
+	if req.ID == nil && (res.Error == nil || res.Error.Code != CodeInvalidRequest) {
+		return nil
+	}
+
+	res.ID = req.ID
+	return res
+}
+
+func (s *Server) invoke(ctx context.Context, req *request) *response {
+	if req.Version != version || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+
+	s.mu.RLock()
+	m, ok := s.methods[req.Method]
+	s.mu.RUnlock()
+	if !ok {
+		return errorResponse(req.ID, CodeMethodNotFound, fmt.Sprintf("method %s not found", req.Method))
+	}
+
+	arg, err := decodeParams(req.Params, m.argType)
+	if err != nil {
+		return errorResponse(req.ID, CodeInvalidParams, err.Error())
+	}
+
+	out := m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	if err, _ := out[1].Interface().(error); err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return &response{Version: version, Error: rpcErr}
+		}
+		return errorResponse(req.ID, CodeServerError, err.Error())
+	}
+
+	result, err := json.Marshal(out[0].Interface())
+	if err != nil {
+		return errorResponse(req.ID, CodeInternalError, fmt.Sprintf("unable to marshal the result: %v", err))
+	}
+	return &response{Version: version, Result: result}
+}
+
+// decodeParams returns a value of argType unmarshaled from params
+func decodeParams(params json.RawMessage, argType reflect.Type) (reflect.Value, error) {
+	var arg reflect.Value
+	if argType.Kind() == reflect.Ptr {
+		arg = reflect.New(argType.Elem())
+	} else {
+		arg = reflect.New(argType)
+	}
+
+	params = bytes.TrimSpace(params)
+	elem := arg.Type().Elem().Kind()
+	if len(params) > 0 && params[0] == '[' && elem != reflect.Slice && elem != reflect.Array {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return reflect.Value{}, err
+		}
+		if len(positional) != 1 {
+			return reflect.Value{}, fmt.Errorf("expected 1 positional param, got %d", len(positional))
+		}
+		params = positional[0]
+	}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, arg.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	if validator, ok := arg.Interface().(cocaine.Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	if argType.Kind() != reflect.Ptr {
+		arg = arg.Elem()
+	}
+	return arg, nil
+}
+
+func parseError(err error) *response {
+	return errorResponse(nil, CodeParseError, err.Error())
+}
+
+func errorResponse(id *json.RawMessage, code int, message string) *response {
+	return &response{
+		Version: version,
+		Error:   &Error{Code: code, Message: message},
+		ID:      id,
+	}
+}
+
+func marshalResponse(res interface{}) []byte {
+	buf, err := json.Marshal(res)
+	if err != nil {
+		buf, _ = json.Marshal(errorResponse(nil, CodeInternalError, err.Error()))
+	}
+	return buf
+}
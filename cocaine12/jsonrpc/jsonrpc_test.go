@@ -0,0 +1,125 @@
+package jsonrpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/cocaine/cocaine-framework-go/cocaine12/cocainetest"
+)
+
+type sumArgs struct {
+	A, B int
+}
+
+func (a sumArgs) Validate() error {
+	if a.A < 0 || a.B < 0 {
+		return errors.New("negative arguments")
+	}
+	return nil
+}
+
+type calc struct{}
+
+func (calc) Sum(ctx context.Context, args sumArgs) (int, error) {
+	return args.A + args.B, nil
+}
+
+func (calc) Fail(ctx context.Context, args *sumArgs) (int, error) {
+	return 0, &Error{Code: 42, Message: "failed", Data: "details"}
+}
+
+func (calc) Broken(ctx context.Context, args []int) (int, error) {
+	return 0, errors.New("broken")
+}
+
+// Skipped is not a method of the server
+func (calc) Skipped() {}
+
+func newTestServer(t *testing.T) *Server {
+	server := NewServer()
+	if err := server.RegisterMethods("calc", calc{}); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestRegister(t *testing.T) {
+	server := newTestServer(t)
+	assert.Equal(t, []string{"calc.Broken", "calc.Fail", "calc.Sum"}, server.Methods())
+
+	assert.Error(t, server.Register("bad", func(a int) int { return a }))
+	assert.Error(t, NewServer().RegisterMethods("empty", struct{}{}))
+}
+
+func TestServe(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		request, response string
+	}{
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 1, "B": 2}, "id": 1}`,
+			`{"jsonrpc":"2.0","result":3,"id":1}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Sum", "params": [{"A": 0, "B": 0}], "id": "zero"}`,
+			`{"jsonrpc":"2.0","result":0,"id":"zero"}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": -1}, "id": 2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"negative arguments"},"id":2}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Fail", "id": 3}`,
+			`{"jsonrpc":"2.0","error":{"code":42,"message":"failed","data":"details"},"id":3}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Broken", "params": [1, 2], "id": 4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32000,"message":"broken"},"id":4}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Missing", "id": 5}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method calc.Missing not found"},"id":5}`,
+		},
+		{
+			`{"method": "calc.Sum"}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`,
+		},
+		{
+			`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {}}`,
+			``,
+		},
+		{
+			`[
+				{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 1, "B": 1}, "id": 1},
+				{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 1, "B": 1}},
+				1
+			]`,
+			`[{"jsonrpc":"2.0","result":2,"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"json: cannot unmarshal number into Go value of type jsonrpc.request"},"id":null}]`,
+		},
+		{
+			`{"jsonrpc"`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"unexpected end of JSON input"},"id":null}`,
+		},
+	} {
+		assert.Equal(t, tc.response, string(server.serve(ctx, []byte(tc.request))), tc.request)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := newTestServer(t)
+
+	req := cocainetest.NewRequest()
+	req.Write([]byte(`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 1, "B": 2}, "id": 1}`))
+	req.Write([]byte(`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 1, "B": 2}}`))
+	req.Write([]byte(`{"jsonrpc": "2.0", "method": "calc.Sum", "params": {"A": 2, "B": 2}, "id": 2}`))
+	res := cocainetest.NewResponse()
+
+	server.Handler()(context.Background(), req, res)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":3,"id":1}{"jsonrpc":"2.0","result":4,"id":2}`, res.String())
+}
@@ -0,0 +1,92 @@
+package cocaine12
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLeakTimeout = 5 * time.Minute
+
+// sessionState is used to find sessions which are leaked:
+// the handler has returned, but the client has not closed its side
+type sessionState struct {
+	event        string
+	lastActivity time.Time
+	// request counts chunks received by the loop against the limit
+	request *sizedRequest
+	// set by the loop once the handler has returned
+	finished bool
+}
+
+// finish is called by the loop, so the session
+// is counted idle since the handler has returned
+func (s *sessionState) finish(now time.Time) {
+	s.finished = true
+	s.lastActivity = now
+}
+
+// SetLeakTimeout sets how long a session is kept after its handler
+// has returned if no message arrives for it. Such sessions are reported
+// and closed, otherwise they are kept until the client closes them.
+// It's 5 minutes by default, zero disables the collection.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetLeakTimeout(timeout time.Duration) {
+	w.leakTimeout = timeout
+}
+
+// LeakedSessions returns the number of sessions closed as leaked
+func (w *WorkerNG) LeakedSessions() int64 {
+	return atomic.LoadInt64(&w.leaked)
+}
+
+func (w *WorkerNG) touchSession(session uint64) {
+	if state, ok := w.sessionStates[session]; ok {
+		state.lastActivity = w.clock.Now()
+	}
+}
+
+// notifySessionFinished is called by the handler goroutine once it has returned
+func (w *WorkerNG) notifySessionFinished(session uint64) {
+	select {
+	case w.sessionFinished <- session:
+	case <-w.stopped:
+	}
+}
+
+func (w *WorkerNG) finishSession(session uint64) {
+	// nobody reads the rest of the request
+	if reqStream, ok := w.sessions[session]; ok {
+		reqStream.release()
+	}
+	if state, ok := w.sessionStates[session]; ok {
+		state.finish(w.clock.Now())
+	}
+}
+
+func (w *WorkerNG) removeSession(session uint64) {
+	if reqStream, ok := w.sessions[session]; ok {
+		reqStream.release()
+	}
+	delete(w.sessions, session)
+	delete(w.sessionStates, session)
+	w.load.setQueueDepth(len(w.sessions))
+}
+
+// collectLeakedSessions is called by the loop periodically
+func (w *WorkerNG) collectLeakedSessions() {
+	now := w.clock.Now()
+	for session, state := range w.sessionStates {
+		if !state.finished || now.Sub(state.lastActivity) < w.leakTimeout {
+			continue
+		}
+
+		fmt.Printf("session %d of event '%s' is leaked: no messages for %v after the handler returned\n",
+			session, state.event, now.Sub(state.lastActivity))
+		if reqStream, ok := w.sessions[session]; ok {
+			reqStream.Close()
+		}
+		w.removeSession(session)
+		atomic.AddInt64(&w.leaked, 1)
+	}
+}
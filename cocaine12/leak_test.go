@@ -0,0 +1,109 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestWorkerLeakedSessions(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetLeakTimeout(time.Minute)
+
+	go w.Run(map[string]EventHandler{
+		// replies without reading the request
+		"test": func(ctx context.Context, req Request, res Response) {
+			res.Write([]byte("OK"))
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "test")
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Write)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	// the session is active
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(30 * time.Second)
+	sock2.Write() <- newChunkV1(2, []byte("late"))
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(30 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(0), w.impl.LeakedSessions())
+
+	// no messages for the leak timeout
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for w.impl.LeakedSessions() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int64(1), w.impl.LeakedSessions())
+	assert.Equal(t, 0, w.Load().QueueDepth)
+}
+
+func TestWorkerLeakTimeoutCountsFromFinish(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetLeakTimeout(time.Minute)
+
+	release := make(chan struct{})
+	go w.Run(map[string]EventHandler{
+		"slow": func(ctx context.Context, req Request, res Response) {
+			<-release
+			res.Write([]byte("OK"))
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "slow")
+	time.Sleep(10 * time.Millisecond)
+	// the handler runs longer than the leak timeout,
+	// the next check is in a minute
+	clock.Advance(90 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(10 * time.Second)
+	close(release)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Write)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(50 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(0), w.impl.LeakedSessions(), "the session is idle only since the handler has returned")
+
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for w.impl.LeakedSessions() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int64(1), w.impl.LeakedSessions())
+}
@@ -0,0 +1,220 @@
+package cocaine12
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimiter decides whether the worker is allowed
+// to start one more handler. Acquire is called for every invoke
+// before the handler is launched, Release is called after the handler
+// returns with the measured latency of the handler.
+type ConcurrencyLimiter interface {
+	// Acquire reserves a slot. It returns false if the request
+	// must be rejected as the worker is saturated
+	Acquire() bool
+	// Release frees the slot reserved by Acquire
+	Release(latency time.Duration)
+	// Limit returns the current concurrency limit
+	Limit() int
+	// InFlight returns the number of the handlers being executed
+	InFlight() int
+}
+
+// AIMDLimiter implements additive-increase/multiplicative-decrease
+// concurrency control. The limit is increased by one when a handler
+// finishes faster than the latency threshold while the limiter is at least
+// half-loaded, and it is multiplied by the backoff ratio otherwise.
+type AIMDLimiter struct {
+	mu sync.Mutex
+
+	limit    float64
+	minLimit float64
+	maxLimit float64
+	inFlight int
+
+	threshold time.Duration
+	backoff   float64
+}
+
+// NewAIMDLimiter creates AIMDLimiter which starts from the initial limit
+// and keeps the limit within [minLimit, maxLimit]. Handlers running longer
+// than threshold are treated as a saturation signal.
+func NewAIMDLimiter(initial, minLimit, maxLimit int, threshold time.Duration) *AIMDLimiter {
+	if minLimit < 1 {
+		minLimit = 1
+	}
+	if maxLimit < minLimit {
+		maxLimit = minLimit
+	}
+
+	return &AIMDLimiter{
+		limit:     clampLimit(float64(initial), float64(minLimit), float64(maxLimit)),
+		minLimit:  float64(minLimit),
+		maxLimit:  float64(maxLimit),
+		threshold: threshold,
+		backoff:   0.9,
+	}
+}
+
+// SetBackoffRatio sets the ratio the limit is multiplied by
+// on a saturation signal. It must be in (0, 1)
+func (l *AIMDLimiter) SetBackoffRatio(ratio float64) {
+	if ratio <= 0 || ratio >= 1 {
+		return
+	}
+
+	l.mu.Lock()
+	l.backoff = ratio
+	l.mu.Unlock()
+}
+
+// Acquire reserves a slot if the limit is not reached
+func (l *AIMDLimiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+
+	l.inFlight++
+	return true
+}
+
+// Release frees a slot and adjusts the limit according to the latency
+func (l *AIMDLimiter) Release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if latency > l.threshold {
+		l.limit = clampLimit(l.limit*l.backoff, l.minLimit, l.maxLimit)
+	} else if float64(l.inFlight)*2 >= l.limit {
+		l.limit = clampLimit(l.limit+1, l.minLimit, l.maxLimit)
+	}
+
+	if l.inFlight > 0 {
+		l.inFlight--
+	}
+}
+
+// Limit returns the current limit
+func (l *AIMDLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of acquired slots
+func (l *AIMDLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// GradientLimiter adjusts the limit using the ratio between
+// the lowest observed latency and the current smoothed latency.
+// When the latency grows because of queueing inside the worker,
+// the gradient becomes less than 1 and the limit goes down.
+type GradientLimiter struct {
+	mu sync.Mutex
+
+	limit    float64
+	minLimit float64
+	maxLimit float64
+	inFlight int
+
+	// the lowest latency observed, it is a no-load estimation
+	minLatency time.Duration
+	// exponentially smoothed latency
+	smoothed float64
+	// how often minLatency is forgotten to catch up with
+	// the changes of the handler's cost
+	resetInterval time.Duration
+	lastReset     time.Time
+}
+
+// NewGradientLimiter creates GradientLimiter which keeps
+// the limit within [minLimit, maxLimit]
+func NewGradientLimiter(minLimit, maxLimit int) *GradientLimiter {
+	if minLimit < 1 {
+		minLimit = 1
+	}
+	if maxLimit < minLimit {
+		maxLimit = minLimit
+	}
+
+	return &GradientLimiter{
+		limit:         float64(minLimit),
+		minLimit:      float64(minLimit),
+		maxLimit:      float64(maxLimit),
+		resetInterval: time.Minute,
+		lastReset:     time.Now(),
+	}
+}
+
+// Acquire reserves a slot if the limit is not reached
+func (l *GradientLimiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+
+	l.inFlight++
+	return true
+}
+
+// Release frees a slot and recalculates the limit
+func (l *GradientLimiter) Release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight > 0 {
+		l.inFlight--
+	}
+
+	if latency <= 0 {
+		return
+	}
+
+	if now := time.Now(); now.Sub(l.lastReset) > l.resetInterval {
+		l.minLatency = 0
+		l.lastReset = now
+	}
+
+	if l.minLatency == 0 || latency < l.minLatency {
+		l.minLatency = latency
+	}
+
+	if l.smoothed == 0 {
+		l.smoothed = float64(latency)
+	} else {
+		l.smoothed = 0.8*l.smoothed + 0.2*float64(latency)
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, float64(l.minLatency)/l.smoothed))
+	// allow some queueing to probe for a higher limit
+	queueSize := math.Sqrt(l.limit)
+	l.limit = clampLimit(l.limit*gradient+queueSize, l.minLimit, l.maxLimit)
+}
+
+// Limit returns the current limit
+func (l *GradientLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of acquired slots
+func (l *GradientLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+func clampLimit(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
@@ -0,0 +1,52 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	l := NewAIMDLimiter(2, 1, 4, time.Millisecond*100)
+
+	assert.True(t, l.Acquire())
+	assert.True(t, l.Acquire())
+	assert.False(t, l.Acquire(), "limit must be reached")
+	assert.Equal(t, 2, l.InFlight())
+
+	// fast handlers increase the limit
+	l.Release(time.Millisecond)
+	assert.Equal(t, 3, l.Limit())
+	l.Release(time.Millisecond)
+	assert.Equal(t, 0, l.InFlight())
+
+	// slow handler decreases the limit
+	assert.True(t, l.Acquire())
+	l.Release(time.Second)
+	assert.Equal(t, 2, l.Limit())
+
+	for i := 0; i < 100; i++ {
+		l.Acquire()
+		l.Release(time.Second)
+	}
+	assert.Equal(t, 1, l.Limit(), "limit must not be less than min")
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := NewGradientLimiter(1, 10)
+	assert.Equal(t, 1, l.Limit())
+
+	for i := 0; i < 50; i++ {
+		assert.True(t, l.Acquire())
+		l.Release(time.Millisecond)
+	}
+	assert.Equal(t, 10, l.Limit(), "stable latency must grow the limit")
+
+	for i := 0; i < 50; i++ {
+		l.Acquire()
+		l.Release(time.Millisecond * 100)
+	}
+	assert.True(t, l.Limit() < 10, "growing latency must shrink the limit")
+	assert.Equal(t, 0, l.InFlight())
+}
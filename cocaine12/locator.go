@@ -1,6 +1,7 @@
 package cocaine12
 
 import (
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -15,6 +16,10 @@ type Locator interface {
 
 type locator struct {
 	*Service
+	// an endpoint the locator is connected to
+	endpoint string
+
+	closeOnce sync.Once
 }
 
 // NewLocator creates a new Locator using given endpoints
@@ -23,24 +28,27 @@ func NewLocator(endpoints []string) (Locator, error) {
 		endpoints = append(endpoints, GetDefaults().Locators()...)
 	}
 
-	var (
-		sock socketIO
-		err  error
-	)
+	return newLocator(endpoints)
+}
 
-	// ToDo: Duplicated code with Service connection
-CONN_LOOP:
-	for _, endpoint := range endpoints {
-		sock, err = newAsyncConnection("tcp", endpoint, time.Second*1)
-		if err != nil {
-			continue
+func newLocator(endpoints []string) (*locator, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrZeroEndpoints
+	}
+
+	addresses, origins := locatorHosts.expand(endpoints)
+	sock, index, errs := dialHappyEyeballs("tcp", addresses, time.Second*1, happyEyeballsDelay)
+	for i, err := range errs {
+		// a locator is reachable if any of its addresses is
+		if err != nil && (sock == nil || origins[i] != origins[index]) {
+			locatorsHealth.markFailed(endpoints[origins[i]])
 		}
-		break CONN_LOOP
 	}
 
-	if err != nil {
-		return nil, err
+	if sock == nil {
+		return nil, errs[len(errs)-1]
 	}
+	locatorHosts.acquire()
 
 	service := Service{
 		ServiceInfo: newLocatorServiceInfo(),
@@ -53,7 +61,8 @@ CONN_LOOP:
 	go service.loop()
 
 	return &locator{
-		Service: &service,
+		Service:  &service,
+		endpoint: endpoints[origins[index]],
 	}, nil
 }
 
@@ -77,5 +86,76 @@ func (l *locator) Resolve(ctx context.Context, name string) (*ServiceInfo, error
 }
 
 func (l *locator) Close() {
-	l.socketIO.Close()
+	l.closeOnce.Do(func() {
+		l.socketIO.Close()
+		locatorHosts.release()
+	})
+}
+
+const (
+	// a failed locator is moved to the end of the list for this period
+	locatorFailureCooldown = time.Second * 30
+)
+
+var locatorsHealth = newEndpointsHealth(locatorFailureCooldown)
+
+// endpointsHealth tracks failures of locators
+// to try healthy ones first
+type endpointsHealth struct {
+	sync.Mutex
+	cooldown time.Duration
+	failures map[string]time.Time
+}
+
+func newEndpointsHealth(cooldown time.Duration) *endpointsHealth {
+	return &endpointsHealth{
+		cooldown: cooldown,
+		failures: make(map[string]time.Time),
+	}
+}
+
+func (h *endpointsHealth) markFailed(endpoint string) {
+	h.Lock()
+	h.failures[endpoint] = time.Now()
+	h.Unlock()
+}
+
+func (h *endpointsHealth) markHealthy(endpoint string) {
+	h.Lock()
+	delete(h.failures, endpoint)
+	h.Unlock()
+}
+
+// order returns healthy endpoints first keeping their order,
+// then endpoints failed recently
+func (h *endpointsHealth) order(endpoints []string) []string {
+	h.Lock()
+	defer h.Unlock()
+
+	var (
+		healthy   = make([]string, 0, len(endpoints))
+		unhealthy []string
+		now       = time.Now()
+	)
+
+	for _, endpoint := range endpoints {
+		failedAt, failed := h.failures[endpoint]
+		if failed && now.Sub(failedAt) < h.cooldown {
+			unhealthy = append(unhealthy, endpoint)
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func removeEndpoint(endpoints []string, endpoint string) []string {
+	var result = make([]string, 0, len(endpoints))
+	for _, item := range endpoints {
+		if item != endpoint {
+			result = append(result, item)
+		}
+	}
+	return result
 }
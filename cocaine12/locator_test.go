@@ -0,0 +1,27 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointsHealth(t *testing.T) {
+	h := newEndpointsHealth(time.Hour)
+	endpoints := []string{"a", "b", "c"}
+
+	assert.Equal(t, endpoints, h.order(endpoints))
+
+	h.markFailed("a")
+	assert.Equal(t, []string{"b", "c", "a"}, h.order(endpoints))
+
+	h.markHealthy("a")
+	assert.Equal(t, endpoints, h.order(endpoints))
+
+	h = newEndpointsHealth(0)
+	h.markFailed("a")
+	assert.Equal(t, endpoints, h.order(endpoints), "cooldown is expired")
+
+	assert.Equal(t, []string{"a", "c"}, removeEndpoint(endpoints, "b"))
+}
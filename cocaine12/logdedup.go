@@ -0,0 +1,180 @@
+package cocaine12
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type dedupKey struct {
+	level    Severity
+	template string
+}
+
+type dedupBurst struct {
+	repeated int
+	timer    *time.Timer
+}
+
+// DedupLogger collapses bursts of identical messages to protect
+// the logging service during error storms. The first message of a burst
+// is written at once, the same messages within the window after it are
+// counted and reported as a single "message repeated N times" entry.
+// Messages are identical if they have the same level and format string.
+type DedupLogger struct {
+	Logger
+	window time.Duration
+
+	mu     sync.Mutex
+	bursts map[dedupKey]*dedupBurst
+}
+
+// just for the type check
+var _ Logger = &DedupLogger{}
+
+// NewDedupLogger wraps the logger to collapse identical messages
+// written within the window
+func NewDedupLogger(logger Logger, window time.Duration) *DedupLogger {
+	return &DedupLogger{
+		Logger: logger,
+		window: window,
+		bursts: make(map[dedupKey]*dedupBurst),
+	}
+}
+
+func (d *DedupLogger) log(level Severity, fields Fields, msg string, args ...interface{}) {
+	if level == FatalLevel {
+		// fatal entries are never collapsed
+		d.Logger.log(level, fields, msg, args...)
+		return
+	}
+
+	key := dedupKey{level, msg}
+
+	d.mu.Lock()
+	if burst, ok := d.bursts[key]; ok {
+		burst.repeated++
+		d.mu.Unlock()
+		return
+	}
+	d.bursts[key] = &dedupBurst{
+		timer: time.AfterFunc(d.window, func() { d.flush(key) }),
+	}
+	d.mu.Unlock()
+
+	d.Logger.log(level, fields, msg, args...)
+}
+
+// flush ends the burst and reports suppressed messages
+func (d *DedupLogger) flush(key dedupKey) {
+	d.mu.Lock()
+	burst, ok := d.bursts[key]
+	delete(d.bursts, key)
+	d.mu.Unlock()
+
+	if ok && burst.repeated > 0 {
+		d.Logger.log(key.level, Fields{"repeated": burst.repeated},
+			"message repeated %d times: %s", burst.repeated, key.template)
+	}
+}
+
+// WithFields returns Entry writing through the DedupLogger
+func (d *DedupLogger) WithFields(fields Fields) *Entry {
+	return &Entry{
+		Logger: d,
+		Fields: fields,
+	}
+}
+
+// Writer returns io.Writer logging through the DedupLogger
+func (d *DedupLogger) Writer(level Severity) io.Writer {
+	return newLogWriter(d, defaultFields, level)
+}
+
+// Close reports suppressed messages and closes the underlying logger
+func (d *DedupLogger) Close() {
+	d.mu.Lock()
+	keys := make([]dedupKey, 0, len(d.bursts))
+	for key, burst := range d.bursts {
+		burst.timer.Stop()
+		keys = append(keys, key)
+	}
+	d.mu.Unlock()
+
+	for _, key := range keys {
+		d.flush(key)
+	}
+	d.Logger.Close()
+}
+
+func (d *DedupLogger) Fatalf(format string, args ...interface{}) {
+	d.log(FatalLevel, defaultFields, format, args...)
+	terminateOnFatal(fmt.Sprintf(format, args...))
+}
+
+func (d *DedupLogger) Fatal(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	d.log(FatalLevel, defaultFields, msg)
+	terminateOnFatal(msg)
+}
+
+func (d *DedupLogger) Errf(format string, args ...interface{}) {
+	if d.V(ErrorLevel) {
+		d.log(ErrorLevel, defaultFields, format, args...)
+	}
+}
+
+func (d *DedupLogger) Err(args ...interface{}) {
+	if d.V(ErrorLevel) {
+		d.log(ErrorLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
+
+func (d *DedupLogger) Warnf(format string, args ...interface{}) {
+	if d.V(WarnLevel) {
+		d.log(WarnLevel, defaultFields, format, args...)
+	}
+}
+
+func (d *DedupLogger) Warn(args ...interface{}) {
+	if d.V(WarnLevel) {
+		d.log(WarnLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
+
+func (d *DedupLogger) Infof(format string, args ...interface{}) {
+	if d.V(InfoLevel) {
+		d.log(InfoLevel, defaultFields, format, args...)
+	}
+}
+
+func (d *DedupLogger) Info(args ...interface{}) {
+	if d.V(InfoLevel) {
+		d.log(InfoLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
+
+func (d *DedupLogger) Debugf(format string, args ...interface{}) {
+	if d.V(DebugLevel) {
+		d.log(DebugLevel, defaultFields, format, args...)
+	}
+}
+
+func (d *DedupLogger) Debug(args ...interface{}) {
+	if d.V(DebugLevel) {
+		d.log(DebugLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
+
+func (d *DedupLogger) Tracef(format string, args ...interface{}) {
+	if d.V(TraceLevel) {
+		d.log(TraceLevel, defaultFields, format, args...)
+	}
+}
+
+func (d *DedupLogger) Trace(args ...interface{}) {
+	if d.V(TraceLevel) {
+		d.log(TraceLevel, defaultFields, fmt.Sprint(args...))
+	}
+}
@@ -0,0 +1,86 @@
+package cocaine12
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedEntry struct {
+	level  Severity
+	fields Fields
+	msg    string
+}
+
+// recordingLogger keeps written entries
+type recordingLogger struct {
+	fallbackLogger
+
+	mu      sync.Mutex
+	entries []recordedEntry
+}
+
+func (r *recordingLogger) log(level Severity, fields Fields, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, recordedEntry{level, fields, msg})
+	r.mu.Unlock()
+}
+
+func (r *recordingLogger) WithFields(fields Fields) *Entry {
+	return &Entry{Logger: r, Fields: fields}
+}
+
+func (r *recordingLogger) Writer(level Severity) io.Writer {
+	return newLogWriter(r, defaultFields, level)
+}
+
+func (r *recordingLogger) recorded() []recordedEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recordedEntry(nil), r.entries...)
+}
+
+func TestDedupLogger(t *testing.T) {
+	recorder := new(recordingLogger)
+	logger := NewDedupLogger(recorder, 50*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		logger.Errf("unable to connect to %s", "storage")
+	}
+	logger.WithFields(Fields{"attempt": 1}).Warnf("unable to connect to %s", "storage")
+	logger.Err("connection refused")
+
+	assert.Equal(t, []recordedEntry{
+		{ErrorLevel, defaultFields, "unable to connect to storage"},
+		{WarnLevel, Fields{"attempt": 1}, "unable to connect to storage"},
+		{ErrorLevel, defaultFields, "connection refused"},
+	}, recorder.recorded())
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.recorded()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := recorder.recorded()
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, recordedEntry{
+			ErrorLevel, Fields{"repeated": 4},
+			"message repeated 4 times: unable to connect to %s",
+		}, entries[3])
+	}
+
+	// the window is over
+	logger.Errf("unable to connect to %s", "storage")
+	logger.Errf("unable to connect to %s", "storage")
+	logger.Close()
+	entries = recorder.recorded()
+	assert.Len(t, entries, 6)
+	assert.Equal(t, "message repeated 1 times: unable to connect to %s", entries[5].msg)
+}
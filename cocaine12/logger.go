@@ -1,6 +1,8 @@
 package cocaine12
 
 import (
+	"io"
+
 	"golang.org/x/net/context"
 )
 
@@ -9,6 +11,11 @@ const defaultLoggerName = "logging"
 type Fields map[string]interface{}
 
 type EntryLogger interface {
+	// Fatalf and Fatal flush the entry and make a running worker
+	// shut down gracefully. The process exits if there is no worker.
+	Fatalf(format string, args ...interface{})
+	Fatal(args ...interface{})
+
 	Errf(format string, args ...interface{})
 	Err(args ...interface{})
 
@@ -20,6 +27,9 @@ type EntryLogger interface {
 
 	Debugf(format string, args ...interface{})
 	Debug(args ...interface{})
+
+	Tracef(format string, args ...interface{})
+	Trace(args ...interface{})
 }
 
 // Logger represents an interface for a cocaine.Logger
@@ -28,6 +38,9 @@ type Logger interface {
 
 	log(level Severity, fields Fields, msg string, args ...interface{})
 	WithFields(Fields) *Entry
+	// Writer returns io.Writer logging every line written to it
+	// with the level. See NewStdLogger
+	Writer(level Severity) io.Writer
 
 	Verbosity(context.Context) Severity
 	V(level Severity) bool
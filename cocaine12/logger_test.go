@@ -1,8 +1,11 @@
 package cocaine12
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 )
 
@@ -19,6 +22,38 @@ func TestLogger(t *testing.T) {
 	log.WithFields(Fields{"a": 1, "b": 2}).Debugf("Debug %v", log.Verbosity(ctx))
 }
 
+func TestEntryWithFields(t *testing.T) {
+	log, _ := newFallbackLogger()
+	entry := log.WithFields(Fields{"a": 1, "b": 2})
+	derived := entry.WithFields(Fields{"b": 3, "c": 4})
+
+	assert.Equal(t, Fields{"a": 1, "b": 2}, entry.Fields)
+	assert.Equal(t, Fields{"a": 1, "b": 3, "c": 4}, derived.Fields)
+	assert.Equal(t, log, derived.Logger)
+}
+
+func TestAttrValue(t *testing.T) {
+	now := time.Unix(1500000000, 123456789)
+	for _, item := range []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{"text", "text"},
+		{42, int64(42)},
+		{int32(-1), int64(-1)},
+		{uint16(7), uint64(7)},
+		{float32(0.5), float64(0.5)},
+		{true, true},
+		{nil, nil},
+		{now, int64(1500000000123456)},
+		{1500 * time.Millisecond, int64(1500000)},
+		{errors.New("failed"), "failed"},
+		{[]int{1, 2}, "[1 2]"},
+	} {
+		assert.Equal(t, item.expected, attrValue(item.value), "%v", item.value)
+	}
+}
+
 func BenchmarkFormatFields5(b *testing.B) {
 	fields := Fields{
 		"A":    1,
@@ -0,0 +1,43 @@
+package cocaine12
+
+import (
+	"bytes"
+	"log"
+)
+
+// logWriter logs every line written to it. Lines split between
+// writes are logged separately, so it suits writers which output
+// whole messages, like log.Logger does.
+type logWriter struct {
+	logger Logger
+	fields Fields
+	level  Severity
+}
+
+func newLogWriter(logger Logger, fields Fields, level Severity) *logWriter {
+	return &logWriter{
+		logger: logger,
+		fields: fields,
+		level:  level,
+	}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if !w.logger.V(w.level) {
+		return len(p), nil
+	}
+
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if line = bytes.TrimRight(line, "\r"); len(line) > 0 {
+			w.logger.log(w.level, w.fields, "%s", line)
+		}
+	}
+	return len(p), nil
+}
+
+// NewStdLogger creates log.Logger writing through the logger
+// with the level, so libraries using the standard log package
+// can have their output routed to cocaine logging
+func NewStdLogger(logger Logger, level Severity) *log.Logger {
+	return log.New(logger.Writer(level), "", 0)
+}
@@ -0,0 +1,25 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogWriter(t *testing.T) {
+	recorder := new(recordingLogger)
+
+	w := recorder.Writer(WarnLevel)
+	n, err := w.Write([]byte("first line\nsecond line\n\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 24, n)
+
+	std := NewStdLogger(recorder.WithFields(Fields{"library": "raft"}), InfoLevel)
+	std.Printf("elected %d%%", 100)
+
+	assert.Equal(t, []recordedEntry{
+		{WarnLevel, defaultFields, "first line"},
+		{WarnLevel, defaultFields, "second line"},
+		{InfoLevel, Fields{"library": "raft"}, "elected 100%"},
+	}, recorder.recorded())
+}
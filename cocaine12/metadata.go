@@ -0,0 +1,173 @@
+package cocaine12
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	IncomingMetadataValue = "metadata.incoming"
+	OutgoingMetadataValue = "metadata.outgoing"
+)
+
+// Metadata is a set of key/value pairs sent as headers.
+// Keys are lowercase, every value is sent as a separate header.
+type Metadata map[string][]string
+
+// NewMetadata creates Metadata from the map
+func NewMetadata(m map[string]string) Metadata {
+	md := make(Metadata, len(m))
+	for k, v := range m {
+		md.Append(k, v)
+	}
+	return md
+}
+
+// Pairs creates Metadata from key/value pairs.
+// It panics if the number of arguments is odd.
+func Pairs(kv ...string) Metadata {
+	if len(kv)%2 == 1 {
+		panic(fmt.Sprintf("cocaine: Pairs got the odd number of arguments: %d", len(kv)))
+	}
+
+	md := make(Metadata, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		md.Append(kv[i], kv[i+1])
+	}
+	return md
+}
+
+// Len returns the number of keys
+func (md Metadata) Len() int {
+	return len(md)
+}
+
+// Get returns values of the key
+func (md Metadata) Get(key string) []string {
+	return md[strings.ToLower(key)]
+}
+
+// Set replaces values of the key
+func (md Metadata) Set(key string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	md[strings.ToLower(key)] = values
+}
+
+// Append adds values to the key
+func (md Metadata) Append(key string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	key = strings.ToLower(key)
+	md[key] = append(md[key], values...)
+}
+
+// Copy returns a deep copy of md
+func (md Metadata) Copy() Metadata {
+	return JoinMetadata(md)
+}
+
+// JoinMetadata merges all values of mds into a new Metadata
+func JoinMetadata(mds ...Metadata) Metadata {
+	result := Metadata{}
+	for _, md := range mds {
+		for k, v := range md {
+			result[k] = append(result[k], v...)
+		}
+	}
+	return result
+}
+
+// MetadataWriter is implemented by responses of the worker, which can send
+// metadata along with a chunk or the close of the response, like headers
+// and trailers of gRPC. Clients receive it by Stream.Header and
+// Stream.Trailer. Type-assert a Response to use it:
+//
+//	if w, ok := response.(MetadataWriter); ok {
+//		w.WriteWithMetadata(data, md)
+//	}
+type MetadataWriter interface {
+	// WriteWithMetadata sends a chunk of data with md as its headers.
+	// It copies data as Write does.
+	WriteWithMetadata(data []byte, md Metadata) error
+	// CloseWithMetadata closes the response with md as headers
+	// of the close message.
+	CloseWithMetadata(md Metadata) error
+}
+
+func (md Metadata) headers() []Header {
+	var headers = make([]Header, 0, len(md))
+	for k, values := range md {
+		if isTraceHeader(k) {
+			// trace headers are filled from TraceInfo
+			continue
+		}
+
+		for _, v := range values {
+			headers = append(headers, Header{Name: k, Value: []byte(v)})
+		}
+	}
+	return headers
+}
+
+// metadataFromHeaders skips trace headers and tuples
+// which can't be decoded
+func metadataFromHeaders(headers CocaineHeaders) Metadata {
+	var md Metadata
+	for _, raw := range headers {
+		decoded, err := DefaultHeaderTable.Decode(CocaineHeaders{raw})
+		if err != nil || isTraceHeader(decoded[0].Name) {
+			continue
+		}
+
+		if md == nil {
+			md = Metadata{}
+		}
+		md.Append(decoded[0].Name, string(decoded[0].Value))
+	}
+	return md
+}
+
+func isTraceHeader(name string) bool {
+	switch name {
+	case TraceIDHeader, SpanIDHeader, ParentIDHeader:
+		return true
+	}
+	return false
+}
+
+// NewOutgoingContext attaches md to ctx. It's sent as headers
+// of calls made by Service with the context.
+func NewOutgoingContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, OutgoingMetadataValue, md)
+}
+
+// AppendToOutgoingContext adds key/value pairs
+// to the outgoing metadata of ctx
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	md, _ := FromOutgoingContext(ctx)
+	return NewOutgoingContext(ctx, JoinMetadata(md, Pairs(kv...)))
+}
+
+// FromOutgoingContext returns the outgoing metadata of ctx
+func FromOutgoingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(OutgoingMetadataValue).(Metadata)
+	return md, ok
+}
+
+// NewIncomingContext attaches md as the metadata of an incoming request.
+// Workers do it for every request, so it's mostly useful in tests.
+func NewIncomingContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, IncomingMetadataValue, md)
+}
+
+// FromIncomingContext returns the metadata of the request handled with ctx.
+// Headers of the request except trace ones are available there.
+func FromIncomingContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(IncomingMetadataValue).(Metadata)
+	return md, ok
+}
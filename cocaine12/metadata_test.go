@@ -0,0 +1,120 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMetadata(t *testing.T) {
+	md := Pairs("X-Request-Id", "abc", "x-tag", "a", "X-Tag", "b")
+	assert.Equal(t, []string{"abc"}, md.Get("x-request-id"))
+	assert.Equal(t, []string{"a", "b"}, md.Get("X-TAG"))
+
+	cp := md.Copy()
+	cp.Set("x-tag", "c")
+	assert.Equal(t, []string{"a", "b"}, md.Get("x-tag"))
+
+	assert.Panics(t, func() { Pairs("odd") })
+
+	joined := JoinMetadata(md, NewMetadata(map[string]string{"x-tag": "c"}))
+	assert.Equal(t, []string{"a", "b", "c"}, joined.Get("x-tag"))
+}
+
+func TestMetadataContext(t *testing.T) {
+	ctx := NewOutgoingContext(context.Background(), Pairs("x-request-id", "abc"))
+	ctx = AppendToOutgoingContext(ctx, "x-tag", "a", "trace_id", "ignored")
+
+	md, ok := FromOutgoingContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a"}, md.Get("x-tag"))
+
+	_, ok = FromIncomingContext(ctx)
+	assert.False(t, ok)
+
+	headers := DefaultHeaderTable.Encode(md.headers())
+	traceHeaders, err := traceInfoToHeaders(&TraceInfo{trace: 1, span: 2, parent: 3})
+	assert.NoError(t, err)
+	headers = append(headers, traceHeaders...)
+	// broken tuples are skipped
+	headers = append(headers, []interface{}{false, 1000})
+
+	incoming := metadataFromHeaders(headers)
+	assert.Equal(t, Metadata{"x-request-id": {"abc"}, "x-tag": {"a"}}, incoming)
+	assert.Nil(t, metadataFromHeaders(traceHeaders))
+}
+
+func TestWorkerV1IncomingMetadata(t *testing.T) {
+	const (
+		testID      = "uuid"
+		testSession = 2
+	)
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, testID, 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	received := make(chan Metadata, 1)
+	go w.Run(map[string]EventHandler{
+		"echo": func(ctx context.Context, req Request, res Response) {
+			md, _ := FromIncomingContext(ctx)
+			received <- md
+			res.Close()
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	invoke := newInvokeV1(testSession, "echo")
+	invoke.Headers = DefaultHeaderTable.Encode(Pairs("x-request-id", "abc").headers())
+	sock2.Write() <- invoke
+
+	select {
+	case md := <-received:
+		assert.Equal(t, []string{"abc"}, md.Get("x-request-id"))
+	case <-time.After(time.Second):
+		t.Fatal("handler has not been called")
+	}
+}
+
+func TestWorkerV1ResponseMetadata(t *testing.T) {
+	const testSession = 2
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	go w.Run(map[string]EventHandler{
+		"echo": func(ctx context.Context, req Request, res Response) {
+			writer := res.(MetadataWriter)
+			writer.WriteWithMetadata([]byte("OK"), Pairs("x-header", "h"))
+			writer.CloseWithMetadata(Pairs("x-trailer", "t"))
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(testSession, "echo")
+
+	chunk := <-sock2.Read()
+	checkTypeAndSession(t, chunk, testSession, v1Write)
+	assert.Equal(t, Pairs("x-header", "h"), metadataFromHeaders(chunk.Headers))
+
+	choke := <-sock2.Read()
+	checkTypeAndSession(t, choke, testSession, v1Close)
+	assert.Equal(t, Pairs("x-trailer", "t"), metadataFromHeaders(choke.Headers))
+}
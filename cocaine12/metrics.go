@@ -0,0 +1,296 @@
+package cocaine12
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultFlushInterval = time.Second * 10
+	metricsDialTimeout   = time.Second * 5
+	// fits into a single datagram of a typical MTU
+	statsdMaxPacketSize = 1432
+)
+
+// MetricKind defines how a metric is aggregated
+type MetricKind int
+
+const (
+	// MetricGauge is a value at the moment of a flush
+	MetricGauge MetricKind = iota
+	// MetricCounter is a monotonically increasing total
+	MetricCounter
+)
+
+// Metric is a named value pushed to a MetricsSink
+type Metric struct {
+	Name  string
+	Value float64
+	Kind  MetricKind
+}
+
+// MetricsSource provides metrics of a worker. Both Worker and WorkerNG
+// implement it
+type MetricsSource interface {
+	Load() WorkerLoad
+	Stats() map[string]EventStats
+}
+
+// MetricsSink sends metrics to an aggregation service
+type MetricsSink interface {
+	Push(metrics []Metric, now time.Time) error
+	Close() error
+}
+
+// MetricsPusher periodically pushes metrics of the source to the sink
+type MetricsPusher struct {
+	source   MetricsSource
+	sink     MetricsSink
+	interval time.Duration
+	clock    Clock
+}
+
+// NewMetricsPusher creates MetricsPusher flushing metrics every interval,
+// 10 seconds by default
+func NewMetricsPusher(source MetricsSource, sink MetricsSink, interval time.Duration) *MetricsPusher {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	return &MetricsPusher{
+		source:   source,
+		sink:     sink,
+		interval: interval,
+		clock:    realClock{},
+	}
+}
+
+// Run pushes metrics until ctx is done, then it flushes them
+// for the last time and closes the sink
+func (p *MetricsPusher) Run(ctx context.Context) error {
+	defer p.sink.Close()
+
+	for {
+		select {
+		case <-p.clock.After(p.interval):
+			if err := p.Flush(); err != nil {
+				fmt.Printf("unable to push metrics: %v\n", err)
+			}
+		case <-ctx.Done():
+			p.Flush()
+			return ctx.Err()
+		}
+	}
+}
+
+// Flush pushes metrics now
+func (p *MetricsPusher) Flush() error {
+	return p.sink.Push(collectMetrics(p.source), p.clock.Now())
+}
+
+func collectMetrics(source MetricsSource) []Metric {
+	load := source.Load()
+	metrics := []Metric{
+		{"load.in_flight", float64(load.InFlight), MetricGauge},
+		{"load.queue_depth", float64(load.QueueDepth), MetricGauge},
+		{"load.utilization", load.Utilization(), MetricGauge},
+	}
+
+	stats := source.Stats()
+	events := make([]string, 0, len(stats))
+	for event := range stats {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	for _, event := range events {
+		s := stats[event]
+		prefix := "events." + metricName(event) + "."
+		metrics = append(metrics,
+			Metric{prefix + "calls", float64(s.Calls), MetricCounter},
+			Metric{prefix + "errors", float64(s.Errors), MetricCounter},
+			Metric{prefix + "request_bytes", float64(s.RequestBytes), MetricCounter},
+			Metric{prefix + "response_bytes", float64(s.ResponseBytes), MetricCounter},
+			Metric{prefix + "too_large", float64(s.TooLarge), MetricCounter},
+			Metric{prefix + "p50_ms", durationToMillis(s.P50), MetricGauge},
+			Metric{prefix + "p95_ms", durationToMillis(s.P95), MetricGauge},
+			Metric{prefix + "p99_ms", durationToMillis(s.P99), MetricGauge},
+		)
+	}
+	return metrics
+}
+
+func durationToMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// metricName replaces characters having a special meaning
+// for statsd and Graphite
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', '/', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// StatsdSink sends metrics to statsd over UDP. Counters are sent
+// as increments since the previous flush, gauges as they are.
+type StatsdSink struct {
+	prefix string
+	conn   net.Conn
+
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+// NewStatsdSink creates StatsdSink prefixing names of metrics
+// with the prefix, e.g. "cocaine.echo"
+func NewStatsdSink(addr, prefix string) (*StatsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsdSink{
+		prefix: prefix,
+		conn:   conn,
+		last:   make(map[string]float64),
+	}, nil
+}
+
+func (s *StatsdSink) Push(metrics []Metric, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		packet bytes.Buffer
+		line   bytes.Buffer
+	)
+
+	for _, metric := range metrics {
+		name := joinMetricName(s.prefix, metric.Name)
+
+		line.Reset()
+		line.WriteString(name)
+		line.WriteByte(':')
+		switch metric.Kind {
+		case MetricCounter:
+			delta := metric.Value - s.last[name]
+			s.last[name] = metric.Value
+			if delta <= 0 {
+				continue
+			}
+			line.WriteString(formatMetricValue(delta))
+			line.WriteString("|c")
+		default:
+			line.WriteString(formatMetricValue(metric.Value))
+			line.WriteString("|g")
+		}
+
+		if packet.Len() > 0 && packet.Len()+1+line.Len() > statsdMaxPacketSize {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line.Bytes())
+	}
+
+	if packet.Len() > 0 {
+		_, err := s.conn.Write(packet.Bytes())
+		return err
+	}
+	return nil
+}
+
+func (s *StatsdSink) Close() error {
+	return s.conn.Close()
+}
+
+// GraphiteSink sends metrics to Graphite using the plaintext protocol
+// over TCP. Counters are sent as totals. The connection is reestablished
+// on the next flush if it fails.
+type GraphiteSink struct {
+	addr   string
+	prefix string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewGraphiteSink creates GraphiteSink prefixing names of metrics
+// with the prefix, e.g. "cocaine.echo"
+func NewGraphiteSink(addr, prefix string) *GraphiteSink {
+	return &GraphiteSink{
+		addr:   addr,
+		prefix: prefix,
+	}
+}
+
+func (g *GraphiteSink) Push(metrics []Metric, now time.Time) error {
+	var buf bytes.Buffer
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	for _, metric := range metrics {
+		buf.WriteString(joinMetricName(g.prefix, metric.Name))
+		buf.WriteByte(' ')
+		buf.WriteString(formatMetricValue(metric.Value))
+		buf.WriteByte(' ')
+		buf.WriteString(timestamp)
+		buf.WriteByte('\n')
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.addr, metricsDialTimeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	if _, err := g.conn.Write(buf.Bytes()); err != nil {
+		g.conn.Close()
+		g.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (g *GraphiteSink) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+func joinMetricName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
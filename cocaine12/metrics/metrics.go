@@ -0,0 +1,147 @@
+// Package metrics provides a client for the metrics service of Cocaine,
+// so applications can put their own counters and gauges into the metrics
+// tree of the runtime
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const (
+	serviceName = "metrics"
+
+	pushTimeout = time.Second * 5
+)
+
+// Client wraps the metrics service
+type Client struct {
+	service *cocaine.Service
+}
+
+// NewClient connects to the metrics service using given locators
+func NewClient(ctx context.Context, endpoints ...string) (*Client, error) {
+	service, err := cocaine.NewService(ctx, serviceName, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{service: service}, nil
+}
+
+// Close disposes the connection
+func (c *Client) Close() {
+	c.service.Close()
+}
+
+// Counter adds delta to the counter
+func (c *Client) Counter(ctx context.Context, name string, delta int64) error {
+	_, err := c.call(ctx, "counter", name, delta)
+	return err
+}
+
+// Gauge sets the value of the gauge
+func (c *Client) Gauge(ctx context.Context, name string, value float64) error {
+	_, err := c.call(ctx, "gauge", name, value)
+	return err
+}
+
+// Fetch returns values of metrics matching the query,
+// e.g. "application.echo.*", keyed by their names
+func (c *Client) Fetch(ctx context.Context, query string) (map[string]interface{}, error) {
+	res, err := c.call(ctx, "fetch", "plain", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := res.Extract(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, args ...interface{}) (cocaine.ServiceResult, error) {
+	channel, err := c.service.Call(ctx, method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return channel.Get(ctx)
+}
+
+// Sink pushes metrics of a worker collected by cocaine.MetricsPusher
+// to the metrics service. Counters are sent as increments since
+// the previous push, gauges as they are.
+type Sink struct {
+	client *Client
+	prefix string
+
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+// NewSink creates Sink prefixing names of metrics with the prefix,
+// e.g. "application.echo". The sink takes the ownership of the client.
+func NewSink(client *Client, prefix string) *Sink {
+	return &Sink{
+		client: client,
+		prefix: prefix,
+		last:   make(map[string]float64),
+	}
+}
+
+func (s *Sink) Push(metrics []cocaine.Metric, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	for _, update := range s.updates(metrics) {
+		var err error
+		if update.Kind == cocaine.MetricCounter {
+			err = s.client.Counter(ctx, update.Name, int64(update.Value))
+		} else {
+			err = s.client.Gauge(ctx, update.Name, update.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updates turns counters into increments skipping unchanged ones
+func (s *Sink) updates(metrics []cocaine.Metric) []cocaine.Metric {
+	var updates []cocaine.Metric
+	for _, metric := range metrics {
+		metric.Name = joinName(s.prefix, metric.Name)
+		if metric.Kind == cocaine.MetricCounter {
+			delta := metric.Value - s.last[metric.Name]
+			s.last[metric.Name] = metric.Value
+			if delta <= 0 {
+				continue
+			}
+			metric.Value = delta
+		}
+		updates = append(updates, metric)
+	}
+	return updates
+}
+
+func (s *Sink) Close() error {
+	s.client.Close()
+	return nil
+}
+
+func joinName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+func TestSinkUpdates(t *testing.T) {
+	s := NewSink(nil, "application.echo")
+
+	updates := s.updates([]cocaine.Metric{
+		{Name: "events.ping.calls", Value: 3, Kind: cocaine.MetricCounter},
+		{Name: "load.in_flight", Value: 2, Kind: cocaine.MetricGauge},
+	})
+	assert.Equal(t, []cocaine.Metric{
+		{Name: "application.echo.events.ping.calls", Value: 3, Kind: cocaine.MetricCounter},
+		{Name: "application.echo.load.in_flight", Value: 2, Kind: cocaine.MetricGauge},
+	}, updates)
+
+	// unchanged counters are skipped
+	updates = s.updates([]cocaine.Metric{
+		{Name: "events.ping.calls", Value: 3, Kind: cocaine.MetricCounter},
+		{Name: "events.pong.calls", Value: 5, Kind: cocaine.MetricCounter},
+	})
+	assert.Equal(t, []cocaine.Metric{
+		{Name: "application.echo.events.pong.calls", Value: 5, Kind: cocaine.MetricCounter},
+	}, updates)
+}
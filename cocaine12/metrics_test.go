@@ -0,0 +1,126 @@
+package cocaine12
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetricsSource struct {
+	load  WorkerLoad
+	stats map[string]EventStats
+}
+
+func (s *testMetricsSource) Load() WorkerLoad {
+	return s.load
+}
+
+func (s *testMetricsSource) Stats() map[string]EventStats {
+	return s.stats
+}
+
+func TestCollectMetrics(t *testing.T) {
+	source := &testMetricsSource{
+		load: WorkerLoad{InFlight: 1, QueueDepth: 2, Limit: 4},
+		stats: map[string]EventStats{
+			"a.b": {Calls: 10, Errors: 1, RequestBytes: 100, ResponseBytes: 200, TooLarge: 1,
+				P50: time.Millisecond, P95: 2 * time.Millisecond, P99: 3 * time.Millisecond},
+		},
+	}
+
+	assert.Equal(t, []Metric{
+		{"load.in_flight", 1, MetricGauge},
+		{"load.queue_depth", 2, MetricGauge},
+		{"load.utilization", 0.25, MetricGauge},
+		{"events.a_b.calls", 10, MetricCounter},
+		{"events.a_b.errors", 1, MetricCounter},
+		{"events.a_b.request_bytes", 100, MetricCounter},
+		{"events.a_b.response_bytes", 200, MetricCounter},
+		{"events.a_b.too_large", 1, MetricCounter},
+		{"events.a_b.p50_ms", 1, MetricGauge},
+		{"events.a_b.p95_ms", 2, MetricGauge},
+		{"events.a_b.p99_ms", 3, MetricGauge},
+	}, collectMetrics(source))
+}
+
+func TestStatsdSink(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sink, err := NewStatsdSink(server.LocalAddr().String(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	read := func() string {
+		buf := make([]byte, statsdMaxPacketSize)
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := server.ReadFrom(buf)
+		assert.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	assert.NoError(t, sink.Push([]Metric{
+		{"calls", 10, MetricCounter},
+		{"in_flight", 1.5, MetricGauge},
+	}, time.Now()))
+	assert.Equal(t, "app.calls:10|c\napp.in_flight:1.5|g", read())
+
+	// counters are sent as increments
+	assert.NoError(t, sink.Push([]Metric{
+		{"calls", 15, MetricCounter},
+		{"in_flight", 0, MetricGauge},
+	}, time.Now()))
+	assert.Equal(t, "app.calls:5|c\napp.in_flight:0|g", read())
+}
+
+func TestGraphiteSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	sink := NewGraphiteSink(ln.Addr().String(), "app")
+	defer sink.Close()
+
+	now := time.Unix(1500000000, 0)
+	assert.NoError(t, sink.Push([]Metric{
+		{"events.echo.calls", 10, MetricCounter},
+		{"load.utilization", 0.25, MetricGauge},
+	}, now))
+
+	for _, expected := range []string{
+		"app.events.echo.calls 10 1500000000",
+		"app.load.utilization 0.25 1500000000",
+	} {
+		select {
+		case line := <-lines:
+			assert.Equal(t, expected, strings.TrimSpace(line))
+		case <-time.After(time.Second):
+			t.Fatal("no metrics received")
+		}
+	}
+}
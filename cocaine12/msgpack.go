@@ -0,0 +1,196 @@
+package cocaine12
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// timestampExt is the type of the timestamp extension of MessagePack (-1)
+const timestampExt = 0xff
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	extMu sync.Mutex
+	// registered extension types by a tag
+	extTypes = map[int8]reflect.Type{-1: timeType}
+)
+
+func init() {
+	for _, h := range msgpackHandles() {
+		// bin and str8 types of the current spec
+		h.WriteExt = true
+	}
+
+	for _, h := range append(msgpackHandles(), &mConvertHandler) {
+		if err := h.AddExt(timeType, timestampExt, encodeTimestamp, decodeTimestamp); err != nil {
+			panic(fmt.Sprintf("unable to register the timestamp extension: %v", err))
+		}
+	}
+}
+
+// msgpackHandles returns handles used for the wire protocol and payloads
+func msgpackHandles() []*codec.MsgpackHandle {
+	return []*codec.MsgpackHandle{&mhAsocket, &mPayloadHandler}
+}
+
+// UseLegacyMsgpack makes the framework emit only types of the old
+// MessagePack spec, i.e. raw instead of bin and str8, and time.Time as raw
+// bytes, for runtimes unable to decode the current spec. Both specs are
+// decoded anyway. It must be called before any connection is made.
+func UseLegacyMsgpack() {
+	for _, h := range msgpackHandles() {
+		h.WriteExt = false
+	}
+}
+
+// msgpMarshaler is implemented by types generated by
+// github.com/tinylib/msgp, which pack themselves without reflection.
+// It's used only where a payload is a standalone msgpack object:
+// requests and responses of TypedHandler, HealthStatus, event lists
+// and error details. Arguments of Service calls are a part of the message
+// and are always packed by reflection, as well as results unpacked by
+// ServiceResult.Extract: they are re-encoded by reflection from
+// the decoded message, UnmarshalMsg only reads the result.
+type msgpMarshaler interface {
+	MarshalMsg(b []byte) ([]byte, error)
+}
+
+// msgpUnmarshaler is the counterpart of msgpMarshaler
+type msgpUnmarshaler interface {
+	UnmarshalMsg(b []byte) ([]byte, error)
+}
+
+// marshalPayload packs v using the code generated by msgp if any
+func marshalPayload(v interface{}) ([]byte, error) {
+	if m, ok := v.(msgpMarshaler); ok {
+		return m.MarshalMsg(nil)
+	}
+
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, payloadHandler).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// unmarshalPayload unpacks data into v using the code generated
+// by msgp if any
+func unmarshalPayload(data []byte, v interface{}) error {
+	if u, ok := v.(msgpUnmarshaler); ok {
+		_, err := u.UnmarshalMsg(data)
+		return err
+	}
+
+	return codec.NewDecoderBytes(data, payloadHandler).Decode(v)
+}
+
+// ExtEncodeFunc packs a value of an extension type into data of the extension
+type ExtEncodeFunc func(value interface{}) ([]byte, error)
+
+// ExtDecodeFunc unpacks data of an extension into a value of the extension type
+type ExtDecodeFunc func(data []byte) (interface{}, error)
+
+// RegisterExt registers a msgpack extension with the tag for the type
+// of the value, e.g. to send UUIDs as the extension 2. The extension is
+// used to pack and unpack payloads of Service calls, worker replies and
+// TypedHandler requests. Tags below 0 are reserved by the spec.
+// This function must be called before any Service or Worker is created,
+// e.g. from init.
+func RegisterExt(value interface{}, tag int8, encode ExtEncodeFunc, decode ExtDecodeFunc) error {
+	if tag < 0 {
+		return fmt.Errorf("msgpack extension tag %d is reserved", tag)
+	}
+
+	rt := reflect.TypeOf(value)
+	if rt == nil || rt.Name() == "" || rt.Kind() == reflect.Interface {
+		return fmt.Errorf("msgpack extension type must be a named type, not %T", value)
+	}
+
+	extMu.Lock()
+	defer extMu.Unlock()
+	if registered, ok := extTypes[tag]; ok && registered != rt {
+		return fmt.Errorf("msgpack extension tag %d is already registered for %v", tag, registered)
+	}
+
+	encFn := func(rv reflect.Value) ([]byte, error) {
+		return encode(rv.Interface())
+	}
+	decFn := func(rv reflect.Value, data []byte) error {
+		v, err := decode(data)
+		if err != nil {
+			return err
+		}
+
+		decoded := reflect.ValueOf(v)
+		if !decoded.IsValid() || !decoded.Type().AssignableTo(rt) {
+			return fmt.Errorf("msgpack extension %d is decoded into %T instead of %v", tag, v, rt)
+		}
+		rv.Set(decoded)
+		return nil
+	}
+
+	for _, h := range append(msgpackHandles(), &mConvertHandler) {
+		if err := h.AddExt(rt, byte(tag), encFn, decFn); err != nil {
+			return err
+		}
+	}
+
+	for registeredTag, registered := range extTypes {
+		if registered == rt {
+			delete(extTypes, registeredTag)
+		}
+	}
+	extTypes[tag] = rt
+	return nil
+}
+
+func encodeTimestamp(rv reflect.Value) ([]byte, error) {
+	t := rv.Interface().(time.Time)
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec>>32 == 0:
+		// timestamp 32
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(sec))
+		return data, nil
+	case sec>>34 == 0:
+		// timestamp 64
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, nsec<<34|uint64(sec))
+		return data, nil
+	default:
+		// timestamp 96
+		data := make([]byte, 12)
+		binary.BigEndian.PutUint32(data, uint32(nsec))
+		binary.BigEndian.PutUint64(data[4:], uint64(sec))
+		return data, nil
+	}
+}
+
+func decodeTimestamp(rv reflect.Value, data []byte) error {
+	var t time.Time
+	switch len(data) {
+	case 4:
+		t = time.Unix(int64(binary.BigEndian.Uint32(data)), 0)
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		t = time.Unix(int64(v&(1<<34-1)), int64(v>>34))
+	case 12:
+		t = time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data)))
+	default:
+		// legacy raw bytes of time.Time.MarshalBinary
+		if err := t.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("invalid timestamp: %v", err)
+		}
+	}
+
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
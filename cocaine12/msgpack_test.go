@@ -0,0 +1,172 @@
+package cocaine12
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+func packPayload(t *testing.T, v interface{}) []byte {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, payloadHandler).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestMsgpackBinAndStr8(t *testing.T) {
+	assert.Equal(t, []byte{0xc4, 0x02, 'o', 'k'}, packPayload(t, []byte("ok")))
+
+	long := make([]byte, 40)
+	for i := range long {
+		long[i] = 'a'
+	}
+	packed := packPayload(t, string(long))
+	assert.Equal(t, []byte{0xd9, 40}, packed[:2])
+	assert.Len(t, packed, 42)
+}
+
+func TestMsgpackDecodeLegacyRaw(t *testing.T) {
+	// raw 16 was used by old runtimes for both strings and bytes
+	legacy := []byte{0xda, 0x00, 0x02, 'o', 'k'}
+
+	var bs []byte
+	assert.NoError(t, codec.NewDecoderBytes(legacy, payloadHandler).Decode(&bs))
+	assert.Equal(t, []byte("ok"), bs)
+
+	var s string
+	assert.NoError(t, codec.NewDecoderBytes(legacy, payloadHandler).Decode(&s))
+	assert.Equal(t, "ok", s)
+
+	var out struct {
+		Data    []byte
+		Message string
+	}
+	assert.NoError(t, convertPayload([]interface{}{[]byte("data"), []byte("message")}, &out))
+	assert.Equal(t, []byte("data"), out.Data)
+	assert.Equal(t, "message", out.Message)
+}
+
+func TestMsgpackTimestamp(t *testing.T) {
+	for _, tc := range []struct {
+		time   time.Time
+		header []byte
+	}{
+		{time.Unix(1500000000, 0), []byte{0xd6, 0xff}},
+		{time.Unix(1500000000, 123456789), []byte{0xd7, 0xff}},
+		{time.Unix(1<<35, 1), []byte{0xc7, 12, 0xff}},
+		{time.Unix(-1, 0), []byte{0xc7, 12, 0xff}},
+	} {
+		packed := packPayload(t, tc.time)
+		assert.Equal(t, tc.header, packed[:len(tc.header)], "%v", tc.time)
+
+		var decoded time.Time
+		if assert.NoError(t, codec.NewDecoderBytes(packed, payloadHandler).Decode(&decoded)) {
+			assert.True(t, tc.time.Equal(decoded), "%v != %v", tc.time, decoded)
+		}
+	}
+}
+
+func TestMsgpackTimestampLegacy(t *testing.T) {
+	now := time.Unix(1500000000, 42)
+	binary, _ := now.MarshalBinary()
+
+	var raw []byte
+	if err := codec.NewEncoderBytes(&raw, payloadHandler).Encode(binary); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded time.Time
+	assert.NoError(t, codec.NewDecoderBytes(raw, payloadHandler).Decode(&decoded))
+	assert.True(t, now.Equal(decoded))
+}
+
+type testUUID [16]byte
+
+func init() {
+	if err := RegisterExt(testUUID{}, 2,
+		func(value interface{}) ([]byte, error) {
+			id := value.(testUUID)
+			return id[:], nil
+		},
+		func(data []byte) (interface{}, error) {
+			var id testUUID
+			if len(data) != len(id) {
+				return nil, fmt.Errorf("invalid UUID length %d", len(data))
+			}
+			copy(id[:], data)
+			return id, nil
+		}); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterExt(t *testing.T) {
+	id := testUUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	packed := packPayload(t, []interface{}{id})
+	assert.Equal(t, []byte{0x91, 0xd8, 0x02, 1}, packed[:4])
+
+	var decoded []testUUID
+	assert.NoError(t, codec.NewDecoderBytes(packed, payloadHandler).Decode(&decoded))
+	assert.Equal(t, []testUUID{id}, decoded)
+
+	// a reply of a service is decoded by the wire codec
+	// and then converted by ServiceResult.Extract
+	var reply []interface{}
+	assert.NoError(t, codec.NewDecoderBytes(packed, hAsocket).Decode(&reply))
+	var extracted testUUID
+	assert.NoError(t, convertPayload(reply[0], &extracted))
+	assert.Equal(t, id, extracted)
+
+	var invalid testUUID
+	assert.Error(t, codec.NewDecoderBytes([]byte{0xd4, 0x02, 0x00}, payloadHandler).Decode(&invalid))
+}
+
+func TestRegisterExtErrors(t *testing.T) {
+	noop := func(value interface{}) ([]byte, error) { return nil, nil }
+	noopDecode := func(data []byte) (interface{}, error) { return nil, nil }
+
+	assert.Error(t, RegisterExt(testUUID{}, -1, noop, noopDecode), "reserved tag")
+	assert.Error(t, RegisterExt([]byte{}, 3, noop, noopDecode), "unnamed type")
+	assert.Error(t, RegisterExt(time.Duration(0), 2, noop, noopDecode), "tag is taken")
+}
+
+// msgpPoint mimics a type generated by msgp, packing itself as an array
+// while the reflection based codec packs structs as maps
+type msgpPoint struct {
+	X, Y int8
+}
+
+func (p *msgpPoint) MarshalMsg(b []byte) ([]byte, error) {
+	return append(b, 0x92, byte(p.X), byte(p.Y)), nil
+}
+
+func (p *msgpPoint) UnmarshalMsg(b []byte) ([]byte, error) {
+	if len(b) < 3 || b[0] != 0x92 {
+		return b, fmt.Errorf("invalid point % x", b)
+	}
+	p.X, p.Y = int8(b[1]), int8(b[2])
+	return b[3:], nil
+}
+
+func TestMsgpFastPath(t *testing.T) {
+	packed, err := marshalPayload(&msgpPoint{X: 1, Y: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x92, 1, 2}, packed)
+
+	var p msgpPoint
+	assert.NoError(t, unmarshalPayload([]byte{0x92, 3, 4}, &p))
+	assert.Equal(t, msgpPoint{3, 4}, p)
+
+	assert.NoError(t, convertPayload([]interface{}{5, 6}, &p))
+	assert.Equal(t, msgpPoint{5, 6}, p)
+
+	// other types are still packed by reflection
+	packed, err = marshalPayload(struct{ X int }{1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0xa1, 'X', 1}, packed)
+}
@@ -0,0 +1,224 @@
+package cocaine12
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// PayloadLimits limits sizes of payloads of an event in bytes.
+// Zero means no limit.
+type PayloadLimits struct {
+	// MaxRequestSize limits the total size of chunks of a request
+	MaxRequestSize int64
+	// MaxResponseSize limits the total size of chunks of a response
+	MaxResponseSize int64
+}
+
+// WithMaxRequestSize limits the total size of chunks of a request
+// to the event. Chunks are counted as they arrive, so a request exceeding
+// the limit is not buffered: the session is dropped, Read returns
+// *PayloadTooLargeError and the request is replied with ErrorPayloadTooLarge.
+func WithMaxRequestSize(n int64) EventOption {
+	return func(o *eventOptions) {
+		o.limits.MaxRequestSize = n
+	}
+}
+
+// WithMaxResponseSize limits the total size of chunks of a response
+// to the event. The chunk exceeding the limit isn't sent, Write returns
+// *PayloadTooLargeError and the response is ended with ErrorPayloadTooLarge.
+func WithMaxResponseSize(n int64) EventOption {
+	return func(o *eventOptions) {
+		o.limits.MaxResponseSize = n
+	}
+}
+
+// PayloadTooLargeError means that a payload of the event exceeds
+// the limit. See WithMaxRequestSize and WithMaxResponseSize
+type PayloadTooLargeError struct {
+	Event string
+	// Response is set if the response rather than the request is too large
+	Response bool
+	Limit    int64
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	direction := "request"
+	if e.Response {
+		direction = "response"
+	}
+	return fmt.Sprintf("%s payload of event '%s' exceeds %d bytes", direction, e.Event, e.Limit)
+}
+
+// SetEventPayloadLimits sets limits of payloads of the event.
+// It takes effect for requests arriving afterwards.
+func (w *WorkerNG) SetEventPayloadLimits(event string, limits PayloadLimits) {
+	w.optionsMu.Lock()
+	options := w.options[event]
+	options.limits = limits
+	w.options[event] = options
+	w.optionsMu.Unlock()
+}
+
+// newSizedStreams wraps streams of a request to count bytes
+// of the event and to enforce its limits
+func newSizedStreams(event string, limits PayloadLimits, counters *eventCounters,
+	request Request, response Response) (*sizedRequest, *sizedResponse) {
+	sizedRes := &sizedResponse{
+		Response: response,
+		event:    event,
+		limit:    limits.MaxResponseSize,
+		counters: counters,
+	}
+	sizedReq := &sizedRequest{
+		Request:  request,
+		limit:    limits.MaxRequestSize,
+		response: sizedRes,
+	}
+	return sizedReq, sizedRes
+}
+
+// sizedRequest counts bytes read by a handler and bytes received
+// by the loop. A handler may read the request from another goroutine.
+type sizedRequest struct {
+	Request
+	read  int64
+	limit int64
+	// received is owned by the loop
+	received int64
+	// set by the loop once received exceeds the limit, accessed atomically
+	exceeded int32
+	response *sizedResponse
+}
+
+func (r *sizedRequest) Read(ctx context.Context) ([]byte, error) {
+	data, err := r.Request.Read(ctx)
+	if err != nil {
+		if atomic.LoadInt32(&r.exceeded) == 1 {
+			// the session has been dropped by the loop
+			return nil, r.tooLargeError()
+		}
+		return data, err
+	}
+
+	read := atomic.AddInt64(&r.read, int64(len(data)))
+	if r.limit > 0 && read > r.limit {
+		// a compressed chunk might have fitted the limit
+		return nil, r.response.tooLarge(r.tooLargeError())
+	}
+	return data, nil
+}
+
+// receive is called by the loop for every chunk of the request.
+// It returns false and replies with ErrorPayloadTooLarge
+// as soon as the chunks exceed the limit.
+func (r *sizedRequest) receive(size int) bool {
+	r.received += int64(size)
+	if r.limit <= 0 || r.received <= r.limit {
+		atomic.AddUint64(&r.response.counters.requestBytes, uint64(size))
+		return true
+	}
+
+	atomic.StoreInt32(&r.exceeded, 1)
+	r.response.tooLarge(r.tooLargeError())
+	return false
+}
+
+func (r *sizedRequest) tooLargeError() *PayloadTooLargeError {
+	return &PayloadTooLargeError{Event: r.response.event, Limit: r.limit}
+}
+
+// receiveChunk counts the chunk against the request limit of its session
+func (w *WorkerNG) receiveChunk(msg *Message) bool {
+	state, ok := w.sessionStates[msg.Session]
+	if !ok || len(msg.Payload) == 0 {
+		return true
+	}
+
+	data, _ := msg.Payload[0].([]byte)
+	return state.request.receive(len(data))
+}
+
+// sizedResponse counts bytes written by a handler
+type sizedResponse struct {
+	Response
+	event    string
+	written  int64
+	limit    int64
+	counters *eventCounters
+	// set once a limit is exceeded, accessed atomically
+	exceeded int32
+}
+
+func (r *sizedResponse) Write(data []byte) (int, error) {
+	if err := r.reserve(len(data)); err != nil {
+		return 0, err
+	}
+
+	n, err := r.Response.Write(data)
+	atomic.AddUint64(&r.counters.responseBytes, uint64(n))
+	return n, err
+}
+
+func (r *sizedResponse) ZeroCopyWrite(data []byte) error {
+	if err := r.reserve(len(data)); err != nil {
+		return err
+	}
+
+	err := r.Response.ZeroCopyWrite(data)
+	if err == nil {
+		atomic.AddUint64(&r.counters.responseBytes, uint64(len(data)))
+	}
+	return err
+}
+
+func (r *sizedResponse) WriteWithMetadata(data []byte, md Metadata) error {
+	writer, ok := r.Response.(MetadataWriter)
+	if !ok {
+		_, err := r.Write(data)
+		return err
+	}
+
+	if err := r.reserve(len(data)); err != nil {
+		return err
+	}
+
+	err := writer.WriteWithMetadata(data, md)
+	if err == nil {
+		atomic.AddUint64(&r.counters.responseBytes, uint64(len(data)))
+	}
+	return err
+}
+
+func (r *sizedResponse) CloseWithMetadata(md Metadata) error {
+	if writer, ok := r.Response.(MetadataWriter); ok {
+		return writer.CloseWithMetadata(md)
+	}
+	return r.Response.Close()
+}
+
+func (r *sizedResponse) reserve(n int) error {
+	written := atomic.AddInt64(&r.written, int64(n))
+	if r.limit > 0 && written > r.limit {
+		return r.tooLarge(&PayloadTooLargeError{Event: r.event, Response: true, Limit: r.limit})
+	}
+	return nil
+}
+
+// tooLarge replies with ErrorPayloadTooLarge once
+func (r *sizedResponse) tooLarge(err *PayloadTooLargeError) error {
+	if atomic.CompareAndSwapInt32(&r.exceeded, 0, 1) {
+		atomic.AddUint64(&r.counters.tooLarge, 1)
+		r.errorMsgWithHeaders(cworkererrorcategory, ErrorPayloadTooLarge, err.Error(), nil)
+	}
+	return err
+}
+
+func (r *sizedResponse) errorMsgWithHeaders(category, code int, message string, headers []Header) error {
+	if sender, ok := r.Response.(errorWithHeadersSender); ok {
+		return sender.errorMsgWithHeaders(category, code, message, headers)
+	}
+	return r.Response.ErrorMsg(code, message)
+}
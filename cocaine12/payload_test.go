@@ -0,0 +1,158 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func errorCodeOf(t *testing.T, msg *Message) (int, string) {
+	var (
+		code    [2]int
+		message string
+	)
+	if err := convertPayload(msg.Payload[0], &code); err != nil {
+		t.Fatal(err)
+	}
+	if err := convertPayload(msg.Payload[1], &message); err != nil {
+		t.Fatal(err)
+	}
+	return code[1], message
+}
+
+func TestWorkerPayloadLimits(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	readErrors := make(chan error, 1)
+	w.On("upload", func(ctx context.Context, req Request, res Response) {
+		for {
+			if _, err := req.Read(ctx); err != nil {
+				readErrors <- err
+				return
+			}
+		}
+	}, WithMaxRequestSize(4))
+	w.On("download", func(ctx context.Context, req Request, res Response) {
+		res.Write([]byte("abc"))
+		_, err := res.Write([]byte("def"))
+		assert.IsType(t, &PayloadTooLargeError{}, err)
+	}, WithMaxResponseSize(4))
+	go w.Run(nil)
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "upload")
+	sock2.Write() <- newChunkV1(2, []byte("abc"))
+	sock2.Write() <- newChunkV1(2, []byte("def"))
+	reply := <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Error)
+	code, message := errorCodeOf(t, reply)
+	assert.Equal(t, ErrorPayloadTooLarge, code)
+	assert.Equal(t, "request payload of event 'upload' exceeds 4 bytes", message)
+	assert.Equal(t, &PayloadTooLargeError{Event: "upload", Limit: 4}, <-readErrors)
+
+	sock2.Write() <- newInvokeV1(3, "download")
+	checkTypeAndSession(t, <-sock2.Read(), 3, v1Write)
+	reply = <-sock2.Read()
+	checkTypeAndSession(t, reply, 3, v1Error)
+	code, _ = errorCodeOf(t, reply)
+	assert.Equal(t, ErrorPayloadTooLarge, code)
+
+	stats := w.Stats()
+	assert.Equal(t, uint64(3), stats["upload"].RequestBytes)
+	assert.Equal(t, uint64(1), stats["upload"].TooLarge)
+	assert.Equal(t, uint64(3), stats["download"].ResponseBytes)
+	assert.Equal(t, uint64(1), stats["download"].TooLarge)
+}
+
+func TestPayloadTooLargeErrorTranslation(t *testing.T) {
+	code, ok := translateError(&PayloadTooLargeError{Event: "upload", Limit: 1})
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCode{cworkererrorcategory, ErrorPayloadTooLarge}, code)
+}
+
+func TestWorkerRequestSizeWithoutReading(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	done := make(chan struct{})
+	w.On("upload", func(ctx context.Context, req Request, res Response) {
+		// chunks are counted even if the handler doesn't read them
+		<-ctx.Done()
+		close(done)
+	}, WithMaxRequestSize(4))
+	go w.Run(nil)
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "upload")
+	sock2.Write() <- newChunkV1(2, []byte("abc"))
+	sock2.Write() <- newChunkV1(2, []byte("def"))
+	reply := <-sock2.Read()
+	checkTypeAndSession(t, reply, 2, v1Error)
+	code, _ := errorCodeOf(t, reply)
+	assert.Equal(t, ErrorPayloadTooLarge, code)
+	<-done
+
+	// the session is dropped, so further chunks are ignored
+	sock2.Write() <- newChunkV1(2, []byte("ghi"))
+	assert.Equal(t, uint64(1), w.Stats()["upload"].TooLarge)
+}
+
+func TestWorkerRequestSizeWhileWriting(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	done := make(chan struct{})
+	w.On("upload", func(ctx context.Context, req Request, res Response) {
+		defer close(done)
+		// the loop replies with ErrorPayloadTooLarge while the handler writes
+		for res.ZeroCopyWrite([]byte("progress")) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}, WithMaxRequestSize(4))
+	go w.Run(nil)
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "upload")
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Write)
+	sock2.Write() <- newChunkV1(2, []byte("abc"))
+	sock2.Write() <- newChunkV1(2, []byte("def"))
+
+	for {
+		reply := <-sock2.Read()
+		if reply.MsgType == v1Write {
+			continue
+		}
+		checkTypeAndSession(t, reply, 2, v1Error)
+		code, _ := errorCodeOf(t, reply)
+		assert.Equal(t, ErrorPayloadTooLarge, code)
+		break
+	}
+	<-done
+}
@@ -0,0 +1,95 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// PoolPolicy selects a connection of ServicePool for a call
+type PoolPolicy int
+
+const (
+	// RoundRobin uses connections in turn
+	RoundRobin PoolPolicy = iota
+	// LeastPending uses the connection with the fewest sessions in flight
+	LeastPending
+)
+
+// ErrInvalidPoolSize means that a pool is requested to have no connections
+var ErrInvalidPoolSize = errors.New("pool size must be positive")
+
+// ServicePool keeps several connections to the same service,
+// as a single connection becomes a bottleneck for high-throughput callers.
+type ServicePool struct {
+	services []*Service
+	policy   PoolPolicy
+	next     uint32
+}
+
+// NewServicePool resolves the service once and opens size connections to it
+func NewServicePool(ctx context.Context, name string, endpoints []string, size int, policy PoolPolicy) (*ServicePool, error) {
+	if size <= 0 {
+		return nil, ErrInvalidPoolSize
+	}
+
+	services := make([]*Service, 0, size)
+	for i := 0; i < size; i++ {
+		service, err := NewService(ctx, name, endpoints)
+		if err != nil {
+			for _, s := range services {
+				s.Close()
+			}
+			return nil, err
+		}
+		services = append(services, service)
+	}
+
+	return newServicePool(services, policy), nil
+}
+
+func newServicePool(services []*Service, policy PoolPolicy) *ServicePool {
+	return &ServicePool{
+		services: services,
+		policy:   policy,
+	}
+}
+
+// Size returns the number of connections
+func (p *ServicePool) Size() int {
+	return len(p.services)
+}
+
+// Call calls the method over one of connections chosen by the policy
+func (p *ServicePool) Call(ctx context.Context, name string, args ...interface{}) (Channel, error) {
+	return p.pick().Call(ctx, name, args...)
+}
+
+// Close closes all connections of the pool
+func (p *ServicePool) Close() {
+	for _, service := range p.services {
+		service.Close()
+	}
+}
+
+func (p *ServicePool) pick() *Service {
+	// connections are checked starting from the next one in turn,
+	// so equally loaded connections are used evenly
+	start := int(atomic.AddUint32(&p.next, 1)-1) % len(p.services)
+	if p.policy == RoundRobin {
+		return p.services[start]
+	}
+
+	var (
+		best    = p.services[start]
+		pending = best.sessions.Len()
+	)
+	for i := 1; i < len(p.services) && pending > 0; i++ {
+		candidate := p.services[(start+i)%len(p.services)]
+		if n := candidate.sessions.Len(); n < pending {
+			best, pending = candidate, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,46 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestServicePool(t *testing.T) {
+	var services []*Service
+	for i := 0; i < 3; i++ {
+		service, _ := newTestService(t, "app")
+		services = append(services, service)
+	}
+
+	pool := newServicePool(services, RoundRobin)
+	defer pool.Close()
+	assert.Equal(t, 3, pool.Size())
+
+	for i := 0; i < 6; i++ {
+		assert.True(t, services[i%3] == pool.pick())
+	}
+
+	pool = newServicePool(services, LeastPending)
+	for i := 0; i < 3; i++ {
+		_, err := services[0].Call(context.Background(), "ping")
+		assert.NoError(t, err)
+	}
+	_, err := services[1].Call(context.Background(), "ping")
+	assert.NoError(t, err)
+
+	assert.True(t, services[2] == pool.pick())
+	assert.True(t, services[2] == pool.pick())
+	assert.True(t, services[2] == pool.pick())
+
+	_, err = pool.Call(context.Background(), "ping")
+	assert.NoError(t, err)
+	_, err = pool.Call(context.Background(), "ping")
+	assert.NoError(t, err)
+	// pending sessions are 3, 2, 1
+	assert.True(t, services[2] == pool.pick())
+
+	_, err = NewServicePool(context.Background(), "app", nil, 0, RoundRobin)
+	assert.Equal(t, ErrInvalidPoolSize, err)
+}
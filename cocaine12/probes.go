@@ -0,0 +1,125 @@
+package cocaine12
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// LivenessPath reports whether the worker is alive
+	LivenessPath = "/healthz"
+	// ReadinessPath reports whether the worker is ready for requests
+	ReadinessPath = "/readyz"
+)
+
+// probeState is updated by the worker loop and read by probes
+// from other goroutines
+type probeState struct {
+	handshake int32
+	running   int32
+	// UnixNano of the last heartbeat reply
+	lastReply int64
+}
+
+func (p *probeState) handshakeSent() {
+	atomic.StoreInt32(&p.handshake, 1)
+}
+
+func (p *probeState) setRunning(running bool) {
+	var value int32
+	if running {
+		value = 1
+	}
+	atomic.StoreInt32(&p.running, value)
+}
+
+func (p *probeState) heartbeatReceived(now time.Time) {
+	atomic.StoreInt64(&p.lastReply, now.UnixNano())
+}
+
+// liveness fails if the worker is stopped or cocaine-runtime
+// has not replied to heartbeats for a long time
+func (w *WorkerNG) liveness() error {
+	if atomic.LoadInt32(&w.probes.handshake) == 0 {
+		return fmt.Errorf("handshake has not been sent")
+	}
+
+	if w.isStopped() {
+		return fmt.Errorf("worker is stopped")
+	}
+
+	if lastReply := atomic.LoadInt64(&w.probes.lastReply); lastReply != 0 {
+		elapsed := w.clock.Now().Sub(time.Unix(0, lastReply))
+		if elapsed > w.heartbeatInterval+w.disownInterval {
+			return fmt.Errorf("last heartbeat reply was %v ago", elapsed)
+		}
+	}
+
+	return nil
+}
+
+// readiness fails until cocaine-runtime replies to the first heartbeat
+func (w *WorkerNG) readiness() error {
+	if err := w.liveness(); err != nil {
+		return err
+	}
+
+	if atomic.LoadInt32(&w.probes.running) == 0 {
+		return fmt.Errorf("worker is not running")
+	}
+
+	if atomic.LoadInt64(&w.probes.lastReply) == 0 {
+		return fmt.Errorf("no heartbeat reply has been received")
+	}
+
+	return nil
+}
+
+// ServeProbes starts an HTTP listener for Kubernetes probes.
+// LivenessPath checks the handshake and freshness of heartbeats.
+// ReadinessPath checks also that the worker is running and
+// all health checks pass. Failed probes are replied with 503.
+// Close the returned listener to stop serving.
+func (w *Worker) ServeProbes(addr string) (io.Closer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, func(rw http.ResponseWriter, r *http.Request) {
+		writeProbe(rw, w.impl.liveness())
+	})
+	mux.HandleFunc(ReadinessPath, func(rw http.ResponseWriter, r *http.Request) {
+		if err := w.impl.readiness(); err != nil {
+			writeProbe(rw, err)
+			return
+		}
+
+		if status := w.health.run(context.Background()); !status.Ready {
+			writeProbe(rw, fmt.Errorf("health checks failed: %s", status.failed()))
+			return
+		}
+
+		writeProbe(rw, nil)
+	})
+
+	go http.Serve(ln, mux)
+	return ln, nil
+}
+
+func writeProbe(rw http.ResponseWriter, err error) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(rw, err)
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
@@ -0,0 +1,99 @@
+package cocaine12
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func getProbe(t *testing.T, addr, path string) (int, string) {
+	resp, err := http.Get("http://" + addr + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestWorkerProbes(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	closer, err := w.ServeProbes("127.0.0.1:0")
+	if err != nil {
+		t.Skip("unable to listen", err)
+	}
+	defer closer.Close()
+	addr := closer.(net.Listener).Addr().String()
+
+	code, _ := getProbe(t, addr, LivenessPath)
+	assert.Equal(t, http.StatusOK, code)
+	code, body := getProbe(t, addr, ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "worker is not running\n", body)
+
+	go w.Run(map[string]EventHandler{})
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newHeartbeatV1()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if code, _ = getProbe(t, addr, ReadinessPath); code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, http.StatusOK, code)
+
+	w.AddHealthCheck("storage", func(ctx context.Context) error {
+		return errors.New("unavailable")
+	})
+	code, body = getProbe(t, addr, ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "health checks failed: storage: unavailable\n", body)
+
+	// health checks don't affect liveness
+	code, _ = getProbe(t, addr, LivenessPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	w.Stop()
+	code, body = getProbe(t, addr, LivenessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "worker is stopped\n", body)
+}
+
+func TestWorkerLivenessHeartbeats(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	newAsyncRW(in)
+
+	w, err := newWorkerNG(sock, "uuid", 1, false, new(NullTokenManager))
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	w.SetClock(clock)
+
+	w.onHeartbeat(newHeartbeatV1())
+	assert.NoError(t, w.liveness())
+
+	clock.Advance(w.heartbeatInterval + w.disownInterval + time.Second)
+	assert.EqualError(t, w.liveness(), "last heartbeat reply was 16s ago")
+}
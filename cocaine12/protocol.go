@@ -79,7 +79,6 @@ func getTrace(header interface{}) (uint64, []byte, error) {
 		case int64:
 			traceNum = uint64(num)
 		default:
-			fmt.Println(reflect.TypeOf(t[1]))
 			return 0, nil, ErrInvalidTraceType
 		}
 
@@ -185,6 +184,9 @@ type Message struct {
 	CommonMessageInfo
 	Payload []interface{}
 	Headers CocaineHeaders
+
+	// called once the message is written to the connection
+	written func()
 }
 
 func (m *Message) String() string {
@@ -92,6 +92,10 @@ func process(w http.ResponseWriter, r *http.Request) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
+
+	if traceInfo, ok := cocaine.ExtractTraceHeaders(r.Header); ok {
+		ctx = cocaine.AttachTraceInfo(ctx, traceInfo)
+	}
 	app, err := cocaine.NewService(ctx, service, nil)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -99,6 +103,11 @@ func process(w http.ResponseWriter, r *http.Request) {
 	}
 	defer app.Close()
 
+	if event == cocaine.HealthEvent {
+		serveHealth(ctx, w, app)
+		return
+	}
+
 	task, err := packRequest(r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -167,6 +176,45 @@ BODY:
 	}
 }
 
+// serveHealth translates the reply to HealthEvent to HTTP:
+// 200 if the application is ready, 503 otherwise
+func serveHealth(ctx context.Context, w http.ResponseWriter, app *cocaine.Service) {
+	channel, err := app.Call(ctx, "enqueue", cocaine.HealthEvent)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	res, err := channel.Get(ctx)
+	if err != nil || res.Err() != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var body []byte
+	if err := res.ExtractTuple(&body); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	status, err := cocaine.UnpackHealthStatus(body)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	for name, message := range status.Checks {
+		if message == "" {
+			message = "OK"
+		}
+		fmt.Fprintf(w, "%s: %s\n", name, message)
+	}
+}
+
 func NewServer() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", process)
@@ -0,0 +1,118 @@
+package cocaine12
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RetryAfterHeader carries the number of seconds a client
+// should wait before it retries a rejected request.
+// Service delays new calls accordingly.
+const RetryAfterHeader = "retry-after"
+
+// RateLimit describes a token bucket: RPS tokens are added every second
+// up to Burst tokens, every request takes one
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// take returns how long to wait for a token if there is none
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit.RPS
+	}
+	b.tokens = math.Min(b.tokens, float64(b.limit.Burst))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if b.limit.RPS <= 0 {
+		return false, time.Second
+	}
+
+	wait := (1 - b.tokens) / b.limit.RPS
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// errorWithHeadersSender is implemented by response
+// and by wrappers of it installed by interceptors
+type errorWithHeadersSender interface {
+	errorMsgWithHeaders(category, code int, message string, headers []Header) error
+}
+
+// RateLimiter limits the rate of requests per event
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	clock   Clock
+}
+
+// NewRateLimiter creates RateLimiter with limits of events.
+// Events without a limit are not limited.
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		buckets: make(map[string]*tokenBucket, len(limits)),
+		clock:   realClock{},
+	}
+
+	for event, limit := range limits {
+		l.SetLimit(event, limit)
+	}
+	return l
+}
+
+// SetLimit sets the limit of the event. The bucket starts full.
+func (l *RateLimiter) SetLimit(event string, limit RateLimit) {
+	l.mu.Lock()
+	l.buckets[event] = &tokenBucket{limit: limit, tokens: float64(limit.Burst)}
+	l.mu.Unlock()
+}
+
+// Allow reports whether a request of the event is allowed now,
+// otherwise it returns how long to wait
+func (l *RateLimiter) Allow(event string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[event]
+	if !ok {
+		return true, 0
+	}
+	return bucket.take(l.clock.Now())
+}
+
+// Interceptor replies with ErrorOverloaded and RetryAfterHeader
+// to requests exceeding the limit of their event. See Worker.Use
+func (l *RateLimiter) Interceptor() Interceptor {
+	return func(event string, handler EventHandler) EventHandler {
+		return func(ctx context.Context, req Request, res Response) {
+			allowed, wait := l.Allow(event)
+			if allowed {
+				handler(ctx, req, res)
+				return
+			}
+
+			message := fmt.Sprintf("rate limit of event '%s' is exceeded", event)
+			// rounded up, like Retry-After of HTTP
+			retryAfter := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+			sendError(res, cworkererrorcategory, ErrorOverloaded, message, []Header{
+				{Name: RetryAfterHeader, Value: []byte(retryAfter)},
+			})
+		}
+	}
+}
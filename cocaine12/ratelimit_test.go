@@ -0,0 +1,112 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	l := NewRateLimiter(map[string]RateLimit{
+		"limited": {RPS: 2, Burst: 2},
+	})
+	l.clock = clock
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.Allow("limited")
+		assert.True(t, allowed)
+	}
+
+	allowed, wait := l.Allow("limited")
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	clock.Advance(500 * time.Millisecond)
+	allowed, _ = l.Allow("limited")
+	assert.True(t, allowed)
+
+	// the bucket is not refilled beyond the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		allowed, _ = l.Allow("limited")
+		assert.True(t, allowed)
+	}
+	allowed, _ = l.Allow("limited")
+	assert.False(t, allowed)
+
+	allowed, _ = l.Allow("unlimited")
+	assert.True(t, allowed)
+}
+
+func TestEventHandlersUse(t *testing.T) {
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(event string, handler EventHandler) EventHandler {
+			return func(ctx context.Context, req Request, res Response) {
+				calls = append(calls, name+":"+event)
+				handler(ctx, req, res)
+			}
+		}
+	}
+
+	handlers := NewEventHandlers()
+	handlers.On("test", func(ctx context.Context, req Request, res Response) {
+		calls = append(calls, "handler")
+	})
+	handlers.SetFallbackHandler(func(ctx context.Context, event string, req Request, res Response) {
+		calls = append(calls, "fallback")
+	})
+	handlers.Use(trace("outer"), trace("inner"))
+
+	handlers.Call(context.Background(), "test", nil, nil)
+	handlers.Call(context.Background(), "unknown", nil, nil)
+	assert.Equal(t, []string{
+		"outer:test", "inner:test", "handler",
+		"outer:unknown", "inner:unknown", "fallback",
+	}, calls)
+}
+
+func TestWorkerRateLimit(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	w.Use(NewRateLimiter(map[string]RateLimit{
+		"test": {RPS: 0.5, Burst: 1},
+	}).Interceptor())
+
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "test")
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	sock2.Write() <- newInvokeV1(3, "test")
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, 3, v1Error)
+
+	var (
+		catAndCode [2]int
+		message    string
+	)
+	assert.NoError(t, convertPayload(msg.Payload, &[]interface{}{&catAndCode, &message}))
+	assert.Equal(t, [2]int{cworkererrorcategory, ErrorOverloaded}, catAndCode)
+
+	headers, err := DefaultHeaderTable.Decode(msg.Headers)
+	assert.NoError(t, err)
+	assert.Equal(t, []Header{{Name: RetryAfterHeader, Value: []byte("2")}}, headers)
+}
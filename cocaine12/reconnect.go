@@ -0,0 +1,64 @@
+package cocaine12
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultReconnectWindow = disownTimeout
+
+	reconnectMinDelay = time.Millisecond * 100
+	reconnectMaxDelay = time.Second
+)
+
+// SetReconnectWindow sets how long the worker tries to reconnect
+// to cocaine-runtime if the connection drops, e.g. as the runtime
+// is restarting. The worker handshakes with the same UUID, so warm caches
+// are preserved. Requests in flight are aborted. It's 5 seconds by default
+// like the disown timeout, zero makes the worker exit with ErrConnectionLost at once.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetReconnectWindow(window time.Duration) {
+	w.reconnectWindow = window
+}
+
+// reconnect reestablishes the connection to the runtime within
+// the reconnect window. It returns false if the window has expired
+// or the worker is stopped.
+func (w *WorkerNG) reconnect() bool {
+	if w.reconnectWindow <= 0 || w.endpoint == "" || w.handedOff {
+		return false
+	}
+
+	var (
+		deadline  = w.clock.Now().Add(w.reconnectWindow)
+		delay     = reconnectMinDelay
+		endpoints = append([]string{w.endpoint}, w.fallbacks...)
+	)
+
+	for {
+		sock, endpoint, fallbacks, err := dialEndpoints(endpoints, coreConnectionTimeout, w.dial)
+		if err == nil {
+			w.fallbacks = fallbacks
+			if err = w.switchConn(sock, endpoint); err == nil {
+				fmt.Printf("reconnected to %s\n", endpoint)
+				return true
+			}
+		}
+
+		if !w.clock.Now().Add(delay).Before(deadline) {
+			fmt.Printf("unable to reconnect within %v: %v\n", w.reconnectWindow, err)
+			return false
+		}
+
+		select {
+		case <-w.clock.After(delay):
+		case <-w.stopped:
+			return false
+		}
+
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestWorkerReconnect(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	dialer := newTestDialer("/run/cocaine.sock")
+	w.impl.dial = dialer.dial
+	w.impl.endpoint = "/run/cocaine.sock"
+
+	aborted := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{
+			"test": func(ctx context.Context, req Request, res Response) {
+				<-ctx.Done()
+				close(aborted)
+			},
+		})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+	sock2.Write() <- newInvokeV1(2, "test")
+	time.Sleep(10 * time.Millisecond)
+
+	// the runtime restarts
+	sock2.Close()
+
+	select {
+	case peer := <-dialer.peers:
+		handshake := <-peer.Read()
+		checkTypeAndSession(t, handshake, v1UtilitySession, v1Handshake)
+		assert.Equal(t, []interface{}{[]byte("uuid")}, handshake.Payload)
+		checkTypeAndSession(t, <-peer.Read(), v1UtilitySession, v1Heartbeat)
+	case err := <-result:
+		t.Fatalf("worker has stopped: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("worker has not reconnected")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("the request of the lost connection is not aborted")
+	}
+}
+
+func TestWorkerReconnectDisabled(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	w.impl.dial = newTestDialer("/run/cocaine.sock").dial
+	w.impl.endpoint = "/run/cocaine.sock"
+	w.SetReconnectWindow(0)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	sock2.Close()
+
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrConnectionLost, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker has not stopped")
+	}
+}
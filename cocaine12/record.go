@@ -0,0 +1,203 @@
+package cocaine12
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+// recordedFrame is an entry of a session record
+type recordedFrame struct {
+	Direction WireDirection
+	Session   uint64
+	MsgType   uint64
+	Payload   []interface{}
+	Headers   CocaineHeaders
+}
+
+// recorder writes frames as a stream of msgpack objects
+type recorder struct {
+	mu      sync.Mutex
+	encoder *codec.Encoder
+}
+
+func newRecorder(w io.Writer) *recorder {
+	return &recorder{encoder: codec.NewEncoder(w, hAsocket)}
+}
+
+func (r *recorder) record(direction WireDirection, msg *Message) {
+	r.mu.Lock()
+	r.encoder.Encode(recordedFrame{direction, msg.Session, msg.MsgType, msg.Payload, msg.Headers})
+	r.mu.Unlock()
+}
+
+// recordingIO passes messages through to the underlying socket
+// writing them to a recorder
+type recordingIO struct {
+	socketIO
+	rec   *recorder
+	read  chan *Message
+	write chan *Message
+}
+
+func newRecordingIO(sock socketIO, w io.Writer) *recordingIO {
+	r := &recordingIO{
+		socketIO: sock,
+		rec:      newRecorder(w),
+		read:     make(chan *Message),
+		write:    make(chan *Message),
+	}
+
+	go r.readLoop()
+	go r.writeLoop()
+	return r
+}
+
+func (r *recordingIO) Read() chan *Message {
+	return r.read
+}
+
+func (r *recordingIO) Write() chan *Message {
+	return r.write
+}
+
+func (r *recordingIO) Send(msg *Message) {
+	select {
+	case r.write <- msg:
+	case <-r.IsClosed():
+	}
+}
+
+func (r *recordingIO) readLoop() {
+	defer close(r.read)
+	for msg := range r.socketIO.Read() {
+		r.rec.record(WireReceived, msg)
+		select {
+		case r.read <- msg:
+		case <-r.IsClosed():
+			return
+		}
+	}
+}
+
+func (r *recordingIO) writeLoop() {
+	for {
+		select {
+		case msg := <-r.write:
+			r.rec.record(WireSent, msg)
+			r.socketIO.Send(msg)
+		case <-r.IsClosed():
+			return
+		}
+	}
+}
+
+// RecordTo makes the worker write every frame it sends or receives
+// after the handshake to out. The record can be replayed by Replay.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) RecordTo(out io.Writer) {
+	w.conn = newRecordingIO(w.conn, out)
+}
+
+// Replay feeds requests from a record made by RecordTo to the handler
+// and checks that it replies with the same frames as recorded.
+// Heartbeats and other utility messages are skipped.
+func Replay(ctx context.Context, record io.Reader, handler RequestHandler) error {
+	var (
+		requests []*Message
+		expected = make(map[uint64][]*Message)
+		pending  = 0
+	)
+
+	frames := newFrameReader(record, 0, 0)
+	for {
+		raw, err := frames.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read the record: %v", err)
+		}
+
+		var frame recordedFrame
+		if err := codec.NewDecoderBytes(raw, hAsocket).Decode(&frame); err != nil {
+			return fmt.Errorf("unable to decode the record: %v", err)
+		}
+
+		if frame.Session == v1UtilitySession {
+			continue
+		}
+
+		msg := &Message{
+			CommonMessageInfo: CommonMessageInfo{frame.Session, frame.MsgType},
+			Payload:           frame.Payload,
+			Headers:           frame.Headers,
+		}
+
+		if frame.Direction == WireReceived {
+			requests = append(requests, msg)
+		} else {
+			expected[msg.Session] = append(expected[msg.Session], msg)
+			pending++
+		}
+	}
+
+	workerConn, runtimeConn := net.Pipe()
+	sock, _ := newAsyncRW(workerConn)
+	runtime, _ := newAsyncRW(runtimeConn)
+	defer runtime.Close()
+
+	w, err := newWorkerNG(sock, "replay", v1, false, new(NullTokenManager))
+	if err != nil {
+		return err
+	}
+	w.EnableStackSignal(false)
+	defer w.Stop()
+
+	go w.Run(handler, nil)
+
+	go func() {
+		for _, msg := range requests {
+			select {
+			case runtime.Write() <- msg:
+			case <-runtime.IsClosed():
+				return
+			}
+		}
+	}()
+
+	for pending > 0 {
+		select {
+		case msg, ok := <-runtime.Read():
+			if !ok {
+				return ErrConnectionLost
+			}
+
+			if msg.Session == v1UtilitySession {
+				continue
+			}
+
+			frames := expected[msg.Session]
+			if len(frames) == 0 {
+				return fmt.Errorf("unexpected frame in session %d: %v", msg.Session, msg)
+			}
+
+			if msg.MsgType != frames[0].MsgType || !reflect.DeepEqual(msg.Payload, frames[0].Payload) {
+				return fmt.Errorf("mismatch in session %d: recorded %v, replayed %v", msg.Session, frames[0], msg)
+			}
+
+			expected[msg.Session] = frames[1:]
+			pending--
+
+		case <-ctx.Done():
+			return fmt.Errorf("%d frames are not replayed: %v", pending, ctx.Err())
+		}
+	}
+
+	return nil
+}
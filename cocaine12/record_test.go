@@ -0,0 +1,76 @@
+package cocaine12
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// lockedBuffer lets the test read a record while the worker may still write
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestRecordReplay(t *testing.T) {
+	const testSession = 2
+
+	echo := NewEventHandlers()
+	echo.On("echo", func(ctx context.Context, req Request, res Response) {
+		data, _ := req.Read(ctx)
+		res.Write(data)
+		res.Close()
+	})
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	var record lockedBuffer
+	w.RecordTo(&record)
+	go w.impl.Run(echo.Call, nil)
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(testSession, "echo")
+	sock2.Write() <- newChunkV1(testSession, []byte("recorded"))
+	sock2.Write() <- newChokeV1(testSession)
+
+	checkTypeAndSession(t, <-sock2.Read(), testSession, v1Write)
+	checkTypeAndSession(t, <-sock2.Read(), testSession, v1Close)
+	w.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	assert.NoError(t, Replay(ctx, bytes.NewReader(record.Bytes()), echo.Call))
+
+	broken := NewEventHandlers()
+	broken.On("echo", func(ctx context.Context, req Request, res Response) {
+		req.Read(ctx)
+		res.Write([]byte("changed"))
+		res.Close()
+	})
+	assert.Error(t, Replay(ctx, bytes.NewReader(record.Bytes()), broken.Call))
+}
@@ -0,0 +1,56 @@
+package cocaine12
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetMaxRequests makes the worker recycle itself after n requests
+// like max_requests of gunicorn does. It mitigates slow memory leaks
+// of handlers. The worker notifies cocaine-runtime that it's shutting down,
+// waits for requests in flight for the termination timeout and
+// Run returns nil. Zero disables the limit.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetMaxRequests(n int) {
+	w.maxRequests = n
+}
+
+// SetMaxUptime makes the worker recycle itself after it has run for d.
+// See SetMaxRequests. Zero disables the limit.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetMaxUptime(d time.Duration) {
+	w.maxUptime = d
+}
+
+// checkRequestLimit is called by the loop after a message is dispatched
+func (w *WorkerNG) checkRequestLimit() bool {
+	if w.recycling || w.maxRequests <= 0 || w.requests < w.maxRequests {
+		return false
+	}
+
+	w.recycle(fmt.Sprintf("recycled after %d requests", w.requests))
+	return true
+}
+
+// recycle notifies cocaine-runtime that the worker is shutting down.
+// The loop stops once requests in flight are done
+// or the termination timeout is over.
+func (w *WorkerNG) recycle(message string) {
+	w.recycling = true
+	w.drainDeadline = w.clock.Now().Add(terminationTimeout)
+
+	select {
+	case w.conn.Write() <- w.dispatcher.newTerminate(TerminationReason{TerminationNormal, message}):
+	case <-w.conn.IsClosed():
+	case <-w.clock.After(disownTimeout):
+	}
+}
+
+// isDrained reports whether the worker can exit after
+// it has handed off the connection or started recycling
+func (w *WorkerNG) isDrained() bool {
+	if w.Load().InFlight == 0 && w.queue.Len() == 0 {
+		return true
+	}
+	return !w.drainDeadline.IsZero() && !w.clock.Now().Before(w.drainDeadline)
+}
@@ -0,0 +1,92 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestWorkerMaxRequests(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	w.SetMaxRequests(1)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{
+			"test": func(ctx context.Context, req Request, res Response) {
+				data, _ := req.Read(ctx)
+				res.Write(data)
+				res.Close()
+			},
+		})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "test")
+	// the worker notifies the runtime at once
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, v1UtilitySession, v1Terminate)
+	assert.Equal(t, TerminationReason{TerminationNormal, "recycled after 1 requests"}, parseTerminationReason(msg))
+
+	// but completes the request in flight
+	sock2.Write() <- newChunkV1(2, []byte("data"))
+	sock2.Write() <- newChokeV1(2)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Write)
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is not recycled")
+	}
+}
+
+func TestWorkerMaxUptime(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetMaxUptime(time.Minute)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(time.Minute)
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, v1UtilitySession, v1Terminate)
+	assert.Equal(t, TerminationReason{TerminationNormal, "recycled after 1m0s of uptime"}, parseTerminationReason(msg))
+
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(drainInterval)
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is not recycled")
+	}
+}
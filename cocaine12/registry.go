@@ -0,0 +1,111 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ErrRegistryClosed means that ServiceRegistry has been closed
+var ErrRegistryClosed = errors.New("service registry is closed")
+
+// ServiceRegistry shares Service instances by name within a process,
+// so libraries that need the same service don't multiply connections.
+// Shared services reconnect on their own and must not be closed
+// by callers, ServiceRegistry.Close closes all of them.
+type ServiceRegistry struct {
+	endpoints []string
+	create    func(ctx context.Context, name string, endpoints []string) (*Service, error)
+
+	mu       sync.Mutex
+	services map[string]*registryEntry
+	closed   bool
+}
+
+type registryEntry struct {
+	ready   chan struct{}
+	service *Service
+	err     error
+}
+
+// NewServiceRegistry creates a registry resolving services
+// via the locators. Default locators are used if none are passed.
+func NewServiceRegistry(endpoints []string) *ServiceRegistry {
+	return &ServiceRegistry{
+		endpoints: endpoints,
+		create:    NewService,
+		services:  make(map[string]*registryEntry),
+	}
+}
+
+// DefaultServiceRegistry is used by SharedService
+var DefaultServiceRegistry = NewServiceRegistry(nil)
+
+// SharedService returns the service from DefaultServiceRegistry
+func SharedService(ctx context.Context, name string) (*Service, error) {
+	return DefaultServiceRegistry.Get(ctx, name)
+}
+
+// Get returns the shared instance of the service creating it if needed.
+// Concurrent callers wait for the same instance. A failure isn't
+// cached, the next call tries to create the service again.
+func (r *ServiceRegistry) Get(ctx context.Context, name string) (*Service, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRegistryClosed
+	}
+
+	entry, ok := r.services[name]
+	if !ok {
+		entry = &registryEntry{ready: make(chan struct{})}
+		r.services[name] = entry
+		r.mu.Unlock()
+
+		entry.service, entry.err = r.create(ctx, name, r.endpoints)
+		r.finish(name, entry)
+	} else {
+		r.mu.Unlock()
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.service, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *ServiceRegistry) finish(name string, entry *registryEntry) {
+	r.mu.Lock()
+	if entry.err != nil {
+		delete(r.services, name)
+	} else if r.closed {
+		// the registry has been closed while the service was connecting
+		entry.service.Close()
+		entry.service, entry.err = nil, ErrRegistryClosed
+	}
+	r.mu.Unlock()
+
+	close(entry.ready)
+}
+
+// Close closes all shared services. The registry can't be used after that.
+func (r *ServiceRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for name, entry := range r.services {
+		select {
+		case <-entry.ready:
+			if entry.service != nil {
+				entry.service.Close()
+			}
+		default:
+			// it's closed by the creator
+		}
+		delete(r.services, name)
+	}
+}
@@ -0,0 +1,59 @@
+package cocaine12
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestServiceRegistry(t *testing.T) {
+	var (
+		created int32
+		fail    int32 = 1
+	)
+
+	registry := NewServiceRegistry(nil)
+	registry.create = func(ctx context.Context, name string, endpoints []string) (*Service, error) {
+		if atomic.CompareAndSwapInt32(&fail, 1, 0) {
+			return nil, errors.New("unable to resolve")
+		}
+		atomic.AddInt32(&created, 1)
+		service, _ := newTestService(t, name)
+		return service, nil
+	}
+
+	// failures are not cached
+	_, err := registry.Get(context.Background(), "storage")
+	assert.Error(t, err)
+
+	var (
+		wg       sync.WaitGroup
+		services = make([]*Service, 10)
+	)
+	for i := range services {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			services[i], _ = registry.Get(context.Background(), "storage")
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created))
+	for _, service := range services {
+		assert.True(t, service == services[0])
+	}
+
+	other, err := registry.Get(context.Background(), "app")
+	assert.NoError(t, err)
+	assert.False(t, other == services[0])
+
+	registry.Close()
+	assert.True(t, services[0].disconnected())
+	_, err = registry.Get(context.Background(), "storage")
+	assert.Equal(t, ErrRegistryClosed, err)
+}
@@ -0,0 +1,157 @@
+package cocaine12
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultResolveCacheTTL = time.Second * 30
+	// resolveTimeout bounds a shared resolve, which doesn't
+	// depend on contexts of the callers waiting for it
+	resolveTimeout = time.Second * 10
+)
+
+var serviceResolveCache = newResolveCache(defaultResolveCacheTTL)
+
+// SetResolveCacheTTL sets how long results of resolving
+// services via locators are cached. Zero TTL disables caching,
+// but concurrent resolves of the same service are still coalesced.
+func SetResolveCacheTTL(ttl time.Duration) {
+	serviceResolveCache.setTTL(ttl)
+}
+
+// InvalidateResolveCache drops cached results of resolving the service
+func InvalidateResolveCache(name string) {
+	serviceResolveCache.invalidate(name)
+}
+
+type resolveFunc func(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error)
+
+type resolveKey struct {
+	name     string
+	locators string
+}
+
+type resolveEntry struct {
+	info      *ServiceInfo
+	expiresAt time.Time
+}
+
+type resolveCall struct {
+	done chan struct{}
+	info *ServiceInfo
+	err  error
+}
+
+// resolveCache caches ServiceInfo per service name and locators
+// and makes concurrent resolves of the same name share one request
+type resolveCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[resolveKey]resolveEntry
+	inflight map[resolveKey]*resolveCall
+}
+
+func newResolveCache(ttl time.Duration) *resolveCache {
+	return &resolveCache{
+		ttl:      ttl,
+		entries:  make(map[resolveKey]resolveEntry),
+		inflight: make(map[resolveKey]*resolveCall),
+	}
+}
+
+func (c *resolveCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	if ttl <= 0 {
+		c.entries = make(map[resolveKey]resolveEntry)
+	}
+	c.mu.Unlock()
+}
+
+func (c *resolveCache) resolve(ctx context.Context, name string, endpoints []string, resolve resolveFunc) (*ServiceInfo, error) {
+	if len(endpoints) == 0 {
+		// the shared resolve must ask the locators of the caller
+		endpoints = FromContext(ctx).Locators()
+	}
+	key := resolveKey{name, strings.Join(endpoints, ",")}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.info, nil
+	}
+
+	call, ok := c.inflight[key]
+	if !ok {
+		call = &resolveCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		// the resolve carries values of the caller who has started it,
+		// e.g. the trace, but not its cancellation
+		go c.do(detachedContext{ctx}, key, call, endpoints, resolve)
+	}
+	c.mu.Unlock()
+
+	// ctx limits only this caller's wait,
+	// others may still wait for the same resolve
+	select {
+	case <-call.done:
+		return call.info, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *resolveCache) do(parent context.Context, key resolveKey, call *resolveCall, endpoints []string, resolve resolveFunc) {
+	ctx, cancel := context.WithTimeout(parent, resolveTimeout)
+	call.info, call.err = resolve(ctx, key.name, endpoints)
+	cancel()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil && c.ttl > 0 {
+		c.entries[key] = resolveEntry{
+			info:      call.info,
+			expiresAt: time.Now().Add(c.ttl),
+		}
+	}
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+func (c *resolveCache) invalidate(name string) {
+	c.mu.Lock()
+	for key := range c.entries {
+		if key.name == name {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// detachedContext keeps values of the parent,
+// but not its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,130 @@
+package cocaine12
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestResolveCache(t *testing.T) {
+	var (
+		calls   int32
+		failure error
+		release = make(chan struct{})
+	)
+
+	resolve := func(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &ServiceInfo{Version: 1}, failure
+	}
+
+	c := newResolveCache(time.Hour)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := c.resolve(ctx, "storage", nil, resolve)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(1), info.Version)
+		}()
+	}
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "concurrent resolves must be coalesced")
+
+	_, err := c.resolve(ctx, "storage", nil, resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "result must be cached")
+
+	_, err = c.resolve(ctx, "storage", []string{"host:10053"}, resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "locators are a part of the key")
+
+	c.invalidate("storage")
+	failure = errors.New("resolve failure")
+	_, err = c.resolve(ctx, "storage", nil, resolve)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	_, err = c.resolve(ctx, "storage", nil, resolve)
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls), "errors must not be cached")
+}
+
+func TestResolveCacheFirstCallerCancelled(t *testing.T) {
+	release := make(chan struct{})
+	resolve := func(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error) {
+		select {
+		case <-release:
+			return &ServiceInfo{Version: 1}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c := newResolveCache(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.resolve(ctx, "storage", nil, resolve)
+		first <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.resolve(context.Background(), "storage", nil, resolve)
+		second <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-first)
+	close(release)
+	assert.NoError(t, <-second, "the shared resolve must outlive the first caller")
+}
+
+func TestResolveCacheContextLocators(t *testing.T) {
+	type traceKey struct{}
+
+	var (
+		mu       sync.Mutex
+		resolved = make(map[string]interface{})
+	)
+	resolve := func(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error) {
+		mu.Lock()
+		resolved[strings.Join(endpoints, ",")] = ctx.Value(traceKey{})
+		mu.Unlock()
+		return &ServiceInfo{Version: uint64(len(endpoints))}, nil
+	}
+
+	c := newResolveCache(time.Hour)
+	first := WithDefaults(context.WithValue(context.Background(), traceKey{}, "first"),
+		&defaultValues{locators: []string{"first:10053"}})
+	second := WithDefaults(context.WithValue(context.Background(), traceKey{}, "second"),
+		&defaultValues{locators: []string{"second:10053", "second:10054"}})
+
+	info, err := c.resolve(first, "storage", nil, resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), info.Version)
+
+	info, err = c.resolve(second, "storage", nil, resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), info.Version, "contexts bound to other locators must not share the entry")
+
+	assert.Equal(t, map[string]interface{}{
+		"first:10053":               "first",
+		"second:10053,second:10054": "second",
+	}, resolved, "the resolve must ask the locators of the caller with its values")
+}
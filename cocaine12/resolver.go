@@ -0,0 +1,301 @@
+package cocaine12
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Resolver finds endpoints and the API of a service by its name
+type Resolver interface {
+	Resolve(ctx context.Context, name string) (*ServiceInfo, error)
+}
+
+// ResolverFunc is an adapter to use ordinary functions as Resolver
+type ResolverFunc func(ctx context.Context, name string) (*ServiceInfo, error)
+
+// Resolve calls f(ctx, name)
+func (f ResolverFunc) Resolve(ctx context.Context, name string) (*ServiceInfo, error) {
+	return f(ctx, name)
+}
+
+var (
+	resolverMu sync.RWMutex
+	resolver   Resolver
+)
+
+// SetResolver makes services resolve via r instead of locators.
+// Nil restores resolving via locators.
+func SetResolver(r Resolver) {
+	resolverMu.Lock()
+	resolver = r
+	resolverMu.Unlock()
+}
+
+func getResolver() Resolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+	return resolver
+}
+
+type locatorResolver struct {
+	endpoints []string
+}
+
+// NewLocatorResolver creates a Resolver which asks the locators.
+// Default locators are used if none are passed.
+func NewLocatorResolver(endpoints []string) Resolver {
+	return &locatorResolver{endpoints: endpoints}
+}
+
+func (l *locatorResolver) Resolve(ctx context.Context, name string) (*ServiceInfo, error) {
+	return serviceResolveCache.resolve(ctx, name, l.endpoints, serviceResolve)
+}
+
+// resolveService uses the resolver set by SetResolver if any,
+// otherwise it asks the locators
+func resolveService(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error) {
+	if r := getResolver(); r != nil {
+		return r.Resolve(ctx, name)
+	}
+	return serviceResolveCache.resolve(ctx, name, endpoints, serviceResolve)
+}
+
+// Method describes a method of a service resolved without a locator
+type Method struct {
+	Name string
+	// Streaming methods accept chunks after the call and reply
+	// with a stream of chunks like `enqueue` of applications.
+	// Other ones reply with either a value or an error.
+	Streaming bool
+}
+
+// ParseMethods parses names of methods marking ones with the `*` suffix
+// as streaming, e.g. `enqueue*`. Empty names are skipped.
+func ParseMethods(names ...string) []Method {
+	var methods []Method
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		methods = append(methods, Method{
+			Name:      strings.TrimSuffix(name, "*"),
+			Streaming: strings.HasSuffix(name, "*"),
+		})
+	}
+	return methods
+}
+
+// appMethods is the API of Cocaine applications
+var appMethods = []Method{{Name: "enqueue", Streaming: true}}
+
+// NewServiceInfo describes a service with the methods
+// listening on the endpoints in host:port format.
+// The API of applications is used if no methods are passed.
+func NewServiceInfo(endpoints []string, methods ...Method) (*ServiceInfo, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrZeroEndpoints
+	}
+
+	items := make([]EndpointItem, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		portNum, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in %s: %v", endpoint, err)
+		}
+		items = append(items, EndpointItem{IP: host, Port: portNum})
+	}
+
+	if len(methods) == 0 {
+		methods = appMethods
+	}
+
+	api := make(dispatchMap, len(methods))
+	for i, method := range methods {
+		if method.Streaming {
+			api[uint64(i)] = dispatchItem{
+				Name:       method.Name,
+				Downstream: newStreamingDescription(),
+				Upstream:   newStreamingDescription(),
+			}
+			continue
+		}
+
+		api[uint64(i)] = dispatchItem{
+			Name:       method.Name,
+			Downstream: emptyDescription,
+			Upstream: &streamDescription{
+				0: &StreamDescriptionItem{Name: "value", Description: emptyDescription},
+				1: &StreamDescriptionItem{Name: "error", Description: emptyDescription},
+			},
+		}
+	}
+
+	return &ServiceInfo{
+		Endpoints: items,
+		Version:   1,
+		API:       api,
+	}, nil
+}
+
+func newStreamingDescription() *streamDescription {
+	return &streamDescription{
+		0: &StreamDescriptionItem{Name: "write", Description: recursiveDescription},
+		1: &StreamDescriptionItem{Name: "error", Description: emptyDescription},
+		2: &StreamDescriptionItem{Name: "close", Description: emptyDescription},
+	}
+}
+
+// StaticResolver resolves services to fixed endpoints
+type StaticResolver struct {
+	mu       sync.RWMutex
+	services map[string]*ServiceInfo
+}
+
+// NewStaticResolver creates an empty StaticResolver
+func NewStaticResolver() *StaticResolver {
+	return &StaticResolver{
+		services: make(map[string]*ServiceInfo),
+	}
+}
+
+// Add makes the service resolvable. See NewServiceInfo for arguments.
+func (s *StaticResolver) Add(name string, endpoints []string, methods ...Method) error {
+	info, err := NewServiceInfo(endpoints, methods...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.services[name] = info
+	s.mu.Unlock()
+	return nil
+}
+
+// Resolve returns the service added before
+func (s *StaticResolver) Resolve(ctx context.Context, name string) (*ServiceInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s is unknown", name)
+	}
+	return info, nil
+}
+
+// FileResolver resolves services listed in a hosts-like file:
+//
+//	# name     endpoints                       methods
+//	storage    127.0.0.1:10054,[::1]:10054     read write find
+//	echo       10.0.0.1:10060
+//
+// Endpoints are separated by commas. Streaming methods are marked by
+// the `*` suffix, e.g. `enqueue*`. If no methods are listed, the service
+// is an application. The file is reread when it's modified.
+type FileResolver struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	static  *StaticResolver
+}
+
+// NewFileResolver loads services from the file
+func NewFileResolver(path string) (*FileResolver, error) {
+	f := &FileResolver{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resolve returns the service listed in the file
+func (f *FileResolver) Resolve(ctx context.Context, name string) (*ServiceInfo, error) {
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	static := f.static
+	f.mu.Unlock()
+
+	return static.Resolve(ctx, name)
+}
+
+func (f *FileResolver) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	if f.static != nil && stat.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	static, err := parseResolverFile(file)
+	if err != nil {
+		return fmt.Errorf("%s: %v", f.path, err)
+	}
+
+	f.static, f.modTime = static, stat.ModTime()
+	return nil
+}
+
+func parseResolverFile(r io.Reader) (*StaticResolver, error) {
+	var (
+		static  = NewStaticResolver()
+		scanner = bufio.NewScanner(r)
+		lineno  = 0
+	)
+
+	for scanner.Scan() {
+		lineno++
+
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			return nil, fmt.Errorf("line %d: no endpoints for %s", lineno, fields[0])
+		}
+
+		methods := ParseMethods(fields[2:]...)
+		if err := static.Add(fields[0], strings.Split(fields[1], ","), methods...); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return static, nil
+}
@@ -0,0 +1,106 @@
+package cocaine12
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseResolverFile(t *testing.T) {
+	static, err := parseResolverFile(strings.NewReader(`
+# name     endpoints                       methods
+storage    127.0.0.1:10054,[::1]:10054     read write find*
+echo       10.0.0.1:10060  # an application
+`))
+	assert.NoError(t, err)
+
+	storage, err := static.Resolve(context.Background(), "storage")
+	assert.NoError(t, err)
+	assert.Equal(t, []EndpointItem{{"127.0.0.1", 10054}, {"::1", 10054}}, storage.Endpoints)
+	assert.Len(t, storage.API, 3)
+
+	find, _ := storage.API.MethodByName("find")
+	assert.Equal(t, otherDispatch, storage.API[find].Downstream.Type())
+	read, _ := storage.API.MethodByName("read")
+	assert.Equal(t, emptyDispatch, storage.API[read].Downstream.Type())
+
+	echo, err := static.Resolve(context.Background(), "echo")
+	assert.NoError(t, err)
+	_, err = echo.API.MethodByName("enqueue")
+	assert.NoError(t, err)
+
+	_, err = static.Resolve(context.Background(), "unknown")
+	assert.Error(t, err)
+
+	_, err = parseResolverFile(strings.NewReader("storage"))
+	assert.EqualError(t, err, "line 1: no endpoints for storage")
+
+	_, err = parseResolverFile(strings.NewReader("storage localhost:port"))
+	assert.Error(t, err)
+}
+
+func TestFileResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "services")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("echo 127.0.0.1:10060\n"), 0644))
+
+	resolver, err := NewFileResolver(path)
+	assert.NoError(t, err)
+
+	_, err = resolver.Resolve(context.Background(), "storage")
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("storage 127.0.0.1:10054 read\n"), 0644))
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, future, future))
+
+	info, err := resolver.Resolve(context.Background(), "storage")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10054), info.Endpoints[0].Port)
+}
+
+func TestServiceStaticResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("unable to listen", err)
+	}
+	defer ln.Close()
+
+	static := NewStaticResolver()
+	assert.NoError(t, static.Add("storage", []string{ln.Addr().String()}, Method{Name: "read"}))
+
+	SetResolver(static)
+	defer SetResolver(nil)
+
+	service, err := NewService(context.Background(), "storage", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer service.Close()
+
+	conn, err := ln.Accept()
+	if !assert.NoError(t, err) {
+		return
+	}
+	peer, _ := newAsyncRW(conn)
+	defer peer.Close()
+
+	_, err = service.Call(context.Background(), "read", "key")
+	assert.NoError(t, err)
+
+	msg := <-peer.Read()
+	assert.Equal(t, uint64(0), msg.MsgType)
+	assert.Equal(t, []interface{}{[]byte("key")}, msg.Payload)
+}
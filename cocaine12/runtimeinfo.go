@@ -0,0 +1,108 @@
+package cocaine12
+
+import (
+	"strings"
+)
+
+// Stock cocaine-runtime doesn't advertise itself. The headers below are
+// a convention for runtimes patched to do it, and features are gated
+// by them only if the application enables it with EnableRuntimeExtensions.
+const (
+	// RuntimeVersionHeader carries the version of cocaine-runtime
+	// in replies to heartbeats
+	RuntimeVersionHeader = "x-cocaine-version"
+	// RuntimeExtensionsHeader carries a comma separated list
+	// of extensions supported by cocaine-runtime
+	RuntimeExtensionsHeader = "x-cocaine-extensions"
+
+	// ExtensionHeaders means that the runtime passes headers
+	// of messages through
+	ExtensionHeaders = "headers"
+	// ExtensionSeal means that the runtime passes the close
+	// of an incoming stream to the worker
+	ExtensionSeal = "seal"
+	// ExtensionHuffman means that the runtime decodes Huffman
+	// coded header values, see HeaderTable.EncodeHuffman
+	ExtensionHuffman = "huffman"
+	// ExtensionUtilization means that the runtime accepts utilization
+	// messages. See WorkerNG.EnableLoadReport
+	ExtensionUtilization = "utilization"
+)
+
+// RuntimeInfo describes cocaine-runtime the worker is connected to.
+// It's learnt from headers of the reply to a heartbeat.
+type RuntimeInfo struct {
+	// Known is false until the runtime advertises itself.
+	// Old runtimes never do it.
+	Known      bool
+	Version    string
+	Extensions []string
+}
+
+// Supports reports whether the runtime has advertised the extension
+func (i RuntimeInfo) Supports(extension string) bool {
+	for _, ext := range i.Extensions {
+		if ext == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether an optional feature depending on the extension
+// may be used. Runtimes which don't advertise extensions are trusted
+// to support features enabled by the application.
+func (i RuntimeInfo) allows(extension string) bool {
+	return !i.Known || i.Supports(extension)
+}
+
+// parseRuntimeInfo returns false if headers don't advertise the runtime
+func parseRuntimeInfo(headers CocaineHeaders) (RuntimeInfo, bool) {
+	if len(headers) == 0 {
+		return RuntimeInfo{}, false
+	}
+
+	version, hasVersion := findHeader(headers, RuntimeVersionHeader)
+	extensions, hasExtensions := findHeader(headers, RuntimeExtensionsHeader)
+	if !hasVersion && !hasExtensions {
+		return RuntimeInfo{}, false
+	}
+
+	info := RuntimeInfo{
+		Known:   true,
+		Version: string(version),
+	}
+	for _, ext := range strings.Split(string(extensions), ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			info.Extensions = append(info.Extensions, ext)
+		}
+	}
+	return info, true
+}
+
+// EnableRuntimeExtensions makes the worker trust extensions advertised
+// by the runtime: optional features are not used if the runtime advertises
+// that it doesn't support them. It's disabled by default, so features
+// enabled by the application are used whatever the runtime advertises.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) EnableRuntimeExtensions(enable bool) {
+	w.runtimeExtensionsEnabled = enable
+}
+
+// runtimeAllows reports whether an optional feature depending
+// on the extension may be used
+func (w *WorkerNG) runtimeAllows(extension string) bool {
+	return !w.runtimeExtensionsEnabled || w.RuntimeInfo().allows(extension)
+}
+
+// RuntimeInfo returns what the runtime has advertised about itself
+func (w *WorkerNG) RuntimeInfo() RuntimeInfo {
+	info, _ := w.runtimeInfo.Load().(RuntimeInfo)
+	return info
+}
+
+func (w *WorkerNG) learnRuntimeInfo(msg *Message) {
+	if info, ok := parseRuntimeInfo(msg.Headers); ok {
+		w.runtimeInfo.Store(info)
+	}
+}
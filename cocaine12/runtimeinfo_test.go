@@ -0,0 +1,91 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseRuntimeInfo(t *testing.T) {
+	_, ok := parseRuntimeInfo(nil)
+	assert.False(t, ok)
+
+	info, ok := parseRuntimeInfo(DefaultHeaderTable.Encode([]Header{
+		{Name: RuntimeVersionHeader, Value: []byte("0.12.14")},
+		{Name: RuntimeExtensionsHeader, Value: []byte("headers, seal")},
+	}))
+	assert.True(t, ok)
+	assert.Equal(t, RuntimeInfo{
+		Known:      true,
+		Version:    "0.12.14",
+		Extensions: []string{ExtensionHeaders, ExtensionSeal},
+	}, info)
+	assert.True(t, info.Supports(ExtensionSeal))
+	assert.False(t, info.allows(ExtensionUtilization))
+
+	// old runtimes are trusted
+	assert.False(t, RuntimeInfo{}.Supports(ExtensionUtilization))
+	assert.True(t, RuntimeInfo{}.allows(ExtensionUtilization))
+}
+
+func TestWorkerRuntimeInfoGatesLoadReport(t *testing.T) {
+	testWorkerRuntimeInfoLoadReport(t, true)
+}
+
+func TestWorkerRuntimeInfoIgnoredByDefault(t *testing.T) {
+	testWorkerRuntimeInfoLoadReport(t, false)
+}
+
+func testWorkerRuntimeInfoLoadReport(t *testing.T, extensions bool) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.EnableLoadReport(true)
+	w.EnableRuntimeExtensions(extensions)
+
+	go w.Run(map[string]EventHandler{
+		"test": func(ctx context.Context, req Request, res Response) {
+			res.Close()
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Utilization)
+	assert.False(t, w.RuntimeInfo().Known)
+
+	reply := newHeartbeatV1()
+	reply.Headers = DefaultHeaderTable.Encode([]Header{
+		{Name: RuntimeVersionHeader, Value: []byte("0.12.14")},
+		{Name: RuntimeExtensionsHeader, Value: []byte("headers")},
+	})
+	sock2.Write() <- reply
+
+	deadline := time.Now().Add(time.Second)
+	for !w.RuntimeInfo().Known && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, "0.12.14", w.RuntimeInfo().Version)
+
+	clock.Advance(heartbeatTimeout)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+	if !extensions {
+		// the advertised extensions are not trusted
+		checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Utilization)
+	}
+
+	// the runtime doesn't support utilization messages,
+	// so the reply comes next
+	sock2.Write() <- newInvokeV1(2, "test")
+	checkTypeAndSession(t, <-sock2.Read(), 2, v1Close)
+}
@@ -0,0 +1,120 @@
+package cocaine12
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SamplingParams describes a request the sampling decision is made for
+type SamplingParams struct {
+	// Event is the name of the invoked event
+	Event string
+	// Parent is trace info received with the request.
+	// It's nil if the request is not traced.
+	Parent *TraceInfo
+}
+
+// Sampler decides whether a request must be traced.
+// If a request is sampled, trace headers are propagated
+// to downstream calls made with the handler's context,
+// so the decision is shared along the whole call chain.
+type Sampler interface {
+	ShouldSample(params SamplingParams) bool
+}
+
+// SamplerFunc is an adapter to use ordinary functions as Sampler
+type SamplerFunc func(params SamplingParams) bool
+
+// ShouldSample calls f(params)
+func (f SamplerFunc) ShouldSample(params SamplingParams) bool {
+	return f(params)
+}
+
+// AlwaysSample returns Sampler which traces every request
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(SamplingParams) bool { return true })
+}
+
+// NeverSample returns Sampler which traces nothing
+func NeverSample() Sampler {
+	return SamplerFunc(func(SamplingParams) bool { return false })
+}
+
+type probabilitySampler struct {
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	fraction float64
+}
+
+// ProbabilitySampler returns Sampler which traces the given fraction of requests
+func ProbabilitySampler(fraction float64) Sampler {
+	return &probabilitySampler{
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		fraction: fraction,
+	}
+}
+
+func (p *probabilitySampler) ShouldSample(SamplingParams) bool {
+	if p.fraction <= 0 {
+		return false
+	}
+	if p.fraction >= 1 {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rnd.Float64() < p.fraction
+}
+
+type rateLimitingSampler struct {
+	mu         sync.Mutex
+	perSecond  float64
+	balance    float64
+	lastUpdate time.Time
+}
+
+// RateLimitingSampler returns Sampler which traces
+// no more than perSecond requests per second
+func RateLimitingSampler(perSecond float64) Sampler {
+	return &rateLimitingSampler{
+		perSecond:  perSecond,
+		balance:    perSecond,
+		lastUpdate: time.Now(),
+	}
+}
+
+func (r *rateLimitingSampler) ShouldSample(SamplingParams) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.balance += now.Sub(r.lastUpdate).Seconds() * r.perSecond
+	if r.balance > r.perSecond {
+		r.balance = r.perSecond
+	}
+	r.lastUpdate = now
+
+	if r.balance < 1 {
+		return false
+	}
+
+	r.balance--
+	return true
+}
+
+// ParentBasedSampler returns Sampler which respects the decision
+// of the caller for traced requests and consults root for others
+func ParentBasedSampler(root Sampler) Sampler {
+	return SamplerFunc(func(params SamplingParams) bool {
+		if params.Parent != nil {
+			return true
+		}
+		return root.ShouldSample(params)
+	})
+}
+
+// the behavior of the worker prior to samplers:
+// only requests traced by the caller are traced
+var defaultSampler = ParentBasedSampler(NeverSample())
@@ -0,0 +1,29 @@
+package cocaine12
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplers(t *testing.T) {
+	var (
+		untraced = SamplingParams{Event: "event"}
+		traced   = SamplingParams{Event: "event", Parent: &TraceInfo{trace: 1, span: 1}}
+	)
+
+	assert.True(t, AlwaysSample().ShouldSample(untraced))
+	assert.False(t, NeverSample().ShouldSample(traced))
+
+	assert.True(t, ProbabilitySampler(1).ShouldSample(untraced))
+	assert.False(t, ProbabilitySampler(0).ShouldSample(untraced))
+
+	rl := RateLimitingSampler(2)
+	assert.True(t, rl.ShouldSample(untraced))
+	assert.True(t, rl.ShouldSample(untraced))
+	assert.False(t, rl.ShouldSample(untraced), "limit must be exceeded")
+
+	assert.True(t, defaultSampler.ShouldSample(traced))
+	assert.False(t, defaultSampler.ShouldSample(untraced))
+	assert.True(t, ParentBasedSampler(AlwaysSample()).ShouldSample(untraced))
+}
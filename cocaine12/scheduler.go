@@ -0,0 +1,231 @@
+package cocaine12
+
+import (
+	"golang.org/x/net/context"
+)
+
+// Priority defines the order in which queued handlers are started
+type Priority int
+
+const (
+	// PriorityLow is intended for bulk processing
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the default priority of events
+	PriorityNormal
+	// PriorityHigh is intended for control events,
+	// e.g. health checks or cache invalidation
+	PriorityHigh
+
+	priorityLanes = 3
+)
+
+// EventOption configures how an event is dispatched
+type EventOption func(*eventOptions)
+
+type eventOptions struct {
+	priority Priority
+	limits   PayloadLimits
+}
+
+// WithPriority makes handlers of the event start ahead of ones
+// with lower priorities when the worker is at SetMaxConcurrency
+func WithPriority(priority Priority) EventOption {
+	return func(o *eventOptions) {
+		o.priority = priority
+	}
+}
+
+// FairnessKeyFunc identifies the client which has sent a request,
+// e.g. by a header from the incoming metadata of ctx
+type FairnessKeyFunc func(ctx context.Context, event string) string
+
+// FairByHeader identifies clients by the value of the header.
+// Requests without the header are treated as sent by the same client.
+func FairByHeader(name string) FairnessKeyFunc {
+	return func(ctx context.Context, event string) string {
+		md, _ := FromIncomingContext(ctx)
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+}
+
+// fairLane starts handlers of different clients in turn,
+// handlers of the same client are started in FIFO order
+type fairLane struct {
+	flows map[string][]func()
+	// clients having queued handlers in round-robin order
+	order []string
+}
+
+func (l *fairLane) push(key string, start func()) {
+	if l.flows == nil {
+		l.flows = make(map[string][]func())
+	}
+
+	if len(l.flows[key]) == 0 {
+		l.order = append(l.order, key)
+	}
+	l.flows[key] = append(l.flows[key], start)
+}
+
+func (l *fairLane) pop() (func(), bool) {
+	if len(l.order) == 0 {
+		return nil, false
+	}
+
+	key := l.order[0]
+	l.order = l.order[1:]
+
+	flow := l.flows[key]
+	start := flow[0]
+	flow[0] = nil
+	if flow = flow[1:]; len(flow) > 0 {
+		l.flows[key] = flow
+		// the client goes to the end of the line
+		l.order = append(l.order, key)
+	} else {
+		delete(l.flows, key)
+	}
+	return start, true
+}
+
+// dispatchQueue keeps handlers waiting for a free slot.
+// It's used by the loop goroutine only.
+type dispatchQueue struct {
+	lanes [priorityLanes]fairLane
+	size  int
+}
+
+func (q *dispatchQueue) Len() int {
+	return q.size
+}
+
+func (q *dispatchQueue) push(priority Priority, key string, start func()) {
+	q.lanes[q.lane(priority)].push(key, start)
+	q.size++
+}
+
+// pop returns the next handler of the highest priority
+func (q *dispatchQueue) pop() (func(), bool) {
+	for lane := priorityLanes - 1; lane >= 0; lane-- {
+		if start, ok := q.lanes[lane].pop(); ok {
+			q.size--
+			return start, true
+		}
+	}
+	return nil, false
+}
+
+func (q *dispatchQueue) lane(priority Priority) int {
+	switch {
+	case priority < PriorityLow:
+		priority = PriorityLow
+	case priority > PriorityHigh:
+		priority = PriorityHigh
+	}
+	return int(priority - PriorityLow)
+}
+
+// SetMaxConcurrency limits the number of handlers running at once.
+// Other requests wait in the queue and are started in order of priorities
+// of their events, see WithPriority. Zero disables the limit.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetMaxConcurrency(n int) {
+	w.maxConcurrency = n
+}
+
+// SetFairnessKey makes the worker start queued handlers of the same
+// priority round-robin across clients identified by key instead of FIFO,
+// so a client flooding the worker doesn't starve others. See FairByHeader.
+// It takes effect with SetMaxConcurrency only.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetFairnessKey(key FairnessKeyFunc) {
+	w.fairnessKey = key
+}
+
+// SetEventPriority sets the priority of the event. See WithPriority.
+// It takes effect for requests arriving afterwards.
+func (w *WorkerNG) SetEventPriority(event string, priority Priority) {
+	w.optionsMu.Lock()
+	options := w.options[event]
+	options.priority = priority
+	w.options[event] = options
+	w.optionsMu.Unlock()
+}
+
+func (w *WorkerNG) setEventOptions(event string, options eventOptions) {
+	w.optionsMu.Lock()
+	w.options[event] = options
+	w.optionsMu.Unlock()
+}
+
+func (w *WorkerNG) unsetEventOptions(event string) {
+	w.optionsMu.Lock()
+	delete(w.options, event)
+	w.optionsMu.Unlock()
+}
+
+// eventOptions returns options of the event
+// or of the most specific pattern matching it
+func (w *WorkerNG) eventOptions(event string) eventOptions {
+	w.optionsMu.RLock()
+	defer w.optionsMu.RUnlock()
+	if options, ok := w.options[event]; ok {
+		return options
+	}
+
+	var (
+		options eventOptions
+		longest = -1
+	)
+	for name, o := range w.options {
+		if isEventPattern(name) && matchEventPattern(name, event) && len(name) > longest {
+			options, longest = o, len(name)
+		}
+	}
+	return options
+}
+
+func (w *WorkerNG) eventPriority(event string) Priority {
+	return w.eventOptions(event).priority
+}
+
+// schedule starts the handler at once or queues it
+// if the worker is at the concurrency limit
+func (w *WorkerNG) schedule(ctx context.Context, event string, start func()) {
+	if w.maxConcurrency <= 0 {
+		start()
+		return
+	}
+
+	var key string
+	if w.fairnessKey != nil {
+		key = w.fairnessKey(ctx, event)
+	}
+
+	w.queue.push(w.eventPriority(event), key, start)
+	w.dispatchQueued()
+}
+
+func (w *WorkerNG) dispatchQueued() {
+	for w.running < w.maxConcurrency {
+		start, ok := w.queue.pop()
+		if !ok {
+			return
+		}
+
+		w.running++
+		start()
+	}
+}
+
+// notifyHandlerDone is called by a handler goroutine
+// to let the loop start the next queued handler
+func (w *WorkerNG) notifyHandlerDone() {
+	select {
+	case w.handlerDone <- struct{}{}:
+	case <-w.stopped:
+	}
+}
@@ -0,0 +1,123 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestDispatchQueue(t *testing.T) {
+	var (
+		q       dispatchQueue
+		started []string
+	)
+
+	for _, item := range []struct {
+		name     string
+		priority Priority
+	}{
+		{"bulk", PriorityLow},
+		{"normal", PriorityNormal},
+		{"health", PriorityHigh},
+		{"bulk2", PriorityLow - 1},
+		{"invalidate", PriorityHigh},
+	} {
+		name := item.name
+		q.push(item.priority, "", func() { started = append(started, name) })
+	}
+	assert.Equal(t, 5, q.Len())
+
+	for start, ok := q.pop(); ok; start, ok = q.pop() {
+		start()
+	}
+	assert.Equal(t, []string{"health", "invalidate", "normal", "bulk", "bulk2"}, started)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestWorkerPriorities(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	w.SetMaxConcurrency(1)
+
+	var (
+		release = make(chan struct{})
+		started = make(chan string, 3)
+	)
+
+	handler := func(ctx context.Context, req Request, res Response) {
+		res.Close()
+	}
+	w.On("block", func(ctx context.Context, req Request, res Response) {
+		started <- "block"
+		<-release
+		res.Close()
+	})
+	w.On("bulk", func(ctx context.Context, req Request, res Response) {
+		started <- "bulk"
+		handler(ctx, req, res)
+	}, WithPriority(PriorityLow))
+	w.On("health", func(ctx context.Context, req Request, res Response) {
+		started <- "health"
+		handler(ctx, req, res)
+	}, WithPriority(PriorityHigh))
+
+	go w.Run(nil)
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "block")
+	assert.Equal(t, "block", <-started)
+
+	sock2.Write() <- newInvokeV1(3, "bulk")
+	sock2.Write() <- newInvokeV1(4, "health")
+	// wait for both requests to be queued
+	deadline := time.Now().Add(time.Second)
+	for w.Load().QueueDepth < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	assert.Equal(t, "health", <-started)
+	assert.Equal(t, "bulk", <-started)
+}
+
+func TestDispatchQueueFairness(t *testing.T) {
+	var (
+		q       dispatchQueue
+		started []string
+	)
+
+	for _, item := range []struct{ client, name string }{
+		{"noisy", "n1"},
+		{"noisy", "n2"},
+		{"noisy", "n3"},
+		{"quiet", "q1"},
+		{"other", "o1"},
+		{"quiet", "q2"},
+	} {
+		name := item.name
+		q.push(PriorityNormal, item.client, func() { started = append(started, name) })
+	}
+
+	for start, ok := q.pop(); ok; start, ok = q.pop() {
+		start()
+	}
+	assert.Equal(t, []string{"n1", "q1", "o1", "n2", "q2", "n3"}, started)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestFairByHeader(t *testing.T) {
+	key := FairByHeader("X-Client")
+	ctx := NewIncomingContext(context.Background(), Pairs("x-client", "tenant"))
+	assert.Equal(t, "tenant", key(ctx, "event"))
+	assert.Equal(t, "", key(context.Background(), "event"))
+}
@@ -0,0 +1,305 @@
+// Package sentry provides a cocaine12.ErrorReporter which sends
+// failures of a worker to Sentry
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const (
+	sentryVersion = 7
+	clientName    = "cocaine-framework-go/sentry"
+
+	defaultTimeout = time.Second * 5
+
+	frameworkPrefix = "github.com/cocaine/cocaine-framework-go/"
+)
+
+// Config configures Reporter
+type Config struct {
+	// DSN of a Sentry project, e.g. https://public@sentry.example.com/1
+	DSN string
+	// Release and Environment are attached to every event
+	Release     string
+	Environment string
+	// ServerName is the hostname by default
+	ServerName string
+	// Tags are attached to every event
+	Tags map[string]string
+
+	HTTPClient *http.Client
+}
+
+// Reporter sends reports to Sentry in background.
+// Call Flush before the process exits to deliver pending events.
+type Reporter struct {
+	config   Config
+	storeURL string
+	auth     string
+
+	wg sync.WaitGroup
+}
+
+// New creates Reporter for the DSN of config
+func New(config Config) (*Reporter, error) {
+	dsn, err := url.Parse(config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %v", err)
+	}
+
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return nil, fmt.Errorf("invalid DSN: no public key")
+	}
+
+	slash := strings.LastIndex(dsn.Path, "/")
+	if slash < 0 || slash == len(dsn.Path)-1 {
+		return nil, fmt.Errorf("invalid DSN: no project id")
+	}
+	path, project := dsn.Path[:slash], dsn.Path[slash+1:]
+
+	auth := fmt.Sprintf("Sentry sentry_version=%d, sentry_client=%s, sentry_key=%s",
+		sentryVersion, clientName, dsn.User.Username())
+	if secret, ok := dsn.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	if config.ServerName == "" {
+		config.ServerName, _ = os.Hostname()
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Reporter{
+		config:   config,
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", dsn.Scheme, dsn.Host, path, project),
+		auth:     auth,
+	}, nil
+}
+
+// Report sends the report asynchronously
+func (r *Reporter) Report(ctx context.Context, report *cocaine.ErrorReport) {
+	body, err := json.Marshal(r.newEvent(report))
+	if err != nil {
+		fmt.Printf("unable to encode an event for Sentry: %v\n", err)
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.send(body); err != nil {
+			fmt.Printf("unable to send an event to Sentry: %v\n", err)
+		}
+	}()
+}
+
+// Flush waits for pending events to be sent.
+// It returns false if the timeout has expired.
+func (r *Reporter) Flush(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (r *Reporter) send(body []byte) error {
+	req, err := http.NewRequest("POST", r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := ctxhttp.Do(context.Background(), r.config.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Sentry replied %s", resp.Status)
+	}
+	return nil
+}
+
+type event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Logger      string                 `json:"logger"`
+	Platform    string                 `json:"platform"`
+	Message     string                 `json:"message"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Exception   *exceptions            `json:"exception,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+func (r *Reporter) newEvent(report *cocaine.ErrorReport) *event {
+	ev := &event{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Level:       "error",
+		Logger:      "cocaine",
+		Platform:    "go",
+		ServerName:  r.config.ServerName,
+		Release:     r.config.Release,
+		Environment: r.config.Environment,
+		Tags:        map[string]string{"kind": report.Kind.String()},
+		Extra:       make(map[string]interface{}),
+	}
+
+	if report.Err != nil {
+		ev.Message = report.Err.Error()
+	}
+	if report.Kind == cocaine.ErrorKindDisown {
+		// the worker exits
+		ev.Level = "fatal"
+	}
+
+	for key, value := range r.config.Tags {
+		ev.Tags[key] = value
+	}
+	if report.Event != "" {
+		ev.Tags["event"] = report.Event
+	}
+	if report.TraceInfo != nil {
+		ev.Tags["trace_id"] = fmt.Sprintf("%x", report.TraceInfo.TraceID())
+		ev.Extra["span_id"] = fmt.Sprintf("%x", report.TraceInfo.SpanID())
+		ev.Extra["parent_id"] = fmt.Sprintf("%x", report.TraceInfo.ParentID())
+	}
+	if report.Session != 0 {
+		ev.Extra["session"] = report.Session
+	}
+	if len(report.Metadata) > 0 {
+		ev.Extra["headers"] = report.Metadata
+	}
+
+	exc := exception{
+		Type:  report.Kind.String(),
+		Value: ev.Message,
+	}
+	if frames := parseStack(report.Stack); len(frames) > 0 {
+		exc.Stacktrace = &stacktrace{Frames: frames}
+	}
+	ev.Exception = &exceptions{Values: []exception{exc}}
+
+	return ev
+}
+
+// parseStack converts a stack trace formatted by runtime.Stack
+// to Sentry frames ordered from the oldest call
+func parseStack(stack []byte) []frame {
+	var (
+		frames   []frame
+		function string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(stack))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "), line == "":
+			function = ""
+
+		case strings.HasPrefix(line, "\t"):
+			if function == "" {
+				continue
+			}
+
+			location := strings.TrimSpace(line)
+			if offset := strings.LastIndex(location, " +0x"); offset >= 0 {
+				location = location[:offset]
+			}
+
+			colon := strings.LastIndex(location, ":")
+			if colon < 0 {
+				continue
+			}
+			lineno, _ := strconv.Atoi(location[colon+1:])
+
+			frames = append(frames, frame{
+				Function: function,
+				Filename: location[:colon],
+				Lineno:   lineno,
+				InApp:    isInApp(function),
+			})
+			function = ""
+
+		default:
+			function = strings.TrimPrefix(line, "created by ")
+			if in := strings.Index(function, " in goroutine "); in >= 0 {
+				function = function[:in]
+			}
+			if args := strings.LastIndex(function, "("); args > 0 {
+				function = function[:args]
+			}
+		}
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+func isInApp(function string) bool {
+	return !strings.HasPrefix(function, "runtime.") &&
+		!strings.HasPrefix(function, "runtime/") &&
+		!strings.HasPrefix(function, frameworkPrefix)
+}
+
+func newEventID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,93 @@
+package sentry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const testStack = `goroutine 7 [running]:
+runtime/debug.Stack()
+	/usr/lib/go/src/runtime/debug/stack.go:24 +0x5e
+main.handler({0x1, 0x2}, 0xc000010000)
+	/app/main.go:12 +0x25
+created by main.serve in goroutine 1
+	/app/main.go:30 +0x8a
+`
+
+func TestParseStack(t *testing.T) {
+	assert.Equal(t, []frame{
+		{Function: "main.serve", Filename: "/app/main.go", Lineno: 30, InApp: true},
+		{Function: "main.handler", Filename: "/app/main.go", Lineno: 12, InApp: true},
+		{Function: "runtime/debug.Stack", Filename: "/usr/lib/go/src/runtime/debug/stack.go", Lineno: 24},
+	}, parseStack([]byte(testStack)))
+}
+
+func TestNewInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"https://sentry.example.com/1",
+		"https://public@sentry.example.com/",
+	} {
+		_, err := New(Config{DSN: dsn})
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var (
+		auth   string
+		path   string
+		posted event
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("X-Sentry-Auth"), r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "://", "://public:secret@", 1) + "/sentry/42"
+	reporter, err := New(Config{DSN: dsn, Release: "1.0", Tags: map[string]string{"app": "echo"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	traceInfo := cocaine.NewTraceInfo(0xabc, 0xdef, 0)
+	reporter.Report(context.Background(), &cocaine.ErrorReport{
+		Kind:      cocaine.ErrorKindPanic,
+		Err:       errors.New("oops"),
+		Stack:     []byte(testStack),
+		Event:     "ping",
+		Session:   2,
+		Metadata:  cocaine.Pairs("x-request-id", "1"),
+		TraceInfo: &traceInfo,
+	})
+	assert.True(t, reporter.Flush(time.Second))
+
+	assert.Equal(t, "/sentry/api/42/store/", path)
+	assert.Contains(t, auth, "sentry_key=public")
+	assert.Contains(t, auth, "sentry_secret=secret")
+
+	assert.Len(t, posted.EventID, 32)
+	assert.Equal(t, "oops", posted.Message)
+	assert.Equal(t, "1.0", posted.Release)
+	assert.Equal(t, map[string]string{
+		"app": "echo", "kind": "panic", "event": "ping", "trace_id": "abc",
+	}, posted.Tags)
+	assert.Equal(t, map[string]interface{}{"x-request-id": []interface{}{"1"}}, posted.Extra["headers"])
+	if assert.Len(t, posted.Exception.Values, 1) {
+		assert.Equal(t, "panic", posted.Exception.Values[0].Type)
+		assert.Len(t, posted.Exception.Values[0].Stacktrace.Frames, 3)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
@@ -61,6 +62,8 @@ type serviceRes struct {
 	payload []interface{}
 	method  uint64
 	err     error
+	// headers of the message, e.g. ErrorDetailsHeader
+	headers CocaineHeaders
 }
 
 //Unpacks the result of the called method in the passed structure.
@@ -124,6 +127,21 @@ type Service struct {
 	sessions *sessions
 	stop     chan struct{}
 
+	credentials CredentialsProvider
+	mirror      *mirror
+
+	// watermarks of queues of received results per channel
+	rxHighWatermark int
+	rxLowWatermark  int
+
+	// default timeouts of calls by the method name
+	methodTimeouts map[string]time.Duration
+
+	// new calls of a method wait until the time
+	// hinted by RetryAfterHeader in a reply to its call
+	backoffMu sync.Mutex
+	backoffs  map[string]time.Time
+
 	args []string
 	name string
 
@@ -133,14 +151,39 @@ type Service struct {
 
 //Creates new service instance with specifed name.
 //Optional parameter is a network endpoint of the locator (default ":10053"). Look at Locator.
+//If a locator fails to answer, the next one is tried.
 func serviceResolve(ctx context.Context, name string, endpoints []string) (*ServiceInfo, error) {
-	l, err := NewLocator(endpoints)
-	if err != nil {
-		return nil, err
+	if len(endpoints) == 0 {
+		endpoints = FromContext(ctx).Locators()
 	}
-	defer l.Close()
 
-	return l.Resolve(ctx, name)
+	candidates := locatorsHealth.order(endpoints)
+	for {
+		l, err := newLocator(candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := l.Resolve(ctx, name)
+		l.Close()
+		if err == nil {
+			locatorsHealth.markHealthy(l.endpoint)
+			return info, nil
+		}
+
+		// the locator has replied with an error
+		// or the caller is not interested anymore,
+		// so there is no reason to ask others
+		if _, isReply := err.(*ErrRequest); isReply || ctx.Err() != nil {
+			return nil, err
+		}
+
+		locatorsHealth.markFailed(l.endpoint)
+		candidates = removeEndpoint(candidates, l.endpoint)
+		if len(candidates) == 0 {
+			return nil, err
+		}
+	}
 }
 
 func serviceCreateIO(endpoints []EndpointItem) (socketIO, error) {
@@ -148,28 +191,34 @@ func serviceCreateIO(endpoints []EndpointItem) (socketIO, error) {
 		return nil, ErrZeroEndpoints
 	}
 
-	var mErr = make(MultiConnectionError, 0)
+	endpoints = interleaveFamilies(endpoints)
+	addresses := make([]string, 0, len(endpoints))
 	for _, endpoint := range endpoints {
-		sock, err := newAsyncConnection("tcp", endpoint.String(), time.Second*1)
-		if err != nil {
-			mErr = append(mErr, ConnectionError{endpoint, err})
-			continue
-		}
+		addresses = append(addresses, endpoint.String())
+	}
 
+	sock, _, errs := dialHappyEyeballs("tcp", addresses, time.Second*1, happyEyeballsDelay)
+	if sock != nil {
 		return sock, nil
 	}
 
+	var mErr = make(MultiConnectionError, 0, len(errs))
+	for i, err := range errs {
+		mErr = append(mErr, ConnectionError{endpoints[i], err})
+	}
+
 	return nil, mErr
 }
 
 func NewService(ctx context.Context, name string, endpoints []string) (s *Service, err error) {
-	info, err := serviceResolve(ctx, name, endpoints)
+	info, err := resolveService(ctx, name, endpoints)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to resolve service %s: %v", name, err)
 	}
 
 	sock, err := serviceCreateIO(info.Endpoints)
 	if err != nil {
+		serviceResolveCache.invalidate(name)
 		return nil, fmt.Errorf("Unable to connect to service %s: %s", name, err)
 	}
 
@@ -189,19 +238,40 @@ func NewService(ctx context.Context, name string, endpoints []string) (s *Servic
 
 func (service *Service) loop() {
 	epoch := service.epoch
+	sock := service.socketIO
 
-	for data := range service.socketIO.Read() {
+	for data := range sock.Read() {
 		if rx, ok := service.sessions.Get(data.Session); ok {
-			rx.push(&serviceRes{
+			service.learnBackoff(rx, data)
+			if err := decodePayload(data); err != nil {
+				rx.push(&serviceRes{
+					method: data.MsgType,
+					err:    err,
+				})
+				continue
+			}
+
+			resume := rx.push(&serviceRes{
 				payload: data.Payload,
 				method:  data.MsgType,
+				headers: data.Headers,
 			})
+			if resume != nil {
+				// the connection is not read until the channel
+				// is drained, so the peer is throttled by TCP
+				select {
+				case <-resume:
+				case <-sock.IsClosed():
+				}
+			}
 		}
 	}
 
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
 	if epoch == service.epoch {
+		// the service might have been moved
+		serviceResolveCache.invalidate(service.name)
 		service.pushDisconnectedError()
 	}
 }
@@ -219,13 +289,18 @@ func (service *Service) Reconnect(ctx context.Context, force bool) error {
 
 	service.pushDisconnectedError()
 
+	// Addresses of locators might have been changed
+	locatorHosts.invalidate()
+	serviceResolveCache.invalidate(service.name)
+
 	// Create new socket
-	info, err := serviceResolve(ctx, service.name, service.args)
+	info, err := resolveService(ctx, service.name, service.args)
 	if err != nil {
 		return err
 	}
 	sock, err := serviceCreateIO(info.Endpoints)
 	if err != nil {
+		serviceResolveCache.invalidate(service.name)
 		return err
 	}
 
@@ -238,24 +313,29 @@ func (service *Service) Reconnect(ctx context.Context, force bool) error {
 	service.socketIO = sock
 	// Start service loop
 	go service.loop()
+	atomic.AddUint64(&totalReconnects, 1)
 	return nil
 }
 
 func (service *Service) pushDisconnectedError() {
 	for _, key := range service.sessions.Keys() {
-		service.sessions.RLock()
 		if ch, ok := service.sessions.Get(key); ok {
 			ch.push(&serviceRes{
 				payload: nil,
 				method:  1,
 				err:     &ServiceError{ErrDisconnected, "Disconnected"}})
 		}
-		service.sessions.RUnlock()
 		service.sessions.Detach(key)
 	}
 }
 
 func (service *Service) call(ctx context.Context, name string, args ...interface{}) (Channel, error) {
+	return service.callNotify(ctx, nil, name, args...)
+}
+
+// callNotify calls the method making the channel call notify
+// after every result is pushed to it
+func (service *Service) callNotify(ctx context.Context, notify func(), name string, args ...interface{}) (Channel, error) {
 	service.mutex.RLock()
 	defer service.mutex.RUnlock()
 
@@ -308,13 +388,49 @@ func (service *Service) call(ctx context.Context, name string, args ...interface
 		}
 	}
 
+	timeout, hasDeadline := timeoutHeader(ctx)
+	if md, ok := FromOutgoingContext(ctx); ok {
+		if hasDeadline && len(md.Get(TimeoutHeader)) > 0 {
+			// the deadline of ctx is more accurate
+			md = md.Copy()
+			delete(md, TimeoutHeader)
+		}
+		headers = append(headers, DefaultHeaderTable.Encode(md.headers())...)
+	}
+
+	if hasDeadline {
+		headers = append(headers, DefaultHeaderTable.Encode([]Header{timeout})...)
+	}
+
+	credentials := service.credentials
+	if credentials == nil {
+		credentials = getCredentialsProvider()
+	}
+
+	if credentials != nil {
+		auth, err := credentials.Credentials(ctx, service.name)
+		if err != nil {
+			traceCall()
+			return nil, fmt.Errorf("unable to get credentials for %s: %v", service.name, err)
+		}
+		headers = append(headers, DefaultHeaderTable.Encode(auth)...)
+	}
+
 	ch := channel{
 		traceReceived: traceReceivedCall,
 		traceSent:     traceSentCall,
+		notify:        notify,
+		method:        name,
+		ctx:           ctx,
 		rx: rx{
 			pushBuffer: make(chan ServiceResult, 1),
 			rxTree:     service.ServiceInfo.API[methodNum].Upstream,
 			done:       false,
+
+			highWatermark: service.rxHighWatermark,
+			lowWatermark:  service.rxLowWatermark,
+
+			deadline: service.methodDeadline(ctx, name),
 		},
 		tx: tx{
 			service: service,
@@ -357,19 +473,62 @@ func (service *Service) sendMsg(msg *Message) {
 	service.mutex.RUnlock()
 }
 
-//Calls a remote method by name and pass args
+//Calls a remote method by name and pass args.
+//If the upstream has replied to a call of the method with RetryAfterHeader,
+//the call is delayed until the hinted backoff has passed.
 func (service *Service) Call(ctx context.Context, name string, args ...interface{}) (Channel, error) {
+	if err := service.waitBackoff(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if err := service.reconnectIfNeeded(ctx); err != nil {
+		return nil, err
+	}
+
+	ch, err := service.call(ctx, name, args...)
+	if err == nil {
+		service.shadow(ctx, name, args...)
+	}
+	return ch, err
+}
+
+// reconnectIfNeeded reconnects the service if it's disconnected
+func (service *Service) reconnectIfNeeded(ctx context.Context) error {
 	service.mutex.RLock()
 	disconnected := service.disconnected()
 	service.mutex.RUnlock()
 
 	if disconnected {
-		if err := service.Reconnect(ctx, false); err != nil {
-			return nil, err
-		}
+		return service.Reconnect(ctx, false)
+	}
+	return nil
+}
+
+// SetCredentialsProvider sets the provider of authorization headers
+// for calls of the service. It overrides the one set by SetCredentialsProvider.
+func (service *Service) SetCredentialsProvider(provider CredentialsProvider) {
+	service.mutex.Lock()
+	service.credentials = provider
+	service.mutex.Unlock()
+}
+
+// SetRxWatermarks bounds queues of results received by channels
+// of new calls. Once a channel has got high results which are not read,
+// the service stops reading the connection until Get drains the queue
+// to low results, so the peer is throttled by TCP as soon as
+// SocketOptions.ReadQueueSize messages are buffered. The connection is
+// shared, so a channel which is not read stalls other channels
+// of the service until the context of its Get is done.
+// Zero high disables the limit.
+func (service *Service) SetRxWatermarks(high, low int) {
+	if low < 0 || low >= high {
+		low = high / 2
 	}
 
-	return service.call(ctx, name, args...)
+	service.mutex.Lock()
+	service.rxHighWatermark = high
+	service.rxLowWatermark = low
+	service.mutex.Unlock()
 }
 
 // Disposes resources of a service. You must call this method if the service isn't used anymore.
@@ -2,66 +2,102 @@ package cocaine12
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
-type sessions struct {
+// sessionShards is the number of shards of the session table.
+// It must be a power of two.
+const sessionShards = 64
+
+// sessionShard guards a part of sessions with its own lock
+type sessionShard struct {
 	sync.RWMutex
-	links   map[uint64]Channel
+	links map[uint64]Channel
+	// keeps shards on separate cache lines
+	_ [32]byte
+}
+
+// sessions is a table of channels of a Service. Sessions are spread
+// over shards by id, so the readloop looking up channels doesn't contend
+// with callers opening and closing other channels.
+type sessions struct {
+	// accessed atomically, must be the first for 64-bit alignment
 	counter uint64
+	count   int64
+
+	shards [sessionShards]sessionShard
 }
 
 func newSessions() *sessions {
-	return &sessions{
-		links:   make(map[uint64]Channel),
+	s := &sessions{
 		counter: 1,
 	}
+	for i := range s.shards {
+		s.shards[i].links = make(map[uint64]Channel)
+	}
+	return s
 }
 
-func (s *sessions) Next() uint64 {
-	s.Lock()
-	s.counter++
-	i := s.counter
-	s.Unlock()
+func (s *sessions) shard(id uint64) *sessionShard {
+	// ids are sequential, so the lowest bits spread them evenly
+	return &s.shards[id&(sessionShards-1)]
+}
 
-	return i
+func (s *sessions) Next() uint64 {
+	return atomic.AddUint64(&s.counter, 1)
 }
 
 func (s *sessions) Attach(session Channel) uint64 {
-	s.Lock()
+	current := s.Next()
 
-	s.counter++
-	s.links[s.counter] = session
-	current := s.counter
+	shard := s.shard(current)
+	shard.Lock()
+	shard.links[current] = session
+	shard.Unlock()
 
-	s.Unlock()
+	atomic.AddInt64(&s.count, 1)
+	atomic.AddInt64(&openChannels, 1)
 	return current
 }
 
 func (s *sessions) Detach(id uint64) {
-	s.Lock()
+	shard := s.shard(id)
+	shard.Lock()
 
-	delete(s.links, id)
+	_, ok := shard.links[id]
+	delete(shard.links, id)
 
-	s.Unlock()
+	shard.Unlock()
+	if ok {
+		atomic.AddInt64(&s.count, -1)
+		atomic.AddInt64(&openChannels, -1)
+	}
 }
 
 func (s *sessions) Get(id uint64) (Channel, bool) {
-	s.RLock()
+	shard := s.shard(id)
+	shard.RLock()
 
-	session, ok := s.links[id]
+	session, ok := shard.links[id]
 
-	s.RUnlock()
+	shard.RUnlock()
 	return session, ok
 }
 
-func (s *sessions) Keys() []uint64 {
-	s.RLock()
+// Len returns the number of attached sessions
+func (s *sessions) Len() int {
+	return int(atomic.LoadInt64(&s.count))
+}
 
-	var keys = make([]uint64, 0, len(s.links))
-	for k := range s.links {
-		keys = append(keys, k)
+func (s *sessions) Keys() []uint64 {
+	var keys = make([]uint64, 0, s.Len())
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.RLock()
+		for k := range shard.links {
+			keys = append(keys, k)
+		}
+		shard.RUnlock()
 	}
-
-	s.RUnlock()
 	return keys
 }
@@ -0,0 +1,96 @@
+package cocaine12
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	s := newSessions()
+	assert.Equal(t, uint64(2), s.Next())
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids []uint64
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ch := &channel{}
+				id := s.Attach(ch)
+				if found, ok := s.Get(id); !ok || found != ch {
+					t.Errorf("session %d is not found", id)
+				}
+
+				mu.Lock()
+				ids = append(ids, id)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 800, s.Len())
+	keys := s.Keys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	assert.Equal(t, ids, keys)
+	assert.Equal(t, uint64(3), keys[0])
+
+	for _, id := range ids {
+		s.Detach(id)
+	}
+	s.Detach(ids[0])
+	assert.Equal(t, 0, s.Len())
+	assert.Empty(t, s.Keys())
+
+	_, ok := s.Get(ids[0])
+	assert.False(t, ok)
+}
+
+func newBenchSessions(n int) (*sessions, []uint64) {
+	s := newSessions()
+	ids := make([]uint64, n)
+	for i := range ids {
+		ids[i] = s.Attach(&channel{})
+	}
+	return s, ids
+}
+
+// BenchmarkSessionsGet looks up channels as the readloop does
+func BenchmarkSessionsGet(b *testing.B) {
+	s, ids := newBenchSessions(50000)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			if _, ok := s.Get(ids[i%len(ids)]); !ok {
+				b.Fatal("session is not found")
+			}
+		}
+	})
+}
+
+// BenchmarkSessionsChurn opens and closes channels concurrently
+// with lookups of other ones
+func BenchmarkSessionsChurn(b *testing.B) {
+	s, ids := newBenchSessions(50000)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			if i%4 == 0 {
+				s.Detach(s.Attach(&channel{}))
+				continue
+			}
+			s.Get(ids[i%len(ids)])
+		}
+	})
+}
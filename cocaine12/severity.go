@@ -2,6 +2,7 @@ package cocaine12
 
 import (
 	"strconv"
+	"sync"
 	"sync/atomic"
 )
 
@@ -12,10 +13,59 @@ const (
 	InfoLevel
 	WarnLevel
 	ErrorLevel = 3
+
+	// TraceLevel is more verbose than DebugLevel.
+	// The runtime has no such level, see SetSeverityMapping
+	TraceLevel Severity = -1
+	// FatalLevel is logged when the worker can't continue.
+	// The runtime has no such level, see SetSeverityMapping
+	FatalLevel Severity = 4
+)
+
+var (
+	severityMappingMu sync.RWMutex
+	severityMapping   = map[Severity]Severity{
+		TraceLevel: DebugLevel,
+		FatalLevel: ErrorLevel,
+	}
 )
 
+// SetSeverityMapping sets levels of the runtime which TraceLevel
+// and FatalLevel are sent with. By default TraceLevel is sent as DebugLevel
+// and FatalLevel as ErrorLevel. Levels missing from the mapping are kept.
+func SetSeverityMapping(mapping map[Severity]Severity) {
+	severityMappingMu.Lock()
+	severityMapping = make(map[Severity]Severity, len(mapping))
+	for level, mapped := range mapping {
+		severityMapping[level] = mapped
+	}
+	severityMappingMu.Unlock()
+}
+
+// runtimeSeverity returns one of four levels of the runtime for the level
+func runtimeSeverity(level Severity) Severity {
+	severityMappingMu.RLock()
+	mapped, ok := severityMapping[level]
+	severityMappingMu.RUnlock()
+	if ok {
+		level = mapped
+	}
+
+	switch {
+	case level < DebugLevel:
+		return DebugLevel
+	case level > ErrorLevel:
+		return ErrorLevel
+	}
+	return level
+}
+
 func (s *Severity) String() string {
 	switch i := s.get(); i {
+	case TraceLevel:
+		return "TRACE"
+	case FatalLevel:
+		return "FATAL"
 	case DebugLevel:
 		return "DEBUG"
 	case InfoLevel:
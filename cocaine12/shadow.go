@@ -0,0 +1,75 @@
+package cocaine12
+
+import (
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// ShadowHeader marks calls mirrored to another service
+	ShadowHeader = "x-cocaine-shadow"
+
+	shadowTimeout = 5 * time.Second
+)
+
+type mirror struct {
+	target *Service
+	// a fraction of mirrored calls from 0 to 1
+	ratio float64
+}
+
+// SetMirror duplicates the percentage of calls to the target,
+// e.g. a staging version of the application. Mirrored calls are
+// fire-and-forget: their replies are dropped and errors are ignored.
+// Only the initial call is mirrored, chunks sent to Channel are not.
+// Nil target disables mirroring.
+func (service *Service) SetMirror(target *Service, percent float64) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if target == nil || percent <= 0 {
+		service.mirror = nil
+		return
+	}
+
+	if percent > 100 {
+		percent = 100
+	}
+	service.mirror = &mirror{target: target, ratio: percent / 100}
+}
+
+func (service *Service) shadow(ctx context.Context, name string, args ...interface{}) {
+	service.mutex.RLock()
+	m := service.mirror
+	service.mutex.RUnlock()
+
+	if m == nil || rand.Float64() >= m.ratio {
+		return
+	}
+
+	// the mirrored call must not be cancelled with the original one
+	shadowCtx := context.Background()
+	if md, ok := FromOutgoingContext(ctx); ok {
+		shadowCtx = NewOutgoingContext(shadowCtx, md)
+	}
+	shadowCtx = AppendToOutgoingContext(shadowCtx, ShadowHeader, service.name)
+
+	go func() {
+		shadowCtx, cancel := context.WithTimeout(shadowCtx, shadowTimeout)
+		defer cancel()
+
+		ch, err := m.target.Call(shadowCtx, name, args...)
+		if err != nil {
+			return
+		}
+
+		// drain replies to release the session
+		for !ch.Closed() {
+			if _, err := ch.Get(shadowCtx); err != nil {
+				return
+			}
+		}
+	}()
+}
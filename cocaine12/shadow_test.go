@@ -0,0 +1,68 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newTestService(t *testing.T, name string) (*Service, socketIO) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	peer, _ := newAsyncRW(in)
+
+	return &Service{
+		socketIO: sock,
+		ServiceInfo: &ServiceInfo{
+			API: dispatchMap{
+				0: dispatchItem{
+					Name:       "ping",
+					Downstream: &streamDescription{},
+					Upstream:   &streamDescription{},
+				},
+			},
+		},
+		sessions: newSessions(),
+		stop:     make(chan struct{}),
+		name:     name,
+	}, peer
+}
+
+func TestServiceMirror(t *testing.T) {
+	service, peer := newTestService(t, "app")
+	staging, stagingPeer := newTestService(t, "app-staging")
+	defer service.Close()
+	defer staging.Close()
+
+	service.SetMirror(staging, 100)
+
+	ctx := AppendToOutgoingContext(context.Background(), "x-request-id", "abc")
+	_, err := service.Call(ctx, "ping", "data")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), (<-peer.Read()).MsgType)
+
+	select {
+	case msg := <-stagingPeer.Read():
+		assert.Equal(t, uint64(0), msg.MsgType)
+		assert.Equal(t, []interface{}{[]byte("data")}, msg.Payload)
+
+		md := metadataFromHeaders(msg.Headers)
+		assert.Equal(t, []string{"abc"}, md.Get("x-request-id"))
+		assert.Equal(t, []string{"app"}, md.Get(ShadowHeader))
+	case <-time.After(time.Second):
+		t.Fatal("the call has not been mirrored")
+	}
+
+	service.SetMirror(staging, 0)
+	_, err = service.Call(ctx, "ping", "data")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), (<-peer.Read()).MsgType)
+
+	select {
+	case <-stagingPeer.Read():
+		t.Fatal("mirroring is disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,121 @@
+package cocaine12
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// TCP keep-alive probes detect half-open connections
+	// to services which sit idle. The connection is considered dead
+	// after several unanswered probes.
+	defaultKeepAlivePeriod = time.Second * 10
+	// the connection is not read while so many received
+	// messages are not handled, see SocketOptions.ReadQueueSize
+	defaultReadQueueSize = 1024
+)
+
+// SocketOptions describes options of connections to cocaine-runtime
+// and services. They are applied to new connections only.
+type SocketOptions struct {
+	// NoDelay disables Nagle's algorithm for TCP connections
+	NoDelay bool
+	// KeepAlive is the period between TCP keep-alive probes.
+	// A negative value disables keep-alive
+	KeepAlive time.Duration
+	// ReadBuffer sets SO_RCVBUF. Zero keeps the OS default
+	ReadBuffer int
+	// WriteBuffer sets SO_SNDBUF. Zero keeps the OS default
+	WriteBuffer int
+	// MaxFrameSize limits the size of an incoming message in bytes.
+	// A connection is closed if the peer sends a larger one. Zero disables the limit
+	MaxFrameSize int
+	// MaxFrameDepth limits nesting of arrays and maps in an incoming message.
+	// Zero disables the limit
+	MaxFrameDepth int
+	// MaxHeaderListSize limits headers of an incoming message. It's counted
+	// like SETTINGS_MAX_HEADER_LIST_SIZE of HTTP/2. Zero disables the limit
+	MaxHeaderListSize int
+	// ReadQueueSize limits the number of received messages
+	// which are not handled yet. The connection is not read while the queue
+	// is full, so a slow reader throttles the peer via TCP flow control.
+	// Zero disables the limit
+	ReadQueueSize int
+	// Handoff makes the worker keep track of bytes read from cocaine-runtime,
+	// so the connection can be passed to a successor. See WorkerNG.Handoff
+	Handoff bool
+}
+
+var (
+	sockOptsMu sync.RWMutex
+	socketOpts = DefaultSocketOptions()
+)
+
+// DefaultSocketOptions returns the options used if nothing is set
+func DefaultSocketOptions() SocketOptions {
+	return SocketOptions{
+		NoDelay:       true,
+		KeepAlive:     defaultKeepAlivePeriod,
+		MaxFrameSize:  defaultMaxFrameSize,
+		MaxFrameDepth: defaultMaxFrameDepth,
+		ReadQueueSize: defaultReadQueueSize,
+
+		MaxHeaderListSize: defaultMaxHeaderListSize,
+	}
+}
+
+// SetSocketOptions sets options for new connections
+// of both the worker and service clients
+func SetSocketOptions(opts SocketOptions) {
+	sockOptsMu.Lock()
+	socketOpts = opts
+	sockOptsMu.Unlock()
+}
+
+// GetSocketOptions returns options for new connections
+func GetSocketOptions() SocketOptions {
+	sockOptsMu.RLock()
+	defer sockOptsMu.RUnlock()
+	return socketOpts
+}
+
+// SetKeepAlivePeriod sets the period between TCP keep-alive probes
+// for new connections to services. A negative value disables keep-alive.
+func SetKeepAlivePeriod(period time.Duration) {
+	sockOptsMu.Lock()
+	socketOpts.KeepAlive = period
+	sockOptsMu.Unlock()
+}
+
+type bufferedConn interface {
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+}
+
+func (opts SocketOptions) apply(conn net.Conn) error {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetNoDelay(opts.NoDelay); err != nil {
+			return err
+		}
+	}
+
+	bconn, ok := conn.(bufferedConn)
+	if !ok {
+		return nil
+	}
+
+	if opts.ReadBuffer > 0 {
+		if err := bconn.SetReadBuffer(opts.ReadBuffer); err != nil {
+			return err
+		}
+	}
+
+	if opts.WriteBuffer > 0 {
+		if err := bconn.SetWriteBuffer(opts.WriteBuffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
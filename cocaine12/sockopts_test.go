@@ -0,0 +1,35 @@
+package cocaine12
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSocketOptions(t *testing.T) {
+	defer SetSocketOptions(DefaultSocketOptions())
+
+	SetKeepAlivePeriod(time.Second)
+	assert.Equal(t, time.Second, GetSocketOptions().KeepAlive)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	SetSocketOptions(SocketOptions{
+		NoDelay:     false,
+		KeepAlive:   -1,
+		ReadBuffer:  1 << 16,
+		WriteBuffer: 1 << 16,
+	})
+
+	sock, err := newTCPConnection(ln.Addr().String(), time.Second)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	sock.Close()
+}
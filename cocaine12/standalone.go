@@ -0,0 +1,258 @@
+package cocaine12
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// StandaloneSocketEnv is the path of a unix socket RunStandalone
+// listens on. Requests are read from stdin if it isn't set.
+const StandaloneSocketEnv = "COCAINE_STANDALONE_SOCKET"
+
+// standaloneRequest is a line of the standalone protocol:
+//
+//	{"id": 1, "event": "echo", "chunks": ["ping"], "headers": {"x-trace": ["1"]}}
+//
+// Chunks are JSON strings or, if "json" is set, JSON values packed to msgpack
+type standaloneRequest struct {
+	ID      uint64              `json:"id"`
+	Event   string              `json:"event"`
+	Chunks  []json.RawMessage   `json:"chunks,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	JSON    bool                `json:"json,omitempty"`
+}
+
+// standaloneReply is a line of a response. A response is a sequence
+// of chunks ended by either close or error.
+type standaloneReply struct {
+	ID    uint64           `json:"id"`
+	Chunk json.RawMessage  `json:"chunk,omitempty"`
+	Error *standaloneError `json:"error,omitempty"`
+	Close bool             `json:"close,omitempty"`
+}
+
+type standaloneError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewStandaloneWorker creates the Worker which runs handlers
+// without cocaine-runtime. See RunStandalone
+func NewStandaloneWorker() (*Worker, error) {
+	impl, err := makeWorkerNG(nil, GetDefaults().UUID(), v0, GetDefaults().Debug(), new(NullTokenManager))
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{impl, NewEventHandlers(), nil, newHealthChecks()}, nil
+}
+
+// RunStandalone runs the handlers without the handshake and cocaine-runtime,
+// so the application can be developed locally or run in a container
+// without cocaine installed. Requests are read as JSON lines from
+// the unix socket named by StandaloneSocketEnv or from stdin, responses
+// are written back as JSON lines to the socket or stdout:
+//
+//	> {"id": 1, "event": "echo", "chunks": ["ping"]}
+//	< {"id":1,"chunk":"ping"}
+//	< {"id":1,"close":true}
+//
+// Requests are handled concurrently, replies carry the ID of the request.
+// A request with "json": true has JSON values instead of strings in chunks,
+// they are converted to msgpack and chunks of its response back to JSON.
+// It returns when the input ends, ctx is done or the worker is stopped.
+func (w *Worker) RunStandalone(ctx context.Context) error {
+	w.registerInfoEvent()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.impl.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if path := os.Getenv(StandaloneSocketEnv); path != "" {
+		return serveStandaloneSocket(ctx, w.handlers, path)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStandalone(ctx, w.handlers, os.Stdin, os.Stdout)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func serveStandaloneSocket(ctx context.Context, handlers *EventHandlers, path string) error {
+	// a stale socket is left by a killed process
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return err
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			serveStandalone(ctx, handlers, conn, conn)
+		}()
+	}
+}
+
+// serveStandalone handles requests read from in until it ends
+// and all handlers have returned
+func serveStandalone(ctx context.Context, handlers *EventHandlers, in io.Reader, out io.Writer) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	encoder := &standaloneEncoder{encoder: json.NewEncoder(out)}
+	decoder := json.NewDecoder(in)
+	for {
+		var request standaloneRequest
+		if err := decoder.Decode(&request); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("malformed standalone request: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveStandaloneRequest(ctx, handlers, &request, encoder)
+		}()
+	}
+}
+
+func serveStandaloneRequest(ctx context.Context, handlers *EventHandlers, request *standaloneRequest, encoder *standaloneEncoder) {
+	response := &standaloneResponse{id: request.ID, jsonFormat: request.JSON, encoder: encoder}
+
+	chunks := make([][]byte, 0, len(request.Chunks))
+	for i, raw := range request.Chunks {
+		chunk, err := standaloneChunk(raw, request.JSON)
+		if err != nil {
+			response.ErrorMsg(ErrorBadRequest, fmt.Sprintf("chunk %d is malformed: %v", i, err))
+			return
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	md := Metadata{}
+	for name, values := range request.Headers {
+		md.Append(name, values...)
+	}
+	ctx = NewIncomingContext(withEventName(ctx, request.Event), md)
+	if timeout, ok := requestTimeout(md); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	defer trapRecoverAndClose(ctx, request.Event, response, true, nil)
+	handlers.Call(ctx, request.Event, &devRequest{chunks: chunks}, response)
+}
+
+func standaloneChunk(raw json.RawMessage, jsonFormat bool) ([]byte, error) {
+	if jsonFormat {
+		return jsonToMsgpack(raw)
+	}
+
+	var chunk string
+	if err := json.Unmarshal(raw, &chunk); err != nil {
+		return nil, err
+	}
+	return []byte(chunk), nil
+}
+
+type standaloneEncoder struct {
+	sync.Mutex
+	encoder *json.Encoder
+}
+
+func (e *standaloneEncoder) encode(reply *standaloneReply) error {
+	e.Lock()
+	defer e.Unlock()
+	return e.encoder.Encode(reply)
+}
+
+type standaloneResponse struct {
+	id         uint64
+	jsonFormat bool
+	encoder    *standaloneEncoder
+	closed     bool
+}
+
+func (r *standaloneResponse) Write(data []byte) (int, error) {
+	if err := r.ZeroCopyWrite(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (r *standaloneResponse) ZeroCopyWrite(data []byte) error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+
+	var (
+		chunk []byte
+		err   error
+	)
+	if r.jsonFormat {
+		chunk, err = msgpackToJSON(data)
+	} else {
+		chunk, err = json.Marshal(string(data))
+	}
+	if err != nil {
+		return err
+	}
+	return r.encoder.encode(&standaloneReply{ID: r.id, Chunk: chunk})
+}
+
+func (r *standaloneResponse) Close() error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+	r.closed = true
+	return r.encoder.encode(&standaloneReply{ID: r.id, Close: true})
+}
+
+func (r *standaloneResponse) ErrorMsg(code int, message string) error {
+	if r.closed {
+		return ErrStreamIsClosed
+	}
+	r.closed = true
+	return r.encoder.encode(&standaloneReply{ID: r.id, Error: &standaloneError{Code: code, Message: message}})
+}
@@ -0,0 +1,136 @@
+package cocaine12
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newStandaloneTestHandlers() *EventHandlers {
+	handlers := NewEventHandlers()
+	handlers.On("echo", func(ctx context.Context, req Request, res Response) {
+		for {
+			chunk, err := req.Read(ctx)
+			if err != nil {
+				return
+			}
+			res.Write(chunk)
+		}
+	})
+	handlers.On("meta", func(ctx context.Context, req Request, res Response) {
+		md, _ := FromIncomingContext(ctx)
+		res.Write([]byte(md.Get("X-Trace")[0]))
+	})
+	handlers.On("sum", func(ctx context.Context, req Request, res Response) {
+		chunk, _ := req.Read(ctx)
+		var args []int
+		unmarshalPayload(chunk, &args)
+		payload, _ := marshalPayload(args[0] + args[1])
+		res.Write(payload)
+	})
+	return handlers
+}
+
+func readStandaloneReplies(t *testing.T, data []byte) map[uint64][]standaloneReply {
+	replies := make(map[uint64][]standaloneReply)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var reply standaloneReply
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies[reply.ID] = append(replies[reply.ID], reply)
+	}
+	return replies
+}
+
+func TestServeStandalone(t *testing.T) {
+	in := strings.NewReader(`{"id": 1, "event": "echo", "chunks": ["a", "b"]}
+{"id": 2, "event": "meta", "headers": {"X-Trace": ["abc"]}}
+{"id": 3, "event": "sum", "chunks": [[1, 2]], "json": true}
+{"id": 4, "event": "missing"}
+{"id": 5, "event": "echo", "chunks": [1]}
+`)
+	out := new(bytes.Buffer)
+	err := serveStandalone(context.Background(), newStandaloneTestHandlers(), in, out)
+	assert.NoError(t, err)
+
+	replies := readStandaloneReplies(t, out.Bytes())
+	assert.Equal(t, []standaloneReply{
+		{ID: 1, Chunk: json.RawMessage(`"a"`)},
+		{ID: 1, Chunk: json.RawMessage(`"b"`)},
+		{ID: 1, Close: true},
+	}, replies[1])
+	assert.Equal(t, json.RawMessage(`"abc"`), replies[2][0].Chunk)
+	assert.Equal(t, json.RawMessage(`3`), replies[3][0].Chunk)
+	if assert.Len(t, replies[4], 1) {
+		assert.Equal(t, ErrorNoEventHandler, replies[4][0].Error.Code)
+	}
+	if assert.Len(t, replies[5], 1) {
+		assert.Equal(t, ErrorBadRequest, replies[5][0].Error.Code)
+	}
+
+	err = serveStandalone(context.Background(), newStandaloneTestHandlers(), strings.NewReader("{"), ioutil.Discard)
+	assert.Error(t, err)
+}
+
+func TestRunStandaloneSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "standalone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "worker.sock")
+	os.Setenv(StandaloneSocketEnv, path)
+	defer os.Unsetenv(StandaloneSocketEnv)
+
+	w, err := NewStandaloneWorker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.On("echo", newStandaloneTestHandlers().handlers["echo"])
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.RunStandalone(context.Background())
+	}()
+
+	var conn net.Conn
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(`{"id": 7, "event": "echo", "chunks": ["ping"]}` + "\n"))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"id":7,"chunk":"ping"}`+"\n", string(line))
+
+	conn.Close()
+	w.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RunStandalone hasn't returned after Stop")
+	}
+}
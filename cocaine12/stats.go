@@ -0,0 +1,109 @@
+package cocaine12
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventStats is a snapshot of statistics of an event
+type EventStats struct {
+	// Calls is the number of invocations
+	Calls uint64
+	// Errors is the number of invocations replied with an error,
+	// including panics and rejections
+	Errors uint64
+	// RequestBytes and ResponseBytes are total sizes of payloads
+	// read and written by handlers
+	RequestBytes  uint64
+	ResponseBytes uint64
+	// TooLarge is the number of invocations whose payloads
+	// have exceeded limits. See WithMaxRequestSize
+	TooLarge uint64
+	// Latencies of handlers
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// ErrorRate returns the fraction of failed invocations
+func (e EventStats) ErrorRate() float64 {
+	if e.Calls == 0 {
+		return 0
+	}
+	return float64(e.Errors) / float64(e.Calls)
+}
+
+type eventCounters struct {
+	calls         uint64
+	errors        uint64
+	requestBytes  uint64
+	responseBytes uint64
+	tooLarge      uint64
+	latencies     histogram
+}
+
+type eventsStats struct {
+	mu     sync.RWMutex
+	events map[string]*eventCounters
+}
+
+func newEventsStats() *eventsStats {
+	return &eventsStats{
+		events: make(map[string]*eventCounters),
+	}
+}
+
+func (s *eventsStats) get(event string) *eventCounters {
+	s.mu.RLock()
+	counters, ok := s.events[event]
+	s.mu.RUnlock()
+	if ok {
+		return counters
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if counters, ok = s.events[event]; !ok {
+		counters = new(eventCounters)
+		s.events[event] = counters
+	}
+	return counters
+}
+
+func (s *eventsStats) record(event string, latency time.Duration, failed bool) {
+	counters := s.get(event)
+	atomic.AddUint64(&counters.calls, 1)
+	if failed {
+		atomic.AddUint64(&counters.errors, 1)
+	}
+	counters.latencies.record(latency)
+}
+
+func (s *eventsStats) recordRejected(event string) {
+	counters := s.get(event)
+	atomic.AddUint64(&counters.calls, 1)
+	atomic.AddUint64(&counters.errors, 1)
+}
+
+func (s *eventsStats) snapshot() map[string]EventStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result = make(map[string]EventStats, len(s.events))
+	for event, counters := range s.events {
+		qs := counters.latencies.quantiles(0.5, 0.95, 0.99)
+		result[event] = EventStats{
+			Calls:         atomic.LoadUint64(&counters.calls),
+			Errors:        atomic.LoadUint64(&counters.errors),
+			RequestBytes:  atomic.LoadUint64(&counters.requestBytes),
+			ResponseBytes: atomic.LoadUint64(&counters.responseBytes),
+			TooLarge:      atomic.LoadUint64(&counters.tooLarge),
+			P50:           qs[0],
+			P95:           qs[1],
+			P99:           qs[2],
+		}
+	}
+
+	return result
+}
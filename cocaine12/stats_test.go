@@ -0,0 +1,38 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	qs := h.quantiles(0.5, 0.95, 0.99)
+	for i, expected := range []time.Duration{50 * time.Millisecond, 95 * time.Millisecond, 99 * time.Millisecond} {
+		diff := float64(qs[i]-expected) / float64(expected)
+		assert.True(t, diff < 0.07 && diff > -0.07, "%v is too far from %v", qs[i], expected)
+	}
+
+	for _, v := range []uint64{0, 1, 15, 16, 17, 100, 1000, 123456789} {
+		assert.True(t, histIndex(histValue(histIndex(v))) == histIndex(v), "%d", v)
+	}
+}
+
+func TestEventsStats(t *testing.T) {
+	s := newEventsStats()
+	s.record("echo", time.Millisecond, false)
+	s.record("echo", time.Millisecond, true)
+	s.recordRejected("echo")
+
+	stats := s.snapshot()["echo"]
+	assert.Equal(t, uint64(3), stats.Calls)
+	assert.Equal(t, uint64(2), stats.Errors)
+	assert.InDelta(t, 2.0/3, stats.ErrorRate(), 0.001)
+	assert.InDelta(t, float64(time.Millisecond), float64(stats.P99), float64(time.Millisecond)/10)
+}
@@ -0,0 +1,63 @@
+package cocaine12
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// TerminationNormal means a planned shutdown, e.g. an app is stopped
+	TerminationNormal = 1
+	// TerminationAbnormal means that something went wrong
+	TerminationAbnormal = 2
+)
+
+// TerminationReason describes why a worker is being terminated
+type TerminationReason struct {
+	Code    int
+	Message string
+}
+
+func (r TerminationReason) String() string {
+	return fmt.Sprintf("[%d] %s", r.Code, r.Message)
+}
+
+// ShutdownHandler is called when the worker is being terminated
+// by cocaine-runtime. The context is canceled when time to prepare is over.
+type ShutdownHandler func(ctx context.Context, reason TerminationReason)
+
+func parseTerminationReason(msg *Message) TerminationReason {
+	var reason struct {
+		Code    int
+		Message string
+	}
+
+	if err := convertPayload(msg.Payload, &reason); err != nil {
+		return TerminationReason{
+			Code:    TerminationAbnormal,
+			Message: fmt.Sprintf("malformed termination message: %v", err),
+		}
+	}
+
+	return TerminationReason{reason.Code, reason.Message}
+}
+
+// OnShutdown attaches the handler which will be called
+// with the reason sent by cocaine-runtime in the terminate message.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) OnShutdown(handler ShutdownHandler) {
+	w.shutdownHandler = handler
+}
+
+// Terminate notifies cocaine-runtime that the worker is shutting down
+// by itself with the reason and stops the worker
+func (w *WorkerNG) Terminate(reason TerminationReason) {
+	conn := w.connection()
+	select {
+	case conn.Write() <- w.dispatcher.newTerminate(reason):
+	case <-conn.IsClosed():
+	case <-w.clock.After(disownTimeout):
+	}
+	w.Stop()
+}
@@ -0,0 +1,89 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestWorkerShutdownReason(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	reasons := make(chan TerminationReason, 1)
+	w.OnShutdown(func(ctx context.Context, reason TerminationReason) {
+		reasons <- reason
+	})
+
+	onStop := make(chan struct{})
+	go func() {
+		w.Run(map[string]EventHandler{})
+		close(onStop)
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newTerminateV1(TerminationReason{TerminationNormal, "app is stopped"})
+
+	select {
+	case reason := <-reasons:
+		assert.Equal(t, TerminationReason{TerminationNormal, "app is stopped"}, reason)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown handler is not called")
+	}
+
+	<-onStop
+}
+
+// testSocket passes written messages to a reader synchronously
+type testSocket struct {
+	read   chan *Message
+	write  chan *Message
+	closed chan struct{}
+}
+
+func newTestSocket() *testSocket {
+	return &testSocket{
+		read:   make(chan *Message),
+		write:  make(chan *Message),
+		closed: make(chan struct{}),
+	}
+}
+
+func (s *testSocket) Send(msg *Message) {
+	select {
+	case s.write <- msg:
+	case <-s.closed:
+	}
+}
+
+func (s *testSocket) Read() chan *Message       { return s.read }
+func (s *testSocket) Write() chan *Message      { return s.write }
+func (s *testSocket) IsClosed() <-chan struct{} { return s.closed }
+func (s *testSocket) Close()                    { close(s.closed) }
+
+func TestWorkerTerminate(t *testing.T) {
+	sock := newTestSocket()
+	go func() {
+		checkTypeAndSession(t, <-sock.write, v1UtilitySession, v1Handshake)
+	}()
+
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	go w.Terminate(TerminationReason{TerminationAbnormal, "out of memory"})
+
+	msg := <-sock.write
+	checkTypeAndSession(t, msg, v1UtilitySession, v1Terminate)
+	assert.Equal(t, TerminationReason{TerminationAbnormal, "out of memory"}, parseTerminationReason(msg))
+}
@@ -0,0 +1,70 @@
+package cocaine12
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ParseMethodTimeouts parses timeouts of methods
+// like "read: 50ms, write: 500ms". See Service.SetMethodTimeouts
+func ParseMethodTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid method timeout %q", item)
+		}
+
+		method := strings.TrimSpace(kv[0])
+		timeout, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of method %s: %v", method, err)
+		}
+		timeouts[method] = timeout
+	}
+	return timeouts, nil
+}
+
+// SetMethodTimeouts sets default timeouts of calls by the method name.
+// A timeout limits the whole call including reading results by Get,
+// unless the context of the call or Get has its own deadline.
+// The call is canceled upstream once the timeout expires.
+// It replaces the timeouts set before.
+func (service *Service) SetMethodTimeouts(timeouts map[string]time.Duration) {
+	var copied = make(map[string]time.Duration, len(timeouts))
+	for method, timeout := range timeouts {
+		copied[method] = timeout
+	}
+
+	service.mutex.Lock()
+	service.methodTimeouts = copied
+	service.mutex.Unlock()
+}
+
+// methodDeadline returns the deadline of a new call of the method
+// if ctx has no deadline. It must be called under the lock.
+func (service *Service) methodDeadline(ctx context.Context, name string) time.Time {
+	if _, ok := ctx.Deadline(); ok {
+		return time.Time{}
+	}
+
+	if timeout := service.methodTimeouts[name]; timeout > 0 {
+		return time.Now().Add(timeout)
+	}
+	return time.Time{}
+}
+
+// withDefaultDeadline applies the deadline to ctx if it has no deadline
+func withDefaultDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, deadline)
+}
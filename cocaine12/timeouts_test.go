@@ -0,0 +1,50 @@
+package cocaine12
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseMethodTimeouts(t *testing.T) {
+	timeouts, err := ParseMethodTimeouts("read: 50ms, write: 500ms,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"read":  50 * time.Millisecond,
+		"write": 500 * time.Millisecond,
+	}, timeouts)
+
+	_, err = ParseMethodTimeouts("read")
+	assert.Error(t, err)
+	_, err = ParseMethodTimeouts("read: fast")
+	assert.Error(t, err)
+}
+
+func TestServiceMethodTimeouts(t *testing.T) {
+	service, peer := newTestStorage(t)
+	defer service.Close()
+
+	service.SetMethodTimeouts(map[string]time.Duration{"ping": 20 * time.Millisecond})
+
+	ch, err := service.Call(context.Background(), "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-peer.Read()
+
+	start := time.Now()
+	_, err = ch.Get(context.Background())
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// the deadline of the caller wins
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	ch, err = service.Call(ctx, "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ch.(*channel).rx.deadline.IsZero())
+}
@@ -28,6 +28,14 @@ func getTraceInfo(ctx context.Context) *TraceInfo {
 	return nil
 }
 
+// TraceInfoFromContext returns TraceInfo attached to the context
+func TraceInfoFromContext(ctx context.Context) (TraceInfo, bool) {
+	if traceInfo := getTraceInfo(ctx); traceInfo != nil {
+		return *traceInfo, true
+	}
+	return TraceInfo{}, false
+}
+
 // CloseSpan closes attached span. It should be call after
 // the rpc ends.
 type CloseSpan func()
@@ -42,6 +50,11 @@ func (traceInfo *TraceInfo) getLog() Logger {
 		return traceInfo.logger
 	}
 
+	return getTraceLogger()
+}
+
+// getTraceLogger returns the logger shared by traces without their own one
+func getTraceLogger() Logger {
 	initTraceLogger.Do(func() {
 		var err error
 		traceLogger, err = NewLogger(context.Background())
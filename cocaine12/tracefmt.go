@@ -0,0 +1,189 @@
+package cocaine12
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TraceparentHeader is the W3C Trace Context header
+	TraceparentHeader = "traceparent"
+
+	// B3Header is the single-header form of Zipkin B3 propagation
+	B3Header = "b3"
+	// B3TraceIDHeader carries a trace id in the multi-header B3 form
+	B3TraceIDHeader = "X-B3-TraceId"
+	// B3SpanIDHeader carries a span id in the multi-header B3 form
+	B3SpanIDHeader = "X-B3-SpanId"
+	// B3ParentSpanIDHeader carries a parent span id in the multi-header B3 form
+	B3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	// B3SampledHeader carries a sampling decision in the multi-header B3 form
+	B3SampledHeader = "X-B3-Sampled"
+
+	traceparentVersion = "00"
+	traceparentSampled = "01"
+)
+
+var (
+	// ErrInvalidTraceparent means that traceparent header is malformed
+	ErrInvalidTraceparent = errors.New("invalid traceparent header")
+	// ErrInvalidB3 means that B3 headers are malformed or absent
+	ErrInvalidB3 = errors.New("invalid b3 headers")
+)
+
+// NewTraceInfo creates TraceInfo from raw ids
+func NewTraceInfo(trace, span, parent uint64) TraceInfo {
+	return TraceInfo{
+		trace:  trace,
+		span:   span,
+		parent: parent,
+	}
+}
+
+// TraceID returns the id of the trace
+func (traceInfo TraceInfo) TraceID() uint64 {
+	return traceInfo.trace
+}
+
+// SpanID returns the id of the current span
+func (traceInfo TraceInfo) SpanID() uint64 {
+	return traceInfo.span
+}
+
+// ParentID returns the id of the parent span. It's 0 for a root span
+func (traceInfo TraceInfo) ParentID() uint64 {
+	return traceInfo.parent
+}
+
+// FormatTraceparent formats TraceInfo as W3C traceparent header value.
+// 64-bit cocaine trace id is padded with zeros to 128 bits.
+func FormatTraceparent(traceInfo TraceInfo) string {
+	return fmt.Sprintf("%s-%032x-%016x-%s",
+		traceparentVersion, traceInfo.trace, traceInfo.span, traceparentSampled)
+}
+
+// ParseTraceparent parses W3C traceparent header value.
+// The parent-id of the header becomes the span of TraceInfo,
+// so a new span started from it is a child of the remote span.
+// Only the lower 64 bits of a 128-bit trace id are kept.
+func ParseTraceparent(value string) (TraceInfo, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return TraceInfo{}, ErrInvalidTraceparent
+	}
+
+	if parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return TraceInfo{}, ErrInvalidTraceparent
+	}
+
+	trace, err := parseHexID(parts[1])
+	if err != nil || trace == 0 {
+		return TraceInfo{}, ErrInvalidTraceparent
+	}
+
+	span, err := parseHexID(parts[2])
+	if err != nil || span == 0 {
+		return TraceInfo{}, ErrInvalidTraceparent
+	}
+
+	return NewTraceInfo(trace, span, 0), nil
+}
+
+// FormatB3 formats TraceInfo as the single b3 header value
+func FormatB3(traceInfo TraceInfo) string {
+	if traceInfo.parent == 0 {
+		return fmt.Sprintf("%016x-%016x-1", traceInfo.trace, traceInfo.span)
+	}
+	return fmt.Sprintf("%016x-%016x-1-%016x", traceInfo.trace, traceInfo.span, traceInfo.parent)
+}
+
+// ParseB3 parses the single b3 header value
+func ParseB3(value string) (TraceInfo, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return TraceInfo{}, ErrInvalidB3
+	}
+
+	var ids [3]uint64
+	for i, part := range []string{parts[0], parts[1]} {
+		id, err := parseHexID(part)
+		if err != nil || id == 0 {
+			return TraceInfo{}, ErrInvalidB3
+		}
+		ids[i] = id
+	}
+
+	if len(parts) == 4 {
+		id, err := parseHexID(parts[3])
+		if err != nil {
+			return TraceInfo{}, ErrInvalidB3
+		}
+		ids[2] = id
+	}
+
+	return NewTraceInfo(ids[0], ids[1], ids[2]), nil
+}
+
+// InjectTraceHeaders sets both W3C and B3 headers describing TraceInfo
+func InjectTraceHeaders(traceInfo TraceInfo, header http.Header) {
+	header.Set(TraceparentHeader, FormatTraceparent(traceInfo))
+	header.Set(B3TraceIDHeader, fmt.Sprintf("%016x", traceInfo.trace))
+	header.Set(B3SpanIDHeader, fmt.Sprintf("%016x", traceInfo.span))
+	if traceInfo.parent != 0 {
+		header.Set(B3ParentSpanIDHeader, fmt.Sprintf("%016x", traceInfo.parent))
+	} else {
+		header.Del(B3ParentSpanIDHeader)
+	}
+	header.Set(B3SampledHeader, "1")
+}
+
+// ExtractTraceHeaders looks for W3C traceparent, single b3
+// and multi-header B3 in this order and returns the first valid one
+func ExtractTraceHeaders(header http.Header) (TraceInfo, bool) {
+	if value := header.Get(TraceparentHeader); value != "" {
+		if traceInfo, err := ParseTraceparent(value); err == nil {
+			return traceInfo, true
+		}
+	}
+
+	if value := header.Get(B3Header); value != "" {
+		if traceInfo, err := ParseB3(value); err == nil {
+			return traceInfo, true
+		}
+	}
+
+	trace, err := parseHexID(header.Get(B3TraceIDHeader))
+	if err != nil || trace == 0 {
+		return TraceInfo{}, false
+	}
+
+	span, err := parseHexID(header.Get(B3SpanIDHeader))
+	if err != nil || span == 0 {
+		return TraceInfo{}, false
+	}
+
+	var parent uint64
+	if value := header.Get(B3ParentSpanIDHeader); value != "" {
+		if parent, err = parseHexID(value); err != nil {
+			return TraceInfo{}, false
+		}
+	}
+
+	return NewTraceInfo(trace, span, parent), true
+}
+
+// parseHexID parses 64 or 128 bit hex id keeping the lower 64 bits
+func parseHexID(value string) (uint64, error) {
+	if len(value) == 0 || len(value) > 32 {
+		return 0, ErrInvalidTraceNumber
+	}
+
+	if len(value) > 16 {
+		value = value[len(value)-16:]
+	}
+
+	return strconv.ParseUint(value, 16, 64)
+}
@@ -0,0 +1,66 @@
+package cocaine12
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceparent(t *testing.T) {
+	traceInfo := NewTraceInfo(0xabcdef, 0x123, 0x456)
+	value := FormatTraceparent(traceInfo)
+	assert.Equal(t, "00-00000000000000000000000000abcdef-0000000000000123-01", value)
+
+	parsed, err := ParseTraceparent(value)
+	assert.NoError(t, err)
+	assert.Equal(t, NewTraceInfo(0xabcdef, 0x123, 0), parsed)
+
+	parsed, err = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xa3ce929d0e0e4736), parsed.TraceID())
+	assert.Equal(t, uint64(0x00f067aa0ba902b7), parsed.SpanID())
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(bad)
+		assert.Equal(t, ErrInvalidTraceparent, err, bad)
+	}
+}
+
+func TestB3(t *testing.T) {
+	traceInfo := NewTraceInfo(0xabcdef, 0x123, 0x456)
+	value := FormatB3(traceInfo)
+	assert.Equal(t, "0000000000abcdef-0000000000000123-1-0000000000000456", value)
+
+	parsed, err := ParseB3(value)
+	assert.NoError(t, err)
+	assert.Equal(t, traceInfo, parsed)
+
+	_, err = ParseB3("0")
+	assert.Equal(t, ErrInvalidB3, err)
+}
+
+func TestTraceHeaders(t *testing.T) {
+	traceInfo := NewTraceInfo(1, 2, 3)
+	header := make(http.Header)
+	InjectTraceHeaders(traceInfo, header)
+
+	parsed, ok := ExtractTraceHeaders(header)
+	assert.True(t, ok)
+	assert.Equal(t, NewTraceInfo(1, 2, 0), parsed, "traceparent has priority")
+
+	header.Del(TraceparentHeader)
+	parsed, ok = ExtractTraceHeaders(header)
+	assert.True(t, ok)
+	assert.Equal(t, traceInfo, parsed)
+
+	_, ok = ExtractTraceHeaders(make(http.Header))
+	assert.False(t, ok)
+}
@@ -0,0 +1,105 @@
+package cocaine12
+
+import (
+	"fmt"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// TypedHandler converts fn to EventHandler. fn must be of the form
+//
+//	func(ctx context.Context, req MyRequest) (MyResponse, error)
+//
+// The first chunk of a request is unpacked by msgpack into MyRequest,
+// which may be a struct or a pointer to a struct with `codec` field tags.
+// The request is replied with ErrorBadRequest if it can't be unpacked
+// or it's rejected by ValidateFunc or Validator, before fn is called.
+// MyResponse is packed by msgpack and sent as a single chunk.
+// Types generated by github.com/tinylib/msgp are packed and unpacked
+// by their MarshalMsg and UnmarshalMsg without reflection.
+// An error returned by fn is replied with its code if it's *ServiceError,
+// with the code registered by RegisterError or RegisterErrorType
+// or with ErrorInternal otherwise.
+func TypedHandler(fn interface{}) (EventHandler, error) {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func ||
+		fnType.NumIn() != 2 || fnType.In(0) != contextType ||
+		fnType.NumOut() != 2 || fnType.Out(1) != errorType {
+		return nil, fmt.Errorf("%v is not func(context.Context, Request) (Response, error)", fnType)
+	}
+
+	reqType := fnType.In(1)
+	return func(ctx context.Context, request Request, response Response) {
+		req, err := decodeTypedRequest(ctx, request, reqType)
+		if err != nil {
+			response.ErrorMsg(ErrorBadRequest, err.Error())
+			return
+		}
+
+		// pointer receivers of Validate are called too
+		target := req
+		if req.CanAddr() {
+			target = req.Addr()
+		}
+		if err := validateTypedRequest(target.Interface()); err != nil {
+			response.ErrorMsg(ErrorBadRequest, invalidArgument(err))
+			return
+		}
+
+		out := fnValue.Call([]reflect.Value{reflect.ValueOf(ctx), req})
+		if err, _ := out[1].Interface().(error); err != nil {
+			replyTypedError(response, err)
+			return
+		}
+
+		buf, err := marshalPayload(out[0].Interface())
+		if err != nil {
+			response.ErrorMsg(ErrorInternal, fmt.Sprintf("unable to pack a response: %v", err))
+			return
+		}
+		response.ZeroCopyWrite(buf)
+	}, nil
+}
+
+// decodeTypedRequest returns a value of reqType unpacked from the request
+func decodeTypedRequest(ctx context.Context, request Request, reqType reflect.Type) (reflect.Value, error) {
+	data, err := request.Read(ctx)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("unable to read a request: %v", err)
+	}
+
+	var req reflect.Value
+	if reqType.Kind() == reflect.Ptr {
+		req = reflect.New(reqType.Elem())
+	} else {
+		req = reflect.New(reqType)
+	}
+
+	if err := unmarshalPayload(data, req.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("unable to unpack a request: %v", err)
+	}
+
+	if reqType.Kind() != reflect.Ptr {
+		req = req.Elem()
+	}
+	return req, nil
+}
+
+func replyTypedError(response Response, err error) {
+	if serviceErr, ok := err.(*ServiceError); ok {
+		response.ErrorMsg(serviceErr.Code, serviceErr.Message)
+		return
+	}
+	if code, ok := translateError(err); ok {
+		sendError(response, code.Category, code.Code, err.Error(), nil)
+		return
+	}
+	response.ErrorMsg(ErrorInternal, err.Error())
+}
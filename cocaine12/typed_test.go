@@ -0,0 +1,85 @@
+package cocaine12
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+type sumRequest struct {
+	A int `codec:"a"`
+	B int `codec:"b"`
+}
+
+type sumResponse struct {
+	Sum int `codec:"sum"`
+}
+
+func packTyped(t *testing.T, v interface{}) []byte {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, payloadHandler).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestTypedHandler(t *testing.T) {
+	handler, err := TypedHandler(func(ctx context.Context, req *sumRequest) (sumResponse, error) {
+		if req.A < 0 {
+			return sumResponse{}, &ServiceError{ErrorBadRequest, "a must not be negative"}
+		}
+		if req.B < 0 {
+			return sumResponse{}, errors.New("b is negative")
+		}
+		return sumResponse{req.A + req.B}, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	res := new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, map[string]int{"a": 1, "b": 2})}, res)
+
+	var reply sumResponse
+	assert.NoError(t, codec.NewDecoderBytes(res.Bytes(), payloadHandler).Decode(&reply))
+	assert.Equal(t, sumResponse{3}, reply)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, sumRequest{A: -1})}, res)
+	assert.Equal(t, ErrorBadRequest, res.code)
+	assert.Equal(t, "a must not be negative", res.message)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, sumRequest{B: -1})}, res)
+	assert.Equal(t, ErrorInternal, res.code)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{[]byte{0xc1}}, res)
+	assert.Equal(t, ErrorBadRequest, res.code)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{}, res)
+	assert.Equal(t, ErrorBadRequest, res.code)
+}
+
+func TestTypedHandlerSignature(t *testing.T) {
+	for _, fn := range []interface{}{
+		42,
+		func(req sumRequest) (sumResponse, error) { return sumResponse{}, nil },
+		func(ctx context.Context, req sumRequest) sumResponse { return sumResponse{} },
+		func(ctx context.Context, req sumRequest) (sumResponse, string) { return sumResponse{}, "" },
+	} {
+		_, err := TypedHandler(fn)
+		assert.Error(t, err)
+	}
+
+	_, err := TypedHandler(func(ctx context.Context, req sumRequest) (*sumResponse, error) { return nil, nil })
+	assert.NoError(t, err)
+
+	w := &Worker{handlers: NewEventHandlers()}
+	assert.Panics(t, func() { w.OnTyped("sum", 42) })
+}
@@ -0,0 +1,235 @@
+package unicorn
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Snapshot is an immutable version of a configuration node.
+// Nested keys are separated by dots, e.g. "limits.rps".
+type Snapshot struct {
+	Node
+}
+
+// Lookup returns a raw value by the key
+func (s Snapshot) Lookup(key string) (interface{}, bool) {
+	var current = s.Value
+	for _, part := range strings.Split(key, ".") {
+		value, ok := lookupKey(current, part)
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+
+	return current, true
+}
+
+// String returns a string value by the key or the default value
+func (s Snapshot) String(key string, def string) string {
+	switch value, _ := s.Lookup(key); v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return def
+	}
+}
+
+// Int returns an integer value by the key or the default value
+func (s Snapshot) Int(key string, def int64) int64 {
+	switch value, _ := s.Lookup(key); v := value.(type) {
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	default:
+		return def
+	}
+}
+
+// Float returns a float value by the key or the default value
+func (s Snapshot) Float(key string, def float64) float64 {
+	switch value, _ := s.Lookup(key); v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	default:
+		return def
+	}
+}
+
+// Bool returns a boolean value by the key or the default value
+func (s Snapshot) Bool(key string, def bool) bool {
+	if value, ok := s.Lookup(key); ok {
+		if v, isBool := value.(bool); isBool {
+			return v
+		}
+	}
+	return def
+}
+
+// Durations returns a map of durations by the key, e.g. timeouts of methods
+// {"read": "50ms", "write": "500ms"} for Service.SetMethodTimeouts.
+// Numbers are treated as milliseconds. Invalid values are skipped.
+func (s Snapshot) Durations(key string) map[string]time.Duration {
+	value, ok := s.Lookup(key)
+	if !ok {
+		return nil
+	}
+
+	durations := make(map[string]time.Duration)
+	for _, name := range keys(value) {
+		raw, _ := lookupKey(value, name)
+		switch v := raw.(type) {
+		case string:
+			if d, err := time.ParseDuration(v); err == nil {
+				durations[name] = d
+			}
+		case []byte:
+			if d, err := time.ParseDuration(string(v)); err == nil {
+				durations[name] = d
+			}
+		case int64:
+			durations[name] = time.Duration(v) * time.Millisecond
+		case uint64:
+			durations[name] = time.Duration(v) * time.Millisecond
+		case float64:
+			durations[name] = time.Duration(v * float64(time.Millisecond))
+		}
+	}
+	return durations
+}
+
+// keys returns keys of a map value
+func keys(value interface{}) []string {
+	var names []string
+	switch m := value.(type) {
+	case map[interface{}]interface{}:
+		for k := range m {
+			switch k := k.(type) {
+			case string:
+				names = append(names, k)
+			case []byte:
+				names = append(names, string(k))
+			}
+		}
+	case map[string]interface{}:
+		for k := range m {
+			names = append(names, k)
+		}
+	}
+	return names
+}
+
+func lookupKey(value interface{}, key string) (interface{}, bool) {
+	switch m := value.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			switch k := k.(type) {
+			case string:
+				if k == key {
+					return v, true
+				}
+			case []byte:
+				if string(k) == key {
+					return v, true
+				}
+			}
+		}
+	case map[string]interface{}:
+		v, ok := m[key]
+		return v, ok
+	}
+
+	return nil, false
+}
+
+// Config keeps the latest version of an application configuration
+// stored in Unicorn and notifies listeners about changes
+type Config struct {
+	watcher *Watcher
+
+	mu        sync.RWMutex
+	current   Snapshot
+	listeners []func(Snapshot)
+}
+
+// NewConfig loads the node and starts watching it.
+// It blocks until the first value is received.
+func NewConfig(ctx context.Context, client *Client, path string) (*Config, error) {
+	watcher := client.Watch(context.Background(), path)
+
+	select {
+	case node, ok := <-watcher.Updates():
+		if !ok {
+			return nil, fmt.Errorf("unable to load config %s: %v", path, watcher.Err())
+		}
+
+		c := &Config{
+			watcher: watcher,
+			current: Snapshot{*node},
+		}
+		go c.loop()
+		return c, nil
+
+	case <-ctx.Done():
+		watcher.Close()
+		err := watcher.Err()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("unable to load config %s: %v", path, err)
+	}
+}
+
+// Snapshot returns the current version of the configuration
+func (c *Config) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+// OnChange registers the callback which is called
+// with every new version of the configuration
+func (c *Config) OnChange(listener func(Snapshot)) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners, listener)
+	c.mu.Unlock()
+}
+
+// Close stops watching the configuration
+func (c *Config) Close() {
+	c.watcher.Close()
+}
+
+func (c *Config) loop() {
+	for node := range c.watcher.Updates() {
+		c.update(*node)
+	}
+}
+
+func (c *Config) update(node Node) {
+	c.mu.Lock()
+	c.current = Snapshot{node}
+	listeners := append([]func(Snapshot){}, c.listeners...)
+	c.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(Snapshot{node})
+	}
+}
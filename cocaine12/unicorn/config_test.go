@@ -0,0 +1,82 @@
+package unicorn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+func testNode(t *testing.T, value interface{}) Node {
+	// emulate a value received from the wire
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, hNode).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded interface{}
+	if err := codec.NewDecoderBytes(buf, hNode).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	return Node{Value: decoded, Version: 1}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := Snapshot{testNode(t, map[string]interface{}{
+		"name":    "app",
+		"enabled": true,
+		"limits": map[string]interface{}{
+			"rps":   100,
+			"ratio": 0.5,
+		},
+	})}
+
+	assert.Equal(t, "app", s.String("name", ""))
+	assert.Equal(t, true, s.Bool("enabled", false))
+	assert.Equal(t, int64(100), s.Int("limits.rps", 0))
+	assert.Equal(t, 0.5, s.Float("limits.ratio", 0))
+
+	assert.Equal(t, "default", s.String("limits.absent", "default"))
+	assert.Equal(t, int64(7), s.Int("name", 7), "type mismatch returns the default")
+
+	var target struct {
+		Name   string `codec:"name"`
+		Limits struct {
+			Rps int `codec:"rps"`
+		} `codec:"limits"`
+	}
+	assert.NoError(t, s.Decode(&target))
+	assert.Equal(t, "app", target.Name)
+	assert.Equal(t, 100, target.Limits.Rps)
+}
+
+func TestConfigUpdate(t *testing.T) {
+	c := &Config{current: Snapshot{testNode(t, map[string]interface{}{"rps": 1})}}
+
+	var received []int64
+	c.OnChange(func(s Snapshot) {
+		received = append(received, s.Int("rps", 0))
+	})
+
+	c.update(testNode(t, map[string]interface{}{"rps": 2}))
+	assert.Equal(t, []int64{2}, received)
+	assert.Equal(t, int64(2), c.Snapshot().Int("rps", 0))
+}
+
+func TestSnapshotDurations(t *testing.T) {
+	s := Snapshot{testNode(t, map[string]interface{}{
+		"timeouts": map[string]interface{}{
+			"read":  "50ms",
+			"write": 500,
+			"bad":   "fast",
+		},
+	})}
+
+	assert.Equal(t, map[string]time.Duration{
+		"read":  50 * time.Millisecond,
+		"write": 500 * time.Millisecond,
+	}, s.Durations("timeouts"))
+	assert.Nil(t, s.Durations("absent"))
+}
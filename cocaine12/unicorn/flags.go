@@ -0,0 +1,131 @@
+package unicorn
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+// FeatureFlagsHeader overrides flags for a single request,
+// e.g. "new-search=true,batch-size=10"
+const FeatureFlagsHeader = "x-feature-flags"
+
+// FeatureFlags are flags stored as a map in a Unicorn node.
+// Flags may be overridden per request by FeatureFlagsHeader.
+type FeatureFlags struct {
+	config *Config
+
+	mu        sync.Mutex
+	last      Snapshot
+	listeners map[string][]func(value interface{})
+}
+
+// NewFeatureFlags loads flags from the node and starts watching it.
+// It blocks until the first value is received.
+func NewFeatureFlags(ctx context.Context, client *Client, path string) (*FeatureFlags, error) {
+	config, err := NewConfig(ctx, client, path)
+	if err != nil {
+		return nil, err
+	}
+	return newFeatureFlags(config), nil
+}
+
+func newFeatureFlags(config *Config) *FeatureFlags {
+	f := &FeatureFlags{
+		config:    config,
+		last:      config.Snapshot(),
+		listeners: make(map[string][]func(value interface{})),
+	}
+	config.OnChange(f.update)
+	return f
+}
+
+// Bool returns the value of the flag or the default value
+func (f *FeatureFlags) Bool(ctx context.Context, name string, def bool) bool {
+	if override, ok := overriddenFlag(ctx, name); ok {
+		if value, err := strconv.ParseBool(override); err == nil {
+			return value
+		}
+	}
+	return f.config.Snapshot().Bool(name, def)
+}
+
+// Int returns the value of the flag or the default value
+func (f *FeatureFlags) Int(ctx context.Context, name string, def int64) int64 {
+	if override, ok := overriddenFlag(ctx, name); ok {
+		if value, err := strconv.ParseInt(override, 10, 64); err == nil {
+			return value
+		}
+	}
+	return f.config.Snapshot().Int(name, def)
+}
+
+// String returns the value of the flag or the default value
+func (f *FeatureFlags) String(ctx context.Context, name string, def string) string {
+	if override, ok := overriddenFlag(ctx, name); ok {
+		return override
+	}
+	return f.config.Snapshot().String(name, def)
+}
+
+// OnChange registers the callback which is called with the new raw value
+// of the flag when it changes. The value is nil if the flag is removed.
+// Overrides don't trigger callbacks.
+func (f *FeatureFlags) OnChange(name string, listener func(value interface{})) {
+	f.mu.Lock()
+	f.listeners[name] = append(f.listeners[name], listener)
+	f.mu.Unlock()
+}
+
+// Close stops watching flags
+func (f *FeatureFlags) Close() {
+	f.config.Close()
+}
+
+func (f *FeatureFlags) update(s Snapshot) {
+	type change struct {
+		listeners []func(value interface{})
+		value     interface{}
+	}
+
+	f.mu.Lock()
+	var changes []change
+	for name, listeners := range f.listeners {
+		previous, _ := f.last.Lookup(name)
+		current, _ := s.Lookup(name)
+		if !reflect.DeepEqual(previous, current) {
+			changes = append(changes, change{append([]func(value interface{}){}, listeners...), current})
+		}
+	}
+	f.last = s
+	f.mu.Unlock()
+
+	for _, c := range changes {
+		for _, listener := range c.listeners {
+			listener(c.value)
+		}
+	}
+}
+
+// overriddenFlag looks up the flag in FeatureFlagsHeader of the request
+func overriddenFlag(ctx context.Context, name string) (string, bool) {
+	md, ok := cocaine.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, header := range md.Get(FeatureFlagsHeader) {
+		for _, pair := range strings.Split(header, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+				return strings.TrimSpace(kv[1]), true
+			}
+		}
+	}
+	return "", false
+}
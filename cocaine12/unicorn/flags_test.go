@@ -0,0 +1,50 @@
+package unicorn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	config := &Config{current: Snapshot{testNode(t, map[string]interface{}{
+		"new-search": false,
+		"batch-size": 10,
+		"backend":    "old",
+	})}}
+	flags := newFeatureFlags(config)
+
+	ctx := context.Background()
+	assert.False(t, flags.Bool(ctx, "new-search", true))
+	assert.Equal(t, int64(10), flags.Int(ctx, "batch-size", 0))
+	assert.Equal(t, "old", flags.String(ctx, "backend", ""))
+	assert.Equal(t, "default", flags.String(ctx, "absent", "default"))
+
+	ctx = cocaine.NewIncomingContext(ctx, cocaine.Pairs(FeatureFlagsHeader, "new-search=true, batch-size=20"))
+	assert.True(t, flags.Bool(ctx, "new-search", false))
+	assert.Equal(t, int64(20), flags.Int(ctx, "batch-size", 0))
+	assert.Equal(t, "old", flags.String(ctx, "backend", ""))
+
+	var changes []interface{}
+	flags.OnChange("batch-size", func(value interface{}) {
+		changes = append(changes, value)
+	})
+
+	config.update(testNode(t, map[string]interface{}{
+		"new-search": true,
+		"batch-size": 10,
+	}))
+	assert.Empty(t, changes, "batch-size is not changed")
+
+	config.update(testNode(t, map[string]interface{}{
+		"batch-size": 30,
+	}))
+	config.update(testNode(t, map[string]interface{}{}))
+	if assert.Len(t, changes, 2) {
+		assert.EqualValues(t, 30, changes[0])
+		assert.Nil(t, changes[1])
+	}
+}
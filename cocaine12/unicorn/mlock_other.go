@@ -0,0 +1,8 @@
+// +build !linux,!darwin,!freebsd
+
+package unicorn
+
+// memory can't be locked on this platform, values are only wiped
+func lockMemory(buf []byte) {}
+
+func unlockMemory(buf []byte) {}
@@ -0,0 +1,21 @@
+// +build linux darwin freebsd
+
+package unicorn
+
+import (
+	"syscall"
+)
+
+// lockMemory prevents the buffer from being swapped out.
+// It's best effort, as the limit of locked memory may be low.
+func lockMemory(buf []byte) {
+	if len(buf) > 0 {
+		syscall.Mlock(buf)
+	}
+}
+
+func unlockMemory(buf []byte) {
+	if len(buf) > 0 {
+		syscall.Munlock(buf)
+	}
+}
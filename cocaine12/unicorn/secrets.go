@@ -0,0 +1,177 @@
+package unicorn
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Secrets keeps secrets stored in Unicorn under a common path,
+// e.g. /secrets/echo. A secret is a node holding a string.
+// Values are cached in memory locked against swapping
+// and updated when secrets are rotated.
+type Secrets struct {
+	client *Client
+	root   string
+
+	mu      sync.Mutex
+	secrets map[string]*secret
+	closed  bool
+}
+
+// NewSecrets creates Secrets reading nodes under the root path
+func NewSecrets(client *Client, root string) *Secrets {
+	return &Secrets{
+		client:  client,
+		root:    root,
+		secrets: make(map[string]*secret),
+	}
+}
+
+// GetSecret returns a copy of the current value of the secret.
+// The secret is loaded on the first request, which blocks until
+// the value is received, and watched for rotation after that.
+func (s *Secrets) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	sec, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-sec.loaded:
+		return sec.get()
+	case <-ctx.Done():
+		err := sec.watcher.Err()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("unable to load secret %s: %v", name, err)
+	}
+}
+
+// Close stops watching secrets and wipes their values
+func (s *Secrets) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for name, sec := range s.secrets {
+		sec.close()
+		delete(s.secrets, name)
+	}
+}
+
+func (s *Secrets) lookup(name string) (*secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("secrets are closed")
+	}
+
+	sec, ok := s.secrets[name]
+	if !ok {
+		sec = newSecret(s.client.Watch(context.Background(), s.root+"/"+name))
+		s.secrets[name] = sec
+		go sec.loop()
+	}
+	return sec, nil
+}
+
+type secret struct {
+	watcher *Watcher
+	// closed once the first value is received
+	loaded chan struct{}
+	once   sync.Once
+
+	mu    sync.RWMutex
+	value []byte
+	err   error
+	// set by close, so a late update doesn't keep a value
+	closed bool
+}
+
+func newSecret(watcher *Watcher) *secret {
+	return &secret{
+		watcher: watcher,
+		loaded:  make(chan struct{}),
+	}
+}
+
+func (s *secret) loop() {
+	for node := range s.watcher.Updates() {
+		s.update(*node)
+	}
+}
+
+func (s *secret) get() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	return append([]byte(nil), s.value...), nil
+}
+
+func (s *secret) update(node Node) {
+	var (
+		value []byte
+		err   error
+	)
+
+	switch v := node.Value.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	default:
+		err = fmt.Errorf("secret %s is %T, not a string", s.watcher.path, node.Value)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		wipe(value)
+		return
+	}
+
+	// the decoded node is copied into locked memory
+	// and wiped to leave no other copies
+	locked := append([]byte(nil), value...)
+	lockMemory(locked)
+	wipe(value)
+
+	s.forget()
+	s.value, s.err = locked, err
+	s.mu.Unlock()
+
+	s.once.Do(func() { close(s.loaded) })
+}
+
+func (s *secret) close() {
+	s.watcher.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	s.forget()
+	s.err = fmt.Errorf("secrets are closed")
+	s.mu.Unlock()
+}
+
+// forget wipes and unlocks the current value
+func (s *secret) forget() {
+	if s.value == nil {
+		return
+	}
+	wipe(s.value)
+	unlockMemory(s.value)
+	s.value = nil
+}
+
+func wipe(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
@@ -0,0 +1,44 @@
+package unicorn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretRotation(t *testing.T) {
+	s := newSecret(&Watcher{path: "/secrets/app/db", cancel: func() {}})
+
+	s.update(Node{Value: []byte("first"), Version: 1})
+	select {
+	case <-s.loaded:
+	default:
+		t.Fatal("secret is not loaded")
+	}
+
+	value, err := s.get()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+
+	old := s.value
+	s.update(Node{Value: "second", Version: 2})
+	assert.Equal(t, make([]byte, len(old)), old, "the previous value must be wiped")
+	value, _ = s.get()
+	assert.Equal(t, []byte("second"), value)
+
+	s.update(Node{Value: int64(1), Version: 3})
+	_, err = s.get()
+	assert.Error(t, err)
+
+	s.update(Node{Value: "third", Version: 4})
+	s.close()
+	_, err = s.get()
+	assert.Error(t, err)
+	assert.Nil(t, s.value)
+
+	// an update racing with close is wiped
+	late := []byte("late")
+	s.update(Node{Value: late, Version: 5})
+	assert.Nil(t, s.value)
+	assert.Equal(t, make([]byte, len(late)), late)
+}
@@ -0,0 +1,192 @@
+// Package unicorn provides a client for the Unicorn configuration service
+// of Cocaine and primitives to watch configuration nodes
+package unicorn
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	cocaine "github.com/cocaine/cocaine-framework-go/cocaine12"
+)
+
+const (
+	serviceName = "unicorn"
+
+	resubscribeMinDelay = time.Millisecond * 100
+	resubscribeMaxDelay = time.Second * 10
+)
+
+var (
+	mhNode codec.MsgpackHandle
+	hNode  = &mhNode
+)
+
+// Node is a value of a Unicorn node with its version
+type Node struct {
+	Value   interface{}
+	Version int64
+}
+
+// Decode unpacks the value of the node into the target
+func (n *Node) Decode(target interface{}) error {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, hNode).Encode(n.Value); err != nil {
+		return err
+	}
+	return codec.NewDecoderBytes(buf, hNode).Decode(target)
+}
+
+// Client wraps the Unicorn service
+type Client struct {
+	service *cocaine.Service
+}
+
+// NewClient connects to Unicorn using given locators
+func NewClient(ctx context.Context, endpoints ...string) (*Client, error) {
+	service, err := cocaine.NewService(ctx, serviceName, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{service: service}, nil
+}
+
+// Close disposes the connection
+func (c *Client) Close() {
+	c.service.Close()
+}
+
+// Get returns the current value of the node
+func (c *Client) Get(ctx context.Context, path string) (*Node, error) {
+	channel, err := c.service.Call(ctx, "get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return readNode(ctx, channel)
+}
+
+// Watch subscribes to changes of the node. The current value is
+// delivered first. The subscription is restored if it breaks,
+// until the context is canceled or Close is called.
+func (c *Client) Watch(ctx context.Context, path string) *Watcher {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{
+		client:  c,
+		path:    path,
+		updates: make(chan *Node, 1),
+		cancel:  cancel,
+	}
+
+	go w.loop(ctx)
+	return w
+}
+
+func readNode(ctx context.Context, channel cocaine.Channel) (*Node, error) {
+	res, err := channel.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var node Node
+	if err := res.ExtractTuple(&node.Value, &node.Version); err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+// Watcher delivers updates of a Unicorn node
+type Watcher struct {
+	client  *Client
+	path    string
+	updates chan *Node
+	cancel  context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Updates returns the channel of new values of the node.
+// It's closed after the watcher is closed.
+func (w *Watcher) Updates() <-chan *Node {
+	return w.updates
+}
+
+// Err returns the last subscription error
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops watching
+func (w *Watcher) Close() {
+	w.cancel()
+}
+
+func (w *Watcher) setErr(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+func (w *Watcher) loop(ctx context.Context) {
+	defer close(w.updates)
+
+	var (
+		delay       = resubscribeMinDelay
+		lastVersion = int64(-1)
+	)
+
+	for {
+		err := w.subscribe(ctx, &lastVersion)
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.setErr(fmt.Errorf("subscription to %s is broken: %v", w.path, err))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		if delay *= 2; delay > resubscribeMaxDelay {
+			delay = resubscribeMaxDelay
+		}
+	}
+}
+
+func (w *Watcher) subscribe(ctx context.Context, lastVersion *int64) error {
+	channel, err := w.client.service.Call(ctx, "subscribe", w.path)
+	if err != nil {
+		return err
+	}
+
+	for {
+		node, err := readNode(ctx, channel)
+		if err != nil {
+			return err
+		}
+
+		w.setErr(nil)
+
+		// a value is repeated after resubscription
+		if node.Version == *lastVersion {
+			continue
+		}
+		*lastVersion = node.Version
+
+		select {
+		case w.updates <- node:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package cocaine12
+
+import (
+	"sync/atomic"
+)
+
+// WorkerLoad describes the current load of the worker
+type WorkerLoad struct {
+	// InFlight is the number of handlers being executed
+	InFlight int
+	// QueueDepth is the number of open incoming streams
+	QueueDepth int
+	// Limit is the concurrency limit. It's 0 if the worker is not limited
+	Limit int
+}
+
+// Utilization returns the ratio of running handlers to the limit.
+// It returns 0 if the worker is not limited
+func (l WorkerLoad) Utilization() float64 {
+	if l.Limit <= 0 {
+		return 0
+	}
+	return float64(l.InFlight) / float64(l.Limit)
+}
+
+type loadCounters struct {
+	inFlight   int64
+	queueDepth int64
+}
+
+func (c *loadCounters) handlerStarted() {
+	atomic.AddInt64(&c.inFlight, 1)
+}
+
+func (c *loadCounters) handlerFinished() {
+	atomic.AddInt64(&c.inFlight, -1)
+}
+
+func (c *loadCounters) setQueueDepth(depth int) {
+	atomic.StoreInt64(&c.queueDepth, int64(depth))
+}
+
+func (c *loadCounters) snapshot(limiter ConcurrencyLimiter) WorkerLoad {
+	load := WorkerLoad{
+		InFlight:   int(atomic.LoadInt64(&c.inFlight)),
+		QueueDepth: int(atomic.LoadInt64(&c.queueDepth)),
+	}
+
+	if limiter != nil {
+		load.Limit = limiter.Limit()
+	}
+
+	return load
+}
@@ -11,17 +11,19 @@ import (
 var (
 	mPayloadHandler codec.MsgpackHandle
 	payloadHandler  = &mPayloadHandler
+
+	// convertHandler packs payloads in convertPayload using raw types
+	// only, because they can be decoded into both strings and []byte
+	mConvertHandler codec.MsgpackHandle
+	convertHandler  = &mConvertHandler
 )
 
 func convertPayload(in interface{}, out interface{}) error {
 	var buf []byte
-	if err := codec.NewEncoderBytes(&buf, payloadHandler).Encode(in); err != nil {
-		return err
-	}
-	if err := codec.NewDecoderBytes(buf, payloadHandler).Decode(out); err != nil {
+	if err := codec.NewEncoderBytes(&buf, convertHandler).Encode(in); err != nil {
 		return err
 	}
-	return nil
+	return unmarshalPayload(buf, out)
 }
 
 type ReaderWithContext interface {
@@ -75,7 +75,7 @@ func TestRequestReaderErrorV1(t *testing.T) {
 	}
 
 	err := dec.Decode(&actual)
-	expectedV1 := &ErrRequest{"error", 100, 200}
+	expectedV1 := &ErrRequest{Message: "error", Category: 100, Code: 200}
 	assert.EqualError(t, err, expectedV1.Error())
 }
 
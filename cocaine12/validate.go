@@ -0,0 +1,76 @@
+package cocaine12
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Validator is implemented by typed requests checking themselves
+// after they are unpacked. See TypedHandler
+type Validator interface {
+	Validate() error
+}
+
+// ValidateFunc checks a typed request, e.g. it might call
+// Struct of go-playground/validator
+type ValidateFunc func(req interface{}) error
+
+// FieldError describes an invalid field of a request
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists invalid fields of a request.
+// Return it from Validate to have every field reported.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var messages = make([]string, len(e))
+	for i, field := range e {
+		messages[i] = field.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+var (
+	validateMu   sync.RWMutex
+	validateFunc ValidateFunc
+)
+
+// SetValidateFunc sets the function checking typed requests
+// before Validator is called. Pass nil to remove it.
+func SetValidateFunc(fn ValidateFunc) {
+	validateMu.Lock()
+	validateFunc = fn
+	validateMu.Unlock()
+}
+
+// validateTypedRequest runs ValidateFunc and Validator of the request
+func validateTypedRequest(req interface{}) error {
+	validateMu.RLock()
+	fn := validateFunc
+	validateMu.RUnlock()
+
+	if fn != nil {
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := req.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// invalidArgument formats the message of ErrorBadRequest
+// sent in reply to an invalid typed request
+func invalidArgument(err error) string {
+	return fmt.Sprintf("invalid argument: %v", err)
+}
@@ -0,0 +1,64 @@
+package cocaine12
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type rangeRequest struct {
+	From int `codec:"from"`
+	To   int `codec:"to"`
+}
+
+func (r *rangeRequest) Validate() error {
+	var errs ValidationErrors
+	if r.From < 0 {
+		errs = append(errs, FieldError{"from", "must not be negative"})
+	}
+	if r.To < r.From {
+		errs = append(errs, FieldError{"to", "must not be less than from"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func TestTypedHandlerValidation(t *testing.T) {
+	called := false
+	handler, err := TypedHandler(func(ctx context.Context, req rangeRequest) (int, error) {
+		called = true
+		return req.To - req.From, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	res := new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, rangeRequest{From: -1, To: -2})}, res)
+	assert.False(t, called)
+	assert.Equal(t, ErrorBadRequest, res.code)
+	assert.Equal(t, "invalid argument: from: must not be negative; to: must not be less than from", res.message)
+
+	SetValidateFunc(func(req interface{}) error {
+		if req.(*rangeRequest).To > 100 {
+			return errors.New("range is too wide")
+		}
+		return nil
+	})
+	defer SetValidateFunc(nil)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, rangeRequest{From: 0, To: 1000})}, res)
+	assert.False(t, called)
+	assert.Equal(t, "invalid argument: range is too wide", res.message)
+
+	res = new(testResponse)
+	handler(ctx, &testRequest{packTyped(t, rangeRequest{From: 1, To: 10})}, res)
+	assert.True(t, called)
+	assert.Equal(t, 0, res.code)
+}
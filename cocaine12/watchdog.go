@@ -0,0 +1,134 @@
+package cocaine12
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWatchdogInterval = time.Second
+
+// MemoryLimits describes limits checked by the memory watchdog
+type MemoryLimits struct {
+	// Soft is the RSS in bytes which makes the watchdog collect garbage,
+	// return memory to the OS and call the warning handler.
+	// Zero disables the limit
+	Soft uint64
+	// Hard is the RSS in bytes which makes the worker terminate
+	// gracefully before the OOM killer does it. Zero disables the limit
+	Hard uint64
+	// Interval between checks, one second by default
+	Interval time.Duration
+}
+
+// MemoryUsage describes the memory used by the process
+type MemoryUsage struct {
+	// RSS is the resident set size in bytes.
+	// It's reported as memory obtained from the OS if RSS is unknown
+	RSS uint64
+	// HeapAlloc is bytes of allocated heap objects
+	HeapAlloc uint64
+}
+
+// MemoryWarningHandler is called once RSS exceeds the soft limit
+type MemoryWarningHandler func(usage MemoryUsage)
+
+// SetMemoryLimits starts the watchdog which checks memory usage
+// of the process while the worker runs. At the hard limit the worker
+// terminates gracefully as it does after SetMaxRequests.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetMemoryLimits(limits MemoryLimits) {
+	if limits.Interval <= 0 {
+		limits.Interval = defaultWatchdogInterval
+	}
+	w.memoryLimits = limits
+}
+
+// OnMemoryWarning attaches the handler called in a separate goroutine
+// when the soft limit is exceeded. It's called again only after
+// the usage has dropped below the limit.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) OnMemoryWarning(handler MemoryWarningHandler) {
+	w.memoryWarningHandler = handler
+}
+
+// watchMemory checks memory usage until done is closed.
+// The usage is sent to exceeded once the hard limit is reached.
+func (w *WorkerNG) watchMemory(done <-chan struct{}, exceeded chan<- MemoryUsage) {
+	var (
+		limits = w.memoryLimits
+		warned = false
+	)
+
+	for {
+		select {
+		case <-w.clock.After(limits.Interval):
+		case <-done:
+			return
+		}
+
+		usage, err := w.memoryUsage()
+		if err != nil {
+			continue
+		}
+
+		if limits.Soft > 0 && usage.RSS >= limits.Soft {
+			debug.FreeOSMemory()
+			if !warned {
+				warned = true
+				if handler := w.memoryWarningHandler; handler != nil {
+					go handler(usage)
+				}
+			}
+		} else {
+			warned = false
+		}
+
+		if limits.Hard > 0 && usage.RSS >= limits.Hard {
+			select {
+			case exceeded <- usage:
+			case <-done:
+			}
+			return
+		}
+	}
+}
+
+func readMemoryUsage() (MemoryUsage, error) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	usage := MemoryUsage{
+		RSS:       stats.Sys,
+		HeapAlloc: stats.HeapAlloc,
+	}
+
+	if rss, err := readRSS(); err == nil {
+		usage.RSS = rss
+	}
+	return usage, nil
+}
+
+// readRSS reads the resident set size from procfs
+func readRSS() (uint64, error) {
+	data, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed statm: %q", data)
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
@@ -0,0 +1,81 @@
+package cocaine12
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMemoryUsage(t *testing.T) {
+	usage, err := readMemoryUsage()
+	assert.NoError(t, err)
+	assert.True(t, usage.RSS > 0)
+	assert.True(t, usage.HeapAlloc > 0)
+}
+
+func TestWorkerMemoryWatchdog(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	clock := NewManualClock(time.Now())
+	w.SetClock(clock)
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetMemoryLimits(MemoryLimits{Soft: 100, Hard: 200})
+
+	var rss uint64
+	w.impl.memoryUsage = func() (MemoryUsage, error) {
+		return MemoryUsage{RSS: atomic.LoadUint64(&rss)}, nil
+	}
+
+	warnings := make(chan MemoryUsage, 1)
+	w.OnMemoryWarning(func(usage MemoryUsage) {
+		warnings <- usage
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Run(map[string]EventHandler{})
+	}()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	atomic.StoreUint64(&rss, 150)
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(defaultWatchdogInterval)
+	select {
+	case usage := <-warnings:
+		assert.Equal(t, uint64(150), usage.RSS)
+	case <-time.After(time.Second):
+		t.Fatal("the soft limit is not reported")
+	}
+
+	atomic.StoreUint64(&rss, 250)
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(defaultWatchdogInterval)
+	msg := <-sock2.Read()
+	checkTypeAndSession(t, msg, v1UtilitySession, v1Terminate)
+	assert.Equal(t,
+		TerminationReason{TerminationNormal, fmt.Sprintf("RSS %d exceeds the hard limit %d", 250, 200)},
+		parseTerminationReason(msg))
+
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(drainInterval)
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker is not terminated")
+	}
+	// the soft limit is reported once
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,103 @@
+package cocaine12
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	wireDebugKey = "COCAINE_WIRE_DEBUG"
+
+	// how many bytes of a payload are printed by the default sink
+	wireDebugPayloadLimit = 64
+)
+
+// WireDirection tells whether a frame is sent or received
+type WireDirection int
+
+const (
+	// WireSent marks outgoing frames
+	WireSent WireDirection = iota
+	// WireReceived marks incoming frames
+	WireReceived
+)
+
+func (d WireDirection) String() string {
+	if d == WireSent {
+		return "->"
+	}
+	return "<-"
+}
+
+// WireFrame describes a frame passing through a connection
+type WireFrame struct {
+	Direction WireDirection
+	Session   uint64
+	MsgType   uint64
+	Payload   []interface{}
+	Headers   CocaineHeaders
+}
+
+// WireSink receives every frame sent or received by the framework.
+// It's called synchronously from IO loops, so it must be fast.
+type WireSink func(frame WireFrame)
+
+type wireSinkHolder struct {
+	sink WireSink
+}
+
+var wireSink atomic.Value
+
+func init() {
+	var sink WireSink
+	if os.Getenv(wireDebugKey) != "" {
+		sink = NewWireWriterSink(os.Stderr)
+	}
+	SetWireSink(sink)
+}
+
+// SetWireSink sets the sink for frames of all connections.
+// nil disables wire debugging. It's enabled by COCAINE_WIRE_DEBUG
+// variable with the sink writing to stderr.
+func SetWireSink(sink WireSink) {
+	wireSink.Store(wireSinkHolder{sink})
+}
+
+// NewWireWriterSink returns the sink printing frames to the writer.
+// A payload is printed as truncated hex of its msgpack representation.
+// Values of sensitive headers are redacted.
+func NewWireWriterSink(w io.Writer) WireSink {
+	return func(frame WireFrame) {
+		var packed []byte
+		codec.NewEncoderBytes(&packed, hAsocket).Encode(frame.Payload)
+
+		var suffix string
+		if len(packed) > wireDebugPayloadLimit {
+			suffix = fmt.Sprintf("... (%d bytes)", len(packed))
+			packed = packed[:wireDebugPayloadLimit]
+		}
+
+		fmt.Fprintf(w, "wire %s session %d type %d payload %s%s headers %s\n",
+			frame.Direction, frame.Session, frame.MsgType, hex.EncodeToString(packed), suffix, formatHeaders(frame.Headers))
+	}
+}
+
+func traceWire(direction WireDirection, msg *Message) {
+	sink := wireSink.Load().(wireSinkHolder).sink
+	if sink == nil {
+		return
+	}
+
+	sink(WireFrame{
+		Direction: direction,
+		Session:   msg.Session,
+		MsgType:   msg.MsgType,
+		Payload:   msg.Payload,
+		Headers:   msg.Headers,
+	})
+}
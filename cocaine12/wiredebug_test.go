@@ -0,0 +1,48 @@
+package cocaine12
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWireSink(t *testing.T) {
+	var frames = make(chan WireFrame, 10)
+	SetWireSink(func(frame WireFrame) {
+		frames <- frame
+	})
+	defer SetWireSink(nil)
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock.Close()
+	defer sock2.Close()
+
+	sock.Write() <- newChunkV1(5, []byte("data"))
+	<-sock2.Read()
+
+	sent, received := <-frames, <-frames
+	if sent.Direction != WireSent {
+		sent, received = received, sent
+	}
+
+	assert.Equal(t, WireSent, sent.Direction)
+	assert.Equal(t, WireReceived, received.Direction)
+	assert.Equal(t, uint64(5), received.Session)
+	assert.Equal(t, uint64(v1Write), received.MsgType)
+}
+
+func TestWireWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWireWriterSink(&buf)
+
+	sink(WireFrame{Direction: WireReceived, Session: 2, MsgType: 0, Payload: []interface{}{"event"}})
+	assert.Equal(t, "wire <- session 2 type 0 payload 91a56576656e74 headers []\n", buf.String())
+
+	buf.Reset()
+	sink(WireFrame{Direction: WireSent, Session: 2, MsgType: 0, Payload: []interface{}{make([]byte, 100)}})
+	assert.True(t, strings.Contains(buf.String(), "... (103 bytes)"), buf.String())
+}
@@ -1,5 +1,14 @@
 package cocaine12
 
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
 // Worker performs IO operations between an application
 // and cocaine-runtime, dispatches incoming messages
 // This is an adapter to WorkerNG
@@ -7,6 +16,7 @@ type Worker struct {
 	impl               *WorkerNG
 	handlers           *EventHandlers
 	terminationHandler TerminationHandler
+	health             *healthChecks
 }
 
 // NewWorker connects to the cocaine-runtime and create WorkerNG on top of this connection
@@ -15,7 +25,23 @@ func NewWorker() (*Worker, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Worker{impl, NewEventHandlers(), nil}, nil
+	return &Worker{impl, NewEventHandlers(), nil, newHealthChecks()}, nil
+}
+
+// NewWorkerFromHandoff takes over the connection to cocaine-runtime
+// from the predecessor listening on path. See WorkerNG.Handoff
+func NewWorkerFromHandoff(path string) (*Worker, error) {
+	impl, err := NewWorkerNGFromHandoff(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{impl, NewEventHandlers(), nil, newHealthChecks()}, nil
+}
+
+// Handoff passes the connection to cocaine-runtime to a successor process.
+// See WorkerNG.Handoff
+func (w *Worker) Handoff(ctx context.Context, path string) error {
+	return w.impl.Handoff(ctx, path)
 }
 
 // Used in tests only
@@ -24,7 +50,48 @@ func newWorker(conn socketIO, id string, protoVersion int, debug bool) (*Worker,
 	if err != nil {
 		return nil, err
 	}
-	return &Worker{impl, NewEventHandlers(), nil}, nil
+	return &Worker{impl, NewEventHandlers(), nil, newHealthChecks()}, nil
+}
+
+// ProtocolVersion returns the version of the protocol the worker
+// speaks: the one passed by cocaine-runtime or the latest supported
+func (w *Worker) ProtocolVersion() int {
+	return w.impl.ProtocolVersion()
+}
+
+// RuntimeInfo returns what the runtime has advertised about itself.
+// See WorkerNG.RuntimeInfo
+func (w *Worker) RuntimeInfo() RuntimeInfo {
+	return w.impl.RuntimeInfo()
+}
+
+// SetClock replaces the source of time. See WorkerNG.SetClock
+func (w *Worker) SetClock(clock Clock) {
+	w.impl.SetClock(clock)
+}
+
+// SetHeartbeatInterval sets how often the worker sends heartbeats.
+// See WorkerNG.SetHeartbeatInterval
+func (w *Worker) SetHeartbeatInterval(interval time.Duration) {
+	w.impl.SetHeartbeatInterval(interval)
+}
+
+// SetDisownTimeout sets how long the worker waits for a heartbeat reply.
+// See WorkerNG.SetDisownTimeout
+func (w *Worker) SetDisownTimeout(timeout time.Duration) {
+	w.impl.SetDisownTimeout(timeout)
+}
+
+// SetReconnectWindow sets how long the worker tries to reconnect
+// to cocaine-runtime. See WorkerNG.SetReconnectWindow
+func (w *Worker) SetReconnectWindow(window time.Duration) {
+	w.impl.SetReconnectWindow(window)
+}
+
+// OnHeartbeatMissed attaches the handler notified about late heartbeat replies.
+// See WorkerNG.OnHeartbeatMissed
+func (w *Worker) OnHeartbeatMissed(handler HeartbeatMissedHandler) {
+	w.impl.OnHeartbeatMissed(handler)
 }
 
 // SetDebug enables debug mode of the Worker.
@@ -40,20 +107,230 @@ func (w *Worker) EnableStackSignal(enable bool) {
 	w.impl.EnableStackSignal(enable)
 }
 
+// SetConcurrencyLimiter attaches the limiter which protects the worker
+// from being overloaded. See WorkerNG.SetConcurrencyLimiter
+func (w *Worker) SetConcurrencyLimiter(limiter ConcurrencyLimiter) {
+	w.impl.SetConcurrencyLimiter(limiter)
+}
+
+// LimitToCPUQuota adapts the worker to a cgroup CPU quota.
+// See WorkerNG.LimitToCPUQuota
+func (w *Worker) LimitToCPUQuota() bool {
+	return w.impl.LimitToCPUQuota()
+}
+
+// SetSampler sets the policy which decides whether a request is traced.
+// See WorkerNG.SetSampler
+func (w *Worker) SetSampler(sampler Sampler) {
+	w.impl.SetSampler(sampler)
+}
+
+// SetCompression enables compression of response chunks.
+// See WorkerNG.SetCompression
+func (w *Worker) SetCompression(compression Compression) error {
+	return w.impl.SetCompression(compression)
+}
+
+// EnableChecksums attaches checksums to response chunks.
+// See WorkerNG.EnableChecksums
+func (w *Worker) EnableChecksums(enable bool) {
+	w.impl.EnableChecksums(enable)
+}
+
+// SetMaxRequests makes the worker recycle itself after n requests.
+// See WorkerNG.SetMaxRequests
+func (w *Worker) SetMaxRequests(n int) {
+	w.impl.SetMaxRequests(n)
+}
+
+// SetMaxUptime makes the worker recycle itself after it has run for d.
+// See WorkerNG.SetMaxUptime
+func (w *Worker) SetMaxUptime(d time.Duration) {
+	w.impl.SetMaxUptime(d)
+}
+
+// SetMemoryLimits starts the memory watchdog. See WorkerNG.SetMemoryLimits
+func (w *Worker) SetMemoryLimits(limits MemoryLimits) {
+	w.impl.SetMemoryLimits(limits)
+}
+
+// OnMemoryWarning attaches the handler called at the soft memory limit.
+// See WorkerNG.OnMemoryWarning
+func (w *Worker) OnMemoryWarning(handler MemoryWarningHandler) {
+	w.impl.OnMemoryWarning(handler)
+}
+
+// SetHuffmanThreshold makes the worker code long values of response
+// headers. See WorkerNG.SetHuffmanThreshold
+func (w *Worker) SetHuffmanThreshold(threshold int) {
+	w.impl.SetHuffmanThreshold(threshold)
+}
+
+// SetRequestWatermarks bounds the number of chunks queued for a handler.
+// See WorkerNG.SetRequestWatermarks
+func (w *Worker) SetRequestWatermarks(high, low int) {
+	w.impl.SetRequestWatermarks(high, low)
+}
+
+// SetLeakTimeout sets how long an idle session is kept after its handler
+// has returned. See WorkerNG.SetLeakTimeout
+func (w *Worker) SetLeakTimeout(timeout time.Duration) {
+	w.impl.SetLeakTimeout(timeout)
+}
+
+// EnableLoadReport allows/disallows the worker to report its load
+// to cocaine-runtime. See WorkerNG.EnableLoadReport
+func (w *Worker) EnableLoadReport(enable bool) {
+	w.impl.EnableLoadReport(enable)
+}
+
+// EnableRuntimeExtensions makes the worker trust extensions advertised
+// by the runtime. See WorkerNG.EnableRuntimeExtensions
+func (w *Worker) EnableRuntimeExtensions(enable bool) {
+	w.impl.EnableRuntimeExtensions(enable)
+}
+
+// Load returns the current load of the worker
+func (w *Worker) Load() WorkerLoad {
+	return w.impl.Load()
+}
+
+// Stats returns a snapshot of per-event statistics
+func (w *Worker) Stats() map[string]EventStats {
+	return w.impl.Stats()
+}
+
 // Token returns the most recently viewed version of the authorization token.
 func (w *Worker) Token() Token {
 	return w.impl.Token()
 }
 
+// EnableDebugEvent registers/unregisters the built-in DebugEvent handler,
+// which serves pprof profiles, goroutine dumps and the list of events.
+// It's disabled by default.
+func (w *Worker) EnableDebugEvent(enable bool) {
+	if enable {
+		w.handlers.On(DebugEvent, debugHandler(w.handlers))
+	} else {
+		w.handlers.Off(DebugEvent)
+	}
+}
+
+// AddHealthCheck registers the check which is run on every request
+// to HealthEvent. The event is registered along with the first check.
+// The worker replies with ErrorNotReady if any check fails.
+func (w *Worker) AddHealthCheck(name string, check HealthCheck) {
+	w.health.add(name, check)
+	w.handlers.On(HealthEvent, healthHandler(w.health))
+}
+
+// RemoveHealthCheck unregisters the check
+func (w *Worker) RemoveHealthCheck(name string) {
+	w.health.remove(name)
+}
+
+// Health runs all health checks and returns the aggregated result
+func (w *Worker) Health(ctx context.Context) HealthStatus {
+	return w.health.run(ctx)
+}
+
 // SetTerminationHandler allows to attach handler which will be called
 // when SIGTERM arrives
 func (w *Worker) SetTerminationHandler(handler TerminationHandler) {
 	w.terminationHandler = handler
 }
 
-// On binds the handler for a given event
-func (w *Worker) On(event string, handler EventHandler) {
-	w.handlers.On(event, handler)
+// OnShutdown attaches the handler which receives the reason
+// of termination. See WorkerNG.OnShutdown
+func (w *Worker) OnShutdown(handler ShutdownHandler) {
+	w.impl.OnShutdown(handler)
+}
+
+// Terminate stops the worker notifying cocaine-runtime about the reason
+func (w *Worker) Terminate(reason TerminationReason) {
+	w.impl.Terminate(reason)
+}
+
+// RecordTo makes the worker write its traffic to out.
+// See WorkerNG.RecordTo
+func (w *Worker) RecordTo(out io.Writer) {
+	w.impl.RecordTo(out)
+}
+
+// On binds the handler for a given event, which may be a pattern
+// like "images.*", see EventWildcard. Options of a pattern apply
+// to all events it matches. Handlers may be bound and replaced
+// after Run has started.
+func (w *Worker) On(event string, handler EventHandler, opts ...EventOption) {
+	w.on(event, handler, nil, opts)
+}
+
+func (w *Worker) on(event string, handler EventHandler, signature reflect.Type, opts []EventOption) {
+	var options = eventOptions{priority: PriorityNormal}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	// options are known before the first request arrives
+	w.impl.setEventOptions(event, options)
+	w.handlers.onDescribed(event, handler, eventDescription{signature: signature, priority: options.priority})
+}
+
+// Off unbinds the handler of the event, e.g. when a plugin is unloaded
+// after Run has started. Requests in progress keep running, new ones
+// are passed to the fallback handler. It reports whether the event
+// has been bound.
+func (w *Worker) Off(event string) bool {
+	if !w.handlers.Off(event) {
+		return false
+	}
+	w.impl.unsetEventOptions(event)
+	return true
+}
+
+// SetMaxConcurrency limits the number of handlers running at once.
+// See WorkerNG.SetMaxConcurrency
+func (w *Worker) SetMaxConcurrency(n int) {
+	w.impl.SetMaxConcurrency(n)
+}
+
+// SetFairnessKey makes queued handlers start round-robin across clients.
+// See WorkerNG.SetFairnessKey
+func (w *Worker) SetFairnessKey(key FairnessKeyFunc) {
+	w.impl.SetFairnessKey(key)
+}
+
+// ConnStats returns stats of the connection to cocaine-runtime.
+// See WorkerNG.ConnStats
+func (w *Worker) ConnStats() ConnStats {
+	return w.impl.ConnStats()
+}
+
+// PublishExpvar publishes statistics of the worker and its handlers.
+// See WorkerNG.PublishExpvar
+func (w *Worker) PublishExpvar() {
+	publishWorkerExpvar(w.impl, w.handlers)
+}
+
+// SetErrorReporter sets the reporter of panics, protocol violations and disowns.
+// See WorkerNG.SetErrorReporter
+func (w *Worker) SetErrorReporter(reporter ErrorReporter) {
+	w.impl.SetErrorReporter(reporter)
+}
+
+// OnTyped registers fn as the handler of the event. See TypedHandler.
+// It panics if fn has an invalid signature.
+func (w *Worker) OnTyped(event string, fn interface{}, opts ...EventOption) {
+	handler, err := TypedHandler(fn)
+	if err != nil {
+		panic(fmt.Sprintf("invalid handler of event '%s': %v", event, err))
+	}
+	w.on(event, handler, reflect.TypeOf(fn), opts)
+}
+
+// Use adds interceptors which wrap handlers of all events.
+// See EventHandlers.Use
+func (w *Worker) Use(interceptors ...Interceptor) {
+	w.handlers.Use(interceptors...)
 }
 
 // SetFallbackHandler sets the handler to be a fallback handler
@@ -61,13 +338,30 @@ func (w *Worker) SetFallbackHandler(handler FallbackEventHandler) {
 	w.handlers.SetFallbackHandler(RequestHandler(handler))
 }
 
+// Events describes the registered events sorted by name
+func (w *Worker) Events() []EventInfo {
+	return w.handlers.events()
+}
+
 func (w *Worker) Run(handlers map[string]EventHandler) error {
 	for event, handler := range handlers {
 		w.On(event, handler)
 	}
+	// the same application runs as created by NewStandaloneWorker
+	if w.impl.connection() == nil {
+		return w.RunStandalone(context.Background())
+	}
+	w.registerInfoEvent()
 	return w.impl.Run(w.handlers.Call, w.terminationHandler)
 }
 
+func (w *Worker) registerInfoEvent() {
+	// the application may serve InfoEvent itself
+	if _, ok := w.handlers.handler(InfoEvent); !ok {
+		w.handlers.On(InfoEvent, infoHandler(w.handlers))
+	}
+}
+
 // Stop makes the Worker stop handling requests
 func (w *Worker) Stop() {
 	w.impl.Stop()
@@ -2,6 +2,10 @@ package cocaine12
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -15,29 +19,123 @@ type RequestHandler func(context.Context, string, Request, Response)
 // TerminationHandler invokes when termination message is received
 type TerminationHandler func(context.Context)
 
+// HeartbeatMissedHandler is called if a heartbeat reply is late
+type HeartbeatMissedHandler func(elapsed time.Duration)
+
 // FallbackEventHandler handles an event if there is no other handler
 // for the given event
 type FallbackEventHandler RequestHandler
 
+// Interceptor wraps the handler of the event, e.g. to limit or log requests
+type Interceptor func(event string, handler EventHandler) EventHandler
+
+// EventHandlers dispatches events to their handlers. Handlers may be
+// registered and unregistered while events are dispatched, e.g. after
+// Worker.Run, requests in progress keep running their handlers.
 type EventHandlers struct {
-	fallback RequestHandler
-	handlers map[string]EventHandler
+	mu           sync.RWMutex
+	fallback     RequestHandler
+	handlers     map[string]EventHandler
+	interceptors []Interceptor
+	// names of handlers which are patterns, see EventWildcard
+	patterns eventPatterns
+	// what Events reports about handlers registered by Worker
+	descriptions map[string]eventDescription
+}
+
+type eventDescription struct {
+	// the type of fn passed to OnTyped
+	signature reflect.Type
+	priority  Priority
 }
 
 func NewEventHandlersFromMap(handlers map[string]EventHandler) *EventHandlers {
-	return &EventHandlers{DefaultFallbackHandler, handlers}
+	var patterns eventPatterns
+	for name := range handlers {
+		if isEventPattern(name) {
+			patterns = patterns.add(name)
+		}
+	}
+
+	return &EventHandlers{
+		fallback:     DefaultFallbackHandler,
+		handlers:     handlers,
+		patterns:     patterns,
+		descriptions: make(map[string]eventDescription),
+	}
 }
 
 func NewEventHandlers() *EventHandlers {
 	return NewEventHandlersFromMap(make(map[string]EventHandler))
 }
 
+// On binds the handler for the event replacing the previous one.
+// The name may be a pattern, see EventWildcard.
 func (e *EventHandlers) On(name string, handler EventHandler) {
+	e.onDescribed(name, handler, eventDescription{})
+}
+
+// onDescribed binds the handler along with its description at once,
+// so Events never reports a handler with a stale description
+func (e *EventHandlers) onDescribed(name string, handler EventHandler, description eventDescription) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.handlers[name] = handler
+	e.descriptions[name] = description
+	if isEventPattern(name) {
+		e.patterns = e.patterns.add(name)
+	}
+}
+
+// Off unbinds the handler of the event, which is dispatched
+// to the fallback handler afterwards. It reports whether the event
+// has been registered.
+func (e *EventHandlers) Off(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.handlers[name]
+	delete(e.handlers, name)
+	delete(e.descriptions, name)
+	e.patterns = e.patterns.remove(name)
+	return ok
+}
+
+// handler returns the handler bound for the event
+func (e *EventHandlers) handler(name string) (EventHandler, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	handler, ok := e.handlers[name]
+	return handler, ok
+}
+
+func (e *EventHandlers) eventNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.sortedNames()
+}
+
+// sortedNames must be called with mu held
+func (e *EventHandlers) sortedNames() []string {
+	var names = make([]string, 0, len(e.handlers))
+	for name := range e.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Use adds interceptors which wrap handlers of all events including
+// the fallback one. The first interceptor is the outermost.
+func (e *EventHandlers) Use(interceptors ...Interceptor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.interceptors = append(e.interceptors, interceptors...)
 }
 
 // SetFallbackHandler sets the handler to be a fallback handler
 func (e *EventHandlers) SetFallbackHandler(handler RequestHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.fallback = handler
 }
 
@@ -49,10 +147,31 @@ func DefaultFallbackHandler(ctx context.Context, event string, request Request,
 }
 
 func (e *EventHandlers) Call(ctx context.Context, event string, request Request, response Response) {
+	// the lock isn't held while the handler runs,
+	// so it may change the handlers itself
+	e.mu.RLock()
 	handler := e.handlers[event]
 	if handler == nil {
-		e.fallback(ctx, event, request, response)
-		return
+		if pattern, ok := e.patterns.match(event); ok {
+			handler = e.handlers[pattern]
+		}
+	}
+	fallback, interceptors := e.fallback, e.interceptors
+	e.mu.RUnlock()
+
+	if handler == nil {
+		if len(interceptors) == 0 {
+			fallback(ctx, event, request, response)
+			return
+		}
+
+		handler = func(ctx context.Context, request Request, response Response) {
+			fallback(ctx, event, request, response)
+		}
+	}
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handler = interceptors[i](event, handler)
 	}
 	handler(ctx, request, response)
 }
@@ -0,0 +1,124 @@
+package cocaine12
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestEventHandlersHotPlug(t *testing.T) {
+	handlers := NewEventHandlers()
+	echo := func(ctx context.Context, req Request, res Response) {
+		chunk, _ := req.Read(ctx)
+		res.Write(chunk)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			event := fmt.Sprintf("plugin%d", i)
+			for j := 0; j < 100; j++ {
+				handlers.On(event, echo)
+				handlers.Call(context.Background(), event, &testRequest{[]byte("ping")}, new(testResponse))
+				handlers.events()
+				handlers.Off(event)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, handlers.eventNames())
+
+	// a handler may unload itself
+	handlers.On("once", func(ctx context.Context, req Request, res Response) {
+		handlers.Off("once")
+		res.Write([]byte("done"))
+	})
+	res := new(testResponse)
+	handlers.Call(context.Background(), "once", &testRequest{}, res)
+	assert.Equal(t, "done", res.String())
+
+	res = new(testResponse)
+	handlers.Call(context.Background(), "once", &testRequest{}, res)
+	assert.Equal(t, ErrorNoEventHandler, res.code)
+	assert.False(t, handlers.Off("once"))
+}
+
+func TestWorkerOff(t *testing.T) {
+	_, out := testConn()
+	sock, _ := newAsyncRW(out)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	w.On("plugin", func(ctx context.Context, req Request, res Response) {}, WithPriority(PriorityHigh))
+	assert.Equal(t, PriorityHigh, w.impl.eventPriority("plugin"))
+	assert.Len(t, w.Events(), 1)
+
+	assert.True(t, w.Off("plugin"))
+	assert.Empty(t, w.Events())
+	assert.Equal(t, Priority(0), w.impl.eventPriority("plugin"))
+	assert.False(t, w.Off("plugin"))
+}
+
+func TestEventHandlersPatterns(t *testing.T) {
+	reply := func(name string) EventHandler {
+		return func(ctx context.Context, req Request, res Response) {
+			res.Write([]byte(name))
+		}
+	}
+
+	handlers := NewEventHandlersFromMap(map[string]EventHandler{
+		"images.*": reply("images.*"),
+	})
+	handlers.On("images.crop.*", reply("images.crop.*"))
+	handlers.On("images.crop.square", reply("images.crop.square"))
+	handlers.On("*", reply("*"))
+
+	var events []string
+	handlers.Use(func(event string, handler EventHandler) EventHandler {
+		events = append(events, event)
+		return handler
+	})
+
+	call := func(event string) string {
+		res := new(testResponse)
+		handlers.Call(context.Background(), event, &testRequest{}, res)
+		return res.String()
+	}
+	assert.Equal(t, "images.crop.square", call("images.crop.square"))
+	assert.Equal(t, "images.crop.*", call("images.crop.circle"))
+	assert.Equal(t, "images.*", call("images.resize"))
+	assert.Equal(t, "images.*", call("images."))
+	assert.Equal(t, "*", call("videos.resize"))
+	// interceptors see the name of the event rather than the pattern
+	assert.Equal(t, "images.resize", events[2])
+
+	handlers.Off("*")
+	handlers.Off("images.crop.*")
+	assert.Equal(t, "images.*", call("images.crop.circle"))
+
+	res := new(testResponse)
+	handlers.Call(context.Background(), "videos.resize", &testRequest{}, res)
+	assert.Equal(t, ErrorNoEventHandler, res.code)
+}
+
+func TestWorkerPatternPriority(t *testing.T) {
+	_, out := testConn()
+	sock, _ := newAsyncRW(out)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	w.On("bulk.*", func(ctx context.Context, req Request, res Response) {}, WithPriority(PriorityLow))
+	w.On("bulk.control.*", func(ctx context.Context, req Request, res Response) {}, WithPriority(PriorityHigh))
+	assert.Equal(t, PriorityLow, w.impl.eventPriority("bulk.import"))
+	assert.Equal(t, PriorityHigh, w.impl.eventPriority("bulk.control.stop"))
+	assert.Equal(t, PriorityNormal, w.impl.eventPriority("other"))
+}
@@ -2,6 +2,7 @@ package cocaine12
 
 import (
 	"fmt"
+	"sync/atomic"
 )
 
 const (
@@ -9,9 +10,62 @@ const (
 	v1 = 1
 )
 
+// dispatchers of protocols supported by the worker
+var workerProtocols = map[int]func() protocolDispather{
+	v0: newV0Protocol,
+	v1: newV1Protocol,
+}
+
+// latestWorkerProtocol is used if the version is not specified
+const latestWorkerProtocol = v1
+
+// selectProtocol chooses the protocol by the version passed with --protocol.
+// The absent flag (v0) means that the runtime has not specified a version:
+// the worker starts with the latest one and detects the framing
+// of the runtime by its frames, see detectProtocol.
+func selectProtocol(requested int) (int, protocolDispather, error) {
+	if requested == v0 {
+		requested = latestWorkerProtocol
+	}
+
+	newDispatcher, ok := workerProtocols[requested]
+	if !ok {
+		return 0, nil, fmt.Errorf("unsupported protocol version %d", requested)
+	}
+
+	return requested, newDispatcher(), nil
+}
+
+// detectProtocol is called for frames of cocaine-runtime until
+// it recognises their framing. A v0 frame is decoded with its type
+// in Session and its session in MsgType, see v0Protocol.
+// The reply to a heartbeat is [1, 0, []] in both versions and
+// it's handled the same way, so the first other frame decides:
+// in v1 it's either a utility message or an invoke of a new session
+// with the only argument, while v0 sessions start from 1.
+func (w *WorkerNG) detectProtocol(msg *Message) {
+	if msg.Session == v1UtilitySession && msg.MsgType == v1Heartbeat && len(msg.Payload) == 0 {
+		return
+	}
+	w.protocolDetection = false
+
+	version := v0
+	if msg.Session == v1UtilitySession || (msg.MsgType == v1Invoke && len(msg.Payload) == 1) {
+		version = v1
+	}
+
+	if version != w.ProtocolVersion() {
+		w.dispatcher = workerProtocols[version]()
+		atomic.StoreInt32(&w.protoVersion, int32(version))
+	}
+}
+
 type ErrRequest struct {
 	Message        string
 	Category, Code int
+	// Details is a msgpack encoded object attached by ErrorWithDetails.
+	// See DecodeDetails
+	Details []byte
 }
 
 func (e *ErrRequest) Error() string {
@@ -34,6 +88,8 @@ type protocolHandler interface {
 type utilityProtocolGenerator interface {
 	newHandshake(id string) *Message
 	newHeartbeat() *Message
+	newUtilization(load WorkerLoad) *Message
+	newTerminate(reason TerminationReason) *Message
 }
 
 type handlerProtocolGenerator interface {
@@ -50,6 +106,10 @@ type protocolDispather interface {
 }
 
 func getEventName(msg *Message) (string, bool) {
+	if len(msg.Payload) == 0 {
+		return "", false
+	}
+
 	switch event := msg.Payload[0].(type) {
 	case string:
 		return event, true
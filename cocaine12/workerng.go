@@ -8,6 +8,9 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,11 +22,31 @@ const (
 	disownTimeout         = time.Second * 5
 	coreConnectionTimeout = time.Second * 5
 	terminationTimeout    = time.Second * 5
+	// how often the worker checks that requests in flight are done
+	// before it exits
+	drainInterval = time.Millisecond * 100
 
 	// ErrorNoEventHandler returns when there is no handler for a given event
 	ErrorNoEventHandler = 200
 	// ErrorPanicInHandler returns when a handler is recovered from panic
 	ErrorPanicInHandler = 100
+	// ErrorOverloaded returns when the worker sheds load
+	ErrorOverloaded = 300
+	// ErrorBadRequest returns when a request is malformed
+	ErrorBadRequest = 400
+	// ErrorNotReady returns when health checks have failed
+	ErrorNotReady = 500
+	// ErrorCancelled is sent by a client which isn't interested
+	// in a response anymore
+	ErrorCancelled = 600
+	// ErrorInternal returns when a typed handler fails. See TypedHandler
+	ErrorInternal = 700
+	// ErrorDeadlineExceeded returns when a typed handler fails
+	// with context.DeadlineExceeded
+	ErrorDeadlineExceeded = 800
+	// ErrorPayloadTooLarge returns when a payload of an event exceeds
+	// its limit. See WithMaxRequestSize
+	ErrorPayloadTooLarge = 900
 )
 
 var (
@@ -39,8 +62,15 @@ var (
 )
 
 type requestStream interface {
-	push(*Message)
+	// push returns a non-nil channel if the worker should stop
+	// reading cocaine-runtime until it's closed
+	push(*Message) <-chan struct{}
+	// release tells that the handler is not going to read anymore,
+	// so the request must not hold the worker
+	release()
 	Close()
+	// abort cancels the context of the handler
+	abort()
 }
 
 // Request provides an interface for a handler to get data
@@ -60,10 +90,15 @@ type ResponseStream interface {
 // Response provides an interface for a handler to reply
 type Response ResponseStream
 
-func trapRecoverAndClose(ctx context.Context, event string, response Response, printStack bool) {
+func trapRecoverAndClose(ctx context.Context, event string, response Response, printStack bool,
+	onPanic func(recoverInfo interface{}, stack []byte)) {
 	if recoverInfo := recover(); recoverInfo != nil {
 		var stack []byte
 
+		if onPanic != nil {
+			onPanic(recoverInfo, debug.Stack())
+		}
+
 		if printStack {
 			stack = make([]byte, 4096)
 			stackSize := runtime.Stack(stack, false)
@@ -83,18 +118,51 @@ func trapRecoverAndClose(ctx context.Context, event string, response Response, p
 // WorkerNG performs IO operations between an application
 // and cocaine-runtime, dispatches incoming messages
 type WorkerNG struct {
-	// Connection to cocaine-runtime
-	conn socketIO
+	// Connection to cocaine-runtime,
+	// it's replaced by the loop on failover
+	conn   socketIO
+	connMu sync.RWMutex
+	// the endpoint of the connection
+	endpoint string
+	// endpoints to fail over to after a disown
+	fallbacks []string
+	dial      dialFunc
+	// the connection is reestablished within it if it drops
+	reconnectWindow time.Duration
 	// Id to introduce myself to cocaine-runtime
 	id string
 	// Each tick we shoud send a heartbeat as keep-alive
-	heartbeatTimer *time.Timer
+	heartbeatTimer Timer
 	// Timeout to receive a heartbeat reply
-	disownTimer *time.Timer
+	disownTimer Timer
+	// Fires if a heartbeat reply is late
+	missTimer Timer
+	// how often heartbeats are sent
+	heartbeatInterval time.Duration
+	// how long a heartbeat reply is waited for
+	disownInterval time.Duration
+	// notified about a late heartbeat reply
+	heartbeatMissedHandler HeartbeatMissedHandler
+	// when the last heartbeat was sent
+	lastHeartbeat time.Time
+	// how long the reply to the last heartbeat took, accessed atomically
+	heartbeatLatency int64
 	// Token manager
 	tokenManager TokenManager
 	// Map handlers to sessions
 	sessions map[uint64]requestStream
+	// used to find leaked sessions
+	sessionStates map[uint64]*sessionState
+	// a request queue is bounded by the watermarks, see SetRequestWatermarks
+	requestHighWatermark int
+	requestLowWatermark  int
+	// closed once the request that has stopped reading is drained
+	readPaused <-chan struct{}
+	// sessions are closed if they are idle for so long
+	// after the handler has returned
+	leakTimeout time.Duration
+	// number of sessions closed as leaked
+	leaked int64
 	// handler
 	handler RequestHandler
 	// Notify Run about stop
@@ -103,86 +171,250 @@ type WorkerNG struct {
 	debug bool
 	// allow the worker to handle SIGUSR1 to print all goroutines stacks
 	stackSignalEnabled bool
-	// protocol version id
-	protoVersion int
+	// protocol version id, accessed atomically
+	protoVersion int32
+	// the version is not specified by cocaine-runtime
+	// and its framing is not recognised yet
+	protocolDetection bool
 	// protocol dispatcher
 	dispatcher protocolDispather
 	// temination handler
 	terminationHandler TerminationHandler
+	// termination handler which receives the reason
+	shutdownHandler ShutdownHandler
+	// optional concurrency limiter to shed load
+	limiter ConcurrencyLimiter
+	// load counters for utilization reports
+	load loadCounters
+	// send utilization reports along with heartbeats
+	loadReportEnabled bool
+	// features are gated by extensions advertised by the runtime
+	runtimeExtensionsEnabled bool
+	// decides which requests are traced
+	sampler Sampler
+	// per-event statistics
+	stats *eventsStats
+	// source of time for timers
+	clock Clock
+	// state for liveness and readiness probes
+	probes probeState
+	// connections to successors passed to the loop by Handoff
+	handoffs chan handoffRequest
+	// set once the connection is handed off
+	handedOff bool
+	// link to the worker the connection is taken over from
+	predecessor socketIO
+	// sessions up to this one belong to the predecessor
+	predecessorSession uint64
+	// the worker recycles itself after so many requests
+	maxRequests int
+	// or after it has run for so long
+	maxUptime time.Duration
+	// number of requests received
+	requests int
+	// set once the worker has started recycling
+	recycling bool
+	// the worker stops after it even if requests are in flight
+	drainDeadline time.Time
+	// limits checked by the memory watchdog
+	memoryLimits MemoryLimits
+	// notified when the soft memory limit is exceeded
+	memoryWarningHandler MemoryWarningHandler
+	// reports memory usage to the watchdog
+	memoryUsage func() (MemoryUsage, error)
+	// no more handlers run at once, zero means no limit
+	maxConcurrency int
+	// number of running handlers if maxConcurrency is set
+	running int
+	// handlers waiting for a free slot
+	queue dispatchQueue
+	// priorities and limits of events,
+	// they may be changed while the worker runs
+	options   map[string]eventOptions
+	optionsMu sync.RWMutex
+	// identifies clients to schedule their handlers fairly
+	fairnessKey FairnessKeyFunc
+	// handlers notify the loop when they return
+	handlerDone chan struct{}
+	// sessions whose handlers have returned
+	sessionFinished chan uint64
+	// notified about panics, protocol violations and disowns
+	errorReporter ErrorReporter
+	// messages of fatal log entries
+	fatals chan string
+	// compression of response chunks
+	compression Compression
+	// attach checksums to response chunks
+	checksums bool
+	// see SetHuffmanThreshold
+	huffmanThreshold int
+	// RuntimeInfo advertised by the runtime
+	runtimeInfo atomic.Value
 }
 
-// NewWorkerNG connects to the cocaine-runtime and create WorkerNG on top of this connection
+// NewWorkerNG connects to the cocaine-runtime and create WorkerNG on top of this connection.
+// Unless SetDefaults has been called, the flags passed by cocaine-runtime
+// are parsed from os.Args. It returns ErrShowVersion for --showcocaineversion.
 func NewWorkerNG() (*WorkerNG, error) {
-	workerID := GetDefaults().UUID()
+	if path := os.Getenv(HandoffEnv); path != "" {
+		return NewWorkerNGFromHandoff(path)
+	}
+
+	defaults, err := workerDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	workerID := defaults.UUID()
 
-	unixSocketEndpoint := GetDefaults().Endpoint()
-	if unixSocketEndpoint == "" {
+	endpoints := defaults.Endpoints()
+	if len(endpoints) == 0 {
 		return nil, ErrNoCocaineEndpoint
 	}
 
-	tokenManager, err := NewTokenManager(GetDefaults().ApplicationName(), GetDefaults().Token())
+	tokenManager, err := NewTokenManager(defaults.ApplicationName(), defaults.Token())
 	if err != nil {
 		return nil, fmt.Errorf("unable to create token manager: %v", err)
 	}
 
-	// Connect to cocaine-runtime over a unix socket
-	sock, err := newUnixConnection(unixSocketEndpoint, coreConnectionTimeout)
+	// Connect to cocaine-runtime usually over a unix socket
+	sock, endpoint, fallbacks, err := dialEndpoints(endpoints, coreConnectionTimeout, newAsyncConnection)
 	if err != nil {
-		return nil, fmt.Errorf("unable to connect to Cocaine via %s: %v",
-			unixSocketEndpoint, err)
+		return nil, err
 	}
 
-	return newWorkerNG(sock, workerID,
-		GetDefaults().Protocol(),
-		GetDefaults().Debug(),
+	w, err := newWorkerNG(sock, workerID,
+		defaults.Protocol(),
+		defaults.Debug(),
 		tokenManager)
+	if err != nil {
+		return nil, err
+	}
+
+	w.endpoint, w.fallbacks = endpoint, fallbacks
+	return w, nil
 }
 
 func newWorkerNG(conn socketIO, id string, protoVersion int, debug bool, tokenManager TokenManager) (*WorkerNG, error) {
+	w, err := makeWorkerNG(conn, id, protoVersion, debug, tokenManager)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send handshake to notify cocaine-runtime
+	// that we have started
+	if err := w.sendHandshake(); err != nil {
+		return nil, err
+	}
+	w.probes.handshakeSent()
+
+	return w, nil
+}
+
+// makeWorkerNG creates the worker without introducing it to cocaine-runtime
+func makeWorkerNG(conn socketIO, id string, protoVersion int, debug bool, tokenManager TokenManager) (*WorkerNG, error) {
 	w := &WorkerNG{
 		conn: conn,
 		id:   id,
 
-		heartbeatTimer: time.NewTimer(heartbeatTimeout),
-		disownTimer:    time.NewTimer(disownTimeout),
+		clock:          realClock{},
+		heartbeatTimer: realClock{}.NewTimer(heartbeatTimeout),
+		disownTimer:    realClock{}.NewTimer(disownTimeout),
+		missTimer:      realClock{}.NewTimer(disownTimeout),
 		tokenManager:   tokenManager,
 
-		sessions: make(map[uint64]requestStream),
+		heartbeatInterval: heartbeatTimeout,
+		disownInterval:    disownTimeout,
 
-		stopped: make(chan struct{}),
+		sessions:      make(map[uint64]requestStream),
+		sessionStates: make(map[uint64]*sessionState),
+		leakTimeout:   defaultLeakTimeout,
+
+		stopped:  make(chan struct{}),
+		handoffs: make(chan handoffRequest),
 
 		debug:              debug,
 		stackSignalEnabled: true,
 
-		protoVersion:       protoVersion,
+		protoVersion:       v0,
+		protocolDetection:  protoVersion == v0,
 		dispatcher:         nil,
 		terminationHandler: nil,
+
+		dial:            newAsyncConnection,
+		reconnectWindow: defaultReconnectWindow,
+
+		sampler: defaultSampler,
+		stats:   newEventsStats(),
+
+		memoryUsage: readMemoryUsage,
+		options:     make(map[string]eventOptions),
+		handlerDone: make(chan struct{}),
+		fatals:      make(chan string, 1),
+
+		sessionFinished: make(chan uint64),
 	}
 
-	switch w.protoVersion {
-	case v1:
-		w.dispatcher = newV1Protocol()
-	default:
-		return nil, fmt.Errorf("unsupported protocol version %d", w.protoVersion)
+	version, dispatcher, err := selectProtocol(protoVersion)
+	if err != nil {
+		return nil, err
 	}
+	w.protoVersion = int32(version)
+	w.dispatcher = dispatcher
 
 	// NewTimer launches timer
 	// but it should be started after
 	// we send heartbeat message
 	w.disownTimer.Stop()
+	w.missTimer.Stop()
 	// It will be reset in onHeartbeat()
 	// after worker runs
 	w.heartbeatTimer.Stop()
 
-	// Send handshake to notify cocaine-runtime
-	// that we have started
-	if err := w.sendHandshake(); err != nil {
-		return nil, err
-	}
-
 	return w, nil
 }
 
+// ProtocolVersion returns the version of the protocol the worker
+// speaks: the one passed by cocaine-runtime or detected by its frames.
+// Until the runtime sends anything but heartbeats it's the latest one.
+func (w *WorkerNG) ProtocolVersion() int {
+	return int(atomic.LoadInt32(&w.protoVersion))
+}
+
+// SetClock replaces the source of time of heartbeats and timeouts.
+// It's intended for tests, see ManualClock.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetClock(clock Clock) {
+	w.clock = clock
+	w.heartbeatTimer = clock.NewTimer(heartbeatTimeout)
+	w.heartbeatTimer.Stop()
+	w.disownTimer = clock.NewTimer(disownTimeout)
+	w.disownTimer.Stop()
+	w.missTimer = clock.NewTimer(disownTimeout)
+	w.missTimer.Stop()
+}
+
+// SetHeartbeatInterval sets how often the worker sends heartbeats.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetHeartbeatInterval(interval time.Duration) {
+	w.heartbeatInterval = interval
+}
+
+// SetDisownTimeout sets how long the worker waits for a heartbeat reply
+// before it considers cocaine-runtime dead and exits with ErrDisowned.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetDisownTimeout(timeout time.Duration) {
+	w.disownInterval = timeout
+}
+
+// OnHeartbeatMissed attaches the handler which is called if a heartbeat reply
+// has not arrived within a half of the disown timeout. The handler
+// is called in a separate goroutine with the time since the heartbeat was sent.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) OnHeartbeatMissed(handler HeartbeatMissedHandler) {
+	w.heartbeatMissedHandler = handler
+}
+
 // SetDebug enables debug mode of the Worker.
 // It allows to print Stack of a paniced handler
 func (w *WorkerNG) SetDebug(debug bool) {
@@ -196,6 +428,45 @@ func (w *WorkerNG) EnableStackSignal(enable bool) {
 	w.stackSignalEnabled = enable
 }
 
+// SetConcurrencyLimiter attaches the limiter which is consulted
+// before every handler starts. If the limiter rejects a request, the worker
+// replies with ErrorOverloaded. nil disables the limitation.
+// See LimitToCPUQuota.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetConcurrencyLimiter(limiter ConcurrencyLimiter) {
+	w.limiter = limiter
+}
+
+// SetSampler sets the policy which decides whether a request is traced.
+// By default only requests traced by a caller are traced.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetSampler(sampler Sampler) {
+	if sampler == nil {
+		sampler = defaultSampler
+	}
+	w.sampler = sampler
+}
+
+// EnableLoadReport allows/disallows the worker to report its load
+// to cocaine-runtime along with every heartbeat. It's disabled by default,
+// as the runtime must support utilization messages. Reports are not sent
+// if the runtime advertises that it doesn't support them and
+// EnableRuntimeExtensions is on, or if it speaks v0. See RuntimeInfo.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) EnableLoadReport(enable bool) {
+	w.loadReportEnabled = enable
+}
+
+// Load returns the current load of the worker
+func (w *WorkerNG) Load() WorkerLoad {
+	return w.load.snapshot(w.limiter)
+}
+
+// Stats returns a snapshot of per-event statistics
+func (w *WorkerNG) Stats() map[string]EventStats {
+	return w.stats.snapshot()
+}
+
 // Token returns the most recently viewed version of the authorization token.
 func (w *WorkerNG) Token() Token {
 	return w.tokenManager.Token()
@@ -219,7 +490,10 @@ func (w *WorkerNG) Stop() {
 
 	w.tokenManager.Stop()
 	close(w.stopped)
-	w.conn.Close()
+	// a standalone worker has no connection
+	if conn := w.connection(); conn != nil {
+		conn.Close()
+	}
 }
 
 func (w *WorkerNG) isStopped() bool {
@@ -231,7 +505,25 @@ func (w *WorkerNG) isStopped() bool {
 	return false
 }
 
+// SetRequestWatermarks bounds the number of chunks queued for a handler.
+// Once a request has high unread chunks, the worker stops reading
+// cocaine-runtime until the handler reads them down to low, so the runtime
+// is pushed back instead of the worker buffering without limit.
+// Messages of other sessions and heartbeats wait as well, so a handler
+// must keep reading its request. Zero high disables the limitation,
+// which is the default.
+// This function must be called before Worker.Run to take effect.
+func (w *WorkerNG) SetRequestWatermarks(high, low int) {
+	if low < 0 || low >= high {
+		low = high / 2
+	}
+	w.requestHighWatermark, w.requestLowWatermark = high, low
+}
+
 func (w *WorkerNG) loop() error {
+	w.probes.setRunning(true)
+	defer w.probes.setRunning(false)
+
 	// Send heartbeat to notify cocaine-runtime
 	// we are ready to work
 	w.onHeartbeatTimeout()
@@ -244,9 +536,55 @@ func (w *WorkerNG) loop() error {
 		defer signal.Stop(stackSignal)
 	}
 
+	var (
+		// replies of requests handled by the predecessor
+		fromPredecessor chan *Message
+		// checks whether requests are done after the handoff
+		// or once the worker started recycling
+		drainTimeout <-chan time.Time
+		// fires when the worker should be recycled
+		uptimeTimeout <-chan time.Time
+		// receives the usage once the hard memory limit is exceeded
+		memoryExceeded chan MemoryUsage
+		// fires when leaked sessions should be collected
+		leakCheck <-chan time.Time
+	)
+
+	if w.leakTimeout > 0 {
+		leakCheck = w.clock.After(w.leakTimeout)
+	}
+
+	if w.predecessor != nil {
+		fromPredecessor = w.predecessor.Read()
+	}
+
+	if w.maxUptime > 0 {
+		uptimeTimeout = w.clock.After(w.maxUptime)
+	}
+
+	registerFatalWorker(w)
+	defer unregisterFatalWorker(w)
+
+	if w.memoryLimits.Soft > 0 || w.memoryLimits.Hard > 0 {
+		memoryExceeded = make(chan MemoryUsage)
+		watchdogDone := make(chan struct{})
+		defer close(watchdogDone)
+		go w.watchMemory(watchdogDone, memoryExceeded)
+	}
+
 	for {
+		read := w.conn.Read()
+		if w.readPaused != nil {
+			// a handler lags behind its request,
+			// so cocaine-runtime is not read until it catches up
+			read = nil
+		}
+
 		select {
-		case msg, ok := <-w.conn.Read():
+		case <-w.readPaused:
+			w.readPaused = nil
+
+		case msg, ok := <-read:
 			if !ok {
 				// either the connection is lost
 				// or the worker was stopped
@@ -254,24 +592,109 @@ func (w *WorkerNG) loop() error {
 				case <-w.stopped:
 					return nil
 				default:
-					return ErrConnectionLost
 				}
+
+				if w.reconnect() {
+					continue
+				}
+				return ErrConnectionLost
+			}
+
+			if w.forwardToPredecessor(msg) {
+				continue
+			}
+
+			if w.protocolDetection {
+				w.detectProtocol(msg)
 			}
 
 			// non-blocking
 			if err := w.dispatcher.onMessage(w, msg); err != nil {
 				fmt.Printf("onMessage returns %v\n", err)
+				w.reportProtocolError(msg, err)
+			}
+
+			if w.checkRequestLimit() {
+				drainTimeout = w.clock.After(drainInterval)
+			}
+
+		case <-uptimeTimeout:
+			uptimeTimeout = nil
+			if !w.recycling {
+				w.recycle(fmt.Sprintf("recycled after %v of uptime", w.maxUptime))
+				drainTimeout = w.clock.After(drainInterval)
+			}
+
+		case msg, ok := <-fromPredecessor:
+			if !ok {
+				// the predecessor has finished its requests
+				w.predecessor.Close()
+				w.predecessor = nil
+				fromPredecessor = nil
+				continue
+			}
+			w.conn.Send(msg)
+
+		case req := <-w.handoffs:
+			err := w.handoff(req.link)
+			req.done <- err
+			if err != nil {
+				if w.isStopped() {
+					return err
+				}
+				continue
+			}
+			drainTimeout = w.clock.After(drainInterval)
+
+		case <-w.handlerDone:
+			w.running--
+			w.dispatchQueued()
+
+		case session := <-w.sessionFinished:
+			w.finishSession(session)
+
+		case <-leakCheck:
+			w.collectLeakedSessions()
+			leakCheck = w.clock.After(w.leakTimeout)
+
+		case usage := <-memoryExceeded:
+			if !w.recycling {
+				w.recycle(fmt.Sprintf("RSS %d exceeds the hard limit %d", usage.RSS, w.memoryLimits.Hard))
+				drainTimeout = w.clock.After(drainInterval)
+			}
+
+		case message := <-w.fatals:
+			if !w.recycling {
+				w.recycle(fmt.Sprintf("fatal error: %s", message))
+				drainTimeout = w.clock.After(drainInterval)
 			}
 
-		case <-w.heartbeatTimer.C:
+		case <-drainTimeout:
+			if w.isDrained() {
+				w.Stop()
+				return nil
+			}
+			drainTimeout = w.clock.After(drainInterval)
+
+		case <-w.heartbeatTimer.C():
 			// Reset (start) disown & heartbeat timers
 			// Send a heartbeat message to cocaine-runtime
 			w.onHeartbeatTimeout() // non-blocking
 
-		case <-w.disownTimer.C:
+		case <-w.disownTimer.C():
+			if w.handedOff {
+				continue
+			}
+			w.reportError(context.Background(), &ErrorReport{Kind: ErrorKindDisown, Err: ErrDisowned})
+			if w.failover() {
+				continue
+			}
 			w.onDisownTimeout() // non-blocking
 			return ErrDisowned
 
+		case <-w.missTimer.C():
+			w.onHeartbeatMissed() // non-blocking
+
 		case <-w.stopped:
 			return nil
 
@@ -300,15 +723,28 @@ func (w *WorkerNG) onDisownTimeout() {
 }
 
 func (w *WorkerNG) onHeartbeatTimeout() {
+	if w.handedOff {
+		// the successor sends heartbeats
+		return
+	}
+
 	// Wait for the reply until disown timeout comes
-	w.disownTimer.Reset(disownTimeout)
+	w.disownTimer.Reset(w.disownInterval)
+	w.missTimer.Reset(w.disownInterval / 2)
 	// Send next heartbeat over heartbeatTimeout
-	w.heartbeatTimer.Reset(heartbeatTimeout)
+	w.heartbeatTimer.Reset(w.heartbeatInterval)
+	w.lastHeartbeat = w.clock.Now()
 
 	select {
 	case w.conn.Write() <- w.dispatcher.newHeartbeat():
 	case <-w.conn.IsClosed():
-	case <-time.After(disownTimeout):
+	case <-w.clock.After(disownTimeout):
+	}
+
+	if w.loadReportEnabled && w.runtimeAllows(ExtensionUtilization) {
+		if msg := w.dispatcher.newUtilization(w.Load()); msg != nil {
+			w.conn.Send(msg)
+		}
 	}
 }
 
@@ -319,7 +755,7 @@ func (w *WorkerNG) sendHandshake() error {
 	select {
 	case w.conn.Write() <- w.dispatcher.newHandshake(w.id):
 	case <-w.conn.IsClosed():
-	case <-time.After(disownTimeout):
+	case <-w.clock.After(disownTimeout):
 		return fmt.Errorf("unable to send a handshake for a long time")
 	}
 	return nil
@@ -330,19 +766,33 @@ func (w *WorkerNG) sendHandshake() error {
 func (w *WorkerNG) onChoke(msg *Message) {
 	if reqStream, ok := w.sessions[msg.Session]; ok {
 		reqStream.Close()
-		delete(w.sessions, msg.Session)
+		w.removeSession(msg.Session)
 	}
 }
 
 func (w *WorkerNG) onChunk(msg *Message) {
 	if reqStream, ok := w.sessions[msg.Session]; ok {
-		reqStream.push(msg)
+		if !w.receiveChunk(msg) {
+			// the request is too large, it has been replied already
+			reqStream.Close()
+			reqStream.abort()
+			w.removeSession(msg.Session)
+			return
+		}
+		if resume := reqStream.push(msg); resume != nil {
+			w.readPaused = resume
+		}
+		w.touchSession(msg.Session)
 	}
 }
 
 func (w *WorkerNG) onError(msg *Message) {
 	if reqStream, ok := w.sessions[msg.Session]; ok {
 		reqStream.push(msg)
+		w.touchSession(msg.Session)
+		// the client has aborted the request,
+		// so the handler should stop as soon as possible
+		reqStream.abort()
 	}
 }
 
@@ -358,26 +808,93 @@ func (w *WorkerNG) onInvoke(msg *Message) error {
 		ctx            context.Context
 	)
 
-	ctx = context.Background()
+	ctx = withEventName(context.Background(), event)
 
+	var parent *TraceInfo
 	if traceInfo, err := msg.Headers.getTraceData(); err == nil {
-		ctx = AttachTraceInfo(ctx, traceInfo)
+		parent = &traceInfo
+	}
+
+	if w.sampler.ShouldSample(SamplingParams{Event: event, Parent: parent}) {
+		if parent != nil {
+			ctx = AttachTraceInfo(ctx, *parent)
+		} else {
+			ctx = BeginNewTraceContext(ctx)
+		}
+	}
+
+	md := metadataFromHeaders(msg.Headers)
+	if md != nil {
+		ctx = NewIncomingContext(ctx, md)
 	}
 
 	responseStream := newResponse(w.dispatcher, currentSession, w.conn)
-	requestStream := newRequest(w.dispatcher)
+	if w.runtimeAllows(ExtensionHeaders) {
+		responseStream.checksums = w.checksums
+		if encoding := w.compression.negotiateEncoding(md); encoding != "" {
+			responseStream.encoding = encoding
+			responseStream.compressionThreshold = w.compression.Threshold
+		}
+	}
+	if w.huffmanThreshold > 0 && w.RuntimeInfo().Supports(ExtensionHuffman) {
+		responseStream.huffmanThreshold = w.huffmanThreshold
+	}
+
+	limiter := w.limiter
+	if limiter != nil && !limiter.Acquire() {
+		w.stats.recordRejected(event)
+		responseStream.errorMsgWithHeaders(cworkererrorcategory, ErrorOverloaded, "worker is overloaded", []Header{
+			{Name: RetryAfterHeader, Value: []byte(overloadRetryAfter)},
+		})
+		return nil
+	}
+
+	w.requests++
+
+	var cancel context.CancelFunc
+	if timeout, ok := requestTimeout(md); ok {
+		// the caller doesn't wait longer
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	requestStream := newBoundedRequest(w.dispatcher, w.requestHighWatermark, w.requestLowWatermark)
+	requestStream.cancel = cancel
+	request, response := newSizedStreams(event, w.eventOptions(event).limits,
+		w.stats.get(event), requestStream, responseStream)
 	w.sessions[currentSession] = requestStream
+	w.sessionStates[currentSession] = &sessionState{event: event, lastActivity: w.clock.Now(), request: request}
+	w.load.setQueueDepth(len(w.sessions))
+
+	w.schedule(ctx, event, func() {
+		w.load.handlerStarted()
+		go func() {
+			if w.maxConcurrency > 0 {
+				defer w.notifyHandlerDone()
+			}
+			defer w.load.handlerFinished()
+			defer w.notifySessionFinished(currentSession)
+			defer cancel()
+
+			startTime := w.clock.Now()
+			defer func() {
+				latency := w.clock.Now().Sub(startTime)
+				w.stats.record(event, latency, responseStream.isFailed())
+				if limiter != nil {
+					limiter.Release(latency)
+				}
+			}()
 
-	go func() {
-		// this trap catches a panic from a handler
-		// and checks if the response is closed.
-		defer trapRecoverAndClose(ctx, event, responseStream, w.debug)
+			// this trap catches a panic from a handler
+			// and checks if the response is closed.
+			defer trapRecoverAndClose(ctx, event, responseStream, w.debug, w.panicReporter(ctx, event, currentSession))
 
-		ctx, closeHandlerSpan := NewSpan(ctx, event)
-		defer closeHandlerSpan()
+			ctx, closeHandlerSpan := NewSpan(ctx, event)
+			defer closeHandlerSpan()
 
-		w.handler(ctx, event, requestStream, responseStream)
-	}()
+			w.handler(ctx, event, request, response)
+		}()
+	})
 	return nil
 }
 
@@ -386,14 +903,31 @@ func (w *WorkerNG) onHeartbeat(msg *Message) {
 	// so we are not disowned & disownTimer must be stopped
 	// It will be launched when the next heartbeat is sent
 	w.disownTimer.Stop()
+	w.missTimer.Stop()
+	now := w.clock.Now()
+	atomic.StoreInt64(&w.heartbeatLatency, int64(now.Sub(w.lastHeartbeat)))
+	w.probes.heartbeatReceived(now)
+	w.learnRuntimeInfo(msg)
+}
+
+func (w *WorkerNG) onHeartbeatMissed() {
+	if handler := w.heartbeatMissedHandler; handler != nil {
+		go handler(w.clock.Now().Sub(w.lastHeartbeat))
+	}
 }
 
 func (w *WorkerNG) onTerminate(msg *Message) {
-	if w.terminationHandler != nil {
-		ctx, cancelTimeout := context.WithTimeout(context.Background(), terminationTimeout)
+	if w.terminationHandler != nil || w.shutdownHandler != nil {
+		reason := parseTerminationReason(msg)
+		ctx, cancelTimeout := withClockTimeout(context.Background(), w.clock, terminationTimeout)
 		onDone := make(chan struct{})
 		go func() {
-			w.terminationHandler(ctx)
+			if w.shutdownHandler != nil {
+				w.shutdownHandler(ctx, reason)
+			}
+			if w.terminationHandler != nil {
+				w.terminationHandler(ctx)
+			}
 			close(onDone)
 		}()
 
@@ -411,7 +945,7 @@ func (w *WorkerNG) onTerminate(msg *Message) {
 	case w.conn.Write() <- msg:
 		// reply with the same termination message
 	case <-w.conn.IsClosed():
-	case <-time.After(disownTimeout):
+	case <-w.clock.After(disownTimeout):
 	}
 	w.Stop()
 }
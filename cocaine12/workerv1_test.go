@@ -333,3 +333,251 @@ func TestWorkerLoad(t *testing.T) {
 		t.Fatalf("unexpected exit")
 	}
 }
+
+func TestWorkerV1LoadReport(t *testing.T) {
+	const (
+		testID = "uuid"
+	)
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, testID, 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	w.SetConcurrencyLimiter(NewAIMDLimiter(10, 1, 10, time.Second))
+	w.EnableLoadReport(true)
+
+	go w.Run(map[string]EventHandler{})
+
+	eHandshake := <-sock2.Read()
+	checkTypeAndSession(t, eHandshake, v1UtilitySession, v1Handshake)
+	eHeartbeat := <-sock2.Read()
+	checkTypeAndSession(t, eHeartbeat, v1UtilitySession, v1Heartbeat)
+	eLoad := <-sock2.Read()
+	checkTypeAndSession(t, eLoad, v1UtilitySession, v1Utilization)
+
+	var load struct {
+		InFlight, QueueDepth, Limit int
+	}
+	assert.NoError(t, convertPayload(eLoad.Payload, &load))
+	assert.Equal(t, 10, load.Limit)
+	assert.Equal(t, WorkerLoad{Limit: 10}, w.Load())
+}
+
+func TestWorkerProtocolSelection(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	defer sock2.Close()
+
+	w, err := newWorker(sock, "uuid", v0, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	assert.Equal(t, v1, w.ProtocolVersion(), "the latest version must be chosen")
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+
+	_, err = newWorker(sock, "uuid", 42, true)
+	assert.Error(t, err)
+}
+
+func TestWorkerProtocolDetection(t *testing.T) {
+	for _, version := range []int{v0, v1} {
+		in, out := testConn()
+		sock, _ := newAsyncRW(out)
+		sock2, _ := newAsyncRW(in)
+
+		w, err := newWorker(sock, "uuid", v0, true)
+		if err != nil {
+			t.Fatal("unable to create worker", err)
+		}
+		w.SetHeartbeatInterval(time.Hour)
+		w.SetDisownTimeout(time.Hour)
+
+		go w.Run(map[string]EventHandler{
+			"echo": func(ctx context.Context, req Request, res Response) {
+				data, err := req.Read(ctx)
+				if err != nil {
+					res.ErrorMsg(1, err.Error())
+					return
+				}
+				res.Write(data)
+				res.Close()
+			},
+		})
+
+		checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+		checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+		var protocol protocolDispather
+		if version == v0 {
+			// old runtimes frame messages as [type, session, payload]
+			protocol = newV0Protocol()
+			sock2.Write() <- newMessageV0(v0UtilitySession, v0Heartbeat)
+			sock2.Write() <- newMessageV0(1, v0Invoke, "echo")
+		} else {
+			protocol = newV1Protocol()
+			sock2.Write() <- newHeartbeatV1()
+			sock2.Write() <- newInvokeV1(2, "echo")
+		}
+		session := uint64(version) + 1
+		sock2.Write() <- protocol.newChunk(session, []byte("ping"))
+		sock2.Write() <- protocol.newChoke(session)
+
+		chunk, choke := protocol.newChunk(session, nil), protocol.newChoke(session)
+		reply := <-sock2.Read()
+		checkTypeAndSession(t, reply, chunk.Session, chunk.MsgType)
+		assert.Equal(t, []byte("ping"), reply.Payload[0])
+		checkTypeAndSession(t, <-sock2.Read(), choke.Session, choke.MsgType)
+		assert.Equal(t, version, w.ProtocolVersion())
+
+		w.Stop()
+		sock2.Close()
+	}
+}
+
+func TestWorkerV1HalfClosed(t *testing.T) {
+	const (
+		testID      = "uuid"
+		testSession = 2
+	)
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, testID, 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	received := make(chan []byte, 1)
+	go w.Run(map[string]EventHandler{
+		"sealed": func(ctx context.Context, req Request, res Response) {
+			// seal the response and keep on reading the request
+			res.Close()
+			data, _ := req.Read(ctx)
+			received <- data
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(testSession, "sealed")
+	checkTypeAndSession(t, <-sock2.Read(), testSession, v1Close)
+
+	sock2.Write() <- newChunkV1(testSession, []byte("after seal"))
+	sock2.Write() <- newChokeV1(testSession)
+
+	select {
+	case data := <-received:
+		assert.Equal(t, []byte("after seal"), data)
+	case <-time.After(time.Second):
+		t.Fatal("request is not readable after the response is sealed")
+	}
+}
+
+func TestWorkerV1Cancellation(t *testing.T) {
+	const (
+		testID      = "uuid"
+		testSession = 2
+	)
+
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, testID, 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+	defer w.Stop()
+
+	cancelled := make(chan error, 1)
+	go w.Run(map[string]EventHandler{
+		"long": func(ctx context.Context, req Request, res Response) {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+		},
+	})
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(testSession, "long")
+	sock2.Write() <- newErrorV1(testSession, cworkererrorcategory, ErrorCancelled, "context canceled")
+
+	select {
+	case err := <-cancelled:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("the handler context has not been cancelled")
+	}
+}
+
+func TestWorkerV1RequestWatermarks(t *testing.T) {
+	in, out := testConn()
+	sock, _ := newAsyncRW(out)
+	sock2, _ := newAsyncRW(in)
+	w, err := newWorker(sock, "uuid", 1, true)
+	if err != nil {
+		t.Fatal("unable to create worker", err)
+	}
+
+	w.SetHeartbeatInterval(time.Hour)
+	w.SetDisownTimeout(time.Hour)
+	w.SetRequestWatermarks(2, 1)
+
+	start := make(chan struct{})
+	go w.Run(map[string]EventHandler{
+		"slow": func(ctx context.Context, req Request, res Response) {
+			<-start
+			for i := 0; i < 3; i++ {
+				if _, err := req.Read(ctx); err != nil {
+					res.ErrorMsg(1, err.Error())
+					return
+				}
+			}
+			res.Write([]byte("done"))
+			res.Close()
+		},
+		"fast": func(ctx context.Context, req Request, res Response) {
+			res.Write([]byte("OK"))
+			res.Close()
+		},
+	})
+	defer w.Stop()
+
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Handshake)
+	checkTypeAndSession(t, <-sock2.Read(), v1UtilitySession, v1Heartbeat)
+
+	sock2.Write() <- newInvokeV1(2, "slow")
+	for i := 0; i < 3; i++ {
+		sock2.Write() <- newChunkV1(2, []byte("chunk"))
+	}
+	// the slow request has reached the high watermark,
+	// so the worker doesn't read the invocation of fast
+	sock2.Write() <- newInvokeV1(3, "fast")
+	select {
+	case msg := <-sock2.Read():
+		t.Fatalf("the worker must not read while the request is full: %v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(start)
+	replies := make(map[uint64][]uint64)
+	for len(replies[2]) < 2 || len(replies[3]) < 2 {
+		select {
+		case msg := <-sock2.Read():
+			replies[msg.Session] = append(replies[msg.Session], msg.MsgType)
+		case <-time.After(time.Second):
+			t.Fatalf("the worker hasn't resumed reading: %v", replies)
+		}
+	}
+	assert.Equal(t, []uint64{v1Write, v1Close}, replies[2])
+	assert.Equal(t, []uint64{v1Write, v1Close}, replies[3])
+}
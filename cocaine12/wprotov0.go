@@ -0,0 +1,119 @@
+package cocaine12
+
+import (
+	"fmt"
+)
+
+// v0 frames are [type, session, payload] unlike v1 [session, type, payload]
+const (
+	v0Handshake = iota
+	v0Heartbeat
+	v0Terminate
+	v0Invoke
+	v0Chunk
+	v0Error
+	v0Choke
+
+	v0UtilitySession = 0
+)
+
+// v0Protocol speaks the framing of old runtimes. Message is laid out
+// as a v1 frame, so a v0 frame is decoded with its type in Session
+// and its session in MsgType. onMessage swaps them back and
+// outgoing messages are built swapped, see newMessageV0.
+type v0Protocol struct{}
+
+func newV0Protocol() protocolDispather {
+	return &v0Protocol{}
+}
+
+func (v *v0Protocol) onMessage(p protocolHandler, msg *Message) error {
+	msg.Session, msg.MsgType = msg.MsgType, msg.Session
+
+	switch msg.MsgType {
+	case v0Heartbeat:
+		p.onHeartbeat(msg)
+	case v0Terminate:
+		// v0 passes the reason as a string, the worker replies
+		// with the message it gets, so it's framed as v0 again
+		var reason struct {
+			Reason  string
+			Message string
+		}
+		if err := convertPayload(msg.Payload, &reason); err != nil {
+			return fmt.Errorf("malformed terminate message %v: %v", msg, err)
+		}
+		p.onTerminate(v.newTerminate(TerminationReason{
+			Code:    TerminationNormal,
+			Message: fmt.Sprintf("%s: %s", reason.Reason, reason.Message),
+		}))
+	case v0Invoke:
+		return p.onInvoke(msg)
+	case v0Chunk:
+		p.onChunk(msg)
+	case v0Choke:
+		p.onChoke(msg)
+	case v0Error:
+		// v0 errors have no category
+		var perr struct {
+			Code    int
+			Message string
+		}
+		if err := convertPayload(msg.Payload, &perr); err != nil {
+			return fmt.Errorf("malformed error message %v: %v", msg, err)
+		}
+		msg.Payload = []interface{}{[2]int{0, perr.Code}, perr.Message}
+		p.onError(msg)
+	default:
+		return fmt.Errorf("an invalid message type: %d, message %v", msg.MsgType, msg)
+	}
+	return nil
+}
+
+func (v *v0Protocol) isChunk(msg *Message) bool {
+	return msg.MsgType == v0Chunk
+}
+
+func (v *v0Protocol) newHandshake(id string) *Message {
+	return newMessageV0(v0UtilitySession, v0Handshake, id)
+}
+
+func (v *v0Protocol) newHeartbeat() *Message {
+	return newMessageV0(v0UtilitySession, v0Heartbeat)
+}
+
+// newUtilization returns nil as v0 has no utilization message
+func (v *v0Protocol) newUtilization(load WorkerLoad) *Message {
+	return nil
+}
+
+func (v *v0Protocol) newTerminate(reason TerminationReason) *Message {
+	return newMessageV0(v0UtilitySession, v0Terminate, reason.Code, reason.Message)
+}
+
+func (v *v0Protocol) newChoke(session uint64) *Message {
+	return newMessageV0(session, v0Choke)
+}
+
+func (v *v0Protocol) newChunk(session uint64, data []byte) *Message {
+	return newMessageV0(session, v0Chunk, data)
+}
+
+func (v *v0Protocol) newError(session uint64, category, code int, message string) *Message {
+	return newMessageV0(session, v0Error, code, message)
+}
+
+func newMessageV0(session, msgType uint64, payload ...interface{}) *Message {
+	if payload == nil {
+		payload = []interface{}{}
+	}
+
+	return &Message{
+		CommonMessageInfo: CommonMessageInfo{
+			// swapped to be encoded as [type, session, payload]
+			Session: msgType,
+			MsgType: session,
+		},
+		Payload: payload,
+	}
+}
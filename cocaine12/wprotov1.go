@@ -12,6 +12,8 @@ const (
 	v1Error     = 1
 	v1Close     = 2
 	v1Terminate = 1
+	// v1Utilization is sent by the worker only
+	v1Utilization = 2
 
 	v1UtilitySession = 1
 )
@@ -80,6 +82,14 @@ func (v *v1Protocol) newHeartbeat() *Message {
 	return newHeartbeatV1()
 }
 
+func (v *v1Protocol) newUtilization(load WorkerLoad) *Message {
+	return newUtilizationV1(load)
+}
+
+func (v *v1Protocol) newTerminate(reason TerminationReason) *Message {
+	return newTerminateV1(reason)
+}
+
 func (v *v1Protocol) newChoke(session uint64) *Message {
 	return newChokeV1(session)
 }
@@ -112,6 +122,26 @@ func newHeartbeatV1() *Message {
 	}
 }
 
+func newUtilizationV1(load WorkerLoad) *Message {
+	return &Message{
+		CommonMessageInfo: CommonMessageInfo{
+			Session: v1UtilitySession,
+			MsgType: v1Utilization,
+		},
+		Payload: []interface{}{load.InFlight, load.QueueDepth, load.Limit},
+	}
+}
+
+func newTerminateV1(reason TerminationReason) *Message {
+	return &Message{
+		CommonMessageInfo: CommonMessageInfo{
+			Session: v1UtilitySession,
+			MsgType: v1Terminate,
+		},
+		Payload: []interface{}{reason.Code, reason.Message},
+	}
+}
+
 func newInvokeV1(session uint64, event string) *Message {
 	return &Message{
 		CommonMessageInfo: CommonMessageInfo{